@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+
+	"rt-manage/internal/config"
+	"rt-manage/internal/database"
+	"rt-manage/internal/repository"
+	"rt-manage/pkg/secret"
+)
+
+// reEncryptBatchSize 重新加密时每批读取的记录数
+const reEncryptBatchSize = 500
+
+// runKeygen 生成新的加密密钥
+func runKeygen() error {
+	key, err := secret.GenerateKey()
+	if err != nil {
+		return err
+	}
+	fmt.Println(key)
+	return nil
+}
+
+// runReEncrypt 使用当前密钥重新加密所有敏感字段
+// 密钥轮换：将新密钥配置为 encryption.key，旧密钥移到 encryption.previous_keys，执行本命令后再删除旧密钥
+func runReEncrypt() error {
+	if !secret.Enabled() {
+		fmt.Println("未配置 encryption.key，将把所有记录解密为明文")
+	}
+
+	cfg := config.Get()
+	if err := database.Init(&cfg.Database); err != nil {
+		return err
+	}
+	defer database.Close()
+
+	db := database.GetDB()
+	rtCount, err := repository.NewRTRepository(db).ReEncrypt(reEncryptBatchSize)
+	if err != nil {
+		return err
+	}
+	rotationCount, err := repository.NewTokenRotationRepository(db).ReEncrypt(reEncryptBatchSize)
+	if err != nil {
+		return err
+	}
+	lineageCount, err := repository.NewTokenLineageRepository(db).ReEncrypt(reEncryptBatchSize)
+	if err != nil {
+		return err
+	}
+	apiKeyCount, err := repository.NewAPIKeyRepository(db).ReEncrypt(reEncryptBatchSize)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("重新加密完成：RT %d 条，轮换日志 %d 条，token 谱系 %d 条，API 密钥 %d 条\n", rtCount, rotationCount, lineageCount, apiKeyCount)
+	return nil
+}
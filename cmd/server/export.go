@@ -0,0 +1,89 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"rt-manage/internal/config"
+	"rt-manage/internal/database"
+	"rt-manage/internal/repository"
+	"rt-manage/internal/service"
+)
+
+// runExport 执行 export 子命令，按筛选条件导出RT到文件或标准输出
+func runExport(args []string) error {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	format := fs.String("format", service.ExportFormatCSV, "导出格式：csv、json、jsonl")
+	columns := fs.String("columns", "", "导出的列，逗号分隔，默认包含导入所需的全部字段；可选："+strings.Join(service.ExportColumnNames(), ","))
+	tokens := fs.String("tokens", service.ExportTokensMask, "token 导出方式：include（明文）、mask（脱敏）、exclude（不导出）")
+	output := fs.String("o", "", "输出文件，默认输出到标准输出")
+	bizId := fs.String("biz-id", "", "按业务ID筛选（模糊匹配）")
+	tag := fs.String("tag", "", "按标签筛选（模糊匹配）")
+	email := fs.String("email", "", "按邮箱筛选（模糊匹配）")
+	typeStr := fs.String("type", "", "按账号类型筛选（模糊匹配）")
+	enabled := fs.String("enabled", "", "按启用状态筛选：true、false")
+	createDate := fs.String("create-date", "", "按创建日期筛选（YYYY-MM-DD）")
+	refreshStatus := fs.String("refresh-status", "", "按刷新状态筛选")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	opts := &service.ExportOptions{
+		Format:        *format,
+		Tokens:        *tokens,
+		BizId:         *bizId,
+		Tag:           *tag,
+		Email:         *email,
+		Type:          *typeStr,
+		CreateDate:    *createDate,
+		RefreshStatus: *refreshStatus,
+	}
+	if *columns != "" {
+		opts.Columns = strings.Split(*columns, ",")
+	}
+	if *enabled != "" {
+		v, err := strconv.ParseBool(*enabled)
+		if err != nil {
+			return fmt.Errorf("enabled 值无效: %s", *enabled)
+		}
+		opts.Enabled = &v
+	}
+	if err := opts.Normalize(); err != nil {
+		return err
+	}
+
+	cfg := config.Get()
+	if err := database.Init(&cfg.Database); err != nil {
+		return err
+	}
+	defer database.Close()
+
+	var w io.Writer = os.Stdout
+	if *output != "" {
+		file, err := os.OpenFile(*output, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+		if err != nil {
+			return fmt.Errorf("创建输出文件失败: %v", err)
+		}
+		defer file.Close()
+		w = file
+	}
+
+	db := database.GetDB()
+	rtService := service.NewRTService(
+		repository.NewRTRepository(db),
+		repository.NewConfigRepository(db),
+		repository.NewRefreshLogRepository(db),
+		repository.NewTokenRotationRepository(db),
+		repository.NewTokenLineageRepository(db),
+	)
+	count, err := rtService.Export(w, opts)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "导出完成：%d 条\n", count)
+	return nil
+}
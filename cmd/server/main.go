@@ -11,9 +11,11 @@ import (
 	"rt-manage/internal/config"
 	"rt-manage/internal/database"
 	"rt-manage/internal/repository"
+	"rt-manage/internal/sandbox"
 	"rt-manage/internal/scheduler"
 	"rt-manage/internal/service"
 	"rt-manage/pkg/logger"
+	"rt-manage/pkg/secret"
 )
 
 func main() {
@@ -28,6 +30,33 @@ func main() {
 	}
 	defer logger.Sync()
 
+	// 初始化敏感字段加密
+	if err := secret.Init(&config.Get().Encryption); err != nil {
+		log.Fatalf("加密密钥初始化失败: %v", err)
+	}
+
+	// 子命令
+	if len(os.Args) > 1 {
+		var err error
+		switch os.Args[1] {
+		case "migrate": // server migrate status|up
+			err = runMigrate(os.Args[2:])
+		case "reencrypt": // server reencrypt
+			err = runReEncrypt()
+		case "keygen": // server keygen
+			err = runKeygen()
+		case "export": // server export [-format csv|json|jsonl] [-tokens include|mask|exclude] [-o file] ...
+			err = runExport(os.Args[2:])
+		default:
+			err = fmt.Errorf("未知的命令: %s", os.Args[1])
+		}
+		if err != nil {
+			logger.Sync()
+			log.Fatalf("执行 %s 失败: %v", os.Args[1], err)
+		}
+		return
+	}
+
 	// 获取配置并打印（用于调试）
 	cfg := config.Get()
 	logger.Info("=== 当前配置信息 ===")
@@ -41,18 +70,45 @@ func main() {
 		"port", cfg.Database.Port, 
 		"database", cfg.Database.Database,
 		"user", cfg.Database.User,
-		"table_prefix", cfg.Database.TablePrefix)
+		"table_prefix", cfg.Database.TablePrefix,
+		"auto_migrate", cfg.Database.AutoMigrate)
 	logger.Info("OpenAI配置", 
 		"client_id", cfg.OpenAI.ClientID, 
 		"refresh_interval", cfg.OpenAI.RefreshInterval,
 		"schedule_enabled", cfg.OpenAI.ScheduleEnabled,
-		"proxy", cfg.OpenAI.Proxy)
+		"refresh_concurrency", cfg.OpenAI.RefreshConcurrency,
+		"refresh_per_proxy_concurrency", cfg.OpenAI.RefreshPerProxyConcurrency,
+		"refresh_proxy_interval_ms", cfg.OpenAI.RefreshProxyIntervalMs,
+		"proxy", cfg.OpenAI.Proxy,
+		"auth_base_url", cfg.OpenAI.AuthBaseURL,
+		"chatgpt_base_url", cfg.OpenAI.ChatGPTBaseURL)
 	logger.Info("认证配置", 
 		"username", cfg.Auth.Username, 
 		"jwt_expire_hours", cfg.Auth.JWTExpireHours,
+		"access_token_minutes", cfg.Auth.AccessTokenMinutes,
 		"api_secret_length", len(cfg.Auth.APISecret))
 	logger.Info("==================")
 
+	if !secret.Enabled() {
+		logger.Warn("未配置 encryption.key，RT/AT 将以明文存储，建议执行 ./server keygen 生成密钥并配置")
+	}
+	if cfg.Auth.APISecret != "" {
+		logger.Warn("已配置 auth.api_secret（旧版对外API密钥，拥有全部对外接口权限），建议在管理后台创建 API 密钥后删除")
+	}
+
+	// 启动沙箱服务（模拟 OpenAI 接口），并将接口地址指向沙箱
+	if cfg.Sandbox.Enabled {
+		sb, err := sandbox.Start(&cfg.Sandbox)
+		if err != nil {
+			logger.Fatal("沙箱服务启动失败", "error", err)
+		}
+		defer sb.Stop()
+
+		cfg.OpenAI.AuthBaseURL = sb.URL()
+		cfg.OpenAI.ChatGPTBaseURL = sb.URL()
+		logger.Warn("已启用沙箱模式，所有 OpenAI 请求将发送到沙箱服务", "url", sb.URL())
+	}
+
 	// 初始化数据库
 	if err := database.Init(&cfg.Database); err != nil {
 		logger.Fatal("数据库初始化失败", "error", err)
@@ -65,8 +121,22 @@ func main() {
 	db := database.GetDB()
 	rtRepo := repository.NewRTRepository(db)
 	configRepo := repository.NewConfigRepository(db)
-	rtService := service.NewRTService(rtRepo, configRepo)
+	refreshLogRepo := repository.NewRefreshLogRepository(db)
+	rotationRepo := repository.NewTokenRotationRepository(db)
+	lineageRepo := repository.NewTokenLineageRepository(db)
+	rtService := service.NewRTService(rtRepo, configRepo, refreshLogRepo, rotationRepo, lineageRepo)
 	configService := service.NewConfigService(configRepo, rtRepo)
+
+	// 管理后台账号表为空时，根据配置文件创建初始管理员
+	adminUserService := service.NewAdminUserService(repository.NewAdminUserRepository(db), repository.NewAdminSessionRepository(db))
+	if err := adminUserService.EnsureDefaultAdmin(cfg.Auth.Username, cfg.Auth.Password); err != nil {
+		logger.Fatal("初始化管理后台账号失败", "error", err)
+	}
+
+	// 处理上次运行中未完成的RT轮换（须在调度器启动前完成）
+	if err := rtService.RecoverRotations(); err != nil {
+		logger.Error("处理RT轮换日志失败", "error", err)
+	}
 	
 	// 初始化全局调度器管理器
 	scheduler.InitManager(rtService, cfg.OpenAI.RefreshInterval)
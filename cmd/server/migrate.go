@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"rt-manage/internal/config"
+	"rt-manage/internal/database"
+)
+
+const migrateUsage = `用法: server migrate <command>
+
+命令:
+  status  查看迁移执行状态
+  up      执行所有未执行的迁移`
+
+// runMigrate 执行 migrate 子命令
+func runMigrate(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("%s", migrateUsage)
+	}
+
+	cfg := config.Get()
+	if err := database.Open(&cfg.Database); err != nil {
+		return err
+	}
+	defer database.Close()
+
+	switch args[0] {
+	case "status":
+		return printMigrationStatus()
+	case "up":
+		if err := database.Migrate(); err != nil {
+			return err
+		}
+		return printMigrationStatus()
+	default:
+		return fmt.Errorf("未知的 migrate 命令: %s\n%s", args[0], migrateUsage)
+	}
+}
+
+// printMigrationStatus 输出迁移执行状态
+func printMigrationStatus() error {
+	statuses, err := database.GetMigrationStatus()
+	if err != nil {
+		return err
+	}
+
+	pending := 0
+	fmt.Fprintf(os.Stdout, "%-8s %-32s %-8s %s\n", "VERSION", "NAME", "STATUS", "APPLIED_AT")
+	for _, s := range statuses {
+		state, appliedAt := "pending", "-"
+		if s.Applied {
+			state = "applied"
+			appliedAt = s.AppliedAt.Format("2006-01-02 15:04:05")
+		} else {
+			pending++
+		}
+		fmt.Fprintf(os.Stdout, "%-8d %-32s %-8s %s\n", s.Version, s.Name, state, appliedAt)
+	}
+	fmt.Fprintf(os.Stdout, "\n共 %d 个迁移，%d 个未执行\n", len(statuses), pending)
+	return nil
+}
@@ -0,0 +1,69 @@
+import request from '@/utils/request';
+import { APIResponse } from './rts';
+
+// 对外 API 密钥（明文密钥和签名密钥只在创建时返回一次）
+// allowed_* 为逗号分隔的列表，为空表示不限制
+export interface APIKey {
+  id: number;
+  name: string;
+  key_prefix: string;
+  allowed_endpoints: string;
+  allowed_tags: string;
+  allowed_types: string;
+  enabled: boolean;
+  require_signature: boolean; // 只接受签名请求
+  expire_time?: string;
+  last_used_time?: string;
+  last_used_ip?: string;
+  memo?: string;
+  create_time: string;
+  update_time: string;
+}
+
+// 密钥列表查询参数
+export interface ListAPIKeyParams {
+  page: number;
+  page_size: number;
+  name?: string;
+  enabled?: boolean;
+}
+
+// 创建密钥请求
+export interface CreateAPIKeyRequest {
+  name: string;
+  allowed_endpoints?: string;
+  allowed_tags?: string;
+  allowed_types?: string;
+  enabled?: boolean;
+  require_signature?: boolean;
+  expire_time?: string; // RFC3339，为空永不过期
+  memo?: string;
+}
+
+// 对外 API 密钥管理 API（POST + JSON Body）
+export const apiKeysApi = {
+  // 获取密钥列表，endpoints 为可授权的接口
+  list: (params: ListAPIKeyParams): Promise<APIResponse<{ items: APIKey[]; total: number; page: number; page_size: number; endpoints: string[] }>> => {
+    return request.post('/api-keys/list', params);
+  },
+
+  // 创建密钥，返回的 key 为明文密钥，signing_secret 为签名请求使用的签名密钥
+  create: (data: CreateAPIKeyRequest): Promise<APIResponse<{ api_key: APIKey; key: string; signing_secret: string }>> => {
+    return request.post('/api-keys/create', data);
+  },
+
+  // 重新生成签名密钥，旧签名密钥立即失效
+  resetSigningSecret: (id: number): Promise<APIResponse<{ api_key: APIKey; signing_secret: string }>> => {
+    return request.post('/api-keys/reset-signing-secret', { id });
+  },
+
+  // 更新密钥（expire_time 传空字符串表示永不过期）
+  update: (id: number, updates: Partial<Omit<CreateAPIKeyRequest, 'name'>> & { name?: string }): Promise<APIResponse<APIKey>> => {
+    return request.post('/api-keys/update', { id, updates });
+  },
+
+  // 删除密钥
+  delete: (id: number): Promise<APIResponse<null>> => {
+    return request.post('/api-keys/delete', { id });
+  },
+};
@@ -0,0 +1,41 @@
+import request from '@/utils/request';
+import { APIResponse } from './rts';
+
+// 审计事件
+export interface AuditEvent {
+  id: number;
+  actor_type: 'jwt' | 'api_key' | 'anonymous';
+  actor: string; // 用户名，API 密钥为 api_key:名称
+  api_key_id?: number;
+  client_ip: string;
+  action: string; // 如 rts.delete、public.get-at
+  target_type: string;
+  target_ids: string; // 逗号分隔
+  changes: string; // JSON：{"字段": {"before": 修改前, "after": 修改后}}
+  status_code: number;
+  success: boolean;
+  create_time: string;
+}
+
+// 审计事件查询参数
+export interface ListAuditEventParams {
+  page: number;
+  page_size: number;
+  actor?: string;
+  actor_type?: string;
+  action?: string; // 按前缀匹配
+  target_type?: string;
+  target_id?: string;
+  client_ip?: string;
+  success?: boolean;
+  start_date?: string; // YYYY-MM-DD
+  end_date?: string; // YYYY-MM-DD
+}
+
+// 审计事件 API（POST + JSON Body，需要 admin 角色）
+export const auditApi = {
+  // 获取审计事件列表
+  list: (params: ListAuditEventParams): Promise<APIResponse<{ items: AuditEvent[]; total: number; page: number; page_size: number }>> => {
+    return request.post('/audit-events/list', params);
+  },
+};
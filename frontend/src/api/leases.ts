@@ -0,0 +1,45 @@
+import request from '@/utils/request';
+import { APIResponse } from './rts';
+
+// 租约状态
+export type LeaseStatus = 'active' | 'released' | 'expired';
+
+// AT 租约
+export interface Lease {
+  id: number;
+  lease_id: string;
+  rt_id: number;
+  biz_id: string;
+  consumer: string;
+  api_key_id?: number; // 租用时使用的 API 密钥 ID
+  exclusive: boolean;
+  strategy: 'round_robin' | 'lru';
+  status: LeaseStatus;
+  expire_time: string;
+  release_time?: string;
+  create_time: string;
+  update_time: string;
+}
+
+// 租约列表查询参数
+export interface ListLeaseParams {
+  page: number;
+  page_size: number;
+  rt_id?: number;
+  biz_id?: string;
+  consumer?: string;
+  status?: LeaseStatus;
+}
+
+// 租约管理 API（POST + JSON Body）
+export const leasesApi = {
+  // 获取租约列表（已到期的租约会先被回收）
+  list: (params: ListLeaseParams): Promise<APIResponse<{ items: Lease[]; total: number; page: number; page_size: number }>> => {
+    return request.post('/leases/list', params);
+  },
+
+  // 强制释放租约
+  release: (leaseId: string): Promise<APIResponse<Lease>> => {
+    return request.post('/leases/release', { lease_id: leaseId });
+  },
+};
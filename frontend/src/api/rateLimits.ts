@@ -0,0 +1,45 @@
+import request from '@/utils/request';
+import { APIResponse } from './rts';
+
+// 限流分组和维度
+export type RateLimitGroup = 'refresh' | 'get-at' | 'default';
+export type RateLimitScope = 'key' | 'ip' | 'account';
+
+// 令牌桶规则，per_minute 为 0 表示不限制
+export interface RateLimitRule {
+  per_minute: number;
+  burst: number;
+}
+
+export interface RateLimitGroupConfig {
+  per_key: RateLimitRule;
+  per_ip: RateLimitRule;
+  per_account: RateLimitRule;
+}
+
+export interface RateLimitConfig {
+  enabled: boolean;
+  refresh: RateLimitGroupConfig;
+  get_at: RateLimitGroupConfig;
+  default: RateLimitGroupConfig;
+}
+
+// 令牌桶计数（内存中，重启后清零）
+export interface RateLimitStat {
+  group: RateLimitGroup;
+  scope: RateLimitScope;
+  id: string; // API 密钥ID、客户端IP 或 RT ID
+  key: string;
+  tokens: number; // 最后一次请求时的剩余令牌
+  allowed: number;
+  limited: number;
+  last_seen: string;
+}
+
+// 限流 API（POST + JSON Body）
+export const rateLimitsApi = {
+  // 获取限流规则和计数，按被限流次数降序
+  stats: (params: { group?: RateLimitGroup; scope?: RateLimitScope; limit?: number }): Promise<APIResponse<{ config: RateLimitConfig; items: RateLimitStat[]; total: number }>> => {
+    return request.post('/rate-limits/stats', params);
+  },
+};
@@ -1,5 +1,8 @@
 import request from '@/utils/request';
 
+// 凭证类型：rt 可自动刷新，at 仅有 AT（会话 JSON 导入），过期后显示为 stale
+export type CredentialType = 'rt' | 'at';
+
 // RT 数据类型
 export interface RT {
   id: number;
@@ -7,8 +10,11 @@ export interface RT {
   user_name?: string;
   email?: string;
   type?: string;
+  credential_type: CredentialType;
   rt: string;
   at?: string;
+  id_token?: string;
+  account_id?: string;
   proxy?: string;
   client_id?: string;
   tag?: string;
@@ -18,15 +24,21 @@ export interface RT {
   user_info?: string;
   account_info?: string;
   last_refresh_time?: string;
+  cooldown_until?: string; // 调用方反馈 401/429 后的冷却截止时间，冷却期间不参与租用
+  cooldown_reason?: 'rate_limited' | 'unauthorized' | '';
   memo?: string;
+  version?: number;
   create_time: string;
   update_time: string;
 }
 
-// 创建 RT 请求
+// 创建 RT 请求（仅AT账号传 at 或 session，不传 rt_token）
 export interface CreateRTRequest {
   biz_id: string;
-  rt_token: string;
+  credential_type?: CredentialType;
+  rt_token?: string;
+  at?: string;
+  session?: string; // ChatGPT 会话 JSON
   proxy?: string;
   client_id?: string;
   tag?: string;
@@ -44,7 +56,61 @@ export interface UpdateRTRequest {
     tag?: string;
     enabled?: boolean;
     memo?: string;
+    at?: string; // 仅AT账号可更换 AT
   };
+  version?: number; // 期望的版本号，不一致时返回 409
+}
+
+// 可查看明文的字段
+export type RevealField = 'rt' | 'at' | 'id_token' | 'last_rt' | 'refresh_result';
+
+// 明文 token
+export interface RevealedRT {
+  id: number;
+  biz_id: string;
+  rt?: string;
+  at?: string;
+  id_token?: string;
+  last_rt?: string;
+  refresh_result?: string;
+}
+
+// token 谱系来源
+export type LineageSource = 'create' | 'refresh' | 'recover' | 'promote';
+
+// token 谱系记录（token 已脱敏）
+export interface TokenLineage {
+  id: number;
+  rt_id: number;
+  parent_id: number;
+  rt: string;
+  at: string;
+  at_expire_time?: string;
+  source: LineageSource;
+  refresh_log_id: number;
+  promoted_from: number;
+  operator: string;
+  create_time: string;
+  current: boolean; // 是否为当前使用的 token
+}
+
+// 授权登录参数
+export interface OnboardingOptions {
+  biz_id?: string;
+  client_id?: string;
+  proxy?: string;
+  tag?: string;
+  memo?: string;
+  enabled?: boolean;
+}
+
+// 授权登录会话
+export interface OnboardingStart {
+  state: string;
+  auth_url: string;
+  client_id: string;
+  redirect_uri: string;
+  expires_at: string;
 }
 
 // 列表查询参数
@@ -79,6 +145,49 @@ export interface BatchResult {
   }>;
 }
 
+// 文件导入参数（文件中未填写的字段使用这里的默认值）
+export interface ImportRTOptions {
+  format?: 'csv' | 'json' | 'jsonl' | 'text'; // 默认按文件扩展名判断
+  tag?: string;
+  proxy?: string;
+  client_id?: string;
+  enabled?: boolean;
+}
+
+// 文件导入单行结果
+export interface ImportRowResult {
+  line: number;
+  id?: number;
+  biz_id: string;
+  rt: string; // 已脱敏
+  status: 'created' | 'duplicate' | 'invalid';
+  reason?: string;
+}
+
+// 文件导入结果
+export interface ImportResult {
+  total_count: number;
+  created_count: number;
+  duplicate_count: number;
+  invalid_count: number;
+  results: ImportRowResult[];
+}
+
+// 导出参数，筛选条件同列表
+export interface ExportRTParams {
+  format?: 'csv' | 'json' | 'jsonl';
+  columns?: string[];
+  tokens?: 'include' | 'mask' | 'exclude'; // 默认 mask，include 需要查看明文的权限
+  biz_id?: string;
+  tag?: string;
+  email?: string;
+  type?: string;
+  enabled?: boolean;
+  create_date?: string;
+  refresh_status?: string;
+  reason?: string;
+}
+
 // API 响应格式
 export interface APIResponse<T = any> {
   success: boolean;
@@ -99,8 +208,23 @@ export const rtsApi = {
   },
 
   // 更新 RT
-  update: (id: number, updates: any): Promise<APIResponse<RT>> => {
-    return request.post('/rts/update', { id, updates });
+  update: (id: number, updates: any, version?: number): Promise<APIResponse<RT>> => {
+    return request.post('/rts/update', { id, updates, version });
+  },
+
+  // 查看明文 token（列表和详情中的 token 均已脱敏，每次调用都会记录审计日志）
+  reveal: (ids: number[], fields: RevealField[], reason?: string): Promise<APIResponse<{ items: RevealedRT[] }>> => {
+    return request.post('/rts/reveal', { ids, fields, reason });
+  },
+
+  // 获取 token 谱系
+  listLineage: (id: number): Promise<APIResponse<{ items: TokenLineage[] }>> => {
+    return request.post('/rts/lineage/list', { id });
+  },
+
+  // 将谱系中较早的 token 恢复为当前 token，refresh 为 true 时恢复后立即刷新验证
+  promoteLineage: (id: number, lineageId: number, refresh?: boolean, reason?: string): Promise<APIResponse<RT>> => {
+    return request.post('/rts/lineage/promote', { id, lineage_id: lineageId, refresh, reason });
   },
 
   // 删除 RT
@@ -138,6 +262,33 @@ export const rtsApi = {
     });
   },
 
+  // 从文件导入（csv、jsonl 或每行一个 RT 的文本文件），返回每一行的导入结果
+  importFile: (file: File, options: ImportRTOptions = {}): Promise<APIResponse<ImportResult>> => {
+    const form = new FormData();
+    form.append('file', file);
+    Object.entries(options).forEach(([key, value]) => {
+      if (value !== undefined && value !== '') {
+        form.append(key, String(value));
+      }
+    });
+    return request.post('/rts/import', form, { headers: { 'Content-Type': 'multipart/form-data' } });
+  },
+
+  // 发起授权登录，返回需要在浏览器中打开的 auth_url
+  startOnboarding: (options: OnboardingOptions = {}): Promise<APIResponse<OnboardingStart>> => {
+    return request.post('/rts/onboarding/start', options);
+  },
+
+  // 完成授权登录，callback 为登录后跳转的完整地址或其中的 code
+  completeOnboarding: (state: string, callback: string): Promise<APIResponse<RT>> => {
+    return request.post('/rts/onboarding/complete', { state, callback });
+  },
+
+  // 导出（返回文件内容）
+  export: (params: ExportRTParams): Promise<Blob> => {
+    return request.post('/rts/export', params, { responseType: 'blob', timeout: 0 });
+  },
+
   // 刷新用户信息
   refreshUserInfo: (id: number): Promise<APIResponse<RT>> => {
     return request.post('/rts/refresh-user-info', { id });
@@ -0,0 +1,62 @@
+import request from '@/utils/request';
+import { APIResponse } from './rts';
+
+// 管理后台登录会话（每次登录一个会话，刷新令牌轮换不会产生新会话）
+export interface AdminSession {
+  id: number;
+  session_id: string;
+  user_id: number;
+  username: string;
+  client_ip: string;
+  user_agent: string;
+  expire_time: string;
+  last_refresh_time?: string;
+  revoked_time?: string;
+  revoke_reason?: string;
+  create_time: string;
+  update_time: string;
+}
+
+// 会话列表查询参数（管理员）
+export interface ListSessionParams {
+  page: number;
+  page_size: number;
+  user_id?: number;
+  username?: string;
+  active_only?: boolean; // 默认 true
+}
+
+type SessionList = { items: AdminSession[]; total: number; current_session_id: string };
+
+// 登录会话 API
+export const sessionsApi = {
+  // 退出登录（注销当前会话）
+  logout: (): Promise<APIResponse<null>> => {
+    return request.post('/auth/logout');
+  },
+
+  // 退出所有设备（注销自己的全部会话）
+  logoutAll: (): Promise<APIResponse<{ count: number }>> => {
+    return request.post('/auth/logout-all');
+  },
+
+  // 自己的有效会话
+  listMine: (): Promise<APIResponse<SessionList>> => {
+    return request.post('/user/sessions/list');
+  },
+
+  // 注销自己的某个会话
+  revokeMine: (sessionId: string): Promise<APIResponse<null>> => {
+    return request.post('/user/sessions/revoke', { session_id: sessionId });
+  },
+
+  // 会话列表（需要 admin 角色）
+  list: (params: ListSessionParams): Promise<APIResponse<SessionList & { page: number; page_size: number }>> => {
+    return request.post('/sessions/list', params);
+  },
+
+  // 注销单个会话或某个账号的全部会话（需要 admin 角色）
+  revoke: (target: { session_id: string } | { user_id: number }): Promise<APIResponse<{ count: number }>> => {
+    return request.post('/sessions/revoke', target);
+  },
+};
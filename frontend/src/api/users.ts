@@ -0,0 +1,72 @@
+import request from '@/utils/request';
+import { APIResponse } from './rts';
+
+// 角色：admin（全部权限）、operator（日常运维）、viewer（只读）
+export type Role = 'admin' | 'operator' | 'viewer';
+
+// 管理后台账号
+export interface AdminUser {
+  id: number;
+  username: string;
+  role: Role;
+  enabled: boolean;
+  last_login_time?: string;
+  last_login_ip?: string;
+  memo?: string;
+  create_time: string;
+  update_time: string;
+}
+
+// 账号列表查询参数
+export interface ListUserParams {
+  page: number;
+  page_size: number;
+  username?: string;
+  role?: Role;
+  enabled?: boolean;
+}
+
+// 创建账号请求
+export interface CreateUserRequest {
+  username: string;
+  password: string;
+  role: Role;
+  enabled?: boolean;
+  memo?: string;
+}
+
+// 更新账号（password 不为空时重置密码）
+export interface UpdateUserRequest {
+  role?: Role;
+  enabled?: boolean;
+  memo?: string;
+  password?: string;
+}
+
+// 管理后台账号 API（POST + JSON Body，需要 admin 角色）
+export const usersApi = {
+  // 获取账号列表，roles 为可分配的角色
+  list: (params: ListUserParams): Promise<APIResponse<{ items: AdminUser[]; total: number; page: number; page_size: number; roles: Role[] }>> => {
+    return request.post('/users/list', params);
+  },
+
+  // 创建账号
+  create: (data: CreateUserRequest): Promise<APIResponse<AdminUser>> => {
+    return request.post('/users/create', data);
+  },
+
+  // 更新账号
+  update: (id: number, updates: UpdateUserRequest): Promise<APIResponse<AdminUser>> => {
+    return request.post('/users/update', { id, updates });
+  },
+
+  // 删除账号
+  delete: (id: number): Promise<APIResponse<null>> => {
+    return request.post('/users/delete', { id });
+  },
+
+  // 修改自己的密码（任意角色）
+  changePassword: (oldPassword: string, newPassword: string): Promise<APIResponse<null>> => {
+    return request.post('/user/change-password', { old_password: oldPassword, new_password: newPassword });
+  },
+};
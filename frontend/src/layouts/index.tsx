@@ -11,6 +11,8 @@ import {
   FileTextOutlined
 } from '@ant-design/icons';
 import { Layout, Menu, Button, Avatar, Dropdown, Space, Typography, message, Modal } from 'antd';
+import { sessionsApi } from '@/api/sessions';
+import { clearAuth } from '@/utils/request';
 
 const { Header, Sider, Content } = Layout;
 const { Text } = Typography;
@@ -76,10 +78,14 @@ const AdminLayout: React.FC = () => {
     navigate(key);
   };
 
-  const handleLogout = () => {
-    // 清除本地存储的token和用户信息
-    localStorage.removeItem('token');
-    localStorage.removeItem('username');
+  const handleLogout = async () => {
+    // 注销服务端会话（失败不影响退出），再清除本地存储的token和用户信息
+    try {
+      await sessionsApi.logout();
+    } catch (error) {
+      console.error('Logout error:', error);
+    }
+    clearAuth();
     message.success('已退出登录');
     navigate('/login');
   };
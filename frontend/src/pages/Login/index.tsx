@@ -33,7 +33,9 @@ const Login: React.FC = () => {
       // 保存 token 到 localStorage
       if (data.token) {
         localStorage.setItem('token', data.token);
+        localStorage.setItem('refresh_token', data.refresh_token);
         localStorage.setItem('username', data.username);
+        localStorage.setItem('role', data.role);
       }
       
       message.success('登录成功');
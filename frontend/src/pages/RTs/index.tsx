@@ -25,7 +25,7 @@ import {
 } from '@ant-design/icons';
 import type { ColumnsType } from 'antd/es/table';
 import dayjs from 'dayjs';
-import { rtsApi, type RT, type CreateRTRequest } from '@/api/rts';
+import { rtsApi, type RT, type CreateRTRequest, type RevealField } from '@/api/rts';
 import { configsApi } from '@/api/configs';
 import RTFormModal from './components/RTFormModal';
 import BatchImportModal from './components/BatchImportModal';
@@ -204,7 +204,8 @@ const RTManagement: React.FC = () => {
           memo: values.memo ?? '',
         };
         console.log('更新数据:', updateData); // 调试日志
-        const response = await rtsApi.update(editingRT.id, updateData);
+        // 带上打开编辑框时的版本号，期间记录被刷新或他人修改时后端返回冲突
+        const response = await rtsApi.update(editingRT.id, updateData, editingRT.version);
         if (response.success) {
           message.success('更新成功');
           setIsModalVisible(false);
@@ -241,13 +242,28 @@ const RTManagement: React.FC = () => {
     message.success('已复制到剪贴板');
   };
 
+  // 复制明文 token（列表中的 token 已脱敏，需要通过 reveal 接口获取）
+  const handleCopySecret = async (id: number, field: RevealField) => {
+    try {
+      const response = await rtsApi.reveal([id], [field], '复制');
+      const value = response.data?.items?.[0]?.[field];
+      if (response.success && value) {
+        handleCopyToken(value);
+      }
+    } catch (error) {
+      console.error('获取明文失败:', error);
+    }
+  };
+
   // 渲染截断文本（带Tooltip和可选的点击复制）
   const renderTruncatedText = (text: string | null | undefined, options?: { 
     withCopy?: boolean, 
     codeStyle?: boolean,
-    maxLength?: number 
+    maxLength?: number,
+    onCopy?: () => void
   }) => {
-    const { withCopy = false, codeStyle = false, maxLength = 10 } = options || {};
+    const { withCopy = false, codeStyle = false, maxLength = 10, onCopy } = options || {};
+    const copy = onCopy || (() => handleCopyToken(text || ''));
     
     if (!text) {
       return <span style={{ color: '#999' }}>-</span>;
@@ -269,14 +285,14 @@ const RTManagement: React.FC = () => {
         fontSize: '11px',
         ...baseStyle
       }}
-      onClick={withCopy ? () => handleCopyToken(text) : undefined}
+      onClick={withCopy ? copy : undefined}
       >
         {truncated}
       </code>
     ) : (
       <span 
         style={baseStyle}
-        onClick={withCopy ? () => handleCopyToken(text) : undefined}
+        onClick={withCopy ? copy : undefined}
       >
         {truncated}
       </span>
@@ -422,57 +438,39 @@ const RTManagement: React.FC = () => {
   };
 
   // 批量复制RT
-  const handleBatchCopyRT = () => {
+  const handleBatchCopyRT = () => handleBatchCopySecret('rt', 'RT');
+
+  // 批量复制AT
+  const handleBatchCopyAT = () => handleBatchCopySecret('at', 'AT');
+
+  // 批量复制明文 token（通过 reveal 接口获取）
+  const handleBatchCopySecret = async (field: 'rt' | 'at', label: string) => {
     if (selectedRowKeys.length === 0) {
-      message.warning('请先选择需要复制RT的记录');
+      message.warning(`请先选择需要复制${label}的记录`);
       return;
     }
 
-    // 获取选中的RT
-    const selectedRTs = dataSource.filter(rt => selectedRowKeys.includes(rt.id));
-    
-    // 提取RT，过滤掉空值，每行一个RT
-    const rtList = selectedRTs
-      .filter(rt => rt.rt && rt.rt.trim() !== '')
-      .map(rt => rt.rt);
-    
-    if (rtList.length === 0) {
-      message.warning('选中的记录中没有可用的RT');
-      return;
-    }
+    try {
+      const response = await rtsApi.reveal(selectedRowKeys as number[], [field], '批量复制');
+      if (!response.success || !response.data) {
+        return;
+      }
 
-    // 每行一个RT（用一个换行符分隔）
-    const rtText = rtList.join('\n');
-    
-    navigator.clipboard.writeText(rtText);
-    message.success(`已复制 ${rtList.length} 个RT到剪贴板`);
-  };
+      // 提取token，过滤掉空值，每行一个
+      const tokenList = response.data.items
+        .map(item => item[field])
+        .filter((token): token is string => !!token && token.trim() !== '');
 
-  // 批量复制AT
-  const handleBatchCopyAT = () => {
-    if (selectedRowKeys.length === 0) {
-      message.warning('请先选择需要复制AT的RT');
-      return;
-    }
+      if (tokenList.length === 0) {
+        message.warning(`选中的记录中没有可用的${label}`);
+        return;
+      }
 
-    // 获取选中的RT
-    const selectedRTs = dataSource.filter(rt => selectedRowKeys.includes(rt.id));
-    
-    // 提取AT，过滤掉空值，每行一个AT
-    const atList = selectedRTs
-      .filter(rt => rt.at && rt.at.trim() !== '')
-      .map(rt => rt.at);
-    
-    if (atList.length === 0) {
-      message.warning('选中的RT中没有可用的AT');
-      return;
+      navigator.clipboard.writeText(tokenList.join('\n'));
+      message.success(`已复制 ${tokenList.length} 个${label}到剪贴板`);
+    } catch (error) {
+      console.error('获取明文失败:', error);
     }
-
-    // 每行一个AT（用一个换行符分隔）
-    const atText = atList.join('\n');
-    
-    navigator.clipboard.writeText(atText);
-    message.success(`已复制 ${atList.length} 个AT到剪贴板`);
   };
 
   // 批量删除
@@ -672,14 +670,14 @@ const RTManagement: React.FC = () => {
       dataIndex: 'rt',
       key: 'rt',
       width: 140,
-      render: (token) => renderTruncatedText(token, { withCopy: true, codeStyle: true, maxLength: 10 }),
+      render: (token, record) => renderTruncatedText(token, { withCopy: true, codeStyle: true, maxLength: 10, onCopy: () => handleCopySecret(record.id, 'rt') }),
     },
     {
       title: 'AT',
       dataIndex: 'at',
       key: 'at',
       width: 140,
-      render: (token) => {
+      render: (token, record) => {
         if (!token) {
           return <span style={{ color: '#999' }}>-</span>;
         }
@@ -694,7 +692,7 @@ const RTManagement: React.FC = () => {
               cursor: 'pointer',
               userSelect: 'none',
             }}
-            onClick={() => handleCopyToken(token)}
+            onClick={() => handleCopySecret(record.id, 'at')}
           >
             {truncated}
           </code>
@@ -749,14 +747,14 @@ const RTManagement: React.FC = () => {
       dataIndex: 'last_rt',
       key: 'last_rt',
       width: 140,
-      render: (lastRt) => renderTruncatedText(lastRt, { withCopy: true, codeStyle: true, maxLength: 10 }),
+      render: (lastRt, record) => renderTruncatedText(lastRt, { withCopy: true, codeStyle: true, maxLength: 10, onCopy: () => handleCopySecret(record.id, 'last_rt') }),
     },
     {
       title: '刷新结果',
       dataIndex: 'refresh_result',
       key: 'refresh_result',
       width: 120,
-      render: (result) => {
+      render: (result, record) => {
         if (!result) {
           return <span style={{ color: '#999' }}>-</span>;
         }
@@ -767,7 +765,7 @@ const RTManagement: React.FC = () => {
               cursor: 'pointer',
               userSelect: 'none',
             }}
-            onClick={() => handleCopyToken(result)}
+            onClick={() => handleCopySecret(record.id, 'refresh_result')}
           >
             {truncated}
           </span>
@@ -1,4 +1,4 @@
-import axios, { AxiosError, AxiosRequestConfig } from 'axios';
+import axios, { AxiosError, AxiosRequestConfig, InternalAxiosRequestConfig } from 'axios';
 import { message } from 'antd';
 
 // 创建axios实例，配置基础URL和默认超时时间
@@ -24,16 +24,57 @@ instance.interceptors.request.use(
   }
 );
 
+// 清除本地保存的登录信息
+export function clearAuth() {
+  localStorage.removeItem('token');
+  localStorage.removeItem('refresh_token');
+  localStorage.removeItem('username');
+  localStorage.removeItem('role');
+}
+
+// 正在进行的刷新请求，多个请求同时 401 时共用一次刷新（刷新令牌只能使用一次）
+let refreshing: Promise<string> | null = null;
+
+// 用刷新令牌换取新的访问令牌，刷新令牌随之轮换
+function refreshAccessToken(): Promise<string> {
+  if (!refreshing) {
+    const refreshToken = localStorage.getItem('refresh_token');
+    refreshing = (refreshToken
+      ? axios.post('/internalweb/v1/auth/refresh', { refresh_token: refreshToken }).then((response) => {
+          const data = response.data;
+          localStorage.setItem('token', data.token);
+          localStorage.setItem('refresh_token', data.refresh_token);
+          localStorage.setItem('role', data.role);
+          return data.token as string;
+        })
+      : Promise.reject(new Error('未登录'))
+    ).finally(() => {
+      refreshing = null;
+    });
+  }
+  return refreshing;
+}
+
 // 响应拦截器：统一错误处理
 instance.interceptors.response.use(
   (response) => {
     return response.data;
   },
-  (error: AxiosError<any>) => {
-    // 401 未认证，跳转到登录页
+  async (error: AxiosError<any>) => {
+    // 401 未认证：先用刷新令牌换取新令牌后重试一次，仍失败则跳转到登录页
     if (error.response?.status === 401) {
-      localStorage.removeItem('token');
-      localStorage.removeItem('username');
+      const config = error.config as (InternalAxiosRequestConfig & { _retried?: boolean }) | undefined;
+      if (config && !config._retried) {
+        config._retried = true;
+        try {
+          const token = await refreshAccessToken();
+          config.headers.Authorization = `Bearer ${token}`;
+          return instance(config);
+        } catch {
+          // 刷新失败，需重新登录
+        }
+      }
+      clearAuth();
       message.error('认证已过期，请重新登录');
       window.location.href = '/login';
       return Promise.reject(new Error('未认证'));
@@ -0,0 +1,327 @@
+package handler
+
+import (
+	"net/http"
+
+	"rt-manage/internal/middleware"
+	"rt-manage/internal/model"
+	"rt-manage/internal/service"
+	"rt-manage/pkg/logger"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AdminUserHandler 管理后台账号处理器
+type AdminUserHandler struct {
+	adminUserService    service.AdminUserService
+	adminSessionService service.AdminSessionService
+}
+
+// NewAdminUserHandler 创建管理后台账号处理器实例
+func NewAdminUserHandler(adminUserService service.AdminUserService, adminSessionService service.AdminSessionService) *AdminUserHandler {
+	return &AdminUserHandler{
+		adminUserService:    adminUserService,
+		adminSessionService: adminSessionService,
+	}
+}
+
+// ListUsers 获取账号列表 - POST /api/users/list
+func (h *AdminUserHandler) ListUsers(c *gin.Context) {
+	var req struct {
+		Page     int    `json:"page"`
+		PageSize int    `json:"page_size"`
+		Username string `json:"username"`
+		Role     string `json:"role"`
+		Enabled  *bool  `json:"enabled"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logger.Error("获取账号列表 - 参数错误", "error", err)
+		c.JSON(http.StatusBadRequest, APIResponse{
+			Success: false,
+			Msg:     "参数错误: " + err.Error(),
+		})
+		return
+	}
+
+	// 默认值
+	if req.Page == 0 {
+		req.Page = 1
+	}
+	if req.PageSize == 0 {
+		req.PageSize = 20
+	}
+
+	users, total, err := h.adminUserService.List(req.Page, req.PageSize, req.Username, req.Role, req.Enabled)
+	if err != nil {
+		logger.Error("获取账号列表失败", "error", err)
+		c.JSON(http.StatusInternalServerError, APIResponse{
+			Success: false,
+			Msg:     "获取账号列表失败: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, APIResponse{
+		Success: true,
+		Msg:     "获取成功",
+		Data: gin.H{
+			"items":     users,
+			"total":     total,
+			"page":      req.Page,
+			"page_size": req.PageSize,
+			"roles":     service.AdminRoles,
+		},
+	})
+}
+
+// CreateUser 创建账号 - POST /api/users/create
+func (h *AdminUserHandler) CreateUser(c *gin.Context) {
+	var req struct {
+		Username string `json:"username" binding:"required"`
+		Password string `json:"password" binding:"required"`
+		Role     string `json:"role" binding:"required"` // admin、operator、viewer
+		Enabled  *bool  `json:"enabled"`                 // 默认启用
+		Memo     string `json:"memo"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logger.Error("创建账号 - 参数错误", "error", err)
+		c.JSON(http.StatusBadRequest, APIResponse{
+			Success: false,
+			Msg:     "参数错误: " + err.Error(),
+		})
+		return
+	}
+
+	user := &model.AdminUser{
+		Username: req.Username,
+		Role:     req.Role,
+		Enabled:  req.Enabled == nil || *req.Enabled,
+		Memo:     req.Memo,
+	}
+
+	logger.Info("创建账号 - 请求", "new_username", req.Username, "role", req.Role, "username", c.GetString("username"))
+
+	if err := h.adminUserService.Create(user, req.Password); err != nil {
+		logger.Error("创建账号失败", "new_username", req.Username, "error", err)
+		c.JSON(http.StatusBadRequest, APIResponse{
+			Success: false,
+			Msg:     err.Error(),
+		})
+		return
+	}
+	middleware.SetAuditTarget(c, model.AuditTargetAdminUser, user.ID)
+	middleware.SetAuditChanges(c, nil, auditAdminUser(user))
+
+	c.JSON(http.StatusOK, APIResponse{
+		Success: true,
+		Msg:     "创建成功",
+		Data:    user,
+	})
+}
+
+// UpdateUser 更新账号 - POST /api/users/update
+// updates 支持 role、enabled、memo，以及 password（重置密码）
+func (h *AdminUserHandler) UpdateUser(c *gin.Context) {
+	var req struct {
+		ID      int64                  `json:"id" binding:"required"`
+		Updates map[string]interface{} `json:"updates" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logger.Error("更新账号 - 参数错误", "error", err)
+		c.JSON(http.StatusBadRequest, APIResponse{
+			Success: false,
+			Msg:     "参数错误: " + err.Error(),
+		})
+		return
+	}
+
+	_, passwordReset := req.Updates["password"]
+	logger.Info("更新账号 - 请求", "id", req.ID, "role", req.Updates["role"], "enabled", req.Updates["enabled"], "password_reset", passwordReset, "username", c.GetString("username"))
+
+	before, _ := h.adminUserService.GetByID(req.ID)
+	user, err := h.adminUserService.Update(req.ID, req.Updates, c.GetString("username"))
+	if err != nil {
+		logger.Error("更新账号失败", "id", req.ID, "error", err)
+		c.JSON(http.StatusBadRequest, APIResponse{
+			Success: false,
+			Msg:     err.Error(),
+		})
+		return
+	}
+	middleware.SetAuditChanges(c, auditAdminUser(before), auditAdminUser(user))
+
+	c.JSON(http.StatusOK, APIResponse{
+		Success: true,
+		Msg:     "更新成功",
+		Data:    user,
+	})
+}
+
+// DeleteUser 删除账号 - POST /api/users/delete
+func (h *AdminUserHandler) DeleteUser(c *gin.Context) {
+	var req struct {
+		ID int64 `json:"id" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logger.Error("删除账号 - 参数错误", "error", err)
+		c.JSON(http.StatusBadRequest, APIResponse{
+			Success: false,
+			Msg:     "参数错误: " + err.Error(),
+		})
+		return
+	}
+
+	logger.Info("删除账号 - 请求", "id", req.ID, "username", c.GetString("username"))
+
+	before, _ := h.adminUserService.GetByID(req.ID)
+	if err := h.adminUserService.Delete(req.ID, c.GetString("username")); err != nil {
+		logger.Error("删除账号失败", "id", req.ID, "error", err)
+		c.JSON(http.StatusBadRequest, APIResponse{
+			Success: false,
+			Msg:     err.Error(),
+		})
+		return
+	}
+	if before != nil {
+		middleware.SetAuditChanges(c, auditAdminUser(before), nil)
+	}
+
+	c.JSON(http.StatusOK, APIResponse{
+		Success: true,
+		Msg:     "删除成功",
+	})
+}
+
+// ListSessions 获取登录会话列表 - POST /api/sessions/list
+func (h *AdminUserHandler) ListSessions(c *gin.Context) {
+	var req struct {
+		Page       int    `json:"page"`
+		PageSize   int    `json:"page_size"`
+		UserID     int64  `json:"user_id"`
+		Username   string `json:"username"`
+		ActiveOnly *bool  `json:"active_only"` // 默认只返回有效会话
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logger.Error("获取会话列表 - 参数错误", "error", err)
+		c.JSON(http.StatusBadRequest, APIResponse{
+			Success: false,
+			Msg:     "参数错误: " + err.Error(),
+		})
+		return
+	}
+
+	// 默认值
+	if req.Page == 0 {
+		req.Page = 1
+	}
+	if req.PageSize == 0 {
+		req.PageSize = 20
+	}
+	activeOnly := req.ActiveOnly == nil || *req.ActiveOnly
+
+	sessions, total, err := h.adminSessionService.List(req.Page, req.PageSize, req.UserID, req.Username, activeOnly)
+	if err != nil {
+		logger.Error("获取会话列表失败", "error", err)
+		c.JSON(http.StatusInternalServerError, APIResponse{
+			Success: false,
+			Msg:     "获取会话列表失败: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, APIResponse{
+		Success: true,
+		Msg:     "获取成功",
+		Data: gin.H{
+			"items":              sessions,
+			"total":              total,
+			"page":               req.Page,
+			"page_size":          req.PageSize,
+			"current_session_id": c.GetString("session_id"),
+		},
+	})
+}
+
+// RevokeSessions 注销登录会话 - POST /api/sessions/revoke
+// 传 session_id 注销单个会话，传 user_id 注销该账号的全部会话
+func (h *AdminUserHandler) RevokeSessions(c *gin.Context) {
+	var req struct {
+		SessionID string `json:"session_id"`
+		UserID    int64  `json:"user_id"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil || (req.SessionID == "") == (req.UserID == 0) {
+		c.JSON(http.StatusBadRequest, APIResponse{
+			Success: false,
+			Msg:     "参数错误: session_id 和 user_id 需且只能传一个",
+		})
+		return
+	}
+
+	logger.Info("注销会话 - 请求", "session_id", req.SessionID, "user_id", req.UserID, "username", c.GetString("username"))
+
+	var count int64
+	if req.SessionID != "" {
+		middleware.SetAuditTarget(c, model.AuditTargetSession, req.SessionID)
+		session, err := h.adminSessionService.GetBySessionID(req.SessionID)
+		if err == nil && session == nil {
+			c.JSON(http.StatusNotFound, APIResponse{
+				Success: false,
+				Msg:     "会话不存在",
+			})
+			return
+		}
+		if err == nil {
+			err = h.adminSessionService.Revoke(req.SessionID, model.SessionRevokeAdmin)
+		}
+		if err != nil {
+			logger.Error("注销会话失败", "session_id", req.SessionID, "error", err)
+			c.JSON(http.StatusInternalServerError, APIResponse{
+				Success: false,
+				Msg:     err.Error(),
+			})
+			return
+		}
+		count = 1
+	} else {
+		middleware.SetAuditTarget(c, model.AuditTargetAdminUser, req.UserID)
+		var err error
+		count, err = h.adminSessionService.RevokeUser(req.UserID, "", model.SessionRevokeAdmin)
+		if err != nil {
+			logger.Error("注销账号的会话失败", "user_id", req.UserID, "error", err)
+			c.JSON(http.StatusInternalServerError, APIResponse{
+				Success: false,
+				Msg:     err.Error(),
+			})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, APIResponse{
+		Success: true,
+		Msg:     "会话已注销",
+		Data: gin.H{
+			"count": count,
+		},
+	})
+}
+
+// auditAdminUser 审计用的账号快照，password 为密码哈希（记录时脱敏），用于体现密码是否被重置
+func auditAdminUser(user *model.AdminUser) gin.H {
+	if user == nil {
+		return nil
+	}
+	return gin.H{
+		"id":       user.ID,
+		"username": user.Username,
+		"role":     user.Role,
+		"enabled":  user.Enabled,
+		"memo":     user.Memo,
+		"password": user.PasswordHash,
+	}
+}
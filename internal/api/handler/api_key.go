@@ -0,0 +1,255 @@
+package handler
+
+import (
+	"net/http"
+	"time"
+
+	"rt-manage/internal/middleware"
+	"rt-manage/internal/model"
+	"rt-manage/internal/service"
+	"rt-manage/pkg/logger"
+
+	"github.com/gin-gonic/gin"
+)
+
+// APIKeyHandler 对外API密钥管理处理器
+type APIKeyHandler struct {
+	apiKeyService service.APIKeyService
+}
+
+// NewAPIKeyHandler 创建API密钥管理处理器实例
+func NewAPIKeyHandler(apiKeyService service.APIKeyService) *APIKeyHandler {
+	return &APIKeyHandler{
+		apiKeyService: apiKeyService,
+	}
+}
+
+// ListAPIKeys 获取密钥列表 - POST /api/api-keys/list
+func (h *APIKeyHandler) ListAPIKeys(c *gin.Context) {
+	var req struct {
+		Page     int    `json:"page"`
+		PageSize int    `json:"page_size"`
+		Name     string `json:"name"`
+		Enabled  *bool  `json:"enabled"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logger.Error("获取API密钥列表 - 参数错误", "error", err)
+		c.JSON(http.StatusBadRequest, APIResponse{
+			Success: false,
+			Msg:     "参数错误: " + err.Error(),
+		})
+		return
+	}
+
+	// 默认值
+	if req.Page == 0 {
+		req.Page = 1
+	}
+	if req.PageSize == 0 {
+		req.PageSize = 20
+	}
+
+	keys, total, err := h.apiKeyService.List(req.Page, req.PageSize, req.Name, req.Enabled)
+	if err != nil {
+		logger.Error("获取API密钥列表失败", "error", err)
+		c.JSON(http.StatusInternalServerError, APIResponse{
+			Success: false,
+			Msg:     "获取API密钥列表失败: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, APIResponse{
+		Success: true,
+		Msg:     "获取成功",
+		Data: gin.H{
+			"items":     keys,
+			"total":     total,
+			"page":      req.Page,
+			"page_size": req.PageSize,
+			"endpoints": service.APIKeyEndpoints,
+		},
+	})
+}
+
+// CreateAPIKey 创建密钥 - POST /api/api-keys/create
+// 返回的明文密钥和签名密钥只在创建时可见，数据库中只保存密钥的哈希
+func (h *APIKeyHandler) CreateAPIKey(c *gin.Context) {
+	var req struct {
+		Name             string `json:"name" binding:"required"`
+		AllowedEndpoints string `json:"allowed_endpoints"` // 逗号分隔，为空不限制
+		AllowedTags      string `json:"allowed_tags"`      // 逗号分隔，为空不限制
+		AllowedTypes     string `json:"allowed_types"`     // 逗号分隔，为空不限制
+		Enabled          *bool  `json:"enabled"`           // 默认启用
+		RequireSignature bool   `json:"require_signature"` // 只接受签名请求
+		ExpireTime       string `json:"expire_time"`       // RFC3339，为空永不过期
+		Memo             string `json:"memo"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logger.Error("创建API密钥 - 参数错误", "error", err)
+		c.JSON(http.StatusBadRequest, APIResponse{
+			Success: false,
+			Msg:     "参数错误: " + err.Error(),
+		})
+		return
+	}
+
+	key := &model.APIKey{
+		Name:             req.Name,
+		AllowedEndpoints: req.AllowedEndpoints,
+		AllowedTags:      req.AllowedTags,
+		AllowedTypes:     req.AllowedTypes,
+		Enabled:          req.Enabled == nil || *req.Enabled,
+		RequireSignature: req.RequireSignature,
+		Memo:             req.Memo,
+	}
+	if req.ExpireTime != "" {
+		expireTime, err := time.Parse(time.RFC3339, req.ExpireTime)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, APIResponse{
+				Success: false,
+				Msg:     "过期时间格式错误: " + err.Error(),
+			})
+			return
+		}
+		key.ExpireTime = &expireTime
+	}
+
+	logger.Info("创建API密钥 - 请求", "name", req.Name, "username", c.GetString("username"))
+
+	plaintext, signingSecret, err := h.apiKeyService.Create(key)
+	if err != nil {
+		logger.Error("创建API密钥失败", "name", req.Name, "error", err)
+		c.JSON(http.StatusBadRequest, APIResponse{
+			Success: false,
+			Msg:     err.Error(),
+		})
+		return
+	}
+	middleware.SetAuditTarget(c, model.AuditTargetAPIKey, key.ID)
+	middleware.SetAuditChanges(c, nil, key)
+
+	c.JSON(http.StatusOK, APIResponse{
+		Success: true,
+		Msg:     "创建成功，密钥只显示一次，请妥善保存",
+		Data: gin.H{
+			"api_key":        key,
+			"key":            plaintext,
+			"signing_secret": signingSecret,
+		},
+	})
+}
+
+// ResetSigningSecret 重新生成签名密钥 - POST /api/api-keys/reset-signing-secret
+// 新的签名密钥只在此时可见，旧签名密钥立即失效
+func (h *APIKeyHandler) ResetSigningSecret(c *gin.Context) {
+	var req struct {
+		ID int64 `json:"id" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logger.Error("重新生成签名密钥 - 参数错误", "error", err)
+		c.JSON(http.StatusBadRequest, APIResponse{
+			Success: false,
+			Msg:     "参数错误: " + err.Error(),
+		})
+		return
+	}
+	middleware.SetAuditTarget(c, model.AuditTargetAPIKey, req.ID)
+
+	logger.Info("重新生成签名密钥 - 请求", "id", req.ID, "username", c.GetString("username"))
+
+	key, signingSecret, err := h.apiKeyService.ResetSigningSecret(req.ID)
+	if err != nil {
+		logger.Error("重新生成签名密钥失败", "id", req.ID, "error", err)
+		c.JSON(http.StatusBadRequest, APIResponse{
+			Success: false,
+			Msg:     err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, APIResponse{
+		Success: true,
+		Msg:     "已重新生成签名密钥，签名密钥只显示一次，请妥善保存",
+		Data: gin.H{
+			"api_key":        key,
+			"signing_secret": signingSecret,
+		},
+	})
+}
+
+// UpdateAPIKey 更新密钥 - POST /api/api-keys/update
+func (h *APIKeyHandler) UpdateAPIKey(c *gin.Context) {
+	var req struct {
+		ID      int64                  `json:"id" binding:"required"`
+		Updates map[string]interface{} `json:"updates" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logger.Error("更新API密钥 - 参数错误", "error", err)
+		c.JSON(http.StatusBadRequest, APIResponse{
+			Success: false,
+			Msg:     "参数错误: " + err.Error(),
+		})
+		return
+	}
+
+	logger.Info("更新API密钥 - 请求", "id", req.ID, "updates", req.Updates, "username", c.GetString("username"))
+
+	before, _ := h.apiKeyService.GetByID(req.ID)
+	key, err := h.apiKeyService.Update(req.ID, req.Updates)
+	if err != nil {
+		logger.Error("更新API密钥失败", "id", req.ID, "error", err)
+		c.JSON(http.StatusBadRequest, APIResponse{
+			Success: false,
+			Msg:     err.Error(),
+		})
+		return
+	}
+	middleware.SetAuditChanges(c, before, key)
+
+	c.JSON(http.StatusOK, APIResponse{
+		Success: true,
+		Msg:     "更新成功",
+		Data:    key,
+	})
+}
+
+// DeleteAPIKey 删除密钥 - POST /api/api-keys/delete
+func (h *APIKeyHandler) DeleteAPIKey(c *gin.Context) {
+	var req struct {
+		ID int64 `json:"id" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logger.Error("删除API密钥 - 参数错误", "error", err)
+		c.JSON(http.StatusBadRequest, APIResponse{
+			Success: false,
+			Msg:     "参数错误: " + err.Error(),
+		})
+		return
+	}
+
+	logger.Info("删除API密钥 - 请求", "id", req.ID, "username", c.GetString("username"))
+
+	before, _ := h.apiKeyService.GetByID(req.ID)
+	if err := h.apiKeyService.Delete(req.ID); err != nil {
+		logger.Error("删除API密钥失败", "id", req.ID, "error", err)
+		c.JSON(http.StatusInternalServerError, APIResponse{
+			Success: false,
+			Msg:     "删除失败: " + err.Error(),
+		})
+		return
+	}
+	if before != nil {
+		middleware.SetAuditChanges(c, before, nil)
+	}
+
+	c.JSON(http.StatusOK, APIResponse{
+		Success: true,
+		Msg:     "删除成功",
+	})
+}
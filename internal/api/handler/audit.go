@@ -0,0 +1,77 @@
+package handler
+
+import (
+	"net/http"
+
+	"rt-manage/internal/service"
+	"rt-manage/pkg/logger"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AuditHandler 审计事件处理器
+type AuditHandler struct {
+	auditService service.AuditService
+}
+
+// NewAuditHandler 创建审计事件处理器实例
+func NewAuditHandler(auditService service.AuditService) *AuditHandler {
+	return &AuditHandler{
+		auditService: auditService,
+	}
+}
+
+// ListAuditEvents 获取审计事件列表 - POST /api/audit-events/list
+func (h *AuditHandler) ListAuditEvents(c *gin.Context) {
+	var req struct {
+		Page       int    `json:"page"`
+		PageSize   int    `json:"page_size"`
+		Actor      string `json:"actor"`       // 用户名，API 密钥为 api_key:名称
+		ActorType  string `json:"actor_type"`  // jwt、api_key、anonymous
+		Action     string `json:"action"`      // 按前缀匹配，如 rts. 或 public.get-at
+		TargetType string `json:"target_type"` // rt、lease、config、api_key、admin_user
+		TargetID   string `json:"target_id"`
+		ClientIP   string `json:"client_ip"`
+		Success    *bool  `json:"success"`
+		StartDate  string `json:"start_date"`
+		EndDate    string `json:"end_date"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logger.Error("获取审计事件 - 参数错误", "error", err)
+		c.JSON(http.StatusBadRequest, APIResponse{
+			Success: false,
+			Msg:     "参数错误: " + err.Error(),
+		})
+		return
+	}
+
+	// 默认值
+	if req.Page == 0 {
+		req.Page = 1
+	}
+	if req.PageSize == 0 {
+		req.PageSize = 20
+	}
+
+	events, total, err := h.auditService.List(req.Page, req.PageSize, req.Actor, req.ActorType, req.Action, req.TargetType, req.TargetID, req.ClientIP, req.Success, req.StartDate, req.EndDate)
+	if err != nil {
+		logger.Error("获取审计事件失败", "error", err)
+		c.JSON(http.StatusInternalServerError, APIResponse{
+			Success: false,
+			Msg:     "获取审计事件失败: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, APIResponse{
+		Success: true,
+		Msg:     "获取成功",
+		Data: gin.H{
+			"items":     events,
+			"total":     total,
+			"page":      req.Page,
+			"page_size": req.PageSize,
+		},
+	})
+}
@@ -1,19 +1,28 @@
 package handler
 
 import (
-	"rt-manage/internal/config"
-	jwtutil "rt-manage/pkg/jwt"
+	"errors"
+
+	"rt-manage/internal/middleware"
+	"rt-manage/internal/model"
+	"rt-manage/internal/service"
+	"rt-manage/pkg/logger"
 
 	"github.com/gin-gonic/gin"
 )
 
 // AuthHandler 认证处理器
 type AuthHandler struct {
+	adminUserService    service.AdminUserService
+	adminSessionService service.AdminSessionService
 }
 
 // NewAuthHandler 创建认证处理器
-func NewAuthHandler() *AuthHandler {
-	return &AuthHandler{}
+func NewAuthHandler(adminUserService service.AdminUserService, adminSessionService service.AdminSessionService) *AuthHandler {
+	return &AuthHandler{
+		adminUserService:    adminUserService,
+		adminSessionService: adminSessionService,
+	}
 }
 
 // LoginRequest 登录请求
@@ -22,10 +31,26 @@ type LoginRequest struct {
 	Password string `json:"password" binding:"required"`
 }
 
-// LoginResponse 登录响应
+// LoginResponse 登录响应（刷新令牌接口返回相同结构）
+// token 为短期访问令牌，过期前用 refresh_token 换取新令牌，refresh_token 每次使用后都会轮换
 type LoginResponse struct {
 	Token string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn int `json:"expires_in"`
+	RefreshExpiresIn int `json:"refresh_expires_in"`
 	Username string `json:"username"`
+	Role string `json:"role"`
+}
+
+func newLoginResponse(tokens *service.SessionTokens) LoginResponse {
+	return LoginResponse{
+		Token: tokens.AccessToken,
+		RefreshToken: tokens.RefreshToken,
+		ExpiresIn: tokens.ExpiresIn,
+		RefreshExpiresIn: tokens.RefreshExpiresIn,
+		Username: tokens.User.Username,
+		Role: tokens.User.Role,
+	}
 }
 
 // Login 登录
@@ -38,27 +63,121 @@ func (h *AuthHandler) Login(c *gin.Context) {
 		return
 	}
 
-	// 从配置读取用户名和密码
-	cfg := config.Get()
-	if req.Username != cfg.Auth.Username || req.Password != cfg.Auth.Password {
-		c.JSON(401, gin.H{
-			"error": "用户名或密码错误",
+	// 审计事件中记录尝试登录的用户名
+	c.Set("username", req.Username)
+
+	// 校验管理后台账号（密码以 bcrypt 哈希保存）
+	user, err := h.adminUserService.Authenticate(req.Username, req.Password, c.ClientIP())
+	if err != nil {
+		logger.Warn("登录失败", "username", req.Username, "client_ip", c.ClientIP(), "error", err)
+		if errors.Is(err, service.ErrLoginFailed) || errors.Is(err, service.ErrAdminUserDisabled) {
+			c.JSON(401, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+		c.JSON(500, gin.H{
+			"error": "登录失败",
 		})
 		return
 	}
 
-	// 生成JWT token
-	token, err := jwtutil.GenerateToken(req.Username, cfg.Auth.JWTSecret, cfg.Auth.JWTExpireHours)
+	// 创建登录会话，签发访问令牌和刷新令牌
+	tokens, err := h.adminSessionService.Create(user, c.ClientIP(), c.GetHeader("User-Agent"))
 	if err != nil {
+		logger.Error("创建登录会话失败", "username", user.Username, "error", err)
 		c.JSON(500, gin.H{
 			"error": "生成令牌失败",
 		})
 		return
 	}
 
-	c.JSON(200, LoginResponse{
-		Token: token,
-		Username: req.Username,
+	logger.Info("登录成功", "username", user.Username, "role", user.Role, "session_id", tokens.Session.SessionID, "client_ip", c.ClientIP())
+	c.JSON(200, newLoginResponse(tokens))
+}
+
+// Refresh 用刷新令牌换取新的访问令牌和刷新令牌 - POST /api/auth/refresh
+func (h *AuthHandler) Refresh(c *gin.Context) {
+	var req struct {
+		RefreshToken string `json:"refresh_token" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{
+			"error": "请求参数错误",
+		})
+		return
+	}
+
+	tokens, err := h.adminSessionService.Refresh(req.RefreshToken, c.ClientIP())
+	if err != nil {
+		if errors.Is(err, service.ErrRefreshTokenInvalid) || errors.Is(err, service.ErrRefreshTokenReused) {
+			logger.Warn("刷新令牌失败", "client_ip", c.ClientIP(), "error", err)
+			c.JSON(401, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+		logger.Error("刷新令牌失败", "client_ip", c.ClientIP(), "error", err)
+		c.JSON(500, gin.H{
+			"error": "刷新令牌失败",
+		})
+		return
+	}
+
+	logger.Debug("刷新令牌成功", "username", tokens.User.Username, "session_id", tokens.Session.SessionID)
+	c.JSON(200, newLoginResponse(tokens))
+}
+
+// Logout 退出登录，注销当前会话 - POST /api/auth/logout
+func (h *AuthHandler) Logout(c *gin.Context) {
+	sessionID := c.GetString("session_id")
+	middleware.SetAuditTarget(c, model.AuditTargetSession, sessionID)
+
+	if err := h.adminSessionService.Revoke(sessionID, model.SessionRevokeLogout); err != nil {
+		logger.Error("退出登录失败", "username", c.GetString("username"), "error", err)
+		c.JSON(500, APIResponse{
+			Success: false,
+			Msg:     "退出登录失败",
+		})
+		return
+	}
+
+	logger.Info("退出登录", "username", c.GetString("username"), "session_id", sessionID)
+	c.JSON(200, APIResponse{
+		Success: true,
+		Msg:     "已退出登录",
+	})
+}
+
+// LogoutAll 退出所有设备，注销当前账号的全部会话（包括当前会话） - POST /api/auth/logout-all
+func (h *AuthHandler) LogoutAll(c *gin.Context) {
+	session, err := h.adminSessionService.GetBySessionID(c.GetString("session_id"))
+	if err != nil || session == nil {
+		c.JSON(500, APIResponse{
+			Success: false,
+			Msg:     "获取当前会话失败",
+		})
+		return
+	}
+	middleware.SetAuditTarget(c, model.AuditTargetAdminUser, session.UserID)
+
+	count, err := h.adminSessionService.RevokeUser(session.UserID, "", model.SessionRevokeLogoutAll)
+	if err != nil {
+		logger.Error("退出所有设备失败", "username", session.Username, "error", err)
+		c.JSON(500, APIResponse{
+			Success: false,
+			Msg:     "退出所有设备失败",
+		})
+		return
+	}
+
+	logger.Info("退出所有设备", "username", session.Username, "count", count)
+	c.JSON(200, APIResponse{
+		Success: true,
+		Msg:     "已退出所有设备",
+		Data: gin.H{
+			"count": count,
+		},
 	})
 }
 
@@ -77,6 +196,117 @@ func (h *AuthHandler) GetCurrentUser(c *gin.Context) {
 		"success":  true,
 		"msg":      "获取成功",
 		"username": username,
+		"role":     c.GetString("role"),
 	})
 }
 
+// ChangePassword 修改当前用户的密码 - POST /api/user/change-password
+func (h *AuthHandler) ChangePassword(c *gin.Context) {
+	var req struct {
+		OldPassword string `json:"old_password" binding:"required"`
+		NewPassword string `json:"new_password" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, APIResponse{
+			Success: false,
+			Msg:     "参数错误: " + err.Error(),
+		})
+		return
+	}
+
+	// 修改成功后其他设备上的会话全部失效，当前会话保持登录
+	username := c.GetString("username")
+	if err := h.adminUserService.ChangePassword(username, req.OldPassword, req.NewPassword, c.GetString("session_id")); err != nil {
+		logger.Warn("修改密码失败", "username", username, "error", err)
+		c.JSON(400, APIResponse{
+			Success: false,
+			Msg:     err.Error(),
+		})
+		return
+	}
+
+	c.JSON(200, APIResponse{
+		Success: true,
+		Msg:     "密码已修改",
+	})
+}
+
+// ListSessions 获取当前账号的有效会话 - POST /api/user/sessions/list
+func (h *AuthHandler) ListSessions(c *gin.Context) {
+	session, err := h.adminSessionService.GetBySessionID(c.GetString("session_id"))
+	if err != nil || session == nil {
+		c.JSON(500, APIResponse{
+			Success: false,
+			Msg:     "获取当前会话失败",
+		})
+		return
+	}
+
+	sessions, total, err := h.adminSessionService.List(1, 100, session.UserID, "", true)
+	if err != nil {
+		logger.Error("获取会话列表失败", "username", session.Username, "error", err)
+		c.JSON(500, APIResponse{
+			Success: false,
+			Msg:     "获取会话列表失败: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(200, APIResponse{
+		Success: true,
+		Msg:     "获取成功",
+		Data: gin.H{
+			"items":              sessions,
+			"total":              total,
+			"current_session_id": session.SessionID,
+		},
+	})
+}
+
+// RevokeSession 注销当前账号的某个会话（如其他设备的登录） - POST /api/user/sessions/revoke
+func (h *AuthHandler) RevokeSession(c *gin.Context) {
+	var req struct {
+		SessionID string `json:"session_id" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, APIResponse{
+			Success: false,
+			Msg:     "参数错误: " + err.Error(),
+		})
+		return
+	}
+	middleware.SetAuditTarget(c, model.AuditTargetSession, req.SessionID)
+
+	// 只能注销自己的会话
+	current, err := h.adminSessionService.GetBySessionID(c.GetString("session_id"))
+	if err != nil || current == nil {
+		c.JSON(500, APIResponse{
+			Success: false,
+			Msg:     "获取当前会话失败",
+		})
+		return
+	}
+	target, err := h.adminSessionService.GetBySessionID(req.SessionID)
+	if err != nil || target == nil || target.UserID != current.UserID {
+		c.JSON(404, APIResponse{
+			Success: false,
+			Msg:     "会话不存在",
+		})
+		return
+	}
+
+	if err := h.adminSessionService.Revoke(req.SessionID, model.SessionRevokeLogout); err != nil {
+		logger.Error("注销会话失败", "session_id", req.SessionID, "error", err)
+		c.JSON(500, APIResponse{
+			Success: false,
+			Msg:     err.Error(),
+		})
+		return
+	}
+
+	c.JSON(200, APIResponse{
+		Success: true,
+		Msg:     "会话已注销",
+	})
+}
@@ -2,8 +2,12 @@ package handler
 
 import (
 	"net/http"
+	"sort"
+	"strings"
 
 	"github.com/gin-gonic/gin"
+	"rt-manage/internal/middleware"
+	"rt-manage/internal/model"
 	"rt-manage/internal/service"
 	"rt-manage/pkg/logger"
 )
@@ -63,6 +67,7 @@ func (h *ConfigHandler) SaveSystemConfigs(c *gin.Context) {
 
 	logger.Info("保存系统配置 - 请求", "configs", req.Configs)
 
+	before, _, _ := h.configService.GetSystemConfigs()
 	if err := h.configService.SaveSystemConfigs(req.Configs); err != nil {
 		logger.Error("保存系统配置失败", "configs", req.Configs, "error", err)
 		c.JSON(http.StatusBadRequest, APIResponse{
@@ -74,6 +79,19 @@ func (h *ConfigHandler) SaveSystemConfigs(c *gin.Context) {
 
 	logger.Info("保存系统配置成功", "configs", req.Configs)
 
+	after := make(map[string]string, len(before)+len(req.Configs))
+	for key, value := range before {
+		after[key] = value
+	}
+	keys := make([]string, 0, len(req.Configs))
+	for key, value := range req.Configs {
+		after[key] = value
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	middleware.SetAuditTarget(c, model.AuditTargetConfig, strings.Join(keys, ","))
+	middleware.SetAuditChanges(c, before, after)
+
 	c.JSON(http.StatusOK, APIResponse{
 		Success: true,
 		Msg:     "保存成功",
@@ -0,0 +1,116 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"rt-manage/internal/middleware"
+	"rt-manage/internal/model"
+	"rt-manage/internal/service"
+	"rt-manage/pkg/logger"
+
+	"github.com/gin-gonic/gin"
+)
+
+// LeaseHandler 租约管理处理器
+type LeaseHandler struct {
+	leaseService service.LeaseService
+}
+
+// NewLeaseHandler 创建租约管理处理器实例
+func NewLeaseHandler(leaseService service.LeaseService) *LeaseHandler {
+	return &LeaseHandler{
+		leaseService: leaseService,
+	}
+}
+
+// ListLeases 获取租约列表 - POST /api/leases/list
+func (h *LeaseHandler) ListLeases(c *gin.Context) {
+	var req struct {
+		Page     int    `json:"page"`
+		PageSize int    `json:"page_size"`
+		RtID     int64  `json:"rt_id"`
+		BizId    string `json:"biz_id"`
+		Consumer string `json:"consumer"`
+		Status   string `json:"status"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logger.Error("获取租约列表 - 参数错误", "error", err)
+		c.JSON(http.StatusBadRequest, APIResponse{
+			Success: false,
+			Msg:     "参数错误: " + err.Error(),
+		})
+		return
+	}
+
+	// 默认值
+	if req.Page == 0 {
+		req.Page = 1
+	}
+	if req.PageSize == 0 {
+		req.PageSize = 20
+	}
+
+	leases, total, err := h.leaseService.List(req.Page, req.PageSize, req.RtID, req.BizId, req.Consumer, req.Status)
+	if err != nil {
+		logger.Error("获取租约列表失败", "error", err)
+		c.JSON(http.StatusInternalServerError, APIResponse{
+			Success: false,
+			Msg:     "获取租约列表失败: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, APIResponse{
+		Success: true,
+		Msg:     "获取成功",
+		Data: gin.H{
+			"items":     leases,
+			"total":     total,
+			"page":      req.Page,
+			"page_size": req.PageSize,
+		},
+	})
+}
+
+// ReleaseLease 管理员强制释放租约 - POST /api/leases/release
+func (h *LeaseHandler) ReleaseLease(c *gin.Context) {
+	var req struct {
+		LeaseID string `json:"lease_id" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logger.Error("释放租约 - 参数错误", "error", err)
+		c.JSON(http.StatusBadRequest, APIResponse{
+			Success: false,
+			Msg:     "参数错误: " + err.Error(),
+		})
+		return
+	}
+
+	logger.Info("管理员释放租约", "lease_id", req.LeaseID, "username", c.GetString("username"))
+	middleware.SetAuditTarget(c, model.AuditTargetLease, req.LeaseID)
+
+	lease, err := h.leaseService.Release(req.LeaseID, nil)
+	if err != nil {
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, service.ErrLeaseNotFound):
+			status = http.StatusNotFound
+		case errors.Is(err, service.ErrLeaseExpired):
+			status = http.StatusGone
+		}
+		c.JSON(status, APIResponse{
+			Success: false,
+			Msg:     err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, APIResponse{
+		Success: true,
+		Msg:     "释放成功",
+		Data:    lease,
+	})
+}
@@ -1,9 +1,12 @@
 package handler
 
 import (
+	"errors"
 	"net/http"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"rt-manage/internal/middleware"
 	"rt-manage/internal/model"
 	"rt-manage/internal/service"
 	"rt-manage/pkg/logger"
@@ -11,13 +14,15 @@ import (
 
 // PublicAPIHandler 对外API处理器
 type PublicAPIHandler struct {
-	rtService service.RTService
+	rtService    service.RTService
+	leaseService service.LeaseService
 }
 
 // NewPublicAPIHandler 创建对外API处理器
-func NewPublicAPIHandler(rtService service.RTService) *PublicAPIHandler {
+func NewPublicAPIHandler(rtService service.RTService, leaseService service.LeaseService) *PublicAPIHandler {
 	return &PublicAPIHandler{
-		rtService: rtService,
+		rtService:    rtService,
+		leaseService: leaseService,
 	}
 }
 
@@ -60,7 +65,7 @@ func (h *PublicAPIHandler) RefreshAndGetAT(c *gin.Context) {
 		rt, err = h.rtService.GetByEmail(req.Email)
 	}
 
-	if err != nil {
+	if err != nil || rt == nil {
 		logger.Error("RefreshAndGetAT - 查找RT失败", "biz_id", req.BizId, "email", req.Email, "error", err)
 		c.JSON(http.StatusNotFound, gin.H{
 			"success": false,
@@ -68,17 +73,22 @@ func (h *PublicAPIHandler) RefreshAndGetAT(c *gin.Context) {
 		})
 		return
 	}
+	middleware.SetAuditTarget(c, model.AuditTargetRT, rt.ID)
+	if !checkAPIKeyScope(c, rt) || !middleware.AllowAccount(c, rt) {
+		return
+	}
 
 	// 刷新RT（不刷新用户信息和账号信息）
-	refreshedRT, err := h.rtService.Refresh(rt.ID, false, false)
+	refreshedRT, err := h.rtService.Refresh(rt.ID, false, false, model.RefreshTriggerPublicAPI)
 	if err != nil {
 		logger.Error("RefreshAndGetAT - 刷新失败", "id", rt.ID, "biz_id", rt.BizId, "error", err)
+		if refreshedRT != nil {
+			rt = refreshedRT
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"success": false,
-			"msg":     "刷新失败: " + err.Error(),
-			"data": gin.H{
-				"refresh_result": rt.RefreshResult,
-			},
+			"msg":     "刷新失败",
+			"data":    refreshFailureData(rt, err),
 		})
 		return
 	}
@@ -95,6 +105,8 @@ func (h *PublicAPIHandler) RefreshAndGetAT(c *gin.Context) {
 			"refresh_token": refreshedRT.Rt,
 			"type":        refreshedRT.Type,
 			"user_name":   refreshedRT.UserName,
+			"credential_type": refreshedRT.CredentialType,
+			"at_expire_time":  refreshedRT.AtExpireTime,
 		},
 	})
 }
@@ -126,19 +138,9 @@ func (h *PublicAPIHandler) GetAT(c *gin.Context) {
 
 	logger.Info("GetAT - 请求", "biz_id", req.BizId, "email", req.Email)
 
-	// 根据条件查找RT
-	var rt *model.RT
-	var err error
-	
-	if req.BizId != "" {
-		// 优先使用 biz_id
-		rt, err = h.rtService.GetByBizId(req.BizId)
-	} else {
-		// 使用 email
-		rt, err = h.rtService.GetByEmail(req.Email)
-	}
-
-	if err != nil {
+	// 根据条件查找RT（优先使用 biz_id，其次 email）
+	rt, err := findRT(h.rtService, req.BizId, req.Email)
+	if err != nil || rt == nil {
 		logger.Error("GetAT - 查找RT失败", "biz_id", req.BizId, "email", req.Email, "error", err)
 		c.JSON(http.StatusNotFound, gin.H{
 			"success": false,
@@ -146,6 +148,25 @@ func (h *PublicAPIHandler) GetAT(c *gin.Context) {
 		})
 		return
 	}
+	middleware.SetAuditTarget(c, model.AuditTargetRT, rt.ID)
+	if !checkAPIKeyScope(c, rt) || !middleware.AllowAccount(c, rt) {
+		return
+	}
+
+	// 仅AT账号无法刷新，AT 过期或经反馈确认失效后不再返回
+	if rt.IsATOnly() && (rt.ATExpired(time.Now()) || rt.RefreshStatus == model.RefreshStatusStale) {
+		logger.Warn("GetAT - 仅AT账号的AT已过期", "id", rt.ID, "biz_id", rt.BizId, "at_expire_time", rt.AtExpireTime)
+		c.JSON(http.StatusConflict, gin.H{
+			"success": false,
+			"msg":     "AT已过期或已失效，该账号没有RT无法刷新，请重新导入会话",
+			"data": gin.H{
+				"credential_type": rt.CredentialType,
+				"refresh_status":  rt.RefreshStatus,
+				"at_expire_time":  rt.AtExpireTime,
+			},
+		})
+		return
+	}
 
 	logger.Info("GetAT - 成功", "id", rt.ID, "biz_id", rt.BizId)
 
@@ -159,7 +180,299 @@ func (h *PublicAPIHandler) GetAT(c *gin.Context) {
 			"refresh_token": rt.Rt,
 			"type":        rt.Type,
 			"user_name":   rt.UserName,
+			"credential_type": rt.CredentialType,
+			"at_expire_time":  rt.AtExpireTime,
+			"cooldown_until":  rt.CooldownUntil,
+		},
+	})
+}
+
+// GetCodexAuth 获取 Codex 可直接使用的 auth.json - POST /public-api/codex-auth
+// refresh 为 true 时先刷新RT
+func (h *PublicAPIHandler) GetCodexAuth(c *gin.Context) {
+	var req struct {
+		BizId   string `json:"biz_id"`
+		Email   string `json:"email"`
+		Refresh bool   `json:"refresh"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logger.Error("GetCodexAuth - 参数错误", "error", err)
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"msg":     "参数错误: " + err.Error(),
+		})
+		return
+	}
+
+	// 优先使用 biz_id，其次 email
+	if req.BizId == "" && req.Email == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"msg":     "biz_id 和 email 至少提供一个",
+		})
+		return
+	}
+
+	logger.Info("GetCodexAuth - 请求", "biz_id", req.BizId, "email", req.Email, "refresh", req.Refresh)
+
+	rt, err := findRT(h.rtService, req.BizId, req.Email)
+	if err != nil || rt == nil {
+		logger.Error("GetCodexAuth - 查找RT失败", "biz_id", req.BizId, "email", req.Email, "error", err)
+		c.JSON(http.StatusNotFound, gin.H{
+			"success": false,
+			"msg":     "RT不存在",
+		})
+		return
+	}
+	middleware.SetAuditTarget(c, model.AuditTargetRT, rt.ID)
+	if !checkAPIKeyScope(c, rt) || !middleware.AllowAccount(c, rt) {
+		return
+	}
+	if req.Refresh && !middleware.AllowRefresh(c, rt) {
+		return
+	}
+
+	writeCodexAuth(c, h.rtService, rt, req.Refresh, model.RefreshTriggerPublicAPI)
+}
+
+// Lease 从账号池中租用一个账号的AT - POST /public-api/lease
+// 按 tag、type 等条件选择已启用且AT有效的账号，返回AT和租约ID，用完后调用 /release 归还
+func (h *PublicAPIHandler) Lease(c *gin.Context) {
+	var req struct {
+		Tag            string   `json:"tag"`
+		Type           string   `json:"type"`
+		CredentialType string   `json:"credential_type"`
+		BizIds         []string `json:"biz_ids"`
+		ExcludeBizIds  []string `json:"exclude_biz_ids"`
+		Strategy       string   `json:"strategy"`
+		Exclusive      bool     `json:"exclusive"`
+		TTL            int      `json:"ttl"`
+		Consumer       string   `json:"consumer"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logger.Error("Lease - 参数错误", "error", err)
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"msg":     "参数错误: " + err.Error(),
+		})
+		return
+	}
+
+	logger.Info("Lease - 请求", "tag", req.Tag, "type", req.Type, "credential_type", req.CredentialType, "strategy", req.Strategy, "exclusive", req.Exclusive, "ttl", req.TTL, "consumer", req.Consumer)
+
+	// 限定了标签或账号类型的密钥只能从允许的范围内租用，租约记录密钥ID（旧版密钥为空），只有该密钥可以释放
+	var allowedTags, allowedTypes []string
+	var apiKeyID *int64
+	if key := middleware.CurrentAPIKey(c); key != nil {
+		allowedTags, allowedTypes = key.TagList(), key.TypeList()
+		apiKeyID = key.LeaseOwnerID()
+	}
+
+	lease, rt, err := h.leaseService.Lease(service.LeaseRequest{
+		Tag:            req.Tag,
+		Type:           req.Type,
+		CredentialType: req.CredentialType,
+		BizIds:         req.BizIds,
+		ExcludeBizIds:  req.ExcludeBizIds,
+		Strategy:       req.Strategy,
+		Exclusive:      req.Exclusive,
+		TTL:            req.TTL,
+		Consumer:       req.Consumer,
+		AllowedTags:    allowedTags,
+		AllowedTypes:   allowedTypes,
+		APIKeyID:       apiKeyID,
+	})
+	if err != nil {
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, service.ErrInvalidLeaseRequest):
+			status = http.StatusBadRequest
+		case errors.Is(err, service.ErrNoLeasableAccount):
+			status = http.StatusServiceUnavailable
+		}
+		logger.Warn("Lease - 失败", "tag", req.Tag, "type", req.Type, "consumer", req.Consumer, "error", err)
+		c.JSON(status, gin.H{
+			"success": false,
+			"msg":     err.Error(),
+		})
+		return
+	}
+	middleware.SetAuditTarget(c, model.AuditTargetRT, rt.ID)
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"msg":     "租用成功",
+		"data": gin.H{
+			"lease_id":        lease.LeaseID,
+			"expire_time":     lease.ExpireTime,
+			"ttl":             int(time.Until(lease.ExpireTime).Seconds()),
+			"exclusive":       lease.Exclusive,
+			"strategy":        lease.Strategy,
+			"biz_id":          rt.BizId,
+			"email":           rt.Email,
+			"type":            rt.Type,
+			"credential_type": rt.CredentialType,
+			"account_id":      rt.AccountID,
+			"access_token":    rt.At,
+			"at_expire_time":  rt.AtExpireTime,
+		},
+	})
+}
+
+// Release 归还租用的账号 - POST /public-api/release
+func (h *PublicAPIHandler) Release(c *gin.Context) {
+	var req struct {
+		LeaseID string `json:"lease_id" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logger.Error("Release - 参数错误", "error", err)
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"msg":     "参数错误: " + err.Error(),
+		})
+		return
+	}
+
+	middleware.SetAuditTarget(c, model.AuditTargetLease, req.LeaseID)
+
+	// 租约对应的账号须在密钥的访问范围内
+	var apiKeyID *int64
+	if key := middleware.CurrentAPIKey(c); key != nil {
+		apiKeyID = key.LeaseOwnerID()
+		existing, err := h.leaseService.GetByLeaseID(req.LeaseID)
+		if err == nil && existing != nil {
+			rt, err := h.rtService.GetByID(existing.RtID)
+			if err == nil && rt != nil && !checkAPIKeyScope(c, rt) {
+				return
+			}
+		}
+	}
+
+	lease, err := h.leaseService.Release(req.LeaseID, apiKeyID)
+	if err != nil {
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, service.ErrLeaseNotFound):
+			status = http.StatusNotFound
+		case errors.Is(err, service.ErrLeaseExpired):
+			status = http.StatusGone
+		case errors.Is(err, service.ErrLeaseForbidden):
+			status = http.StatusForbidden
+		}
+		logger.Warn("Release - 失败", "lease_id", req.LeaseID, "error", err)
+		c.JSON(status, gin.H{
+			"success": false,
+			"msg":     err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"msg":     "释放成功",
+		"data": gin.H{
+			"lease_id":     lease.LeaseID,
+			"biz_id":       lease.BizId,
+			"status":       lease.Status,
+			"release_time": lease.ReleaseTime,
+		},
+	})
+}
+
+// Report 调用方反馈AT在下游返回 401/403/429 - POST /public-api/report
+// 429 使账号按 Retry-After 冷却；401/403 使账号冷却并触发刷新（仅AT账号则校验AT），冷却期间账号不参与 /lease 选择
+func (h *PublicAPIHandler) Report(c *gin.Context) {
+	var req struct {
+		BizId       string `json:"biz_id"`
+		Email       string `json:"email"`
+		Status      int    `json:"status" binding:"required"`
+		RetryAfter  int    `json:"retry_after"`
+		AccessToken string `json:"access_token"`
+		Consumer    string `json:"consumer"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logger.Error("Report - 参数错误", "error", err)
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"msg":     "参数错误: " + err.Error(),
+		})
+		return
+	}
+
+	// 优先使用 biz_id，其次 email
+	if req.BizId == "" && req.Email == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"msg":     "biz_id 和 email 至少提供一个",
+		})
+		return
+	}
+
+	rt, err := findRT(h.rtService, req.BizId, req.Email)
+	if err != nil || rt == nil {
+		logger.Error("Report - 查找RT失败", "biz_id", req.BizId, "email", req.Email, "error", err)
+		c.JSON(http.StatusNotFound, gin.H{
+			"success": false,
+			"msg":     "RT不存在",
+		})
+		return
+	}
+	middleware.SetAuditTarget(c, model.AuditTargetRT, rt.ID)
+	if !checkAPIKeyScope(c, rt) || !middleware.AllowAccount(c, rt) {
+		return
+	}
+	// 针对当前AT的 401/403 反馈会刷新RT，计入 refresh 限额
+	if req.Status != http.StatusTooManyRequests && !rt.IsATOnly() && req.AccessToken != "" && req.AccessToken == rt.At &&
+		!middleware.AllowRefresh(c, rt) {
+		return
+	}
+
+	result, err := h.rtService.Report(rt.ID, service.ReportRequest{
+		Status:      req.Status,
+		RetryAfter:  req.RetryAfter,
+		AccessToken: req.AccessToken,
+		Consumer:    req.Consumer,
+	})
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, service.ErrInvalidReport) {
+			status = http.StatusBadRequest
+		}
+		logger.Warn("Report - 失败", "id", rt.ID, "biz_id", rt.BizId, "consumer", req.Consumer, "error", err)
+		c.JSON(status, gin.H{
+			"success": false,
+			"msg":     err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"msg":     "反馈成功",
+		"data": gin.H{
+			"biz_id":          result.RT.BizId,
+			"action":          result.Action,
+			"cooldown_until":  result.RT.CooldownUntil,
+			"cooldown_reason": result.RT.CooldownReason,
+			"refresh_status":  result.RT.RefreshStatus,
 		},
 	})
 }
 
+// checkAPIKeyScope 校验当前API密钥是否允许访问该账号（标签、账号类型），无权限时返回 403
+func checkAPIKeyScope(c *gin.Context, rt *model.RT) bool {
+	key := middleware.CurrentAPIKey(c)
+	if key == nil || key.AllowsRT(rt) {
+		return true
+	}
+	logger.Warn("API密钥无权访问该账号", "key_id", key.ID, "key_name", key.Name, "biz_id", rt.BizId, "tag", rt.Tag, "type", rt.Type)
+	c.JSON(http.StatusForbidden, gin.H{
+		"success": false,
+		"msg":     "API密钥无权访问该账号",
+	})
+	return false
+}
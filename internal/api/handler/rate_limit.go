@@ -0,0 +1,50 @@
+package handler
+
+import (
+	"net/http"
+
+	"rt-manage/internal/config"
+	"rt-manage/internal/middleware"
+	"rt-manage/pkg/logger"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetRateLimitStats 获取对外API限流规则和各令牌桶的计数 - POST /api/rate-limits/stats
+// 计数保存在内存中，服务重启后清零；tokens 为最后一次请求时的剩余令牌
+func GetRateLimitStats(c *gin.Context) {
+	var req struct {
+		Group string `json:"group"` // refresh、get-at、default
+		Scope string `json:"scope"` // key、ip、account
+		Limit int    `json:"limit"` // 最多返回的条数，默认 100
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logger.Error("获取限流计数 - 参数错误", "error", err)
+		c.JSON(http.StatusBadRequest, APIResponse{
+			Success: false,
+			Msg:     "参数错误: " + err.Error(),
+		})
+		return
+	}
+
+	if req.Limit <= 0 {
+		req.Limit = 100
+	}
+
+	stats := middleware.RateLimitStats(req.Group, req.Scope)
+	total := len(stats)
+	if len(stats) > req.Limit {
+		stats = stats[:req.Limit]
+	}
+
+	c.JSON(http.StatusOK, APIResponse{
+		Success: true,
+		Msg:     "获取成功",
+		Data: gin.H{
+			"config": config.Get().RateLimit,
+			"items":  stats,
+			"total":  total,
+		},
+	})
+}
@@ -0,0 +1,29 @@
+package handler
+
+import (
+	"rt-manage/internal/model"
+	"rt-manage/pkg/secret"
+)
+
+// maskRT 返回脱敏后的RT副本，管理接口默认只返回脱敏的 token，明文需通过 /rts/reveal 获取
+func maskRT(rt *model.RT) *model.RT {
+	if rt == nil {
+		return nil
+	}
+	masked := *rt
+	masked.Rt = secret.Mask(rt.Rt)
+	masked.At = secret.Mask(rt.At)
+	masked.IdToken = secret.Mask(rt.IdToken)
+	masked.LastRT = secret.Mask(rt.LastRT)
+	masked.RefreshResult = secret.RedactJSON(rt.RefreshResult)
+	return &masked
+}
+
+// maskRTs 批量脱敏
+func maskRTs(rts []*model.RT) []*model.RT {
+	masked := make([]*model.RT, len(rts))
+	for i, rt := range rts {
+		masked[i] = maskRT(rt)
+	}
+	return masked
+}
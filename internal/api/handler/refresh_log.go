@@ -0,0 +1,115 @@
+package handler
+
+import (
+	"net/http"
+
+	"rt-manage/internal/service"
+	"rt-manage/pkg/logger"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RefreshLogHandler 刷新记录处理器
+type RefreshLogHandler struct {
+	refreshLogService service.RefreshLogService
+}
+
+// NewRefreshLogHandler 创建刷新记录处理器实例
+func NewRefreshLogHandler(refreshLogService service.RefreshLogService) *RefreshLogHandler {
+	return &RefreshLogHandler{
+		refreshLogService: refreshLogService,
+	}
+}
+
+// ListRefreshLogs 获取刷新记录列表 - POST /api/rts/refresh-logs/list
+func (h *RefreshLogHandler) ListRefreshLogs(c *gin.Context) {
+	var req struct {
+		Page          int    `json:"page"`
+		PageSize      int    `json:"page_size"`
+		RtID          int64  `json:"rt_id"`
+		BizId         string `json:"biz_id"`
+		Trigger       string `json:"trigger"`
+		RefreshStatus string `json:"refresh_status"`
+		StartDate     string `json:"start_date"`
+		EndDate       string `json:"end_date"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logger.Error("获取刷新记录 - 参数错误", "error", err)
+		c.JSON(http.StatusBadRequest, APIResponse{
+			Success: false,
+			Msg:     "参数错误: " + err.Error(),
+		})
+		return
+	}
+
+	// 默认值
+	if req.Page == 0 {
+		req.Page = 1
+	}
+	if req.PageSize == 0 {
+		req.PageSize = 20
+	}
+
+	logger.Info("获取刷新记录 - 请求", "page", req.Page, "page_size", req.PageSize, "rt_id", req.RtID, "biz_id", req.BizId, "trigger", req.Trigger, "refresh_status", req.RefreshStatus)
+
+	logs, total, err := h.refreshLogService.List(req.Page, req.PageSize, req.RtID, req.BizId, req.Trigger, req.RefreshStatus, req.StartDate, req.EndDate)
+	if err != nil {
+		logger.Error("获取刷新记录失败", "error", err)
+		c.JSON(http.StatusInternalServerError, APIResponse{
+			Success: false,
+			Msg:     "获取刷新记录失败: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, APIResponse{
+		Success: true,
+		Msg:     "获取成功",
+		Data: gin.H{
+			"items":     logs,
+			"total":     total,
+			"page":      req.Page,
+			"page_size": req.PageSize,
+		},
+	})
+}
+
+// GetRefreshLog 获取刷新记录详情（包含脱敏后的响应内容） - POST /api/rts/refresh-logs/detail
+func (h *RefreshLogHandler) GetRefreshLog(c *gin.Context) {
+	var req struct {
+		ID int64 `json:"id" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logger.Error("获取刷新记录详情 - 参数错误", "error", err)
+		c.JSON(http.StatusBadRequest, APIResponse{
+			Success: false,
+			Msg:     "参数错误: " + err.Error(),
+		})
+		return
+	}
+
+	log, err := h.refreshLogService.GetByID(req.ID)
+	if err != nil {
+		logger.Error("获取刷新记录详情失败", "id", req.ID, "error", err)
+		c.JSON(http.StatusInternalServerError, APIResponse{
+			Success: false,
+			Msg:     "获取刷新记录详情失败: " + err.Error(),
+		})
+		return
+	}
+	if log == nil {
+		c.JSON(http.StatusNotFound, APIResponse{
+			Success: false,
+			Msg:     "刷新记录不存在",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, APIResponse{
+		Success: true,
+		Msg:     "获取成功",
+		Data:    log,
+	})
+}
@@ -1,12 +1,18 @@
 package handler
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
+	"rt-manage/internal/middleware"
 	"rt-manage/internal/model"
 	"rt-manage/internal/service"
 	"rt-manage/pkg/logger"
+	"rt-manage/pkg/secret"
 
 	"github.com/gin-gonic/gin"
 )
@@ -35,14 +41,15 @@ type APIResponse struct {
 // ListRTs 获取RT列表 - POST /api/rts/list
 func (h *RTHandler) ListRTs(c *gin.Context) {
 	var req struct {
-		Page       int    `json:"page"`
-		PageSize   int    `json:"page_size"`
-		BizId      string `json:"biz_id"`
-		Tag        string `json:"tag"`
-		Email      string `json:"email"`
-		Type       string `json:"type"`
-		Enabled    *bool  `json:"enabled"`
-		CreateDate string `json:"create_date"`
+		Page          int    `json:"page"`
+		PageSize      int    `json:"page_size"`
+		BizId         string `json:"biz_id"`
+		Tag           string `json:"tag"`
+		Email         string `json:"email"`
+		Type          string `json:"type"`
+		Enabled       *bool  `json:"enabled"`
+		CreateDate    string `json:"create_date"`
+		RefreshStatus string `json:"refresh_status"`
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -62,9 +69,9 @@ func (h *RTHandler) ListRTs(c *gin.Context) {
 		req.PageSize = 10
 	}
 
-	logger.Info("获取RT列表 - 请求", "page", req.Page, "page_size", req.PageSize, "biz_id", req.BizId, "tag", req.Tag, "email", req.Email, "type", req.Type, "enabled", req.Enabled)
+	logger.Info("获取RT列表 - 请求", "page", req.Page, "page_size", req.PageSize, "biz_id", req.BizId, "tag", req.Tag, "email", req.Email, "type", req.Type, "enabled", req.Enabled, "refresh_status", req.RefreshStatus)
 
-	rts, total, err := h.rtService.List(req.Page, req.PageSize, req.BizId, req.Tag, req.Email, req.Type, req.Enabled, req.CreateDate)
+	rts, total, err := h.rtService.List(req.Page, req.PageSize, req.BizId, req.Tag, req.Email, req.Type, req.Enabled, req.CreateDate, req.RefreshStatus)
 	if err != nil {
 		logger.Error("获取RT列表失败", "error", err)
 		c.JSON(http.StatusInternalServerError, APIResponse{
@@ -80,7 +87,7 @@ func (h *RTHandler) ListRTs(c *gin.Context) {
 		Success: true,
 		Msg:     "获取成功",
 		Data: gin.H{
-			"items":     rts,
+			"items":     maskRTs(rts),
 			"total":     total,
 			"page":      req.Page,
 			"page_size": req.PageSize,
@@ -88,16 +95,141 @@ func (h *RTHandler) ListRTs(c *gin.Context) {
 	})
 }
 
+// GetRT 获取RT详情（token 已脱敏） - POST /api/rts/detail
+func (h *RTHandler) GetRT(c *gin.Context) {
+	var req struct {
+		ID int64 `json:"id" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, APIResponse{
+			Success: false,
+			Msg:     "参数错误: " + err.Error(),
+		})
+		return
+	}
+
+	rt, err := h.rtService.GetByID(req.ID)
+	if err != nil {
+		logger.Error("获取RT详情失败", "id", req.ID, "error", err)
+		c.JSON(http.StatusInternalServerError, APIResponse{
+			Success: false,
+			Msg:     "获取详情失败: " + err.Error(),
+		})
+		return
+	}
+	if rt == nil {
+		c.JSON(http.StatusNotFound, APIResponse{
+			Success: false,
+			Msg:     "RT不存在",
+		})
+		return
+	}
+
+	c.Header("ETag", fmt.Sprintf(`"%d"`, rt.Version))
+	c.JSON(http.StatusOK, APIResponse{
+		Success: true,
+		Msg:     "获取成功",
+		Data:    maskRT(rt),
+	})
+}
+
+// revealFields 允许查看明文的字段
+var revealFields = map[string]bool{"rt": true, "at": true, "id_token": true, "last_rt": true, "refresh_result": true}
+
+// RevealRT 查看明文token（需要权限，每次调用记录审计日志） - POST /api/rts/reveal
+func (h *RTHandler) RevealRT(c *gin.Context) {
+	var req struct {
+		IDs    []int64  `json:"ids" binding:"required"`
+		Fields []string `json:"fields"` // rt、at、id_token、last_rt、refresh_result，默认 rt 和 at
+		Reason string   `json:"reason"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, APIResponse{
+			Success: false,
+			Msg:     "参数错误: " + err.Error(),
+		})
+		return
+	}
+	if len(req.Fields) == 0 {
+		req.Fields = []string{"rt", "at"}
+	}
+	for _, field := range req.Fields {
+		if !revealFields[field] {
+			c.JSON(http.StatusBadRequest, APIResponse{
+				Success: false,
+				Msg:     "不支持的字段: " + field,
+			})
+			return
+		}
+	}
+
+	rts, err := h.rtService.GetByIDs(req.IDs)
+	if err != nil {
+		logger.Error("查看明文Token失败", "ids", req.IDs, "error", err)
+		c.JSON(http.StatusInternalServerError, APIResponse{
+			Success: false,
+			Msg:     "查询失败: " + err.Error(),
+		})
+		return
+	}
+
+	items := make([]gin.H, 0, len(rts))
+	bizIds := make([]string, 0, len(rts))
+	for _, rt := range rts {
+		item := gin.H{"id": rt.ID, "biz_id": rt.BizId}
+		for _, field := range req.Fields {
+			switch field {
+			case "rt":
+				item["rt"] = rt.Rt
+			case "at":
+				item["at"] = rt.At
+			case "id_token":
+				item["id_token"] = rt.IdToken
+			case "last_rt":
+				item["last_rt"] = rt.LastRT
+			case "refresh_result":
+				item["refresh_result"] = rt.RefreshResult
+			}
+		}
+		items = append(items, item)
+		bizIds = append(bizIds, rt.BizId)
+	}
+
+	logger.Warn("审计：查看明文Token",
+		"username", c.GetString("username"),
+		"auth_type", c.GetString("auth_type"),
+		"client_ip", c.ClientIP(),
+		"ids", req.IDs,
+		"biz_ids", bizIds,
+		"fields", req.Fields,
+		"reason", req.Reason,
+	)
+
+	c.JSON(http.StatusOK, APIResponse{
+		Success: true,
+		Msg:     "获取成功",
+		Data: gin.H{
+			"items": items,
+		},
+	})
+}
+
 // CreateRT 创建RT - POST /api/rts/create
+// 仅AT账号传 at 或 session（ChatGPT 会话 JSON），不传 rt_token
 func (h *RTHandler) CreateRT(c *gin.Context) {
 	var req struct {
-		BizId    string `json:"biz_id"`
-		RTToken  string `json:"rt_token" binding:"required"`
-		Proxy    string `json:"proxy"`
-		ClientID string `json:"client_id"`
-		Tag      string `json:"tag"`
-		Enabled  bool   `json:"enabled"`
-		Memo     string `json:"memo"`
+		BizId          string `json:"biz_id"`
+		CredentialType string `json:"credential_type"`
+		RTToken        string `json:"rt_token"`
+		AT             string `json:"at"`
+		Session        string `json:"session"`
+		Proxy          string `json:"proxy"`
+		ClientID       string `json:"client_id"`
+		Tag            string `json:"tag"`
+		Enabled        bool   `json:"enabled"`
+		Memo           string `json:"memo"`
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -109,17 +241,31 @@ func (h *RTHandler) CreateRT(c *gin.Context) {
 		return
 	}
 
-	// 记录请求参数（完整RT Token）
+	// 记录请求参数（RT Token 在日志中脱敏）
 	logger.Info("创建RT - 请求", "biz_id", req.BizId, "rt_token", req.RTToken, "proxy", req.Proxy, "client_id", req.ClientID, "tag", req.Tag, "enabled", req.Enabled)
 
 	rt := &model.RT{
-		BizId:    req.BizId,
-		Rt:       req.RTToken,
-		Proxy:    req.Proxy,
-		ClientID: req.ClientID,
-		Tag:      req.Tag,
-		Enabled:  req.Enabled,
-		Memo:     req.Memo,
+		BizId:          req.BizId,
+		CredentialType: req.CredentialType,
+		Rt:             strings.TrimSpace(req.RTToken),
+		At:             strings.TrimSpace(req.AT),
+		Proxy:          req.Proxy,
+		ClientID:       req.ClientID,
+		Tag:            req.Tag,
+		Enabled:        req.Enabled,
+		Memo:           req.Memo,
+	}
+
+	if req.Session != "" {
+		session, err := service.ParseChatGPTSession([]byte(req.Session))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, APIResponse{
+				Success: false,
+				Msg:     err.Error(),
+			})
+			return
+		}
+		session.Apply(rt)
 	}
 
 	if err := h.rtService.Create(rt); err != nil {
@@ -132,11 +278,13 @@ func (h *RTHandler) CreateRT(c *gin.Context) {
 	}
 
 	logger.Info("创建RT成功", "id", rt.ID, "biz_id", rt.BizId, "rt_token", rt.Rt)
+	middleware.SetAuditTarget(c, model.AuditTargetRT, rt.ID)
+	middleware.SetAuditChanges(c, nil, rt)
 
 	c.JSON(http.StatusOK, APIResponse{
 		Success: true,
 		Msg:     "创建成功",
-		Data:    rt,
+		Data:    maskRT(rt),
 	})
 }
 
@@ -145,6 +293,7 @@ func (h *RTHandler) UpdateRT(c *gin.Context) {
 	var req struct {
 		ID      int64                  `json:"id" binding:"required"`
 		Updates map[string]interface{} `json:"updates" binding:"required"`
+		Version *int64                 `json:"version"` // 可选，期望的版本号（也可通过 If-Match 头传递）
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -156,24 +305,45 @@ func (h *RTHandler) UpdateRT(c *gin.Context) {
 		return
 	}
 
-	logger.Info("更新RT - 请求", "id", req.ID, "updates", req.Updates)
+	// If-Match 优先于请求体中的 version
+	expectedVersion := req.Version
+	if ifMatch := c.GetHeader("If-Match"); ifMatch != "" && ifMatch != "*" {
+		version, err := strconv.ParseInt(strings.Trim(strings.TrimPrefix(ifMatch, "W/"), `"`), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, APIResponse{
+				Success: false,
+				Msg:     "If-Match 格式错误",
+			})
+			return
+		}
+		expectedVersion = &version
+	}
+
+	logger.Info("更新RT - 请求", "id", req.ID, "updates", req.Updates, "expected_version", expectedVersion)
 
-	rt, err := h.rtService.Update(req.ID, req.Updates)
+	before, _ := h.rtService.GetByID(req.ID)
+	rt, err := h.rtService.Update(req.ID, req.Updates, expectedVersion)
 	if err != nil {
 		logger.Error("更新RT失败", "id", req.ID, "error", err)
-		c.JSON(http.StatusBadRequest, APIResponse{
+		status := http.StatusBadRequest
+		if errors.Is(err, service.ErrVersionConflict) {
+			status = http.StatusConflict
+		}
+		c.JSON(status, APIResponse{
 			Success: false,
 			Msg:     err.Error(),
 		})
 		return
 	}
 
-	logger.Info("更新RT成功", "id", req.ID, "name", rt.BizId)
+	logger.Info("更新RT成功", "id", req.ID, "name", rt.BizId, "version", rt.Version)
+	middleware.SetAuditChanges(c, before, rt)
 
+	c.Header("ETag", fmt.Sprintf(`"%d"`, rt.Version))
 	c.JSON(http.StatusOK, APIResponse{
 		Success: true,
 		Msg:     "更新成功",
-		Data:    rt,
+		Data:    maskRT(rt),
 	})
 }
 
@@ -194,6 +364,7 @@ func (h *RTHandler) DeleteRT(c *gin.Context) {
 
 	logger.Info("删除RT - 请求", "id", req.ID)
 
+	before, _ := h.rtService.GetByID(req.ID)
 	if err := h.rtService.Delete(req.ID); err != nil {
 		logger.Error("删除RT失败", "id", req.ID, "error", err)
 		c.JSON(http.StatusInternalServerError, APIResponse{
@@ -204,6 +375,9 @@ func (h *RTHandler) DeleteRT(c *gin.Context) {
 	}
 
 	logger.Info("删除RT成功", "id", req.ID)
+	if before != nil {
+		middleware.SetAuditChanges(c, before, nil)
+	}
 
 	c.JSON(http.StatusOK, APIResponse{
 		Success: true,
@@ -228,9 +402,11 @@ func (h *RTHandler) BatchDeleteRTs(c *gin.Context) {
 
 	logger.Info("批量删除RT - 请求", "ids", req.IDs, "count", len(req.IDs))
 
+	befores := h.auditSnapshot(req.IDs)
 	successCount, failCount, err := h.rtService.BatchDelete(req.IDs)
 
 	logger.Info("批量删除RT完成", "success_count", successCount, "fail_count", failCount, "error", err)
+	auditRTBatch(c, befores, h.auditSnapshot(req.IDs))
 
 	c.JSON(http.StatusOK, APIResponse{
 		Success: true,
@@ -262,7 +438,7 @@ func (h *RTHandler) RefreshRT(c *gin.Context) {
 
 	logger.Info("刷新RT - 请求", "id", req.ID, "refresh_user_info", req.RefreshUserInfo, "refresh_account_info", req.RefreshAccountInfo)
 
-	rt, err := h.rtService.Refresh(req.ID, req.RefreshUserInfo, req.RefreshAccountInfo)
+	rt, err := h.rtService.Refresh(req.ID, req.RefreshUserInfo, req.RefreshAccountInfo, model.RefreshTriggerManual)
 	if err != nil {
 		logger.Error("刷新RT失败", "id", req.ID, "error", err)
 		c.JSON(http.StatusInternalServerError, APIResponse{
@@ -277,7 +453,7 @@ func (h *RTHandler) RefreshRT(c *gin.Context) {
 	c.JSON(http.StatusOK, APIResponse{
 		Success: true,
 		Msg:     "刷新成功",
-		Data:    rt,
+		Data:    maskRT(rt),
 	})
 }
 
@@ -298,7 +474,9 @@ func (h *RTHandler) BatchRefreshRTs(c *gin.Context) {
 
 	logger.Info("批量刷新RT - 请求", "ids", req.IDs, "count", len(req.IDs))
 
-	successCount, failCount, results, err := h.rtService.BatchRefresh(req.IDs)
+	befores := h.auditSnapshot(req.IDs)
+	successCount, failCount, results, err := h.rtService.BatchRefresh(req.IDs, model.RefreshTriggerBatch)
+	auditRTBatch(c, befores, h.auditSnapshot(req.IDs))
 	if err != nil {
 		logger.Error("批量刷新RT失败", "ids", req.IDs, "error", err)
 		c.JSON(http.StatusInternalServerError, APIResponse{
@@ -356,7 +534,8 @@ func (h *RTHandler) BatchImportRTs(c *gin.Context) {
 	proxyList, _ := h.configService.GetProxyList()
 	clientIdList, _ := h.configService.GetClientIdList()
 
-	successCount, failCount, err := h.rtService.BatchImport(req.BatchName, req.Tag, req.Proxy, req.ClientID, req.RTTokens, proxyList, clientIdList)
+	successCount, failCount, created, err := h.rtService.BatchImport(req.BatchName, req.Tag, req.Proxy, req.ClientID, req.RTTokens, proxyList, clientIdList)
+	auditRTBatch(c, nil, created)
 	if err != nil {
 		logger.Error("批量导入RT失败", "batch_name", req.BatchName, "tag", req.Tag, "token_count", len(req.RTTokens), "error", err)
 		c.JSON(http.StatusInternalServerError, APIResponse{
@@ -379,6 +558,426 @@ func (h *RTHandler) BatchImportRTs(c *gin.Context) {
 	})
 }
 
+// auditSnapshot 批量操作前后查询RT，用于记录审计内容；查询失败时不记录修改内容
+func (h *RTHandler) auditSnapshot(ids []int64) []*model.RT {
+	if len(ids) == 0 {
+		return nil
+	}
+	rts, err := h.rtService.GetByIDs(ids)
+	if err != nil {
+		logger.Error("查询RT审计快照失败", "ids", ids, "error", err)
+		return nil
+	}
+	return rts
+}
+
+// auditRTBatch 记录批量操作影响的RT：操作对象为这些RT的ID，修改内容按RT分别记录业务ID和修改前后内容（token 已脱敏）
+// befores 为操作前存在的RT，afters 为操作后存在的RT；只在 befores 中的视为删除，只在 afters 中的视为新建
+func auditRTBatch(c *gin.Context, befores, afters []*model.RT) {
+	afterByID := make(map[int64]*model.RT, len(afters))
+	for _, rt := range afters {
+		afterByID[rt.ID] = rt
+	}
+
+	ids := make([]interface{}, 0, len(befores)+len(afters))
+	items := make([]service.AuditBatchItem, 0, len(befores)+len(afters))
+	for _, before := range befores {
+		item := service.AuditBatchItem{ID: before.ID, BizId: before.BizId, Before: before}
+		if after, ok := afterByID[before.ID]; ok {
+			item.After = after
+			delete(afterByID, before.ID)
+		}
+		ids = append(ids, before.ID)
+		items = append(items, item)
+	}
+	for _, after := range afters {
+		if _, ok := afterByID[after.ID]; !ok {
+			continue
+		}
+		ids = append(ids, after.ID)
+		items = append(items, service.AuditBatchItem{ID: after.ID, BizId: after.BizId, After: after})
+	}
+	if len(items) == 0 {
+		return
+	}
+
+	middleware.SetAuditTarget(c, model.AuditTargetRT, ids...)
+	middleware.SetAuditBatchChanges(c, items)
+}
+
+// maxImportFileSize 导入文件大小上限
+const maxImportFileSize = 20 << 20
+
+// ImportRTs 从文件导入RT（multipart/form-data） - POST /api/rts/import
+// 表单字段：file（csv、jsonl 或每行一个RT的文本文件）、format（默认按扩展名判断）、
+// tag、proxy、client_id、enabled（文件中未填写时使用的默认值）
+func (h *RTHandler) ImportRTs(c *gin.Context) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxImportFileSize)
+
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, APIResponse{
+			Success: false,
+			Msg:     "参数错误: 请上传导入文件（" + err.Error() + "）",
+		})
+		return
+	}
+
+	format := strings.ToLower(strings.TrimSpace(c.PostForm("format")))
+	if format == "" {
+		format = service.DetectImportFormat(fileHeader.Filename)
+	}
+	defaults := service.ImportDefaults{
+		Tag:      strings.TrimSpace(c.PostForm("tag")),
+		Proxy:    strings.TrimSpace(c.PostForm("proxy")),
+		ClientID: strings.TrimSpace(c.PostForm("client_id")),
+	}
+	if enabled := c.PostForm("enabled"); enabled != "" {
+		defaults.Enabled, err = strconv.ParseBool(enabled)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, APIResponse{
+				Success: false,
+				Msg:     "参数错误: enabled 值无效",
+			})
+			return
+		}
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, APIResponse{
+			Success: false,
+			Msg:     "读取导入文件失败: " + err.Error(),
+		})
+		return
+	}
+	defer file.Close()
+
+	rows, err := service.ParseImportFile(format, file)
+	if err != nil {
+		logger.Error("文件导入RT - 解析失败", "filename", fileHeader.Filename, "format", format, "error", err)
+		c.JSON(http.StatusBadRequest, APIResponse{
+			Success: false,
+			Msg:     "解析导入文件失败: " + err.Error(),
+		})
+		return
+	}
+
+	logger.Info("文件导入RT - 请求", "filename", fileHeader.Filename, "format", format, "row_count", len(rows), "tag", defaults.Tag, "proxy", defaults.Proxy, "client_id", defaults.ClientID, "enabled", defaults.Enabled)
+
+	proxyList, _ := h.configService.GetProxyList()
+	clientIdList, _ := h.configService.GetClientIdList()
+
+	results := h.rtService.Import(rows, defaults, proxyList, clientIdList)
+
+	counts := map[string]int{}
+	for _, result := range results {
+		counts[result.Status]++
+	}
+
+	logger.Info("文件导入RT完成", "filename", fileHeader.Filename, "total_count", len(results), "created_count", counts[service.ImportStatusCreated], "duplicate_count", counts[service.ImportStatusDuplicate], "invalid_count", counts[service.ImportStatusInvalid])
+
+	c.JSON(http.StatusOK, APIResponse{
+		Success: true,
+		Msg:     fmt.Sprintf("导入完成: 成功 %d 个, 重复 %d 个, 无效 %d 个", counts[service.ImportStatusCreated], counts[service.ImportStatusDuplicate], counts[service.ImportStatusInvalid]),
+		Data: gin.H{
+			"total_count":     len(results),
+			"created_count":   counts[service.ImportStatusCreated],
+			"duplicate_count": counts[service.ImportStatusDuplicate],
+			"invalid_count":   counts[service.ImportStatusInvalid],
+			"results":         results,
+		},
+	})
+}
+
+// exportContentTypes 导出格式对应的 Content-Type
+var exportContentTypes = map[string]string{
+	service.ExportFormatCSV:   "text/csv; charset=utf-8",
+	service.ExportFormatJSON:  "application/json; charset=utf-8",
+	service.ExportFormatJSONL: "application/x-ndjson; charset=utf-8",
+}
+
+// ExportRTs 按筛选条件导出RT（流式返回文件） - POST /api/rts/export
+// tokens 为 include 时导出明文 token，需要查看明文的权限并记录审计日志
+func (h *RTHandler) ExportRTs(c *gin.Context) {
+	var req struct {
+		Format        string   `json:"format"`  // csv、json、jsonl，默认 csv
+		Columns       []string `json:"columns"` // 导出的列，默认包含导入所需的全部字段
+		Tokens        string   `json:"tokens"`  // include、mask、exclude，默认 mask
+		BizId         string   `json:"biz_id"`
+		Tag           string   `json:"tag"`
+		Email         string   `json:"email"`
+		Type          string   `json:"type"`
+		Enabled       *bool    `json:"enabled"`
+		CreateDate    string   `json:"create_date"`
+		RefreshStatus string   `json:"refresh_status"`
+		Reason        string   `json:"reason"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, APIResponse{
+			Success: false,
+			Msg:     "参数错误: " + err.Error(),
+		})
+		return
+	}
+
+	opts := &service.ExportOptions{
+		Format:        req.Format,
+		Columns:       req.Columns,
+		Tokens:        req.Tokens,
+		BizId:         req.BizId,
+		Tag:           req.Tag,
+		Email:         req.Email,
+		Type:          req.Type,
+		Enabled:       req.Enabled,
+		CreateDate:    req.CreateDate,
+		RefreshStatus: req.RefreshStatus,
+	}
+	if err := opts.Normalize(); err != nil {
+		c.JSON(http.StatusBadRequest, APIResponse{
+			Success: false,
+			Msg:     "参数错误: " + err.Error(),
+		})
+		return
+	}
+
+	if opts.IncludesTokens() {
+		if !middleware.CanReveal(c) {
+			c.JSON(http.StatusForbidden, APIResponse{
+				Success: false,
+				Msg:     "没有导出明文Token的权限",
+			})
+			return
+		}
+		logger.Warn("审计：导出明文Token",
+			"username", c.GetString("username"),
+			"auth_type", c.GetString("auth_type"),
+			"client_ip", c.ClientIP(),
+			"columns", opts.Columns,
+			"biz_id", req.BizId,
+			"tag", req.Tag,
+			"email", req.Email,
+			"type", req.Type,
+			"enabled", req.Enabled,
+			"create_date", req.CreateDate,
+			"refresh_status", req.RefreshStatus,
+			"reason", req.Reason,
+		)
+	}
+
+	filename := fmt.Sprintf("rts-%s.%s", time.Now().Format("20060102-150405"), opts.Format)
+	c.Header("Content-Type", exportContentTypes[opts.Format])
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	c.Status(http.StatusOK)
+
+	count, err := h.rtService.Export(c.Writer, opts)
+	if err != nil {
+		// 响应头已发送，只能中断输出
+		logger.Error("导出RT失败", "format", opts.Format, "exported_count", count, "error", err)
+		return
+	}
+	logger.Info("导出RT完成", "format", opts.Format, "tokens", opts.Tokens, "columns", opts.Columns, "count", count, "username", c.GetString("username"))
+}
+
+// ExportCodexAuth 导出 Codex 可直接使用的 auth.json（需要查看明文的权限，记录审计日志） - POST /api/rts/codex-auth
+// 按 id、biz_id 或 email 查找RT，refresh 为 true 时先刷新
+func (h *RTHandler) ExportCodexAuth(c *gin.Context) {
+	var req struct {
+		ID      int64  `json:"id"`
+		BizId   string `json:"biz_id"`
+		Email   string `json:"email"`
+		Refresh bool   `json:"refresh"`
+		Reason  string `json:"reason"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, APIResponse{
+			Success: false,
+			Msg:     "参数错误: " + err.Error(),
+		})
+		return
+	}
+	if req.ID == 0 && req.BizId == "" && req.Email == "" {
+		c.JSON(http.StatusBadRequest, APIResponse{
+			Success: false,
+			Msg:     "id、biz_id 和 email 至少提供一个",
+		})
+		return
+	}
+
+	var rt *model.RT
+	var err error
+	if req.ID != 0 {
+		rt, err = h.rtService.GetByID(req.ID)
+	} else {
+		rt, err = findRT(h.rtService, req.BizId, req.Email)
+	}
+	if err != nil {
+		logger.Error("导出auth.json - 查找RT失败", "id", req.ID, "biz_id", req.BizId, "email", req.Email, "error", err)
+		c.JSON(http.StatusInternalServerError, APIResponse{
+			Success: false,
+			Msg:     "查询失败: " + err.Error(),
+		})
+		return
+	}
+	if rt == nil {
+		c.JSON(http.StatusNotFound, APIResponse{
+			Success: false,
+			Msg:     "RT不存在",
+		})
+		return
+	}
+
+	logger.Warn("审计：导出auth.json",
+		"username", c.GetString("username"),
+		"auth_type", c.GetString("auth_type"),
+		"client_ip", c.ClientIP(),
+		"id", rt.ID,
+		"biz_id", rt.BizId,
+		"refresh", req.Refresh,
+		"reason", req.Reason,
+	)
+
+	writeCodexAuth(c, h.rtService, rt, req.Refresh, model.RefreshTriggerManual)
+}
+
+// findRT 按 biz_id 或 email 查找RT，优先使用 biz_id
+func findRT(rtService service.RTService, bizId, email string) (*model.RT, error) {
+	if bizId != "" {
+		return rtService.GetByBizId(bizId)
+	}
+	return rtService.GetByEmail(email)
+}
+
+// refreshFailureData 刷新失败时返回给调用方的信息，只包含分类后的刷新状态和上游错误码，不返回上游原始响应
+func refreshFailureData(rt *model.RT, err error) gin.H {
+	data := gin.H{
+		"refresh_status": rt.RefreshStatus,
+		"error_code":     "",
+	}
+	var refreshErr *service.RefreshError
+	if errors.As(err, &refreshErr) {
+		data["refresh_status"] = refreshErr.Status
+		data["error_code"] = refreshErr.Code
+	}
+	return data
+}
+
+// writeCodexAuth 返回 auth.json 文件内容，refresh 为 true 时先刷新RT
+func writeCodexAuth(c *gin.Context, rtService service.RTService, rt *model.RT, refresh bool, trigger string) {
+	if refresh {
+		refreshed, err := rtService.Refresh(rt.ID, false, false, trigger)
+		if err != nil {
+			logger.Error("导出auth.json - 刷新失败", "id", rt.ID, "biz_id", rt.BizId, "error", err)
+			if refreshed != nil {
+				rt = refreshed
+			}
+			c.JSON(http.StatusInternalServerError, APIResponse{
+				Success: false,
+				Msg:     "刷新失败",
+				Data:    refreshFailureData(rt, err),
+			})
+			return
+		}
+		rt = refreshed
+	}
+
+	auth, err := service.BuildCodexAuth(rt)
+	if err != nil {
+		c.JSON(http.StatusConflict, APIResponse{
+			Success: false,
+			Msg:     err.Error(),
+		})
+		return
+	}
+
+	c.Header("Content-Disposition", `attachment; filename="auth.json"`)
+	c.IndentedJSON(http.StatusOK, auth)
+}
+
+// StartOnboarding 发起授权码登录（PKCE），返回授权地址 - POST /api/rts/onboarding/start
+func (h *RTHandler) StartOnboarding(c *gin.Context) {
+	var req struct {
+		BizId    string `json:"biz_id"`
+		ClientID string `json:"client_id"`
+		Proxy    string `json:"proxy"`
+		Tag      string `json:"tag"`
+		Memo     string `json:"memo"`
+		Enabled  bool   `json:"enabled"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, APIResponse{
+			Success: false,
+			Msg:     "参数错误: " + err.Error(),
+		})
+		return
+	}
+
+	proxyList, _ := h.configService.GetProxyList()
+	clientIdList, _ := h.configService.GetClientIdList()
+
+	start, err := h.rtService.StartOnboarding(service.OnboardingOptions{
+		BizId:    strings.TrimSpace(req.BizId),
+		ClientID: strings.TrimSpace(req.ClientID),
+		Proxy:    strings.TrimSpace(req.Proxy),
+		Tag:      req.Tag,
+		Memo:     req.Memo,
+		Enabled:  req.Enabled,
+	}, proxyList, clientIdList)
+	if err != nil {
+		logger.Error("发起授权登录失败", "biz_id", req.BizId, "client_id", req.ClientID, "error", err)
+		c.JSON(http.StatusBadRequest, APIResponse{
+			Success: false,
+			Msg:     "发起授权失败: " + err.Error(),
+		})
+		return
+	}
+
+	logger.Info("发起授权登录", "username", c.GetString("username"), "biz_id", req.BizId, "client_id", start.ClientID)
+
+	c.JSON(http.StatusOK, APIResponse{
+		Success: true,
+		Msg:     "请在浏览器中打开授权地址完成登录",
+		Data:    start,
+	})
+}
+
+// CompleteOnboarding 提交授权回调（授权码或完整回调地址），兑换 token 并创建RT - POST /api/rts/onboarding/complete
+func (h *RTHandler) CompleteOnboarding(c *gin.Context) {
+	var req struct {
+		State    string `json:"state"`
+		Callback string `json:"callback" binding:"required"` // 授权码或完整回调地址
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, APIResponse{
+			Success: false,
+			Msg:     "参数错误: " + err.Error(),
+		})
+		return
+	}
+
+	rt, err := h.rtService.CompleteOnboarding(strings.TrimSpace(req.State), req.Callback)
+	if err != nil {
+		logger.Error("完成授权登录失败", "error", err)
+		c.JSON(http.StatusBadRequest, APIResponse{
+			Success: false,
+			Msg:     err.Error(),
+		})
+		return
+	}
+	middleware.SetAuditTarget(c, model.AuditTargetRT, rt.ID)
+	middleware.SetAuditChanges(c, nil, rt)
+
+	c.JSON(http.StatusOK, APIResponse{
+		Success: true,
+		Msg:     "授权成功，已创建RT",
+		Data:    maskRT(rt),
+	})
+}
+
 // RefreshUserInfo 刷新用户信息 - POST /api/rts/refresh-user-info
 func (h *RTHandler) RefreshUserInfo(c *gin.Context) {
 	var req struct {
@@ -411,7 +1010,7 @@ func (h *RTHandler) RefreshUserInfo(c *gin.Context) {
 	c.JSON(http.StatusOK, APIResponse{
 		Success: true,
 		Msg:     "刷新用户信息成功",
-		Data:    rt,
+		Data:    maskRT(rt),
 	})
 }
 
@@ -447,6 +1046,146 @@ func (h *RTHandler) RefreshAccountInfo(c *gin.Context) {
 	c.JSON(http.StatusOK, APIResponse{
 		Success: true,
 		Msg:     "刷新账号信息成功",
-		Data:    rt,
+		Data:    maskRT(rt),
+	})
+}
+
+// lineageItem token 谱系记录（token 已脱敏），current 表示是否为RT当前使用的 token
+type lineageItem struct {
+	*model.TokenLineage
+	Current bool `json:"current"`
+}
+
+// ListLineage 获取RT的 token 谱系 - POST /api/rts/lineage/list
+func (h *RTHandler) ListLineage(c *gin.Context) {
+	var req struct {
+		ID int64 `json:"id" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, APIResponse{
+			Success: false,
+			Msg:     "参数错误: " + err.Error(),
+		})
+		return
+	}
+
+	rt, err := h.rtService.GetByID(req.ID)
+	if err == nil && rt == nil {
+		c.JSON(http.StatusNotFound, APIResponse{
+			Success: false,
+			Msg:     "RT不存在",
+		})
+		return
+	}
+	var lineages []*model.TokenLineage
+	if err == nil {
+		lineages, err = h.rtService.ListLineage(req.ID)
+	}
+	if err != nil {
+		logger.Error("获取token谱系失败", "id", req.ID, "error", err)
+		c.JSON(http.StatusInternalServerError, APIResponse{
+			Success: false,
+			Msg:     "获取token谱系失败: " + err.Error(),
+		})
+		return
+	}
+
+	items := make([]lineageItem, 0, len(lineages))
+	current := false
+	for _, lineage := range lineages {
+		// 谱系按时间倒序，第一条与当前 token 一致的记录即为当前 token
+		isCurrent := !current && lineage.Rt == rt.Rt && lineage.At == rt.At
+		current = current || isCurrent
+
+		masked := *lineage
+		masked.Rt = secret.Mask(lineage.Rt)
+		masked.At = secret.Mask(lineage.At)
+		items = append(items, lineageItem{TokenLineage: &masked, Current: isCurrent})
+	}
+
+	c.JSON(http.StatusOK, APIResponse{
+		Success: true,
+		Msg:     "获取成功",
+		Data: gin.H{
+			"items": items,
+		},
+	})
+}
+
+// PromoteLineage 将谱系中较早的 token 恢复为当前 token - POST /api/rts/lineage/promote
+// 恢复后不会自动刷新（历史RT可能已被使用，刷新会导致整组token被撤销），refresh 为 true 时恢复后立即刷新一次验证
+func (h *RTHandler) PromoteLineage(c *gin.Context) {
+	var req struct {
+		ID        int64  `json:"id" binding:"required"`
+		LineageID int64  `json:"lineage_id" binding:"required"`
+		Refresh   bool   `json:"refresh"`
+		Reason    string `json:"reason"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, APIResponse{
+			Success: false,
+			Msg:     "参数错误: " + err.Error(),
+		})
+		return
+	}
+
+	username := c.GetString("username")
+	before, _ := h.rtService.GetByID(req.ID)
+	rt, err := h.rtService.PromoteLineage(req.ID, req.LineageID, username)
+
+	// 审计日志在恢复完成后记录，包含执行结果
+	auditErr := ""
+	if err != nil {
+		auditErr = err.Error()
+	}
+	logger.Warn("审计：恢复历史Token",
+		"username", username,
+		"auth_type", c.GetString("auth_type"),
+		"client_ip", c.ClientIP(),
+		"id", req.ID,
+		"lineage_id", req.LineageID,
+		"refresh", req.Refresh,
+		"reason", req.Reason,
+		"success", err == nil,
+		"error", auditErr,
+	)
+	if err != nil {
+		logger.Error("恢复历史Token失败", "id", req.ID, "lineage_id", req.LineageID, "error", err)
+		status := http.StatusBadRequest
+		if errors.Is(err, service.ErrVersionConflict) || errors.Is(err, service.ErrRotationInProgress) {
+			status = http.StatusConflict
+		}
+		c.JSON(status, APIResponse{
+			Success: false,
+			Msg:     "恢复失败: " + err.Error(),
+		})
+		return
+	}
+	middleware.SetAuditChanges(c, before, rt)
+
+	if req.Refresh {
+		refreshed, err := h.rtService.Refresh(req.ID, false, false, model.RefreshTriggerManual)
+		if err != nil {
+			logger.Warn("恢复历史Token后刷新失败", "id", req.ID, "lineage_id", req.LineageID, "error", err)
+			if refreshed == nil {
+				refreshed = rt
+			}
+			c.JSON(http.StatusOK, APIResponse{
+				Success: false,
+				Msg:     "已恢复，但" + err.Error(),
+				Data:    maskRT(refreshed),
+			})
+			return
+		}
+		rt = refreshed
+	}
+
+	c.Header("ETag", fmt.Sprintf(`"%d"`, rt.Version))
+	c.JSON(http.StatusOK, APIResponse{
+		Success: true,
+		Msg:     "恢复成功",
+		Data:    maskRT(rt),
 	})
 }
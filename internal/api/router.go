@@ -6,6 +6,7 @@ import (
 	"rt-manage/internal/config"
 	"rt-manage/internal/database"
 	"rt-manage/internal/middleware"
+	"rt-manage/internal/model"
 	"rt-manage/internal/repository"
 	"rt-manage/internal/service"
 	"rt-manage/internal/web"
@@ -31,18 +32,37 @@ func NewRouter() *gin.Engine {
 	db := database.GetDB()
 	rtRepo := repository.NewRTRepository(db)
 	configRepo := repository.NewConfigRepository(db)
+	refreshLogRepo := repository.NewRefreshLogRepository(db)
+	rotationRepo := repository.NewTokenRotationRepository(db)
+	lineageRepo := repository.NewTokenLineageRepository(db)
+	leaseRepo := repository.NewLeaseRepository(db)
+	apiKeyRepo := repository.NewAPIKeyRepository(db)
+	adminUserRepo := repository.NewAdminUserRepository(db)
+	auditEventRepo := repository.NewAuditEventRepository(db)
+	adminSessionRepo := repository.NewAdminSessionRepository(db)
 
 	// 初始化服务
-	rtService := service.NewRTService(rtRepo, configRepo)
+	rtService := service.NewRTService(rtRepo, configRepo, refreshLogRepo, rotationRepo, lineageRepo)
 	configService := service.NewConfigService(configRepo, rtRepo)
+	refreshLogService := service.NewRefreshLogService(refreshLogRepo)
+	leaseService := service.NewLeaseService(leaseRepo, rtRepo)
+	apiKeyService := service.NewAPIKeyService(apiKeyRepo)
+	adminUserService := service.NewAdminUserService(adminUserRepo, adminSessionRepo)
+	adminSessionService := service.NewAdminSessionService(adminSessionRepo, adminUserRepo)
+	auditService := service.NewAuditService(auditEventRepo)
 
 	// 初始化处理器
 	rtHandler := handler.NewRTHandler(rtService, configService)
 	configHandler := handler.NewConfigHandler(configService)
-	authHandler := handler.NewAuthHandler()
-	publicAPIHandler := handler.NewPublicAPIHandler(rtService)
-
-	// 对外公开API路由组（使用API Secret认证）
+	refreshLogHandler := handler.NewRefreshLogHandler(refreshLogService)
+	leaseHandler := handler.NewLeaseHandler(leaseService)
+	apiKeyHandler := handler.NewAPIKeyHandler(apiKeyService)
+	adminUserHandler := handler.NewAdminUserHandler(adminUserService, adminSessionService)
+	auditHandler := handler.NewAuditHandler(auditService)
+	authHandler := handler.NewAuthHandler(adminUserService, adminSessionService)
+	publicAPIHandler := handler.NewPublicAPIHandler(rtService, leaseService)
+
+	// 对外公开API路由组（使用API密钥认证）
 	// 从配置文件读取路由前缀，默认为 "/public-api"
 	publicAPIPrefix := config.Get().Auth.PublicAPIPrefix
 	if publicAPIPrefix == "" {
@@ -56,54 +76,132 @@ func NewRouter() *gin.Engine {
 	}
 	
 	publicAPI := r.Group(publicAPIPrefix)
-	publicAPI.Use(middleware.APISecret())
+	publicAPI.Use(middleware.APIKeyAuth(apiKeyService, publicAPIPrefix))
+	publicAPI.Use(middleware.RateLimit())
+	publicAPI.Use(middleware.Audit(auditService, publicAPIPrefix)) // 记录所有对外接口调用（不含健康检查）
 	{
 		publicAPI.GET("/health", handler.Health)                          // 健康检查
 		publicAPI.POST("/refresh", publicAPIHandler.RefreshAndGetAT)      // 刷新RT并获取AT
 		publicAPI.POST("/get-at", publicAPIHandler.GetAT)                 // 获取AT（不刷新）
+		publicAPI.POST("/codex-auth", publicAPIHandler.GetCodexAuth)      // 获取 Codex auth.json
+		publicAPI.POST("/lease", publicAPIHandler.Lease)                  // 从账号池租用AT
+		publicAPI.POST("/release", publicAPIHandler.Release)              // 归还租用的AT
+		publicAPI.POST("/report", publicAPIHandler.Report)                // 反馈AT失效或被限流
 	}
 	
 	logger.Info("对外API路由前缀", "prefix", publicAPIPrefix)
 
 	// API路由组（内部管理使用）
 	api := r.Group("/internalweb/v1")
+	// 审计：写操作和查看明文token的操作（放在角色校验之前，被拒绝的操作也会记录）
+	audit := middleware.Audit(auditService, "/internalweb/v1")
 	{
 		// 认证路由（不需要JWT验证）
 		auth := api.Group("/auth")
 		{
-			auth.POST("/login", authHandler.Login)  // 登录
+			auth.POST("/login", audit, authHandler.Login)  // 登录
+			auth.POST("/refresh", authHandler.Refresh)     // 用刷新令牌换取新令牌（刷新令牌随之轮换）
 		}
 
 		// 需要JWT认证的路由（全部使用POST + JSON Body）
+		// 登录用户至少为 viewer（只读），写操作需要 operator，系统配置、API密钥、账号管理需要 admin
 		authorized := api.Group("")
-		authorized.Use(middleware.JWTAuth())
+		authorized.Use(middleware.JWTAuth(adminSessionService))
+		operator := middleware.RequireRole(model.RoleOperator)
+		admin := middleware.RequireRole(model.RoleAdmin)
 		{
+			// 退出登录
+			authSession := authorized.Group("/auth")
+			{
+				authSession.POST("/logout", audit, authHandler.Logout)         // 退出登录（注销当前会话）
+				authSession.POST("/logout-all", audit, authHandler.LogoutAll)  // 退出所有设备
+			}
+
 			// 用户信息
 			user := authorized.Group("/user")
 			{
 				user.POST("/info", authHandler.GetCurrentUser)  // 获取当前用户
+				user.POST("/change-password", audit, authHandler.ChangePassword) // 修改自己的密码（同时注销其他会话）
+				user.POST("/sessions/list", authHandler.ListSessions)           // 自己的有效会话
+				user.POST("/sessions/revoke", audit, authHandler.RevokeSession) // 注销自己的某个会话
+			}
+
+			// 管理后台账号路由
+			users := authorized.Group("/users")
+			{
+				users.POST("/list", admin, adminUserHandler.ListUsers)           // 账号列表
+				users.POST("/create", audit, admin, adminUserHandler.CreateUser) // 创建账号
+				users.POST("/update", audit, admin, adminUserHandler.UpdateUser) // 修改角色、启用状态或重置密码
+				users.POST("/delete", audit, admin, adminUserHandler.DeleteUser) // 删除账号
+			}
+
+			// 登录会话管理路由
+			sessions := authorized.Group("/sessions")
+			{
+				sessions.POST("/list", admin, adminUserHandler.ListSessions)             // 会话列表
+				sessions.POST("/revoke", audit, admin, adminUserHandler.RevokeSessions)  // 注销单个会话或某个账号的全部会话
+			}
+
+			// 审计事件路由
+			auditEvents := authorized.Group("/audit-events", admin)
+			{
+				auditEvents.POST("/list", auditHandler.ListAuditEvents) // 审计事件列表
 			}
 
 			// RT管理路由
 			rts := authorized.Group("/rts")
 			{
 				rts.POST("/list", rtHandler.ListRTs)                // 获取列表
-				rts.POST("/create", rtHandler.CreateRT)             // 创建RT
-				rts.POST("/update", rtHandler.UpdateRT)             // 更新RT
-				rts.POST("/delete", rtHandler.DeleteRT)             // 删除RT
-				rts.POST("/batch-delete", rtHandler.BatchDeleteRTs) // 批量删除
-			rts.POST("/batch-refresh", rtHandler.BatchRefreshRTs) // 批量刷新
-			rts.POST("/batch-import", rtHandler.BatchImportRTs) // 批量导入
-			rts.POST("/refresh", rtHandler.RefreshRT)           // 单个刷新
-			rts.POST("/refresh-user-info", rtHandler.RefreshUserInfo)       // 刷新用户信息
-			rts.POST("/refresh-account-info", rtHandler.RefreshAccountInfo) // 刷新账号信息
+				rts.POST("/detail", rtHandler.GetRT)                // 获取详情（token 已脱敏）
+				rts.POST("/reveal", audit, middleware.RevealPermission(), rtHandler.RevealRT) // 查看明文token（需权限，记录审计日志）
+				rts.POST("/create", audit, operator, rtHandler.CreateRT)             // 创建RT
+				rts.POST("/update", audit, operator, rtHandler.UpdateRT)             // 更新RT
+				rts.POST("/delete", audit, operator, rtHandler.DeleteRT)             // 删除RT
+				rts.POST("/batch-delete", audit, operator, rtHandler.BatchDeleteRTs) // 批量删除
+			rts.POST("/batch-refresh", audit, operator, rtHandler.BatchRefreshRTs) // 批量刷新
+			rts.POST("/batch-import", audit, operator, rtHandler.BatchImportRTs) // 批量导入
+			rts.POST("/import", audit, operator, rtHandler.ImportRTs)            // 从文件导入（csv、jsonl、文本）
+			rts.POST("/export", audit, rtHandler.ExportRTs)            // 导出（csv、json、jsonl，导出明文token需权限）
+			rts.POST("/codex-auth", audit, middleware.RevealPermission(), rtHandler.ExportCodexAuth) // 导出 Codex auth.json（需权限，记录审计日志）
+			rts.POST("/refresh", audit, operator, rtHandler.RefreshRT)           // 单个刷新
+			rts.POST("/refresh-user-info", audit, operator, rtHandler.RefreshUserInfo)       // 刷新用户信息
+			rts.POST("/refresh-account-info", audit, operator, rtHandler.RefreshAccountInfo) // 刷新账号信息
+			rts.POST("/refresh-logs/list", refreshLogHandler.ListRefreshLogs) // 刷新记录列表
+			rts.POST("/refresh-logs/detail", refreshLogHandler.GetRefreshLog) // 刷新记录详情
+			rts.POST("/onboarding/start", operator, rtHandler.StartOnboarding)          // 发起授权码登录（PKCE）
+			rts.POST("/onboarding/complete", audit, operator, rtHandler.CompleteOnboarding)    // 提交授权回调并创建RT
+			rts.POST("/lineage/list", rtHandler.ListLineage)                  // token 谱系
+			rts.POST("/lineage/promote", audit, operator, rtHandler.PromoteLineage)            // 恢复历史token（记录审计日志）
 		}
 
+			// 租约管理路由
+			leases := authorized.Group("/leases")
+			{
+				leases.POST("/list", leaseHandler.ListLeases)       // 租约列表
+				leases.POST("/release", audit, operator, leaseHandler.ReleaseLease)  // 强制释放租约
+			}
+
+			// 对外API密钥管理路由
+			apiKeys := authorized.Group("/api-keys")
+			{
+				apiKeys.POST("/list", admin, apiKeyHandler.ListAPIKeys)           // 密钥列表
+				apiKeys.POST("/create", audit, admin, apiKeyHandler.CreateAPIKey) // 创建密钥（明文只返回一次）
+				apiKeys.POST("/update", audit, admin, apiKeyHandler.UpdateAPIKey) // 更新授权范围、启用状态、过期时间
+				apiKeys.POST("/delete", audit, admin, apiKeyHandler.DeleteAPIKey) // 删除密钥
+				apiKeys.POST("/reset-signing-secret", audit, admin, apiKeyHandler.ResetSigningSecret) // 重新生成签名密钥（只返回一次）
+			}
+
+			// 对外API限流
+			rateLimits := authorized.Group("/rate-limits")
+			{
+				rateLimits.POST("/stats", handler.GetRateLimitStats) // 限流规则和计数
+			}
+
 			// 配置管理路由
 			configs := authorized.Group("/configs")
 			{
 				configs.POST("/get-system", configHandler.GetSystemConfigs)       // 获取系统配置
-				configs.POST("/save-system", configHandler.SaveSystemConfigs)     // 保存系统配置
+				configs.POST("/save-system", audit, admin, configHandler.SaveSystemConfigs)     // 保存系统配置
 				configs.POST("/get-proxy-list", configHandler.GetProxyList)       // 获取代理列表
 				configs.POST("/get-clientid-list", configHandler.GetClientIDList) // 获取 Client ID 列表
 			}
@@ -11,6 +11,14 @@ type Config struct {
 	Database DatabaseConfig `mapstructure:"database"`
 	OpenAI   OpenAIConfig   `mapstructure:"openai"`
 	Auth     AuthConfig     `mapstructure:"auth"`
+	Sandbox  SandboxConfig  `mapstructure:"sandbox"`
+	Lease    LeaseConfig    `mapstructure:"lease"`
+	Report   ReportConfig   `mapstructure:"report"`
+	Audit    AuditConfig    `mapstructure:"audit"`
+
+	RateLimit RateLimitConfig `mapstructure:"rate_limit"`
+
+	Encryption EncryptionConfig `mapstructure:"encryption"`
 }
 
 // ServerConfig 服务器配置
@@ -40,24 +48,108 @@ type DatabaseConfig struct {
 	MaxIdleConns    int    `mapstructure:"max_idle_conns"`
 	MaxOpenConns    int    `mapstructure:"max_open_conns"`
 	ConnMaxLifetime int    `mapstructure:"conn_max_lifetime"`
+	AutoMigrate     bool   `mapstructure:"auto_migrate"` // 启动时自动执行数据库迁移
 }
 
 // OpenAIConfig OpenAI 配置
 type OpenAIConfig struct {
 	ClientID        string `mapstructure:"client_id"`
+	RedirectURI     string `mapstructure:"redirect_uri"`     // OAuth 回调地址，需与 client_id 注册的一致
+	Scope           string `mapstructure:"scope"`            // 授权码登录申请的 scope
+	AuthBaseURL     string `mapstructure:"auth_base_url"`    // Token 接口地址，默认 https://auth.openai.com
+	ChatGPTBaseURL  string `mapstructure:"chatgpt_base_url"` // /me、accounts/check 接口地址，默认 https://chatgpt.com
 	Proxy           string `mapstructure:"proxy"`
-	RefreshInterval int    `mapstructure:"refresh_interval"` // 自动刷新间隔（天）
+	RefreshInterval int    `mapstructure:"refresh_interval"` // 自动刷新间隔（天），即 RT 最长使用时间
 	ScheduleEnabled bool   `mapstructure:"schedule_enabled"` // 是否启用定时刷新
+
+	RefreshMarginMinutes int `mapstructure:"refresh_margin_minutes"` // AT 过期前提前刷新的时间（分钟）
+	RefreshRetryMinutes  int `mapstructure:"refresh_retry_minutes"`  // 刷新失败后的重试间隔（分钟）
+	ScheduleScanSeconds  int `mapstructure:"schedule_scan_seconds"`  // 调度器扫描到期RT的间隔（秒）
+
+	// 刷新失败处理策略，key 为刷新状态（invalid_grant、rate_limited 等）
+	FailurePolicies map[string]FailurePolicy `mapstructure:"failure_policies"`
+
+	RefreshConcurrency         int `mapstructure:"refresh_concurrency"`           // 批量刷新全局并发数
+	RefreshPerProxyConcurrency int `mapstructure:"refresh_per_proxy_concurrency"` // 批量刷新单个代理的并发数
+	RefreshProxyIntervalMs     int `mapstructure:"refresh_proxy_interval_ms"`     // 批量刷新时同一代理相邻两次请求的平均间隔（毫秒），实际间隔在 50%-150% 之间随机，0 表示不间隔
 }
 
 // AuthConfig 认证配置
 type AuthConfig struct {
-	Username        string `mapstructure:"username"`
-	Password        string `mapstructure:"password"`
-	JWTSecret       string `mapstructure:"jwt_secret"`
-	JWTExpireHours  int    `mapstructure:"jwt_expire_hours"`
-	APISecret       string `mapstructure:"api_secret"`
-	PublicAPIPrefix string `mapstructure:"public_api_prefix"` // 对外API路由前缀，默认 "/public-api"
+	Username        string   `mapstructure:"username"`
+	Password        string   `mapstructure:"password"`
+	JWTSecret       string   `mapstructure:"jwt_secret"`
+	JWTExpireHours  int      `mapstructure:"jwt_expire_hours"`  // 登录会话（刷新令牌）有效期（小时），每次刷新后顺延
+	APISecret       string   `mapstructure:"api_secret"`        // 旧版对外API密钥，拥有全部对外接口权限，为空时不启用
+	PublicAPIPrefix string   `mapstructure:"public_api_prefix"` // 对外API路由前缀，默认 "/public-api"
+	RevealUsers     []string `mapstructure:"reveal_users"`      // 允许查看明文 token 的管理员用户名，为空时 operator 及以上角色均可
+
+	SignatureMaxSkew int `mapstructure:"signature_max_skew"` // 签名请求允许的时钟偏差（秒），nonce 在 2 倍偏差内不能重复使用
+
+	AccessTokenMinutes int `mapstructure:"access_token_minutes"` // 访问令牌（JWT）有效期（分钟），过期后用刷新令牌换取
+}
+
+// FailurePolicy 刷新失败处理策略
+type FailurePolicy struct {
+	Disable      bool `mapstructure:"disable"`       // 是否自动禁用该RT
+	RetryMinutes int  `mapstructure:"retry_minutes"` // 重试间隔（分钟），0 表示使用 refresh_retry_minutes
+}
+
+// EncryptionConfig 敏感字段加密配置（RT、AT 等以 AES-256-GCM 加密存储）
+type EncryptionConfig struct {
+	Key          string   `mapstructure:"key"`           // base64 编码的 32 字节密钥，为空且未配置 key_file 时不加密
+	KeyFile      string   `mapstructure:"key_file"`      // 密钥文件路径（文件内容为 base64 密钥），key 为空时使用
+	PreviousKeys []string `mapstructure:"previous_keys"` // 轮换前的旧密钥，仅用于解密，重新加密完成后可删除
+}
+
+// SandboxConfig 沙箱配置（模拟 OpenAI 接口，用于联调和集成测试）
+type SandboxConfig struct {
+	Enabled        bool   `mapstructure:"enabled"`          // 是否启动沙箱服务，启用后 OpenAI 接口地址会指向沙箱
+	Host           string `mapstructure:"host"`             // 监听地址
+	Port           int    `mapstructure:"port"`             // 监听端口
+	AccessTokenTTL int    `mapstructure:"access_token_ttl"` // 签发的 AT 有效期（秒）
+	LatencyMs      int    `mapstructure:"latency_ms"`       // 模拟的接口延迟（毫秒）
+}
+
+// LeaseConfig AT 租约配置（对外接口 /lease、/release）
+type LeaseConfig struct {
+	DefaultTTL int `mapstructure:"default_ttl"` // 默认租约时长（秒）
+	MaxTTL     int `mapstructure:"max_ttl"`     // 最长租约时长（秒）
+	MaxShared  int `mapstructure:"max_shared"`  // 单个账号同时存在的共享租约上限，0 表示不限
+}
+
+// ReportConfig 调用方反馈配置（对外接口 /report）
+type ReportConfig struct {
+	RateLimitCooldown    int `mapstructure:"rate_limit_cooldown"`   // 反馈 429 且未提供 Retry-After 时的冷却时长（秒）
+	UnauthorizedCooldown int `mapstructure:"unauthorized_cooldown"` // 反馈 401/403 后的冷却时长（秒），刷新或校验通过后提前解除
+	MaxCooldown          int `mapstructure:"max_cooldown"`          // 冷却时长上限（秒）
+}
+
+// AuditConfig 审计日志配置
+type AuditConfig struct {
+	Enabled       bool `mapstructure:"enabled"`        // 是否记录审计事件
+	RetentionDays int  `mapstructure:"retention_days"` // 审计事件保留天数，0 表示永久保留
+}
+
+// RateLimitConfig 对外API限流配置（令牌桶），/refresh、/get-at 与其他接口分别计算
+type RateLimitConfig struct {
+	Enabled bool           `mapstructure:"enabled" json:"enabled"`
+	Refresh RateLimitGroup `mapstructure:"refresh" json:"refresh"` // /refresh
+	GetAT   RateLimitGroup `mapstructure:"get_at" json:"get_at"`   // /get-at
+	Default RateLimitGroup `mapstructure:"default" json:"default"` // 其他接口
+}
+
+// RateLimitGroup 一组接口按 API 密钥、客户端 IP、目标账号分别限流
+type RateLimitGroup struct {
+	PerKey     RateLimitRule `mapstructure:"per_key" json:"per_key"`
+	PerIP      RateLimitRule `mapstructure:"per_ip" json:"per_ip"`
+	PerAccount RateLimitRule `mapstructure:"per_account" json:"per_account"`
+}
+
+// RateLimitRule 令牌桶规则
+type RateLimitRule struct {
+	PerMinute int `mapstructure:"per_minute" json:"per_minute"` // 每分钟补充的请求数，0 表示不限制
+	Burst     int `mapstructure:"burst" json:"burst"`           // 允许的突发请求数，0 时等于 per_minute
 }
 
 var cfg *Config
@@ -86,15 +178,66 @@ func Init() error {
 	viper.SetDefault("database.max_idle_conns", 10)
 	viper.SetDefault("database.max_open_conns", 100)
 	viper.SetDefault("database.conn_max_lifetime", 3600)
+	viper.SetDefault("database.auto_migrate", true)
 	viper.SetDefault("openai.client_id", "app_WXrF1LSkiTtfYqiL6XtjygvX")
+	viper.SetDefault("openai.redirect_uri", "com.openai.chat://auth0.openai.com/ios/com.openai.chat/callback")
+	viper.SetDefault("openai.scope", "openid email profile offline_access model.request model.read organization.read organization.write")
 	viper.SetDefault("openai.refresh_interval", 2) // 默认2天
+	viper.SetDefault("openai.auth_base_url", "https://auth.openai.com")
+	viper.SetDefault("openai.chatgpt_base_url", "https://chatgpt.com")
 	viper.SetDefault("openai.schedule_enabled", false)
+	viper.SetDefault("openai.refresh_margin_minutes", 720)
+	viper.SetDefault("openai.refresh_retry_minutes", 30)
+	viper.SetDefault("openai.schedule_scan_seconds", 60)
+	viper.SetDefault("openai.failure_policies.invalid_grant.disable", true)
+	viper.SetDefault("openai.failure_policies.refresh_token_reused.disable", true)
+	viper.SetDefault("openai.failure_policies.account_deactivated.disable", true)
+	viper.SetDefault("openai.failure_policies.network_error.retry_minutes", 5)
+	viper.SetDefault("openai.failure_policies.rate_limited.retry_minutes", 30)
+	viper.SetDefault("openai.failure_policies.upstream_error.retry_minutes", 10)
+	viper.SetDefault("openai.failure_policies.unknown_error.retry_minutes", 30)
+	viper.SetDefault("openai.refresh_concurrency", 16)
+	viper.SetDefault("openai.refresh_per_proxy_concurrency", 2)
+	viper.SetDefault("openai.refresh_proxy_interval_ms", 2000) // 与旧版本的 1-3 秒随机间隔一致
+	viper.SetDefault("sandbox.enabled", false)
+	viper.SetDefault("sandbox.host", "127.0.0.1")
+	viper.SetDefault("sandbox.port", 18080)
+	viper.SetDefault("sandbox.access_token_ttl", 864000) // 默认10天，与线上一致
+	viper.SetDefault("sandbox.latency_ms", 0)
+	viper.SetDefault("lease.default_ttl", 300)
+	viper.SetDefault("lease.max_ttl", 3600)
+	viper.SetDefault("lease.max_shared", 0)
+	viper.SetDefault("report.rate_limit_cooldown", 300)
+	viper.SetDefault("report.unauthorized_cooldown", 600)
+	viper.SetDefault("report.max_cooldown", 86400)
+	viper.SetDefault("audit.enabled", true)
+	viper.SetDefault("audit.retention_days", 180)
+	viper.SetDefault("rate_limit.enabled", true)
+	viper.SetDefault("rate_limit.refresh.per_key.per_minute", 120)
+	viper.SetDefault("rate_limit.refresh.per_key.burst", 30)
+	viper.SetDefault("rate_limit.refresh.per_ip.per_minute", 120)
+	viper.SetDefault("rate_limit.refresh.per_ip.burst", 30)
+	viper.SetDefault("rate_limit.refresh.per_account.per_minute", 6)
+	viper.SetDefault("rate_limit.refresh.per_account.burst", 3)
+	viper.SetDefault("rate_limit.get_at.per_key.per_minute", 1200)
+	viper.SetDefault("rate_limit.get_at.per_key.burst", 200)
+	viper.SetDefault("rate_limit.get_at.per_ip.per_minute", 1200)
+	viper.SetDefault("rate_limit.get_at.per_ip.burst", 200)
+	viper.SetDefault("rate_limit.get_at.per_account.per_minute", 120)
+	viper.SetDefault("rate_limit.get_at.per_account.burst", 30)
+	viper.SetDefault("rate_limit.default.per_key.per_minute", 600)
+	viper.SetDefault("rate_limit.default.per_key.burst", 100)
+	viper.SetDefault("rate_limit.default.per_ip.per_minute", 600)
+	viper.SetDefault("rate_limit.default.per_ip.burst", 100)
+	viper.SetDefault("rate_limit.default.per_account.per_minute", 0)
+	viper.SetDefault("rate_limit.default.per_account.burst", 0)
 	viper.SetDefault("auth.username", "admin")
 	viper.SetDefault("auth.password", "admin123")
 	viper.SetDefault("auth.jwt_secret", "your-secret-key-change-this-in-production")
 	viper.SetDefault("auth.jwt_expire_hours", 24)
-	viper.SetDefault("auth.api_secret", "my-api-secret-2025")
+	viper.SetDefault("auth.access_token_minutes", 15)
 	viper.SetDefault("auth.public_api_prefix", "/public-api")
+	viper.SetDefault("auth.signature_max_skew", 300)
 
 	if err := viper.ReadInConfig(); err != nil {
 		// 如果配置文件不存在，使用默认值
@@ -6,9 +6,10 @@ import (
 
 	"rt-manage/internal/config"
 	"rt-manage/internal/model"
+	"rt-manage/pkg/logger"
 	"gorm.io/driver/mysql"
 	"gorm.io/gorm"
-	"gorm.io/gorm/logger"
+	gormlogger "gorm.io/gorm/logger"
 	
 	// 使用纯 Go 的 SQLite 驱动（不需要 CGO）
 	sqlite "github.com/glebarez/sqlite"
@@ -16,8 +17,21 @@ import (
 
 var db *gorm.DB
 
-// Init 初始化数据库连接
+// Init 初始化数据库连接，并在启用 auto_migrate 时执行未完成的迁移
 func Init(cfg *config.DatabaseConfig) error {
+	if err := Open(cfg); err != nil {
+		return err
+	}
+
+	if !cfg.AutoMigrate {
+		logger.Info("已关闭启动时自动迁移，请手动执行 migrate up")
+		return nil
+	}
+	return Migrate()
+}
+
+// Open 打开数据库连接（不执行迁移）
+func Open(cfg *config.DatabaseConfig) error {
 	var dialector gorm.Dialector
 	var err error
 
@@ -43,7 +57,7 @@ func Init(cfg *config.DatabaseConfig) error {
 
 	// 配置 GORM
 	gormConfig := &gorm.Config{
-		Logger: logger.Default.LogMode(logger.Silent),
+		Logger: gormlogger.Default.LogMode(gormlogger.Silent),
 	}
 
 	db, err = gorm.Open(dialector, gormConfig)
@@ -61,22 +75,6 @@ func Init(cfg *config.DatabaseConfig) error {
 	sqlDB.SetMaxOpenConns(cfg.MaxOpenConns)
 	sqlDB.SetConnMaxLifetime(time.Duration(cfg.ConnMaxLifetime) * time.Second)
 
-	// 自动迁移数据库表（只创建表和列，不处理索引变更）
-	migrator := db.Migrator()
-	
-	// 检查表是否存在，不存在才自动迁移
-	if !migrator.HasTable(&model.RT{}) {
-		if err := db.AutoMigrate(&model.RT{}); err != nil {
-			return fmt.Errorf("创建 rt_rts 表失败: %w", err)
-		}
-	}
-	
-	if !migrator.HasTable(&model.SystemConfig{}) {
-		if err := db.AutoMigrate(&model.SystemConfig{}); err != nil {
-			return fmt.Errorf("创建 system_configs 表失败: %w", err)
-		}
-	}
-
 	return nil
 }
 
@@ -0,0 +1,307 @@
+package database
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"rt-manage/internal/config"
+
+	"rt-manage/internal/model"
+	"rt-manage/pkg/logger"
+
+	"gorm.io/gorm"
+)
+
+// migration 一次版本化的数据库迁移，版本号递增且发布后不可修改
+type migration struct {
+	Version int64
+	Name    string
+	Up      func(s *schema) error
+}
+
+// MigrationStatus 迁移执行状态
+type MigrationStatus struct {
+	Version   int64
+	Name      string
+	Applied   bool
+	AppliedAt *time.Time
+}
+
+// dialectSQL 同一迁移步骤在不同数据库下的 SQL，语句中的 %[1]s 为带前缀的表名
+type dialectSQL struct {
+	SQLite []string
+	MySQL  []string
+}
+
+// schema 迁移辅助方法
+// 对象已存在时跳过，兼容旧版本通过 AutoMigrate 建出的表
+type schema struct {
+	db      *gorm.DB
+	dialect string
+}
+
+// tableName 为表名添加前缀
+func tableName(name string) string {
+	if prefix := model.GetTablePrefix(); prefix != "" {
+		return prefix + "_" + name
+	}
+	return name
+}
+
+// exec 按数据库类型执行 SQL
+func (s *schema) exec(table string, sql dialectSQL) error {
+	statements := sql.SQLite
+	if s.dialect == "mysql" {
+		statements = sql.MySQL
+	}
+	if len(statements) == 0 {
+		return fmt.Errorf("缺少 %s 的迁移语句", s.dialect)
+	}
+	for _, stmt := range statements {
+		if err := s.db.Exec(fmt.Sprintf(stmt, table)).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// createTable 建表（含索引），表已存在时跳过
+func (s *schema) createTable(table string, sql dialectSQL) error {
+	if s.db.Migrator().HasTable(table) {
+		logger.Info("表已存在，跳过创建", "table", table)
+		return nil
+	}
+	return s.exec(table, sql)
+}
+
+// addColumn 添加列，列已存在时跳过
+func (s *schema) addColumn(table, column string, sql dialectSQL) error {
+	columns, err := s.db.Migrator().ColumnTypes(table)
+	if err != nil {
+		return fmt.Errorf("读取 %s 表结构失败: %v", table, err)
+	}
+	for _, c := range columns {
+		if c.Name() == column {
+			logger.Info("列已存在，跳过添加", "table", table, "column", column)
+			return nil
+		}
+	}
+	return s.exec(table, sql)
+}
+
+// createIndex 创建索引，索引已存在时跳过
+func (s *schema) createIndex(table, index string, sql dialectSQL) error {
+	if s.db.Migrator().HasIndex(table, index) {
+		logger.Info("索引已存在，跳过创建", "table", table, "index", index)
+		return nil
+	}
+	return s.exec(table, sql)
+}
+
+// backfillBatchSize 回填数据时每批读取的记录数
+const backfillBatchSize = 500
+
+// backfillRefreshSchedule 为升级前的RT回填 at_expire_time 和 next_refresh_time（只处理 next_refresh_time 为空的记录）
+// 有 exp 的按 AT 过期时间减去提前量排期，已过期或即将过期的在提前量内随机分散；
+// 没有可用 exp 的在一个刷新周期内随机分散，避免所有RT同时到期
+func (s *schema) backfillRefreshSchedule(table string, now time.Time) error {
+	cfg := config.Get().OpenAI
+	margin := time.Duration(cfg.RefreshMarginMinutes) * time.Minute
+	if margin <= 0 {
+		margin = time.Hour
+	}
+	maxAge := time.Duration(refreshIntervalDays(s.db, cfg.RefreshInterval)) * 24 * time.Hour
+
+	type row struct {
+		ID int64
+		At string
+	}
+	count := 0
+	var lastID int64
+	for {
+		var rows []row
+		err := s.db.Table(table).Select("id, at").
+			Where("id > ? AND next_refresh_time IS NULL", lastID).
+			Order("id ASC").Limit(backfillBatchSize).
+			Find(&rows).Error
+		if err != nil {
+			return fmt.Errorf("读取待排期的RT失败: %v", err)
+		}
+		if len(rows) == 0 {
+			break
+		}
+		lastID = rows[len(rows)-1].ID
+
+		for _, r := range rows {
+			updates := map[string]interface{}{}
+			next := now.Add(time.Duration(rand.Int63n(int64(maxAge))))
+			if expireTime := tokenExpireTime(r.At); expireTime != nil {
+				updates["at_expire_time"] = *expireTime
+				next = expireTime.Add(-margin)
+				if !next.After(now) {
+					next = now.Add(time.Duration(rand.Int63n(int64(margin))))
+				} else if limit := now.Add(maxAge); next.After(limit) {
+					next = limit
+				}
+			}
+			updates["next_refresh_time"] = next
+			if err := s.db.Table(table).Where("id = ?", r.ID).UpdateColumns(updates).Error; err != nil {
+				return fmt.Errorf("回填RT刷新计划失败(id=%d): %v", r.ID, err)
+			}
+			count++
+		}
+	}
+	if count > 0 {
+		logger.Info("已为已有RT回填刷新计划", "count", count)
+	}
+	return nil
+}
+
+// refreshIntervalDays RT 最长使用时间（天），系统配置 auto_refresh_interval 优先于配置文件
+func refreshIntervalDays(db *gorm.DB, days int) int {
+	var value string
+	err := db.Table(tableName("system_configs")).Select("config_value").
+		Where("config_key = ?", "auto_refresh_interval").
+		Limit(1).Scan(&value).Error
+	if err == nil {
+		if v, err := strconv.Atoi(value); err == nil && v > 0 {
+			days = v
+		}
+	}
+	if days <= 0 {
+		days = 2
+	}
+	return days
+}
+
+// tokenExpireTime 读取 JWT 的 exp 声明（不校验签名），不是 JWT 或没有 exp 时返回 nil
+func tokenExpireTime(token string) *time.Time {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return nil
+	}
+	var claims struct {
+		Exp float64 `json:"exp"`
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil || claims.Exp <= 0 {
+		return nil
+	}
+	t := time.Unix(int64(claims.Exp), 0)
+	return &t
+}
+
+// ensureMigrationTable 创建迁移记录表
+func ensureMigrationTable() error {
+	if db.Migrator().HasTable(&model.SchemaMigration{}) {
+		return nil
+	}
+	if err := db.Migrator().CreateTable(&model.SchemaMigration{}); err != nil {
+		return fmt.Errorf("创建 schema_migrations 表失败: %w", err)
+	}
+	return nil
+}
+
+// appliedMigrations 读取已执行的迁移
+func appliedMigrations() (map[int64]model.SchemaMigration, error) {
+	var records []model.SchemaMigration
+	if err := db.Order("version ASC").Find(&records).Error; err != nil {
+		return nil, fmt.Errorf("读取迁移记录失败: %w", err)
+	}
+
+	applied := make(map[int64]model.SchemaMigration, len(records))
+	for _, r := range records {
+		applied[r.Version] = r
+	}
+	return applied, nil
+}
+
+// Migrate 按版本顺序执行所有未执行的迁移
+// 每个迁移与其记录在同一事务中提交（MySQL 的 DDL 会隐式提交，失败时已执行的语句不会回滚，修复后重新执行即可跳过已存在的对象）
+func Migrate() error {
+	if err := ensureMigrationTable(); err != nil {
+		return err
+	}
+
+	applied, err := appliedMigrations()
+	if err != nil {
+		return err
+	}
+
+	latest := migrations[len(migrations)-1].Version
+	for version := range applied {
+		if version > latest {
+			logger.Warn("数据库中存在未知的迁移版本，可能由更新版本的程序执行", "version", version, "latest_known", latest)
+		}
+	}
+
+	dialect := db.Dialector.Name()
+	count := 0
+	for _, m := range migrations {
+		if _, ok := applied[m.Version]; ok {
+			continue
+		}
+
+		logger.Info("执行数据库迁移", "version", m.Version, "name", m.Name)
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := m.Up(&schema{db: tx, dialect: dialect}); err != nil {
+				return err
+			}
+			return tx.Create(&model.SchemaMigration{
+				Version:   m.Version,
+				Name:      m.Name,
+				AppliedAt: time.Now(),
+			}).Error
+		})
+		if err != nil {
+			return fmt.Errorf("迁移 %d_%s 执行失败: %w", m.Version, m.Name, err)
+		}
+		count++
+	}
+
+	if count > 0 {
+		logger.Info("数据库迁移完成", "applied", count, "version", latest)
+	}
+	return nil
+}
+
+// GetMigrationStatus 获取所有迁移的执行状态（包含数据库中存在但程序未知的版本）
+func GetMigrationStatus() ([]MigrationStatus, error) {
+	applied := map[int64]model.SchemaMigration{}
+	if db.Migrator().HasTable(&model.SchemaMigration{}) {
+		var err error
+		if applied, err = appliedMigrations(); err != nil {
+			return nil, err
+		}
+	}
+
+	statuses := make([]MigrationStatus, 0, len(migrations))
+	for _, m := range migrations {
+		status := MigrationStatus{Version: m.Version, Name: m.Name}
+		if r, ok := applied[m.Version]; ok {
+			appliedAt := r.AppliedAt
+			status.Applied = true
+			status.AppliedAt = &appliedAt
+			delete(applied, m.Version)
+		}
+		statuses = append(statuses, status)
+	}
+	for _, r := range applied {
+		appliedAt := r.AppliedAt
+		statuses = append(statuses, MigrationStatus{Version: r.Version, Name: r.Name, Applied: true, AppliedAt: &appliedAt})
+	}
+
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].Version < statuses[j].Version
+	})
+	return statuses, nil
+}
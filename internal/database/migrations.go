@@ -0,0 +1,506 @@
+package database
+
+import "time"
+
+// migrations 所有数据库迁移，按版本号升序排列
+// 新增表或字段时在末尾追加迁移，不要修改已发布的迁移
+var migrations = []migration{
+	{
+		Version: 1,
+		Name:    "create_rts",
+		Up: func(s *schema) error {
+			return s.createTable(tableName("rts"), dialectSQL{
+				SQLite: []string{
+					"CREATE TABLE `%[1]s` (`id` integer PRIMARY KEY AUTOINCREMENT,`biz_id` varchar(255) NOT NULL,`user_name` varchar(255),`email` varchar(255),`type` varchar(50),`rt` text NOT NULL,`at` text,`proxy` varchar(255),`client_id` varchar(255),`tag` varchar(255),`enabled` numeric NOT NULL DEFAULT false,`last_rt` text,`refresh_result` text,`user_info` text,`account_info` text,`last_refresh_time` datetime DEFAULT null,`memo` text,`create_time` datetime,`update_time` datetime)",
+					"CREATE UNIQUE INDEX `uni_rt_rts_biz_id` ON `%[1]s`(`biz_id`)",
+				},
+				MySQL: []string{
+					"CREATE TABLE `%[1]s` (" +
+						"`id` bigint NOT NULL AUTO_INCREMENT COMMENT '主键ID'," +
+						"`create_time` datetime(3) DEFAULT NULL COMMENT '创建时间'," +
+						"`update_time` datetime(3) DEFAULT NULL COMMENT '更新时间'," +
+						"`biz_id` varchar(255) NOT NULL COMMENT '业务ID（唯一标识）'," +
+						"`user_name` varchar(255) DEFAULT NULL COMMENT '用户名'," +
+						"`email` varchar(255) DEFAULT NULL COMMENT '邮箱'," +
+						"`type` varchar(50) DEFAULT NULL COMMENT '账号类型（如：free, team）'," +
+						"`rt` text NOT NULL COMMENT 'Refresh Token'," +
+						"`at` text COMMENT 'Access Token'," +
+						"`proxy` varchar(255) DEFAULT NULL COMMENT '代理地址'," +
+						"`client_id` varchar(255) DEFAULT NULL COMMENT 'OpenAI Client ID'," +
+						"`tag` varchar(255) DEFAULT NULL COMMENT '标签'," +
+						"`enabled` tinyint(1) NOT NULL DEFAULT '1' COMMENT '是否启用（1:启用, 0:禁用）'," +
+						"`last_rt` text COMMENT '上一次的 Refresh Token'," +
+						"`refresh_result` text COMMENT '刷新结果'," +
+						"`user_info` text COMMENT '用户信息（JSON）'," +
+						"`account_info` text COMMENT '账号信息（JSON）'," +
+						"`last_refresh_time` datetime DEFAULT NULL COMMENT '最后刷新时间'," +
+						"`memo` text COMMENT '备注'," +
+						"PRIMARY KEY (`id`)," +
+						"UNIQUE KEY `uni_rt_rts_biz_id` (`biz_id`)" +
+						") ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='RT Token 管理表'",
+				},
+			})
+		},
+	},
+	{
+		Version: 2,
+		Name:    "create_system_configs",
+		Up: func(s *schema) error {
+			return s.createTable(tableName("system_configs"), dialectSQL{
+				SQLite: []string{
+					"CREATE TABLE `%[1]s` (`id` integer PRIMARY KEY AUTOINCREMENT,`config_key` varchar(255) NOT NULL,`config_value` text,`create_time` datetime,`update_time` datetime)",
+					"CREATE UNIQUE INDEX `idx_config_key` ON `%[1]s`(`config_key`)",
+				},
+				MySQL: []string{
+					"CREATE TABLE `%[1]s` (" +
+						"`id` bigint NOT NULL AUTO_INCREMENT COMMENT '主键ID'," +
+						"`create_time` datetime(3) DEFAULT NULL COMMENT '创建时间'," +
+						"`update_time` datetime(3) DEFAULT NULL COMMENT '更新时间'," +
+						"`config_key` varchar(255) NOT NULL COMMENT '配置键'," +
+						"`config_value` text COMMENT '配置值'," +
+						"PRIMARY KEY (`id`)," +
+						"UNIQUE KEY `idx_config_key` (`config_key`)" +
+						") ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='系统配置表'",
+				},
+			})
+		},
+	},
+	{
+		Version: 3,
+		Name:    "add_rts_refresh_schedule",
+		Up: func(s *schema) error {
+			table := tableName("rts")
+			if err := s.addColumn(table, "at_expire_time", dialectSQL{
+				SQLite: []string{"ALTER TABLE `%[1]s` ADD COLUMN `at_expire_time` datetime DEFAULT null"},
+				MySQL:  []string{"ALTER TABLE `%[1]s` ADD COLUMN `at_expire_time` datetime DEFAULT NULL COMMENT 'Access Token 过期时间'"},
+			}); err != nil {
+				return err
+			}
+			if err := s.addColumn(table, "next_refresh_time", dialectSQL{
+				SQLite: []string{"ALTER TABLE `%[1]s` ADD COLUMN `next_refresh_time` datetime DEFAULT null"},
+				MySQL:  []string{"ALTER TABLE `%[1]s` ADD COLUMN `next_refresh_time` datetime DEFAULT NULL COMMENT '下次计划刷新时间'"},
+			}); err != nil {
+				return err
+			}
+			if err := s.createIndex(table, "idx_rt_rts_next_refresh_time", dialectSQL{
+				SQLite: []string{"CREATE INDEX `idx_rt_rts_next_refresh_time` ON `%[1]s`(`next_refresh_time`)"},
+				MySQL:  []string{"ALTER TABLE `%[1]s` ADD INDEX `idx_rt_rts_next_refresh_time` (`next_refresh_time`)"},
+			}); err != nil {
+				return err
+			}
+			// 已有RT按各自的AT过期时间排期，避免升级后首次扫描同时刷新所有RT
+			return s.backfillRefreshSchedule(table, time.Now())
+		},
+	},
+	{
+		Version: 4,
+		Name:    "add_rts_refresh_status",
+		Up: func(s *schema) error {
+			table := tableName("rts")
+			if err := s.addColumn(table, "refresh_status", dialectSQL{
+				SQLite: []string{"ALTER TABLE `%[1]s` ADD COLUMN `refresh_status` varchar(50)"},
+				MySQL:  []string{"ALTER TABLE `%[1]s` ADD COLUMN `refresh_status` varchar(50) DEFAULT NULL COMMENT '刷新状态' AFTER `refresh_result`"},
+			}); err != nil {
+				return err
+			}
+			return s.createIndex(table, "idx_rt_rts_refresh_status", dialectSQL{
+				SQLite: []string{"CREATE INDEX `idx_rt_rts_refresh_status` ON `%[1]s`(`refresh_status`)"},
+				MySQL:  []string{"ALTER TABLE `%[1]s` ADD INDEX `idx_rt_rts_refresh_status` (`refresh_status`)"},
+			})
+		},
+	},
+	{
+		Version: 5,
+		Name:    "create_refresh_logs",
+		Up: func(s *schema) error {
+			return s.createTable(tableName("refresh_logs"), dialectSQL{
+				SQLite: []string{
+					"CREATE TABLE `%[1]s` (`id` integer PRIMARY KEY AUTOINCREMENT,`rt_id` integer NOT NULL,`biz_id` varchar(255),`trigger` varchar(20),`proxy` varchar(255),`client_id` varchar(255),`http_status` integer,`refresh_status` varchar(50),`error_code` varchar(100),`error_message` text,`latency_ms` integer,`response_body` text,`create_time` datetime)",
+					"CREATE INDEX `idx_refresh_logs_rt_id` ON `%[1]s`(`rt_id`)",
+					"CREATE INDEX `idx_refresh_logs_biz_id` ON `%[1]s`(`biz_id`)",
+					"CREATE INDEX `idx_refresh_logs_trigger` ON `%[1]s`(`trigger`)",
+					"CREATE INDEX `idx_refresh_logs_refresh_status` ON `%[1]s`(`refresh_status`)",
+					"CREATE INDEX `idx_refresh_logs_create_time` ON `%[1]s`(`create_time`)",
+				},
+				MySQL: []string{
+					"CREATE TABLE `%[1]s` (" +
+						"`id` bigint NOT NULL AUTO_INCREMENT COMMENT '主键ID'," +
+						"`create_time` datetime(3) DEFAULT NULL COMMENT '创建时间'," +
+						"`rt_id` bigint NOT NULL COMMENT 'RT ID'," +
+						"`biz_id` varchar(255) DEFAULT NULL COMMENT '业务ID'," +
+						"`trigger` varchar(20) DEFAULT NULL COMMENT '触发来源（manual, batch, scheduler, public_api）'," +
+						"`proxy` varchar(255) DEFAULT NULL COMMENT '使用的代理'," +
+						"`client_id` varchar(255) DEFAULT NULL COMMENT '使用的 Client ID'," +
+						"`http_status` bigint DEFAULT NULL COMMENT '上游 HTTP 状态码'," +
+						"`refresh_status` varchar(50) DEFAULT NULL COMMENT '刷新状态'," +
+						"`error_code` varchar(100) DEFAULT NULL COMMENT '上游错误码'," +
+						"`error_message` text COMMENT '错误信息'," +
+						"`latency_ms` bigint DEFAULT NULL COMMENT '耗时（毫秒）'," +
+						"`response_body` text COMMENT '响应内容（已脱敏）'," +
+						"PRIMARY KEY (`id`)," +
+						"KEY `idx_refresh_logs_rt_id` (`rt_id`)," +
+						"KEY `idx_refresh_logs_biz_id` (`biz_id`)," +
+						"KEY `idx_refresh_logs_trigger` (`trigger`)," +
+						"KEY `idx_refresh_logs_refresh_status` (`refresh_status`)," +
+						"KEY `idx_refresh_logs_create_time` (`create_time`)" +
+						") ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='刷新记录表'",
+				},
+			})
+		},
+	},
+	{
+		Version: 6,
+		Name:    "create_token_rotations",
+		Up: func(s *schema) error {
+			return s.createTable(tableName("token_rotations"), dialectSQL{
+				SQLite: []string{
+					"CREATE TABLE `%[1]s` (`id` integer PRIMARY KEY AUTOINCREMENT,`rt_id` integer NOT NULL,`old_rt` text,`new_rt` text,`new_at` text,`expires_in` integer,`status` varchar(20),`create_time` datetime,`update_time` datetime)",
+					"CREATE INDEX `idx_token_rotations_rt_id` ON `%[1]s`(`rt_id`)",
+					"CREATE INDEX `idx_token_rotations_status` ON `%[1]s`(`status`)",
+				},
+				MySQL: []string{
+					"CREATE TABLE `%[1]s` (" +
+						"`id` bigint NOT NULL AUTO_INCREMENT COMMENT '主键ID'," +
+						"`create_time` datetime(3) DEFAULT NULL COMMENT '创建时间'," +
+						"`update_time` datetime(3) DEFAULT NULL COMMENT '更新时间'," +
+						"`rt_id` bigint NOT NULL COMMENT 'RT ID'," +
+						"`old_rt` text COMMENT '轮换前的 Refresh Token'," +
+						"`new_rt` text COMMENT '上游返回的新 Refresh Token'," +
+						"`new_at` text COMMENT '上游返回的新 Access Token'," +
+						"`expires_in` bigint DEFAULT NULL COMMENT 'AT 有效期（秒）'," +
+						"`status` varchar(20) DEFAULT NULL COMMENT '状态（pending, received, unknown）'," +
+						"PRIMARY KEY (`id`)," +
+						"KEY `idx_token_rotations_rt_id` (`rt_id`)," +
+						"KEY `idx_token_rotations_status` (`status`)" +
+						") ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='RT 轮换日志表'",
+				},
+			})
+		},
+	},
+	{
+		Version: 7,
+		Name:    "add_rts_version",
+		Up: func(s *schema) error {
+			return s.addColumn(tableName("rts"), "version", dialectSQL{
+				SQLite: []string{"ALTER TABLE `%[1]s` ADD COLUMN `version` integer NOT NULL DEFAULT 0"},
+				MySQL:  []string{"ALTER TABLE `%[1]s` ADD COLUMN `version` bigint NOT NULL DEFAULT '0' COMMENT '乐观锁版本号' AFTER `memo`"},
+			})
+		},
+	},
+	{
+		Version: 8,
+		Name:    "add_rts_rt_hash",
+		Up: func(s *schema) error {
+			table := tableName("rts")
+			if err := s.addColumn(table, "rt_hash", dialectSQL{
+				SQLite: []string{"ALTER TABLE `%[1]s` ADD COLUMN `rt_hash` varchar(64)"},
+				MySQL:  []string{"ALTER TABLE `%[1]s` ADD COLUMN `rt_hash` varchar(64) DEFAULT NULL COMMENT 'Refresh Token 的 HMAC（加密存储时用于查找）' AFTER `rt`"},
+			}); err != nil {
+				return err
+			}
+			return s.createIndex(table, "idx_rt_rts_rt_hash", dialectSQL{
+				SQLite: []string{"CREATE INDEX `idx_rt_rts_rt_hash` ON `%[1]s`(`rt_hash`)"},
+				MySQL:  []string{"ALTER TABLE `%[1]s` ADD INDEX `idx_rt_rts_rt_hash` (`rt_hash`)"},
+			})
+		},
+	},
+	{
+		Version: 9,
+		Name:    "create_token_lineages",
+		Up: func(s *schema) error {
+			return s.createTable(tableName("token_lineages"), dialectSQL{
+				SQLite: []string{
+					"CREATE TABLE `%[1]s` (`id` integer PRIMARY KEY AUTOINCREMENT,`rt_id` integer NOT NULL,`parent_id` integer,`rt` text,`at` text,`at_expire_time` datetime DEFAULT null,`source` varchar(20),`refresh_log_id` integer,`promoted_from` integer,`operator` varchar(255),`create_time` datetime)",
+					"CREATE INDEX `idx_token_lineages_rt_id` ON `%[1]s`(`rt_id`)",
+				},
+				MySQL: []string{
+					"CREATE TABLE `%[1]s` (" +
+						"`id` bigint NOT NULL AUTO_INCREMENT COMMENT '主键ID'," +
+						"`create_time` datetime(3) DEFAULT NULL COMMENT '创建时间'," +
+						"`rt_id` bigint NOT NULL COMMENT 'RT ID'," +
+						"`parent_id` bigint DEFAULT NULL COMMENT '产生本条记录时的当前谱系记录'," +
+						"`rt` text COMMENT 'Refresh Token'," +
+						"`at` text COMMENT 'Access Token'," +
+						"`at_expire_time` datetime DEFAULT NULL COMMENT 'Access Token 过期时间'," +
+						"`source` varchar(20) DEFAULT NULL COMMENT '来源（create, refresh, recover, promote）'," +
+						"`refresh_log_id` bigint DEFAULT NULL COMMENT '产生该 token 的刷新记录ID'," +
+						"`promoted_from` bigint DEFAULT NULL COMMENT 'promote 时被恢复的谱系记录ID'," +
+						"`operator` varchar(255) DEFAULT NULL COMMENT '操作人'," +
+						"PRIMARY KEY (`id`)," +
+						"KEY `idx_token_lineages_rt_id` (`rt_id`)" +
+						") ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='token 谱系表'",
+				},
+			})
+		},
+	},
+	{
+		Version: 10,
+		Name:    "add_rts_id_token",
+		Up: func(s *schema) error {
+			table := tableName("rts")
+			if err := s.addColumn(table, "id_token", dialectSQL{
+				SQLite: []string{"ALTER TABLE `%[1]s` ADD COLUMN `id_token` text"},
+				MySQL:  []string{"ALTER TABLE `%[1]s` ADD COLUMN `id_token` text COMMENT 'id_token' AFTER `at`"},
+			}); err != nil {
+				return err
+			}
+			return s.addColumn(table, "account_id", dialectSQL{
+				SQLite: []string{"ALTER TABLE `%[1]s` ADD COLUMN `account_id` varchar(255)"},
+				MySQL:  []string{"ALTER TABLE `%[1]s` ADD COLUMN `account_id` varchar(255) DEFAULT NULL COMMENT 'ChatGPT 账号ID（取自 id_token）' AFTER `id_token`"},
+			})
+		},
+	},
+	{
+		Version: 11,
+		Name:    "add_rts_credential_type",
+		Up: func(s *schema) error {
+			return s.addColumn(tableName("rts"), "credential_type", dialectSQL{
+				SQLite: []string{"ALTER TABLE `%[1]s` ADD COLUMN `credential_type` varchar(20) NOT NULL DEFAULT 'rt'"},
+				MySQL:  []string{"ALTER TABLE `%[1]s` ADD COLUMN `credential_type` varchar(20) NOT NULL DEFAULT 'rt' COMMENT '凭证类型（rt, at）' AFTER `type`"},
+			})
+		},
+	},
+	{
+		Version: 12,
+		Name:    "create_leases",
+		Up: func(s *schema) error {
+			return s.createTable(tableName("leases"), dialectSQL{
+				SQLite: []string{
+					"CREATE TABLE `%[1]s` (`id` integer PRIMARY KEY AUTOINCREMENT,`lease_id` varchar(64) NOT NULL,`rt_id` integer NOT NULL,`biz_id` varchar(255),`consumer` varchar(255),`exclusive` numeric NOT NULL DEFAULT false,`strategy` varchar(20),`status` varchar(20),`expire_time` datetime,`release_time` datetime DEFAULT null,`create_time` datetime,`update_time` datetime)",
+					"CREATE UNIQUE INDEX `idx_leases_lease_id` ON `%[1]s`(`lease_id`)",
+					"CREATE INDEX `idx_leases_rt_id` ON `%[1]s`(`rt_id`)",
+					"CREATE INDEX `idx_leases_status` ON `%[1]s`(`status`)",
+				},
+				MySQL: []string{
+					"CREATE TABLE `%[1]s` (" +
+						"`id` bigint NOT NULL AUTO_INCREMENT COMMENT '主键ID'," +
+						"`create_time` datetime(3) DEFAULT NULL COMMENT '创建时间'," +
+						"`update_time` datetime(3) DEFAULT NULL COMMENT '更新时间'," +
+						"`lease_id` varchar(64) NOT NULL COMMENT '租约ID'," +
+						"`rt_id` bigint NOT NULL COMMENT 'RT ID'," +
+						"`biz_id` varchar(255) DEFAULT NULL COMMENT '业务ID'," +
+						"`consumer` varchar(255) DEFAULT NULL COMMENT '调用方标识'," +
+						"`exclusive` tinyint(1) NOT NULL DEFAULT '0' COMMENT '是否独占'," +
+						"`strategy` varchar(20) DEFAULT NULL COMMENT '选择策略（round_robin, lru）'," +
+						"`status` varchar(20) DEFAULT NULL COMMENT '状态（active, released, expired）'," +
+						"`expire_time` datetime DEFAULT NULL COMMENT '租约到期时间'," +
+						"`release_time` datetime DEFAULT NULL COMMENT '释放时间'," +
+						"PRIMARY KEY (`id`)," +
+						"UNIQUE KEY `idx_leases_lease_id` (`lease_id`)," +
+						"KEY `idx_leases_rt_id` (`rt_id`)," +
+						"KEY `idx_leases_status` (`status`)" +
+						") ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='AT 租约表'",
+				},
+			})
+		},
+	},
+	{
+		Version: 13,
+		Name:    "add_rts_cooldown",
+		Up: func(s *schema) error {
+			table := tableName("rts")
+			if err := s.addColumn(table, "cooldown_until", dialectSQL{
+				SQLite: []string{"ALTER TABLE `%[1]s` ADD COLUMN `cooldown_until` datetime DEFAULT null"},
+				MySQL:  []string{"ALTER TABLE `%[1]s` ADD COLUMN `cooldown_until` datetime DEFAULT NULL COMMENT '冷却截止时间（调用方反馈 401/429）' AFTER `next_refresh_time`"},
+			}); err != nil {
+				return err
+			}
+			return s.addColumn(table, "cooldown_reason", dialectSQL{
+				SQLite: []string{"ALTER TABLE `%[1]s` ADD COLUMN `cooldown_reason` varchar(50)"},
+				MySQL:  []string{"ALTER TABLE `%[1]s` ADD COLUMN `cooldown_reason` varchar(50) DEFAULT NULL COMMENT '冷却原因（rate_limited, unauthorized）' AFTER `cooldown_until`"},
+			})
+		},
+	},
+	{
+		Version: 14,
+		Name:    "create_api_keys",
+		Up: func(s *schema) error {
+			return s.createTable(tableName("api_keys"), dialectSQL{
+				SQLite: []string{
+					"CREATE TABLE `%[1]s` (`id` integer PRIMARY KEY AUTOINCREMENT,`name` varchar(255) NOT NULL,`key_prefix` varchar(20),`key_hash` varchar(64) NOT NULL,`allowed_endpoints` text,`allowed_tags` text,`allowed_types` text,`enabled` numeric NOT NULL DEFAULT true,`expire_time` datetime DEFAULT null,`last_used_time` datetime DEFAULT null,`last_used_ip` varchar(64),`memo` text,`create_time` datetime,`update_time` datetime)",
+					"CREATE UNIQUE INDEX `idx_api_keys_key_hash` ON `%[1]s`(`key_hash`)",
+				},
+				MySQL: []string{
+					"CREATE TABLE `%[1]s` (" +
+						"`id` bigint NOT NULL AUTO_INCREMENT COMMENT '主键ID'," +
+						"`create_time` datetime(3) DEFAULT NULL COMMENT '创建时间'," +
+						"`update_time` datetime(3) DEFAULT NULL COMMENT '更新时间'," +
+						"`name` varchar(255) NOT NULL COMMENT '名称'," +
+						"`key_prefix` varchar(20) DEFAULT NULL COMMENT '密钥前缀（用于识别）'," +
+						"`key_hash` varchar(64) NOT NULL COMMENT '密钥的 SHA-256'," +
+						"`allowed_endpoints` text COMMENT '允许的接口（逗号分隔，为空不限制）'," +
+						"`allowed_tags` text COMMENT '允许的标签（逗号分隔，为空不限制）'," +
+						"`allowed_types` text COMMENT '允许的账号类型（逗号分隔，为空不限制）'," +
+						"`enabled` tinyint(1) NOT NULL DEFAULT '1' COMMENT '是否启用'," +
+						"`expire_time` datetime DEFAULT NULL COMMENT '过期时间'," +
+						"`last_used_time` datetime DEFAULT NULL COMMENT '最后使用时间'," +
+						"`last_used_ip` varchar(64) DEFAULT NULL COMMENT '最后使用IP'," +
+						"`memo` text COMMENT '备注'," +
+						"PRIMARY KEY (`id`)," +
+						"UNIQUE KEY `idx_api_keys_key_hash` (`key_hash`)" +
+						") ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='对外 API 密钥表'",
+				},
+			})
+		},
+	},
+	{
+		Version: 15,
+		Name:    "add_api_keys_require_signature",
+		Up: func(s *schema) error {
+			table := tableName("api_keys")
+			if err := s.addColumn(table, "require_signature", dialectSQL{
+				SQLite: []string{"ALTER TABLE `%[1]s` ADD COLUMN `require_signature` numeric NOT NULL DEFAULT false"},
+				MySQL:  []string{"ALTER TABLE `%[1]s` ADD COLUMN `require_signature` tinyint(1) NOT NULL DEFAULT '0' COMMENT '只接受签名请求' AFTER `enabled`"},
+			}); err != nil {
+				return err
+			}
+			return s.createIndex(table, "idx_api_keys_key_prefix", dialectSQL{
+				SQLite: []string{"CREATE INDEX `idx_api_keys_key_prefix` ON `%[1]s`(`key_prefix`)"},
+				MySQL:  []string{"CREATE INDEX `idx_api_keys_key_prefix` ON `%[1]s`(`key_prefix`)"},
+			})
+		},
+	},
+	{
+		Version: 16,
+		Name:    "create_admin_users",
+		Up: func(s *schema) error {
+			return s.createTable(tableName("admin_users"), dialectSQL{
+				SQLite: []string{
+					"CREATE TABLE `%[1]s` (`id` integer PRIMARY KEY AUTOINCREMENT,`username` varchar(100) NOT NULL,`password_hash` varchar(255) NOT NULL,`role` varchar(20) NOT NULL,`enabled` numeric NOT NULL DEFAULT true,`last_login_time` datetime DEFAULT null,`last_login_ip` varchar(64),`memo` text,`create_time` datetime,`update_time` datetime)",
+					"CREATE UNIQUE INDEX `idx_admin_users_username` ON `%[1]s`(`username`)",
+				},
+				MySQL: []string{
+					"CREATE TABLE `%[1]s` (" +
+						"`id` bigint NOT NULL AUTO_INCREMENT COMMENT '主键ID'," +
+						"`create_time` datetime(3) DEFAULT NULL COMMENT '创建时间'," +
+						"`update_time` datetime(3) DEFAULT NULL COMMENT '更新时间'," +
+						"`username` varchar(100) NOT NULL COMMENT '用户名'," +
+						"`password_hash` varchar(255) NOT NULL COMMENT '密码的 bcrypt 哈希'," +
+						"`role` varchar(20) NOT NULL COMMENT '角色（admin, operator, viewer）'," +
+						"`enabled` tinyint(1) NOT NULL DEFAULT '1' COMMENT '是否启用'," +
+						"`last_login_time` datetime DEFAULT NULL COMMENT '最后登录时间'," +
+						"`last_login_ip` varchar(64) DEFAULT NULL COMMENT '最后登录IP'," +
+						"`memo` text COMMENT '备注'," +
+						"PRIMARY KEY (`id`)," +
+						"UNIQUE KEY `idx_admin_users_username` (`username`)" +
+						") ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='管理后台账号表'",
+				},
+			})
+		},
+	},
+	{
+		Version: 17,
+		Name:    "create_audit_events",
+		Up: func(s *schema) error {
+			return s.createTable(tableName("audit_events"), dialectSQL{
+				SQLite: []string{
+					"CREATE TABLE `%[1]s` (`id` integer PRIMARY KEY AUTOINCREMENT,`actor_type` varchar(20) NOT NULL,`actor` varchar(150),`api_key_id` integer DEFAULT null,`client_ip` varchar(64),`action` varchar(100) NOT NULL,`target_type` varchar(50),`target_ids` text,`changes` text,`status_code` integer,`success` numeric NOT NULL,`create_time` datetime)",
+					"CREATE INDEX `idx_audit_events_actor` ON `%[1]s`(`actor`)",
+					"CREATE INDEX `idx_audit_events_action` ON `%[1]s`(`action`)",
+					"CREATE INDEX `idx_audit_events_create_time` ON `%[1]s`(`create_time`)",
+				},
+				MySQL: []string{
+					"CREATE TABLE `%[1]s` (" +
+						"`id` bigint NOT NULL AUTO_INCREMENT COMMENT '主键ID'," +
+						"`create_time` datetime(3) DEFAULT NULL COMMENT '创建时间'," +
+						"`actor_type` varchar(20) NOT NULL COMMENT '操作者类型（jwt, api_key, anonymous）'," +
+						"`actor` varchar(150) DEFAULT NULL COMMENT '操作者（用户名或 api_key:名称）'," +
+						"`api_key_id` bigint DEFAULT NULL COMMENT 'API 密钥ID'," +
+						"`client_ip` varchar(64) DEFAULT NULL COMMENT '客户端IP'," +
+						"`action` varchar(100) NOT NULL COMMENT '操作'," +
+						"`target_type` varchar(50) DEFAULT NULL COMMENT '操作对象类型'," +
+						"`target_ids` text COMMENT '操作对象ID（逗号分隔）'," +
+						"`changes` text COMMENT '修改前后内容（JSON，token 已脱敏）'," +
+						"`status_code` int DEFAULT NULL COMMENT 'HTTP 状态码'," +
+						"`success` tinyint(1) NOT NULL COMMENT '是否成功'," +
+						"PRIMARY KEY (`id`)," +
+						"KEY `idx_audit_events_actor` (`actor`)," +
+						"KEY `idx_audit_events_action` (`action`)," +
+						"KEY `idx_audit_events_create_time` (`create_time`)" +
+						") ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='审计事件表'",
+				},
+			})
+		},
+	},
+	{
+		Version: 18,
+		Name:    "create_admin_sessions",
+		Up: func(s *schema) error {
+			return s.createTable(tableName("admin_sessions"), dialectSQL{
+				SQLite: []string{
+					"CREATE TABLE `%[1]s` (`id` integer PRIMARY KEY AUTOINCREMENT,`session_id` varchar(64) NOT NULL,`user_id` integer NOT NULL,`username` varchar(100),`refresh_token_hash` varchar(64) NOT NULL,`previous_token_hash` varchar(64),`client_ip` varchar(64),`user_agent` varchar(255),`expire_time` datetime NOT NULL,`last_refresh_time` datetime DEFAULT null,`revoked_time` datetime DEFAULT null,`revoke_reason` varchar(50),`create_time` datetime,`update_time` datetime)",
+					"CREATE UNIQUE INDEX `idx_admin_sessions_session_id` ON `%[1]s`(`session_id`)",
+					"CREATE INDEX `idx_admin_sessions_user_id` ON `%[1]s`(`user_id`)",
+					"CREATE UNIQUE INDEX `idx_admin_sessions_refresh_token_hash` ON `%[1]s`(`refresh_token_hash`)",
+					"CREATE INDEX `idx_admin_sessions_previous_token_hash` ON `%[1]s`(`previous_token_hash`)",
+				},
+				MySQL: []string{
+					"CREATE TABLE `%[1]s` (" +
+						"`id` bigint NOT NULL AUTO_INCREMENT COMMENT '主键ID'," +
+						"`create_time` datetime(3) DEFAULT NULL COMMENT '创建时间'," +
+						"`update_time` datetime(3) DEFAULT NULL COMMENT '更新时间'," +
+						"`session_id` varchar(64) NOT NULL COMMENT '会话ID（JWT 中的 sid）'," +
+						"`user_id` bigint NOT NULL COMMENT '管理后台账号ID'," +
+						"`username` varchar(100) DEFAULT NULL COMMENT '用户名'," +
+						"`refresh_token_hash` varchar(64) NOT NULL COMMENT '当前刷新令牌的 SHA-256'," +
+						"`previous_token_hash` varchar(64) DEFAULT NULL COMMENT '上一个刷新令牌的 SHA-256，用于发现令牌重放'," +
+						"`client_ip` varchar(64) DEFAULT NULL COMMENT '登录IP'," +
+						"`user_agent` varchar(255) DEFAULT NULL COMMENT '登录 User-Agent'," +
+						"`expire_time` datetime NOT NULL COMMENT '刷新令牌过期时间'," +
+						"`last_refresh_time` datetime DEFAULT NULL COMMENT '最后刷新时间'," +
+						"`revoked_time` datetime DEFAULT NULL COMMENT '注销时间'," +
+						"`revoke_reason` varchar(50) DEFAULT NULL COMMENT '注销原因'," +
+						"PRIMARY KEY (`id`)," +
+						"UNIQUE KEY `idx_admin_sessions_session_id` (`session_id`)," +
+						"KEY `idx_admin_sessions_user_id` (`user_id`)," +
+						"UNIQUE KEY `idx_admin_sessions_refresh_token_hash` (`refresh_token_hash`)," +
+						"KEY `idx_admin_sessions_previous_token_hash` (`previous_token_hash`)" +
+						") ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='管理后台登录会话表'",
+				},
+			})
+		},
+	},
+	{
+		Version: 19,
+		Name:    "add_leases_api_key_id",
+		Up: func(s *schema) error {
+			table := tableName("leases")
+			if err := s.addColumn(table, "api_key_id", dialectSQL{
+				SQLite: []string{"ALTER TABLE `%[1]s` ADD COLUMN `api_key_id` integer"},
+				MySQL:  []string{"ALTER TABLE `%[1]s` ADD COLUMN `api_key_id` bigint DEFAULT NULL COMMENT '租用时使用的API密钥ID' AFTER `consumer`"},
+			}); err != nil {
+				return err
+			}
+			return s.createIndex(table, "idx_leases_api_key_id", dialectSQL{
+				SQLite: []string{"CREATE INDEX `idx_leases_api_key_id` ON `%[1]s`(`api_key_id`)"},
+				MySQL:  []string{"CREATE INDEX `idx_leases_api_key_id` ON `%[1]s`(`api_key_id`)"},
+			})
+		},
+	},
+	{
+		Version: 20,
+		Name:    "add_api_keys_signing_secret",
+		Up: func(s *schema) error {
+			return s.addColumn(tableName("api_keys"), "signing_secret", dialectSQL{
+				SQLite: []string{"ALTER TABLE `%[1]s` ADD COLUMN `signing_secret` text"},
+				MySQL:  []string{"ALTER TABLE `%[1]s` ADD COLUMN `signing_secret` text COMMENT '签名请求的 HMAC 密钥（启用加密时加密保存）' AFTER `key_hash`"},
+			})
+		},
+	},
+	{
+		Version: 21,
+		Name:    "widen_audit_events_changes",
+		Up: func(s *schema) error {
+			// 批量操作按RT记录修改内容，可能超过 MySQL text 的 64KB 上限；SQLite 的 text 不限长度
+			if s.dialect != "mysql" {
+				return nil
+			}
+			return s.exec(tableName("audit_events"), dialectSQL{
+				MySQL: []string{
+					"ALTER TABLE `%[1]s` MODIFY COLUMN `target_ids` mediumtext COMMENT '操作对象ID（逗号分隔）'",
+					"ALTER TABLE `%[1]s` MODIFY COLUMN `changes` mediumtext COMMENT '修改前后内容（JSON，token 已脱敏）'",
+				},
+			})
+		},
+	},
+}
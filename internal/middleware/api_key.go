@@ -0,0 +1,133 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"rt-manage/internal/config"
+	"rt-manage/internal/model"
+	"rt-manage/internal/service"
+	"rt-manage/pkg/logger"
+)
+
+// legacyAPIKeyName 配置文件中的 auth.api_secret 视为一个拥有全部权限的密钥
+const legacyAPIKeyName = "auth.api_secret"
+
+// APIKeyAuth 对外API密钥认证中间件，按密钥允许的接口校验；prefix 为对外API路由前缀
+// 支持两种方式：直接通过 X-API-Key（兼容 X-API-Secret）请求头传递密钥；
+// 或带 X-Signature 的签名请求（X-Key-Id、X-Timestamp、X-Nonce），密钥不出现在请求中，且请求无法被重放
+func APIKeyAuth(apiKeyService service.APIKeyService, prefix string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var key *model.APIKey
+		var err error
+		if c.GetHeader("X-Signature") != "" {
+			key, err = authenticateSigned(c, apiKeyService)
+		} else {
+			plaintext := c.GetHeader("X-API-Key")
+			if plaintext == "" {
+				plaintext = c.GetHeader("X-API-Secret")
+			}
+
+			if plaintext == "" {
+				logger.Warn("API请求缺少密钥", "path", c.Request.URL.Path, "ip", c.ClientIP())
+				c.JSON(http.StatusUnauthorized, gin.H{
+					"success": false,
+					"msg":     "缺少API密钥",
+				})
+				c.Abort()
+				return
+			}
+			key, err = authenticateAPIKey(apiKeyService, plaintext, c.ClientIP())
+		}
+
+		if err != nil {
+			status := http.StatusUnauthorized
+			msg := err.Error()
+			if !isAuthError(err) {
+				logger.Error("API密钥验证出错", "path", c.Request.URL.Path, "error", err)
+				status = http.StatusInternalServerError
+				msg = "API密钥验证失败"
+			}
+			logger.Warn("API密钥验证失败", "path", c.Request.URL.Path, "ip", c.ClientIP(), "error", err)
+			c.JSON(status, gin.H{
+				"success": false,
+				"msg":     msg,
+			})
+			c.Abort()
+			return
+		}
+
+		endpoint := strings.TrimPrefix(c.FullPath(), prefix+"/")
+		if endpoint != "health" && !key.AllowsEndpoint(endpoint) {
+			logger.Warn("API密钥无权调用该接口", "key_id", key.ID, "key_name", key.Name, "endpoint", endpoint, "ip", c.ClientIP())
+			c.JSON(http.StatusForbidden, gin.H{
+				"success": false,
+				"msg":     "API密钥无权调用该接口",
+			})
+			c.Abort()
+			return
+		}
+
+		c.Set("api_key", key)
+		c.Set("endpoint", endpoint)
+		c.Set("username", "api_key:"+key.Name)
+		c.Set("auth_type", "api_key")
+		c.Next()
+	}
+}
+
+// authenticateAPIKey 先校验配置文件中的 auth.api_secret（未配置时不启用），再查找数据库中的密钥
+func authenticateAPIKey(apiKeyService service.APIKeyService, plaintext string, ip string) (*model.APIKey, error) {
+	legacySecret := config.Get().Auth.APISecret
+	if legacySecret != "" && subtle.ConstantTimeCompare([]byte(plaintext), []byte(legacySecret)) == 1 {
+		return &model.APIKey{Name: legacyAPIKeyName, Enabled: true}, nil
+	}
+	return apiKeyService.Authenticate(plaintext, ip)
+}
+
+// authenticateSigned 校验签名请求，读取请求体计算哈希后放回，供后续处理器读取
+func authenticateSigned(c *gin.Context, apiKeyService service.APIKeyService) (*model.APIKey, error) {
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		return nil, err
+	}
+	c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+	return apiKeyService.AuthenticateSigned(&service.SignedRequest{
+		KeyID:     c.GetHeader("X-Key-Id"),
+		Method:    c.Request.Method,
+		Path:      c.Request.URL.RequestURI(),
+		Timestamp: c.GetHeader("X-Timestamp"),
+		Nonce:     c.GetHeader("X-Nonce"),
+		Body:      body,
+		Signature: c.GetHeader("X-Signature"),
+	}, c.ClientIP())
+}
+
+// isAuthError 是否为认证失败（返回 401），其他错误为服务端错误
+func isAuthError(err error) bool {
+	for _, target := range []error{
+		service.ErrAPIKeyInvalid, service.ErrAPIKeyDisabled, service.ErrAPIKeyExpired,
+		service.ErrSignatureRequired, service.ErrSignatureInvalid, service.ErrSignatureExpired, service.ErrNonceReused,
+	} {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// CurrentAPIKey 获取当前请求认证通过的API密钥，未经 APIKeyAuth 认证时返回 nil
+func CurrentAPIKey(c *gin.Context) *model.APIKey {
+	if value, ok := c.Get("api_key"); ok {
+		if key, ok := value.(*model.APIKey); ok {
+			return key
+		}
+	}
+	return nil
+}
@@ -0,0 +1,132 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"rt-manage/internal/config"
+	"rt-manage/internal/model"
+	"rt-manage/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 审计信息在请求上下文中的键
+const (
+	auditTargetTypeKey = "audit_target_type"
+	auditTargetIDsKey  = "audit_target_ids"
+	auditChangesKey    = "audit_changes"
+)
+
+// auditMaxPeekBody 预读请求体（解析默认操作对象）的最大长度
+const auditMaxPeekBody = 1 << 20
+
+// auditTargetTypes 管理接口路由分组对应的默认操作对象类型
+var auditTargetTypes = map[string]string{
+	"rts":      model.AuditTargetRT,
+	"leases":   model.AuditTargetLease,
+	"configs":  model.AuditTargetConfig,
+	"api-keys": model.AuditTargetAPIKey,
+	"users":    model.AuditTargetAdminUser,
+	"user":     model.AuditTargetAdminUser,
+	"auth":     model.AuditTargetAdminUser,
+	"sessions": model.AuditTargetSession,
+}
+
+// Audit 审计中间件，请求结束后保存审计事件（须在认证中间件之后）
+// 操作名由路由去掉 prefix 后得到，如 /internalweb/v1/rts/delete 为 rts.delete，对外API为 public.get-at
+// 操作对象默认取请求体中的 id、ids，处理器可通过 SetAuditTarget、SetAuditChanges 补充
+func Audit(auditService service.AuditService, prefix string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !config.Get().Audit.Enabled || c.GetString("endpoint") == "health" {
+			c.Next()
+			return
+		}
+
+		action := strings.ReplaceAll(strings.TrimPrefix(c.FullPath(), prefix+"/"), "/", ".")
+		targetType := auditTargetTypes[strings.SplitN(action, ".", 2)[0]]
+		if c.GetString("auth_type") == model.AuditActorAPIKey {
+			action = "public." + action
+		}
+		targetIDs := peekTargetIDs(c)
+
+		c.Next()
+
+		if t := c.GetString(auditTargetTypeKey); t != "" {
+			targetType = t
+			targetIDs = c.GetString(auditTargetIDsKey)
+		}
+
+		event := &model.AuditEvent{
+			ActorType:  c.GetString("auth_type"),
+			Actor:      c.GetString("username"),
+			ClientIP:   c.ClientIP(),
+			Action:     action,
+			TargetType: targetType,
+			TargetIDs:  targetIDs,
+			Changes:    c.GetString(auditChangesKey),
+			StatusCode: c.Writer.Status(),
+			Success:    c.Writer.Status() < 400,
+		}
+		if event.ActorType == "" {
+			event.ActorType = model.AuditActorAnonymous
+		}
+		if key := CurrentAPIKey(c); key != nil && key.ID > 0 {
+			event.APIKeyID = &key.ID
+		}
+		auditService.Record(event)
+	}
+}
+
+// SetAuditTarget 设置审计事件的操作对象
+func SetAuditTarget(c *gin.Context, targetType string, ids ...interface{}) {
+	parts := make([]string, len(ids))
+	for i, id := range ids {
+		parts[i] = fmt.Sprint(id)
+	}
+	c.Set(auditTargetTypeKey, targetType)
+	c.Set(auditTargetIDsKey, strings.Join(parts, ","))
+}
+
+// SetAuditChanges 设置审计事件的修改前后内容（before 为 nil 表示新建，after 为 nil 表示删除）
+func SetAuditChanges(c *gin.Context, before, after interface{}) {
+	c.Set(auditChangesKey, service.AuditChanges(before, after))
+}
+
+// SetAuditBatchChanges 设置批量操作的修改前后内容，按对象分别记录
+func SetAuditBatchChanges(c *gin.Context, items []service.AuditBatchItem) {
+	c.Set(auditChangesKey, service.AuditBatchChanges(items))
+}
+
+// peekTargetIDs 预读 JSON 请求体中的 id、ids，读取后恢复请求体（文件上传不处理）
+func peekTargetIDs(c *gin.Context) string {
+	if c.Request.Body == nil || strings.HasPrefix(c.ContentType(), "multipart/") ||
+		c.Request.ContentLength <= 0 || c.Request.ContentLength > auditMaxPeekBody {
+		return ""
+	}
+	body, err := io.ReadAll(c.Request.Body)
+	c.Request.Body = io.NopCloser(bytes.NewReader(body))
+	if err != nil {
+		return ""
+	}
+
+	var req struct {
+		ID  json.Number   `json:"id"`
+		IDs []json.Number `json:"ids"`
+	}
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	_ = decoder.Decode(&req)
+
+	ids := make([]string, 0, len(req.IDs)+1)
+	if req.ID != "" {
+		ids = append(ids, req.ID.String())
+	}
+	for _, id := range req.IDs {
+		ids = append(ids, id.String())
+	}
+	return strings.Join(ids, ",")
+}
@@ -4,14 +4,17 @@ import (
 	"strings"
 
 	"rt-manage/internal/config"
+	"rt-manage/internal/model"
+	"rt-manage/internal/service"
 	jwtutil "rt-manage/pkg/jwt"
 	"rt-manage/pkg/logger"
 
 	"github.com/gin-gonic/gin"
 )
 
-// JWTAuth JWT认证中间件（支持JWT Token和API Secret两种方式）
-func JWTAuth() gin.HandlerFunc {
+// JWTAuth JWT认证中间件（管理后台只接受登录获得的 JWT，对外API密钥不能用于管理接口）
+// 令牌所属的登录会话被注销后立即失效，无需更换 jwt_secret
+func JWTAuth(sessionService service.AdminSessionService) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// 获取Authorization header
 		authHeader := c.GetHeader("Authorization")
@@ -38,20 +41,9 @@ func JWTAuth() gin.HandlerFunc {
 		token := parts[1]
 		cfg := config.Get()
 
-		// 方式1: 检查是否为 API Secret（固定密码）
-		if cfg.Auth.APISecret != "" && token == cfg.Auth.APISecret {
-			logger.Debug("使用API Secret认证通过")
-			// API Secret 认证成功，设置默认用户名
-			c.Set("username", "api_user")
-			c.Set("auth_type", "api_secret")
-			c.Next()
-			return
-		}
-
-		// 方式2: 尝试作为 JWT Token 解析
 		claims, err := jwtutil.ParseToken(token, cfg.Auth.JWTSecret)
 		if err != nil {
-			logger.Debug("JWT解析失败，且不匹配API Secret", "error", err)
+			logger.Debug("JWT解析失败", "error", err)
 			c.JSON(401, gin.H{
 				"success": false,
 				"msg":     "认证失败：令牌无效或已过期",
@@ -60,10 +52,42 @@ func JWTAuth() gin.HandlerFunc {
 			return
 		}
 
+		// 旧版本签发的令牌没有角色，需重新登录
+		if model.RoleLevel(claims.Role) == 0 {
+			c.JSON(401, gin.H{
+				"success": false,
+				"msg":     "认证失败：令牌缺少角色信息，请重新登录",
+			})
+			c.Abort()
+			return
+		}
+
+		// 旧版本签发的令牌不属于任何会话，无法注销，需重新登录
+		if claims.SessionID == "" {
+			c.JSON(401, gin.H{
+				"success": false,
+				"msg":     "认证失败：令牌缺少会话信息，请重新登录",
+			})
+			c.Abort()
+			return
+		}
+
+		if err := sessionService.Validate(claims.SessionID); err != nil {
+			logger.Debug("登录会话校验失败", "username", claims.Username, "session_id", claims.SessionID, "error", err)
+			c.JSON(401, gin.H{
+				"success": false,
+				"msg":     "认证失败：登录会话已失效，请重新登录",
+			})
+			c.Abort()
+			return
+		}
+
 		// JWT 认证成功
-		logger.Debug("使用JWT Token认证通过", "username", claims.Username)
+		logger.Debug("使用JWT Token认证通过", "username", claims.Username, "role", claims.Role)
 		c.Set("username", claims.Username)
+		c.Set("role", claims.Role)
 		c.Set("auth_type", "jwt")
+		c.Set("session_id", claims.SessionID)
 		c.Next()
 	}
 }
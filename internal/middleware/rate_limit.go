@@ -0,0 +1,158 @@
+package middleware
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"rt-manage/internal/config"
+	"rt-manage/internal/model"
+	"rt-manage/pkg/logger"
+	"rt-manage/pkg/ratelimit"
+)
+
+// publicLimiter 对外API的令牌桶（包级共享），空闲 30 分钟的桶会被清理
+var publicLimiter = ratelimit.New(30 * time.Minute)
+
+// 限流分组
+const (
+	RateLimitGroupRefresh = "refresh"
+	RateLimitGroupGetAT   = "get-at"
+	RateLimitGroupDefault = "default"
+)
+
+// 限流维度
+const (
+	RateLimitScopeKey     = "key"
+	RateLimitScopeIP      = "ip"
+	RateLimitScopeAccount = "account"
+)
+
+// RateLimitStat 令牌桶计数，key 拆分为分组、维度和对象
+type RateLimitStat struct {
+	Group string `json:"group"`
+	Scope string `json:"scope"`
+	ID    string `json:"id"` // API 密钥ID、客户端IP 或 RT ID
+	ratelimit.Stat
+}
+
+// rateLimitGroup 接口所属的限流分组及规则
+func rateLimitGroup(endpoint string) (string, config.RateLimitGroup) {
+	cfg := config.Get().RateLimit
+	switch endpoint {
+	case "refresh":
+		return RateLimitGroupRefresh, cfg.Refresh
+	case "get-at":
+		return RateLimitGroupGetAT, cfg.GetAT
+	default:
+		return RateLimitGroupDefault, cfg.Default
+	}
+}
+
+func toRule(rule config.RateLimitRule) ratelimit.Rule {
+	return ratelimit.Rule{PerMinute: rule.PerMinute, Burst: rule.Burst}
+}
+
+// RateLimit 对外API限流中间件（须在 APIKeyAuth 之后），按 API 密钥和客户端 IP 限流
+// 目标账号在处理器中查到账号后由 AllowAccount 限流
+func RateLimit() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		endpoint := c.GetString("endpoint")
+		if !config.Get().RateLimit.Enabled || endpoint == "health" {
+			c.Next()
+			return
+		}
+
+		group, rules := rateLimitGroup(endpoint)
+		now := time.Now()
+
+		keyID := "legacy"
+		if key := CurrentAPIKey(c); key != nil && key.ID > 0 {
+			keyID = strconv.FormatInt(key.ID, 10)
+		}
+		if !allow(c, group, RateLimitScopeKey, keyID, toRule(rules.PerKey), now) {
+			return
+		}
+		if !allow(c, group, RateLimitScopeIP, c.ClientIP(), toRule(rules.PerIP), now) {
+			return
+		}
+		c.Next()
+	}
+}
+
+// AllowAccount 按目标账号限流，超限时返回 429 并终止请求
+func AllowAccount(c *gin.Context, rt *model.RT) bool {
+	if !config.Get().RateLimit.Enabled {
+		return true
+	}
+	group, rules := rateLimitGroup(c.GetString("endpoint"))
+	return allow(c, group, RateLimitScopeAccount, strconv.FormatInt(rt.ID, 10), toRule(rules.PerAccount), time.Now())
+}
+
+// AllowRefresh 其他接口（如 /codex-auth 的 refresh、/report 的 401）触发上游刷新时，
+// 按 refresh 分组的密钥、IP 和账号限额计数，避免绕过 /refresh 的限流；超限时返回 429 并终止请求
+func AllowRefresh(c *gin.Context, rt *model.RT) bool {
+	cfg := config.Get().RateLimit
+	if !cfg.Enabled || c.GetString("endpoint") == "refresh" {
+		return true
+	}
+	rules := cfg.Refresh
+	now := time.Now()
+
+	keyID := "legacy"
+	if key := CurrentAPIKey(c); key != nil && key.ID > 0 {
+		keyID = strconv.FormatInt(key.ID, 10)
+	}
+	return allow(c, RateLimitGroupRefresh, RateLimitScopeKey, keyID, toRule(rules.PerKey), now) &&
+		allow(c, RateLimitGroupRefresh, RateLimitScopeIP, c.ClientIP(), toRule(rules.PerIP), now) &&
+		allow(c, RateLimitGroupRefresh, RateLimitScopeAccount, strconv.FormatInt(rt.ID, 10), toRule(rules.PerAccount), now)
+}
+
+func allow(c *gin.Context, group, scope, id string, rule ratelimit.Rule, now time.Time) bool {
+	ok, wait := publicLimiter.Allow(group+"|"+scope+"|"+id, rule, now)
+	if ok {
+		return true
+	}
+
+	retryAfter := int(math.Ceil(wait.Seconds()))
+	if retryAfter < 1 {
+		retryAfter = 1
+	}
+	logger.Warn("对外API请求被限流", "group", group, "scope", scope, "id", id, "username", c.GetString("username"), "ip", c.ClientIP(), "retry_after", retryAfter)
+	c.Header("Retry-After", strconv.Itoa(retryAfter))
+	c.JSON(http.StatusTooManyRequests, gin.H{
+		"success": false,
+		"msg":     fmt.Sprintf("请求过于频繁，请 %d 秒后重试", retryAfter),
+		"data": gin.H{
+			"scope":       scope,
+			"retry_after": retryAfter,
+		},
+	})
+	c.Abort()
+	return false
+}
+
+// RateLimitStats 获取限流计数，group、scope 为空时不筛选
+func RateLimitStats(group, scope string) []RateLimitStat {
+	prefix := ""
+	if group != "" {
+		prefix = group + "|"
+		if scope != "" {
+			prefix += scope + "|"
+		}
+	}
+
+	stats := make([]RateLimitStat, 0)
+	for _, stat := range publicLimiter.Stats(prefix) {
+		parts := strings.SplitN(stat.Key, "|", 3)
+		if len(parts) != 3 || (scope != "" && parts[1] != scope) {
+			continue
+		}
+		stats = append(stats, RateLimitStat{Group: parts[0], Scope: parts[1], ID: parts[2], Stat: stat})
+	}
+	return stats
+}
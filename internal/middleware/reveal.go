@@ -0,0 +1,48 @@
+package middleware
+
+import (
+	"rt-manage/internal/config"
+	"rt-manage/internal/model"
+	"rt-manage/pkg/logger"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RevealPermission 查看明文 token 的权限校验
+// 仅允许 JWT 登录的 operator 及以上角色访问，配置了 auth.reveal_users 时还需在名单内
+func RevealPermission() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !CanReveal(c) {
+			c.JSON(403, gin.H{
+				"success": false,
+				"msg":     "没有查看明文Token的权限",
+			})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// CanReveal 判断当前请求是否有查看明文 token 的权限，无权限时记录审计日志
+// 用于只有部分参数组合需要明文权限的接口（如导出）
+func CanReveal(c *gin.Context) bool {
+	username := c.GetString("username")
+
+	allowed := c.GetString("auth_type") == "jwt" && HasRole(c, model.RoleOperator)
+	if allowed && len(config.Get().Auth.RevealUsers) > 0 {
+		allowed = false
+		for _, user := range config.Get().Auth.RevealUsers {
+			if user == username {
+				allowed = true
+				break
+			}
+		}
+	}
+
+	if !allowed {
+		logger.Warn("审计：查看明文Token被拒绝", "username", username, "role", c.GetString("role"), "auth_type", c.GetString("auth_type"), "client_ip", c.ClientIP(), "path", c.Request.URL.Path)
+	}
+	return allowed
+}
@@ -0,0 +1,31 @@
+package middleware
+
+import (
+	"rt-manage/internal/model"
+	"rt-manage/pkg/logger"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequireRole 角色校验中间件（须在 JWTAuth 之后），当前角色低于 role 时返回 403
+// 角色权限依次为 viewer < operator < admin
+func RequireRole(role string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !HasRole(c, role) {
+			logger.Warn("权限不足", "username", c.GetString("username"), "role", c.GetString("role"), "required_role", role, "path", c.Request.URL.Path)
+			c.JSON(403, gin.H{
+				"success": false,
+				"msg":     "权限不足，需要 " + role + " 及以上角色",
+			})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// HasRole 判断当前登录用户的角色是否不低于 role
+func HasRole(c *gin.Context, role string) bool {
+	return model.RoleLevel(c.GetString("role")) >= model.RoleLevel(role)
+}
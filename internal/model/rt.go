@@ -1,6 +1,7 @@
 package model
 
 import (
+	"strings"
 	"time"
 )
 
@@ -24,6 +25,25 @@ func withPrefix(tableName string) string {
 	return tablePrefix + "_" + tableName
 }
 
+// 刷新状态（最近一次刷新的结果分类）
+const (
+	RefreshStatusOK                 = "ok"                   // 刷新成功
+	RefreshStatusInvalidGrant       = "invalid_grant"        // RT 无效或已过期
+	RefreshStatusRefreshTokenReused = "refresh_token_reused" // RT 已被使用过
+	RefreshStatusAccountDeactivated = "account_deactivated"  // 账号已停用
+	RefreshStatusNetworkError       = "network_error"        // 网络或代理错误
+	RefreshStatusRateLimited        = "rate_limited"         // 被限流
+	RefreshStatusUpstreamError      = "upstream_error"       // 上游 5xx
+	RefreshStatusUnknownError       = "unknown_error"        // 其他错误
+	RefreshStatusStale              = "stale"                // 仅AT账号的AT已过期
+)
+
+// 凭证类型
+const (
+	CredentialTypeRT = "rt" // 有 RT，可自动刷新获取新的 AT
+	CredentialTypeAT = "at" // 仅有 AT（如 ChatGPT 会话 JSON），AT 过期后需重新导入
+)
+
 // RT 存储 RT token 的模型
 type RT struct {
 	ID              int64     `json:"id" gorm:"primaryKey;autoIncrement"`
@@ -31,18 +51,28 @@ type RT struct {
 	UserName        string    `json:"user_name" gorm:"type:varchar(255)"`
 	Email           string    `json:"email" gorm:"type:varchar(255)"`
 	Type            string    `json:"type" gorm:"type:varchar(50)"`
+	CredentialType  string    `json:"credential_type" gorm:"type:varchar(20);not null;default:'rt'"` // 凭证类型：rt、at
 	Rt              string    `json:"rt" gorm:"type:text;not null"`
+	RtHash          string    `json:"-" gorm:"type:varchar(64);index:idx_rt_rts_rt_hash"` // Rt 的 HMAC，加密存储时用于按 token 查找
 	At              string    `json:"at" gorm:"type:text"`
+	IdToken         string    `json:"id_token" gorm:"type:text"`                     // 刷新时返回的 id_token
+	AccountID       string    `json:"account_id" gorm:"type:varchar(255)"`           // ChatGPT 账号ID（取自 id_token 的声明）
 	Proxy           string    `json:"proxy" gorm:"type:varchar(255)"`
 	ClientID        string    `json:"client_id" gorm:"type:varchar(255)"`
 	Tag             string    `json:"tag" gorm:"type:varchar(255)"`
 	Enabled         bool      `json:"enabled" gorm:"default:false;not null"`
 	LastRT          string     `json:"last_rt" gorm:"type:text"`
 	RefreshResult   string     `json:"refresh_result" gorm:"type:text"`
+	RefreshStatus   string     `json:"refresh_status" gorm:"type:varchar(50);index:idx_rt_rts_refresh_status"`
 	UserInfo        string     `json:"user_info" gorm:"type:text"`
 	AccountInfo     string     `json:"account_info" gorm:"type:text"`
 	LastRefreshTime *time.Time `json:"last_refresh_time" gorm:"type:datetime;default:null"`
+	AtExpireTime    *time.Time `json:"at_expire_time" gorm:"type:datetime;default:null"`
+	NextRefreshTime *time.Time `json:"next_refresh_time" gorm:"type:datetime;default:null;index:idx_rt_rts_next_refresh_time"`
+	CooldownUntil   *time.Time `json:"cooldown_until" gorm:"type:datetime;default:null"` // 调用方反馈 401/429 后的冷却截止时间，冷却期间不参与租用
+	CooldownReason  string     `json:"cooldown_reason" gorm:"type:varchar(50)"`          // 冷却原因：rate_limited、unauthorized
 	Memo            string     `json:"memo" gorm:"type:text"`
+	Version         int64      `json:"version" gorm:"not null;default:0"` // 乐观锁版本号，每次更新加1
 	CreateTime      time.Time `json:"create_time" gorm:"autoCreateTime"`
 	UpdateTime      time.Time `json:"update_time" gorm:"autoUpdateTime"`
 }
@@ -52,6 +82,27 @@ func (RT) TableName() string {
 	return withPrefix("rts")
 }
 
+// IsATOnly 是否为仅AT账号（无RT，无法刷新）
+func (rt *RT) IsATOnly() bool {
+	return rt.CredentialType == CredentialTypeAT
+}
+
+// ATExpired AT 是否已过期，未记录过期时间时视为未过期
+func (rt *RT) ATExpired(now time.Time) bool {
+	return rt.AtExpireTime != nil && !rt.AtExpireTime.After(now)
+}
+
+// InCooldown 账号是否处于调用方反馈触发的冷却期
+func (rt *RT) InCooldown(now time.Time) bool {
+	return rt.CooldownUntil != nil && rt.CooldownUntil.After(now)
+}
+
+// 冷却原因
+const (
+	CooldownReasonRateLimited  = "rate_limited" // 调用方反馈 429
+	CooldownReasonUnauthorized = "unauthorized" // 调用方反馈 401/403
+)
+
 // SystemConfig 系统配置模型
 type SystemConfig struct {
 	ID          int64     `json:"id" gorm:"primaryKey;autoIncrement"`
@@ -65,3 +116,339 @@ type SystemConfig struct {
 func (SystemConfig) TableName() string {
 	return withPrefix("system_configs")
 }
+
+// 刷新触发来源
+const (
+	RefreshTriggerManual    = "manual"     // 管理后台单个刷新
+	RefreshTriggerBatch     = "batch"      // 管理后台批量刷新
+	RefreshTriggerScheduler = "scheduler"  // 调度器自动刷新
+	RefreshTriggerPublicAPI = "public_api" // 对外API刷新
+	RefreshTriggerReport    = "report"     // 调用方反馈 AT 失效后刷新
+)
+
+// RefreshLog 刷新记录（每次刷新尝试一条）
+type RefreshLog struct {
+	ID            int64     `json:"id" gorm:"primaryKey;autoIncrement"`
+	RtID          int64     `json:"rt_id" gorm:"index:idx_refresh_logs_rt_id;not null"`
+	BizId         string    `json:"biz_id" gorm:"type:varchar(255);index:idx_refresh_logs_biz_id"`
+	Trigger       string    `json:"trigger" gorm:"type:varchar(20);index:idx_refresh_logs_trigger"`
+	Proxy         string    `json:"proxy" gorm:"type:varchar(255)"`
+	ClientID      string    `json:"client_id" gorm:"type:varchar(255)"`
+	HTTPStatus    int       `json:"http_status"`
+	RefreshStatus string    `json:"refresh_status" gorm:"type:varchar(50);index:idx_refresh_logs_refresh_status"`
+	ErrorCode     string    `json:"error_code" gorm:"type:varchar(100)"`
+	ErrorMessage  string    `json:"error_message" gorm:"type:text"`
+	LatencyMs     int64     `json:"latency_ms"`
+	ResponseBody  string    `json:"response_body" gorm:"type:text"` // 已脱敏
+	CreateTime    time.Time `json:"create_time" gorm:"autoCreateTime;index:idx_refresh_logs_create_time"`
+}
+
+// TableName 指定表名
+func (RefreshLog) TableName() string {
+	return withPrefix("refresh_logs")
+}
+
+// RT 轮换日志状态
+const (
+	RotationStatusPending  = "pending"  // 已发起上游请求，结果未知
+	RotationStatusReceived = "received" // 已收到新RT，尚未写入RT表
+	RotationStatusUnknown  = "unknown"  // 上游请求结果无法确认（超时、进程中断等）
+)
+
+// TokenRotation RT 轮换日志
+// 上游刷新成功后旧RT立即失效，新RT先写入此表再更新RT表，保证新RT不会因写库失败或进程退出而丢失
+type TokenRotation struct {
+	ID         int64     `json:"id" gorm:"primaryKey;autoIncrement"`
+	RtID       int64     `json:"rt_id" gorm:"index:idx_token_rotations_rt_id;not null"`
+	OldRt      string    `json:"old_rt" gorm:"type:text"`
+	NewRt      string    `json:"new_rt" gorm:"type:text"`
+	NewAt      string    `json:"new_at" gorm:"type:text"`
+	ExpiresIn  int       `json:"expires_in"`
+	Status     string    `json:"status" gorm:"type:varchar(20);index:idx_token_rotations_status"`
+	CreateTime time.Time `json:"create_time" gorm:"autoCreateTime"`
+	UpdateTime time.Time `json:"update_time" gorm:"autoUpdateTime"`
+}
+
+// TableName 指定表名
+func (TokenRotation) TableName() string {
+	return withPrefix("token_rotations")
+}
+
+// SchemaMigration 已执行的数据库迁移记录
+type SchemaMigration struct {
+	Version   int64     `json:"version" gorm:"primaryKey;autoIncrement:false"`
+	Name      string    `json:"name" gorm:"type:varchar(255);not null"`
+	AppliedAt time.Time `json:"applied_at" gorm:"not null"`
+}
+
+// TableName 指定表名
+func (SchemaMigration) TableName() string {
+	return withPrefix("schema_migrations")
+}
+
+// token 谱系来源
+const (
+	LineageSourceCreate  = "create"  // 新增或导入
+	LineageSourceRefresh = "refresh" // 刷新轮换
+	LineageSourceRecover = "recover" // 从轮换日志恢复
+	LineageSourcePromote = "promote" // 管理员将历史 token 恢复为当前 token
+)
+
+// TokenLineage token 谱系（只追加），记录RT签发过的每一个 RT/AT
+type TokenLineage struct {
+	ID           int64      `json:"id" gorm:"primaryKey;autoIncrement"`
+	RtID         int64      `json:"rt_id" gorm:"index:idx_token_lineages_rt_id;not null"`
+	ParentID     int64      `json:"parent_id"`           // 产生本条记录时的当前谱系记录
+	Rt           string     `json:"rt" gorm:"type:text"` // Refresh Token
+	At           string     `json:"at" gorm:"type:text"` // Access Token
+	AtExpireTime *time.Time `json:"at_expire_time" gorm:"type:datetime;default:null"`
+	Source       string     `json:"source" gorm:"type:varchar(20)"`    // create, refresh, recover, promote
+	RefreshLogID int64      `json:"refresh_log_id"`                    // 产生该 token 的刷新记录
+	PromotedFrom int64      `json:"promoted_from"`                     // promote 时被恢复的谱系记录
+	Operator     string     `json:"operator" gorm:"type:varchar(255)"` // 操作人（promote 时）
+	CreateTime   time.Time  `json:"create_time" gorm:"autoCreateTime"`
+}
+
+// TableName 指定表名
+func (TokenLineage) TableName() string {
+	return withPrefix("token_lineages")
+}
+
+// 租约状态
+const (
+	LeaseStatusActive   = "active"   // 使用中
+	LeaseStatusReleased = "released" // 已释放
+	LeaseStatusExpired  = "expired"  // 超时未释放，已自动回收
+)
+
+// 租约选择账号的策略
+const (
+	LeaseStrategyRoundRobin = "round_robin" // 轮询
+	LeaseStrategyLRU        = "lru"         // 最久未被租用的优先
+)
+
+// Lease AT 租约，调用方通过 /lease 从账号池中借用一个账号的 AT，用完后 /release 归还
+type Lease struct {
+	ID          int64      `json:"id" gorm:"primaryKey;autoIncrement"`
+	LeaseID     string     `json:"lease_id" gorm:"type:varchar(64);uniqueIndex:idx_leases_lease_id;not null"`
+	RtID        int64      `json:"rt_id" gorm:"index:idx_leases_rt_id;not null"`
+	BizId       string     `json:"biz_id" gorm:"type:varchar(255)"`
+	Consumer    string     `json:"consumer" gorm:"type:varchar(255)"`             // 调用方标识（可选）
+	APIKeyID    *int64     `json:"api_key_id" gorm:"index:idx_leases_api_key_id"` // 租用时使用的API密钥，只有该密钥可以释放；旧版密钥为空
+	Exclusive   bool       `json:"exclusive" gorm:"not null;default:false"`
+	Strategy    string     `json:"strategy" gorm:"type:varchar(20)"`
+	Status      string     `json:"status" gorm:"type:varchar(20);index:idx_leases_status"`
+	ExpireTime  time.Time  `json:"expire_time" gorm:"type:datetime"`
+	ReleaseTime *time.Time `json:"release_time" gorm:"type:datetime;default:null"`
+	CreateTime  time.Time  `json:"create_time" gorm:"autoCreateTime"`
+	UpdateTime  time.Time  `json:"update_time" gorm:"autoUpdateTime"`
+}
+
+// TableName 指定表名
+func (Lease) TableName() string {
+	return withPrefix("leases")
+}
+
+// APIKey 对外 API 密钥，数据库中只保存哈希，明文仅在创建时返回一次
+// 允许的接口、标签、账号类型为逗号分隔的列表，为空表示不限制
+type APIKey struct {
+	ID               int64      `json:"id" gorm:"primaryKey;autoIncrement"`
+	Name             string     `json:"name" gorm:"type:varchar(255);not null"`
+	KeyPrefix        string     `json:"key_prefix" gorm:"type:varchar(20);index:idx_api_keys_key_prefix"`     // 密钥前几位，用于识别，签名请求中作为密钥ID
+	KeyHash          string     `json:"-" gorm:"type:varchar(64);uniqueIndex:idx_api_keys_key_hash;not null"` // 密钥的 SHA-256
+	SigningSecret    string     `json:"-" gorm:"type:text"`                                                   // 签名请求的 HMAC 密钥（启用加密时加密保存）
+	AllowedEndpoints string     `json:"allowed_endpoints" gorm:"type:text"`                                   // 如 get-at,lease,release
+	AllowedTags      string     `json:"allowed_tags" gorm:"type:text"`
+	AllowedTypes     string     `json:"allowed_types" gorm:"type:text"`
+	Enabled          bool       `json:"enabled" gorm:"not null"`
+	RequireSignature bool       `json:"require_signature" gorm:"not null;default:false"` // 只接受签名请求，不接受直接传递密钥
+	ExpireTime       *time.Time `json:"expire_time" gorm:"type:datetime;default:null"`
+	LastUsedTime     *time.Time `json:"last_used_time" gorm:"type:datetime;default:null"`
+	LastUsedIP       string     `json:"last_used_ip" gorm:"type:varchar(64)"`
+	Memo             string     `json:"memo" gorm:"type:text"`
+	CreateTime       time.Time  `json:"create_time" gorm:"autoCreateTime"`
+	UpdateTime       time.Time  `json:"update_time" gorm:"autoUpdateTime"`
+}
+
+// TableName 指定表名
+func (APIKey) TableName() string {
+	return withPrefix("api_keys")
+}
+
+// Expired 密钥是否已过期，未设置过期时间时永不过期
+func (k *APIKey) Expired(now time.Time) bool {
+	return k.ExpireTime != nil && !k.ExpireTime.After(now)
+}
+
+// AllowsEndpoint 是否允许调用指定接口（如 get-at）
+func (k *APIKey) AllowsEndpoint(endpoint string) bool {
+	return allowed(k.AllowedEndpoints, endpoint)
+}
+
+// AllowsRT 是否允许访问指定账号（标签和账号类型均须在允许范围内）
+func (k *APIKey) AllowsRT(rt *RT) bool {
+	return allowed(k.AllowedTags, rt.Tag) && allowed(k.AllowedTypes, rt.Type)
+}
+
+// LeaseOwnerID 租约记录的密钥ID；旧版密钥（auth.api_secret）没有数据库记录，返回 nil
+func (k *APIKey) LeaseOwnerID() *int64 {
+	if k == nil || k.ID == 0 {
+		return nil
+	}
+	id := k.ID
+	return &id
+}
+
+// TagList 允许的标签，nil 表示不限制
+func (k *APIKey) TagList() []string {
+	return SplitList(k.AllowedTags)
+}
+
+// TypeList 允许的账号类型，nil 表示不限制
+func (k *APIKey) TypeList() []string {
+	return SplitList(k.AllowedTypes)
+}
+
+// SplitList 拆分逗号分隔的列表，去掉空白和空项
+func SplitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// allowed 列表为空时不限制，否则须包含 value
+func allowed(list string, value string) bool {
+	items := SplitList(list)
+	if len(items) == 0 {
+		return true
+	}
+	for _, item := range items {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
+
+// 管理后台角色，权限依次递增
+const (
+	RoleViewer   = "viewer"   // 只读
+	RoleOperator = "operator" // 日常运维：增改、刷新、导入导出、查看明文 token
+	RoleAdmin    = "admin"    // 全部权限：系统配置、API 密钥、管理员账号
+)
+
+// RoleLevel 角色的权限等级，未知角色为 0
+func RoleLevel(role string) int {
+	switch role {
+	case RoleViewer:
+		return 1
+	case RoleOperator:
+		return 2
+	case RoleAdmin:
+		return 3
+	default:
+		return 0
+	}
+}
+
+// AdminUser 管理后台账号，密码以 bcrypt 哈希保存
+type AdminUser struct {
+	ID            int64      `json:"id" gorm:"primaryKey;autoIncrement"`
+	Username      string     `json:"username" gorm:"type:varchar(100);uniqueIndex:idx_admin_users_username;not null"`
+	PasswordHash  string     `json:"-" gorm:"type:varchar(255);not null"`
+	Role          string     `json:"role" gorm:"type:varchar(20);not null"`
+	Enabled       bool       `json:"enabled" gorm:"not null"`
+	LastLoginTime *time.Time `json:"last_login_time" gorm:"type:datetime;default:null"`
+	LastLoginIP   string     `json:"last_login_ip" gorm:"type:varchar(64)"`
+	Memo          string     `json:"memo" gorm:"type:text"`
+	CreateTime    time.Time  `json:"create_time" gorm:"autoCreateTime"`
+	UpdateTime    time.Time  `json:"update_time" gorm:"autoUpdateTime"`
+}
+
+// TableName 指定表名
+func (AdminUser) TableName() string {
+	return withPrefix("admin_users")
+}
+
+// 审计事件的操作者类型
+const (
+	AuditActorJWT       = "jwt"       // 管理后台登录用户
+	AuditActorAPIKey    = "api_key"   // 对外 API 密钥
+	AuditActorAnonymous = "anonymous" // 未认证（如登录）
+)
+
+// 审计事件的操作对象类型
+const (
+	AuditTargetRT        = "rt"
+	AuditTargetLease     = "lease"
+	AuditTargetConfig    = "config"
+	AuditTargetAPIKey    = "api_key"
+	AuditTargetAdminUser = "admin_user"
+	AuditTargetSession   = "session"
+)
+
+// AuditEvent 审计事件，记录管理后台的写操作和查看明文 token 的操作
+type AuditEvent struct {
+	ID         int64     `json:"id" gorm:"primaryKey;autoIncrement"`
+	ActorType  string    `json:"actor_type" gorm:"type:varchar(20);not null"`
+	Actor      string    `json:"actor" gorm:"type:varchar(150);index:idx_audit_events_actor"` // 用户名，API 密钥为 api_key:名称
+	APIKeyID   *int64    `json:"api_key_id" gorm:"default:null"`
+	ClientIP   string    `json:"client_ip" gorm:"type:varchar(64)"`
+	Action     string    `json:"action" gorm:"type:varchar(100);index:idx_audit_events_action;not null"` // 如 rts.delete、public.get-at
+	TargetType string    `json:"target_type" gorm:"type:varchar(50)"`
+	TargetIDs  string    `json:"target_ids" gorm:"type:text"` // 逗号分隔
+	Changes    string    `json:"changes" gorm:"type:text"`    // JSON：{"字段": {"before": 修改前, "after": 修改后}}，批量操作为 [{"id", "biz_id", "changes"}]，token 已脱敏
+	StatusCode int       `json:"status_code"`
+	Success    bool      `json:"success" gorm:"not null"`
+	CreateTime time.Time `json:"create_time" gorm:"autoCreateTime;index:idx_audit_events_create_time"`
+}
+
+// TableName 指定表名
+func (AuditEvent) TableName() string {
+	return withPrefix("audit_events")
+}
+
+// 管理后台会话注销原因
+const (
+	SessionRevokeLogout          = "logout"           // 用户退出登录
+	SessionRevokeLogoutAll       = "logout_all"       // 用户退出所有设备
+	SessionRevokeAdmin           = "admin"            // 管理员注销
+	SessionRevokeRefreshReused   = "refresh_reused"   // 已轮换的刷新令牌被再次使用，可能已泄露
+	SessionRevokeUserDisabled    = "user_disabled"    // 账号被禁用
+	SessionRevokeUserDeleted     = "user_deleted"     // 账号被删除
+	SessionRevokeRoleChanged     = "role_changed"     // 角色被修改
+	SessionRevokePasswordChanged = "password_changed" // 密码被修改或重置
+)
+
+// AdminSession 管理后台登录会话
+// 访问令牌（JWT）有效期很短，通过会话的刷新令牌换取新的访问令牌，每次刷新都会轮换刷新令牌
+type AdminSession struct {
+	ID                int64      `json:"id" gorm:"primaryKey;autoIncrement"`
+	SessionID         string     `json:"session_id" gorm:"type:varchar(64);uniqueIndex:idx_admin_sessions_session_id;not null"` // JWT 中的 sid
+	UserID            int64      `json:"user_id" gorm:"index:idx_admin_sessions_user_id;not null"`
+	Username          string     `json:"username" gorm:"type:varchar(100)"`
+	RefreshTokenHash  string     `json:"-" gorm:"type:varchar(64);uniqueIndex:idx_admin_sessions_refresh_token_hash;not null"`
+	PreviousTokenHash string     `json:"-" gorm:"type:varchar(64);index:idx_admin_sessions_previous_token_hash"` // 上一个刷新令牌，用于发现令牌重放
+	ClientIP          string     `json:"client_ip" gorm:"type:varchar(64)"`
+	UserAgent         string     `json:"user_agent" gorm:"type:varchar(255)"`
+	ExpireTime        time.Time  `json:"expire_time" gorm:"type:datetime;not null"` // 刷新令牌过期时间，每次刷新后顺延
+	LastRefreshTime   *time.Time `json:"last_refresh_time" gorm:"type:datetime;default:null"`
+	RevokedTime       *time.Time `json:"revoked_time" gorm:"type:datetime;default:null"`
+	RevokeReason      string     `json:"revoke_reason" gorm:"type:varchar(50)"`
+	CreateTime        time.Time  `json:"create_time" gorm:"autoCreateTime"`
+	UpdateTime        time.Time  `json:"update_time" gorm:"autoUpdateTime"`
+}
+
+// TableName 指定表名
+func (AdminSession) TableName() string {
+	return withPrefix("admin_sessions")
+}
+
+// Active 会话未注销且未过期
+func (s *AdminSession) Active(now time.Time) bool {
+	return s.RevokedTime == nil && s.ExpireTime.After(now)
+}
@@ -0,0 +1,135 @@
+package repository
+
+import (
+	"errors"
+	"time"
+
+	"rt-manage/internal/model"
+
+	"gorm.io/gorm"
+)
+
+// AdminSessionRepository 管理后台会话数据仓库接口
+type AdminSessionRepository interface {
+	Create(session *model.AdminSession) error
+	GetBySessionID(sessionID string) (*model.AdminSession, error)
+	GetByRefreshHash(tokenHash string) (*model.AdminSession, error)
+	GetByPreviousHash(tokenHash string) (*model.AdminSession, error)
+	Rotate(sessionID, oldHash, newHash string, expireTime, now time.Time) (bool, error)
+	Revoke(sessionID, reason string, now time.Time) (int64, error)
+	RevokeByUser(userID int64, exceptSessionID, reason string, now time.Time) (int64, error)
+	DeleteExpiredBefore(before time.Time) (int64, error)
+	List(page, pageSize int, userID int64, username string, activeOnly bool, now time.Time) ([]*model.AdminSession, int64, error)
+}
+
+type adminSessionRepository struct {
+	db *gorm.DB
+}
+
+// NewAdminSessionRepository 创建管理后台会话仓库实例
+func NewAdminSessionRepository(db *gorm.DB) AdminSessionRepository {
+	return &adminSessionRepository{db: db}
+}
+
+// Create 创建会话
+func (r *adminSessionRepository) Create(session *model.AdminSession) error {
+	return r.db.Create(session).Error
+}
+
+// GetBySessionID 根据会话ID获取会话
+func (r *adminSessionRepository) GetBySessionID(sessionID string) (*model.AdminSession, error) {
+	return r.first("session_id = ?", sessionID)
+}
+
+// GetByRefreshHash 根据当前刷新令牌的哈希获取会话
+func (r *adminSessionRepository) GetByRefreshHash(tokenHash string) (*model.AdminSession, error) {
+	return r.first("refresh_token_hash = ?", tokenHash)
+}
+
+// GetByPreviousHash 根据上一个刷新令牌的哈希获取会话
+func (r *adminSessionRepository) GetByPreviousHash(tokenHash string) (*model.AdminSession, error) {
+	return r.first("previous_token_hash = ?", tokenHash)
+}
+
+func (r *adminSessionRepository) first(query string, args ...interface{}) (*model.AdminSession, error) {
+	var session model.AdminSession
+	err := r.db.Where(query, args...).First(&session).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &session, nil
+}
+
+// Rotate 轮换刷新令牌：仅当当前令牌仍为 oldHash 且会话未注销时更新，返回是否更新成功
+// 以条件更新保证同一个刷新令牌只能成功使用一次
+func (r *adminSessionRepository) Rotate(sessionID, oldHash, newHash string, expireTime, now time.Time) (bool, error) {
+	result := r.db.Model(&model.AdminSession{}).
+		Where("session_id = ? AND refresh_token_hash = ? AND revoked_time IS NULL", sessionID, oldHash).
+		Updates(map[string]interface{}{
+			"refresh_token_hash":  newHash,
+			"previous_token_hash": oldHash,
+			"expire_time":         expireTime,
+			"last_refresh_time":   now,
+		})
+	return result.RowsAffected > 0, result.Error
+}
+
+// Revoke 注销单个会话（已注销的会话不受影响）
+func (r *adminSessionRepository) Revoke(sessionID, reason string, now time.Time) (int64, error) {
+	result := r.db.Model(&model.AdminSession{}).
+		Where("session_id = ? AND revoked_time IS NULL", sessionID).
+		Updates(map[string]interface{}{"revoked_time": now, "revoke_reason": reason})
+	return result.RowsAffected, result.Error
+}
+
+// RevokeByUser 注销账号的所有会话，exceptSessionID 不为空时保留该会话
+func (r *adminSessionRepository) RevokeByUser(userID int64, exceptSessionID, reason string, now time.Time) (int64, error) {
+	query := r.db.Model(&model.AdminSession{}).
+		Where("user_id = ? AND revoked_time IS NULL", userID)
+	if exceptSessionID != "" {
+		query = query.Where("session_id <> ?", exceptSessionID)
+	}
+	result := query.Updates(map[string]interface{}{"revoked_time": now, "revoke_reason": reason})
+	return result.RowsAffected, result.Error
+}
+
+// DeleteExpiredBefore 删除在指定时间之前已过期的会话
+func (r *adminSessionRepository) DeleteExpiredBefore(before time.Time) (int64, error) {
+	result := r.db.Where("expire_time < ?", before).Delete(&model.AdminSession{})
+	return result.RowsAffected, result.Error
+}
+
+// List 获取会话列表，activeOnly 为 true 时只返回未注销且未过期的会话
+func (r *adminSessionRepository) List(page, pageSize int, userID int64, username string, activeOnly bool, now time.Time) ([]*model.AdminSession, int64, error) {
+	var sessions []*model.AdminSession
+	var total int64
+
+	query := r.db.Model(&model.AdminSession{})
+
+	// 应用筛选条件
+	if userID > 0 {
+		query = query.Where("user_id = ?", userID)
+	}
+	if username != "" {
+		query = query.Where("username = ?", username)
+	}
+	if activeOnly {
+		query = query.Where("revoked_time IS NULL AND expire_time > ?", now)
+	}
+
+	// 获取总数
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	// 分页查询
+	offset := (page - 1) * pageSize
+	if err := query.Order("id DESC").Offset(offset).Limit(pageSize).Find(&sessions).Error; err != nil {
+		return nil, 0, err
+	}
+
+	return sessions, total, nil
+}
@@ -0,0 +1,128 @@
+package repository
+
+import (
+	"errors"
+	"time"
+
+	"rt-manage/internal/model"
+
+	"gorm.io/gorm"
+)
+
+// AdminUserRepository 管理后台账号数据仓库接口
+type AdminUserRepository interface {
+	Create(user *model.AdminUser) error
+	Update(user *model.AdminUser) error
+	Delete(id int64) error
+	GetByID(id int64) (*model.AdminUser, error)
+	GetByUsername(username string) (*model.AdminUser, error)
+	UpdateLastLogin(id int64, loginTime time.Time, ip string) error
+	Count() (int64, error)
+	CountEnabledAdmins() (int64, error)
+	List(page, pageSize int, username, role string, enabled *bool) ([]*model.AdminUser, int64, error)
+}
+
+type adminUserRepository struct {
+	db *gorm.DB
+}
+
+// NewAdminUserRepository 创建管理后台账号仓库实例
+func NewAdminUserRepository(db *gorm.DB) AdminUserRepository {
+	return &adminUserRepository{db: db}
+}
+
+// Create 创建账号
+func (r *adminUserRepository) Create(user *model.AdminUser) error {
+	return r.db.Create(user).Error
+}
+
+// Update 更新账号
+func (r *adminUserRepository) Update(user *model.AdminUser) error {
+	return r.db.Save(user).Error
+}
+
+// Delete 删除账号
+func (r *adminUserRepository) Delete(id int64) error {
+	return r.db.Delete(&model.AdminUser{}, id).Error
+}
+
+// GetByID 根据 ID 获取账号
+func (r *adminUserRepository) GetByID(id int64) (*model.AdminUser, error) {
+	var user model.AdminUser
+	err := r.db.Where("id = ?", id).First(&user).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &user, nil
+}
+
+// GetByUsername 根据用户名获取账号
+func (r *adminUserRepository) GetByUsername(username string) (*model.AdminUser, error) {
+	var user model.AdminUser
+	err := r.db.Where("username = ?", username).First(&user).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &user, nil
+}
+
+// UpdateLastLogin 只更新最后登录时间和IP，不影响 update_time
+func (r *adminUserRepository) UpdateLastLogin(id int64, loginTime time.Time, ip string) error {
+	return r.db.Model(&model.AdminUser{}).
+		Where("id = ?", id).
+		UpdateColumns(map[string]interface{}{"last_login_time": loginTime, "last_login_ip": ip}).Error
+}
+
+// Count 账号总数
+func (r *adminUserRepository) Count() (int64, error) {
+	var total int64
+	err := r.db.Model(&model.AdminUser{}).Count(&total).Error
+	return total, err
+}
+
+// CountEnabledAdmins 已启用的管理员数量
+func (r *adminUserRepository) CountEnabledAdmins() (int64, error) {
+	var total int64
+	err := r.db.Model(&model.AdminUser{}).
+		Where("role = ? AND enabled = ?", model.RoleAdmin, true).
+		Count(&total).Error
+	return total, err
+}
+
+// List 获取账号列表
+func (r *adminUserRepository) List(page, pageSize int, username, role string, enabled *bool) ([]*model.AdminUser, int64, error) {
+	var users []*model.AdminUser
+	var total int64
+
+	query := r.db.Model(&model.AdminUser{})
+
+	// 应用筛选条件
+	if username != "" {
+		query = query.Where("username LIKE ?", "%"+username+"%")
+	}
+	if role != "" {
+		query = query.Where("role = ?", role)
+	}
+	if enabled != nil {
+		query = query.Where("enabled = ?", *enabled)
+	}
+
+	// 获取总数
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	// 分页查询
+	offset := (page - 1) * pageSize
+	if err := query.Order("id ASC").Offset(offset).Limit(pageSize).Find(&users).Error; err != nil {
+		return nil, 0, err
+	}
+
+	return users, total, nil
+}
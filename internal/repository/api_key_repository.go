@@ -0,0 +1,213 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"rt-manage/internal/model"
+	"rt-manage/pkg/secret"
+
+	"gorm.io/gorm"
+)
+
+// APIKeyRepository 对外 API 密钥数据仓库接口
+type APIKeyRepository interface {
+	Create(key *model.APIKey) error
+	Update(key *model.APIKey) error
+	Delete(id int64) error
+	GetByID(id int64) (*model.APIKey, error)
+	GetByHash(keyHash string) (*model.APIKey, error)
+	GetByPrefix(keyPrefix string) (*model.APIKey, error)
+	UpdateLastUsed(id int64, usedTime time.Time, ip string) error
+	List(page, pageSize int, name string, enabled *bool) ([]*model.APIKey, int64, error)
+	ReEncrypt(batchSize int) (int, error)
+}
+
+type apiKeyRepository struct {
+	db *gorm.DB
+}
+
+// NewAPIKeyRepository 创建 API 密钥仓库实例
+func NewAPIKeyRepository(db *gorm.DB) APIKeyRepository {
+	return &apiKeyRepository{db: db}
+}
+
+// encryptAPIKey 返回加密签名密钥后的副本
+func encryptAPIKey(key *model.APIKey) (*model.APIKey, error) {
+	row := *key
+	var err error
+	if row.SigningSecret, err = secret.Encrypt(key.SigningSecret); err != nil {
+		return nil, err
+	}
+	return &row, nil
+}
+
+// decryptAPIKey 原地解密签名密钥
+func decryptAPIKey(key *model.APIKey) error {
+	var err error
+	if key.SigningSecret, err = secret.Decrypt(key.SigningSecret); err != nil {
+		return fmt.Errorf("解密API密钥失败(id=%d): %w", key.ID, err)
+	}
+	return nil
+}
+
+// Create 创建密钥
+func (r *apiKeyRepository) Create(key *model.APIKey) error {
+	row, err := encryptAPIKey(key)
+	if err != nil {
+		return err
+	}
+	if err := r.db.Create(row).Error; err != nil {
+		return err
+	}
+	key.ID = row.ID
+	key.CreateTime = row.CreateTime
+	key.UpdateTime = row.UpdateTime
+	return nil
+}
+
+// Update 更新密钥
+func (r *apiKeyRepository) Update(key *model.APIKey) error {
+	row, err := encryptAPIKey(key)
+	if err != nil {
+		return err
+	}
+	if err := r.db.Save(row).Error; err != nil {
+		return err
+	}
+	key.UpdateTime = row.UpdateTime
+	return nil
+}
+
+// Delete 删除密钥
+func (r *apiKeyRepository) Delete(id int64) error {
+	return r.db.Delete(&model.APIKey{}, id).Error
+}
+
+// GetByID 根据 ID 获取密钥
+func (r *apiKeyRepository) GetByID(id int64) (*model.APIKey, error) {
+	var key model.APIKey
+	err := r.db.Where("id = ?", id).First(&key).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if err := decryptAPIKey(&key); err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+// GetByHash 根据密钥哈希获取密钥
+func (r *apiKeyRepository) GetByHash(keyHash string) (*model.APIKey, error) {
+	var key model.APIKey
+	err := r.db.Where("key_hash = ?", keyHash).First(&key).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if err := decryptAPIKey(&key); err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+// GetByPrefix 根据密钥前缀获取密钥
+func (r *apiKeyRepository) GetByPrefix(keyPrefix string) (*model.APIKey, error) {
+	var key model.APIKey
+	err := r.db.Where("key_prefix = ?", keyPrefix).First(&key).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if err := decryptAPIKey(&key); err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+// UpdateLastUsed 只更新最后使用时间和IP，不影响 update_time
+func (r *apiKeyRepository) UpdateLastUsed(id int64, usedTime time.Time, ip string) error {
+	return r.db.Model(&model.APIKey{}).
+		Where("id = ?", id).
+		UpdateColumns(map[string]interface{}{"last_used_time": usedTime, "last_used_ip": ip}).Error
+}
+
+// List 获取密钥列表
+func (r *apiKeyRepository) List(page, pageSize int, name string, enabled *bool) ([]*model.APIKey, int64, error) {
+	var keys []*model.APIKey
+	var total int64
+
+	query := r.db.Model(&model.APIKey{})
+
+	// 应用筛选条件
+	if name != "" {
+		query = query.Where("name LIKE ?", "%"+name+"%")
+	}
+	if enabled != nil {
+		query = query.Where("enabled = ?", *enabled)
+	}
+
+	// 获取总数
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	// 分页查询
+	offset := (page - 1) * pageSize
+	if err := query.Order("id DESC").Offset(offset).Limit(pageSize).Find(&keys).Error; err != nil {
+		return nil, 0, err
+	}
+	for _, key := range keys {
+		if err := decryptAPIKey(key); err != nil {
+			return nil, 0, err
+		}
+	}
+
+	return keys, total, nil
+}
+
+// ReEncrypt 使用当前密钥重新加密所有签名密钥，返回重新加密的记录数
+func (r *apiKeyRepository) ReEncrypt(batchSize int) (int, error) {
+	count := 0
+	var lastID int64
+	for {
+		var keys []*model.APIKey
+		if err := r.db.Where("id > ?", lastID).Order("id ASC").Limit(batchSize).Find(&keys).Error; err != nil {
+			return count, err
+		}
+		if len(keys) == 0 {
+			return count, nil
+		}
+		lastID = keys[len(keys)-1].ID
+
+		for _, key := range keys {
+			if secret.IsCurrent(key.SigningSecret) {
+				continue
+			}
+
+			plain := *key
+			if err := decryptAPIKey(&plain); err != nil {
+				return count, err
+			}
+			row, err := encryptAPIKey(&plain)
+			if err != nil {
+				return count, err
+			}
+			err = r.db.Model(&model.APIKey{}).
+				Where("id = ?", key.ID).
+				UpdateColumn("signing_secret", row.SigningSecret).Error
+			if err != nil {
+				return count, fmt.Errorf("重新加密API密钥失败(id=%d): %w", key.ID, err)
+			}
+			count++
+		}
+	}
+}
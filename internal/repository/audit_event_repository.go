@@ -0,0 +1,95 @@
+package repository
+
+import (
+	"time"
+
+	"rt-manage/internal/model"
+
+	"gorm.io/gorm"
+)
+
+// AuditEventRepository 审计事件数据仓库接口
+type AuditEventRepository interface {
+	Create(event *model.AuditEvent) error
+	DeleteBefore(before time.Time) (int64, error)
+	List(page, pageSize int, actor, actorType, action, targetType, targetID, clientIP string, success *bool, startDate, endDate string) ([]*model.AuditEvent, int64, error)
+}
+
+type auditEventRepository struct {
+	db *gorm.DB
+}
+
+// NewAuditEventRepository 创建审计事件仓库实例
+func NewAuditEventRepository(db *gorm.DB) AuditEventRepository {
+	return &auditEventRepository{db: db}
+}
+
+// Create 创建审计事件
+func (r *auditEventRepository) Create(event *model.AuditEvent) error {
+	return r.db.Create(event).Error
+}
+
+// DeleteBefore 删除指定时间之前的审计事件
+func (r *auditEventRepository) DeleteBefore(before time.Time) (int64, error) {
+	result := r.db.Where("create_time < ?", before).Delete(&model.AuditEvent{})
+	return result.RowsAffected, result.Error
+}
+
+// List 获取审计事件列表
+func (r *auditEventRepository) List(page, pageSize int, actor, actorType, action, targetType, targetID, clientIP string, success *bool, startDate, endDate string) ([]*model.AuditEvent, int64, error) {
+	var events []*model.AuditEvent
+	var total int64
+
+	query := r.db.Model(&model.AuditEvent{})
+
+	// 应用筛选条件
+	if actor != "" {
+		query = query.Where("actor = ?", actor)
+	}
+	if actorType != "" {
+		query = query.Where("actor_type = ?", actorType)
+	}
+	if action != "" {
+		// 支持按前缀筛选，如 rts. 匹配所有 RT 管理操作
+		query = query.Where("action LIKE ?", action+"%")
+	}
+	if targetType != "" {
+		query = query.Where("target_type = ?", targetType)
+	}
+	if targetID != "" {
+		// target_ids 为逗号分隔的列表
+		query = query.Where("target_ids = ? OR target_ids LIKE ? OR target_ids LIKE ? OR target_ids LIKE ?",
+			targetID, targetID+",%", "%,"+targetID, "%,"+targetID+",%")
+	}
+	if clientIP != "" {
+		query = query.Where("client_ip = ?", clientIP)
+	}
+	if success != nil {
+		query = query.Where("success = ?", *success)
+	}
+	if startDate != "" {
+		// 按日期筛选（包含开始日期当天）
+		if startTime, err := time.ParseInLocation("2006-01-02", startDate, time.Local); err == nil {
+			query = query.Where("create_time >= ?", startTime)
+		}
+	}
+	if endDate != "" {
+		// 按日期筛选（包含结束日期当天）
+		if endTime, err := time.ParseInLocation("2006-01-02", endDate, time.Local); err == nil {
+			query = query.Where("create_time < ?", endTime.Add(24*time.Hour))
+		}
+	}
+
+	// 获取总数
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	// 分页查询
+	offset := (page - 1) * pageSize
+	if err := query.Order("id DESC").Offset(offset).Limit(pageSize).Find(&events).Error; err != nil {
+		return nil, 0, err
+	}
+
+	return events, total, nil
+}
@@ -0,0 +1,138 @@
+package repository
+
+import (
+	"errors"
+	"time"
+
+	"rt-manage/internal/model"
+
+	"gorm.io/gorm"
+)
+
+// LeaseRepository AT 租约数据仓库接口
+type LeaseRepository interface {
+	Create(lease *model.Lease) error
+	Update(lease *model.Lease) error
+	GetByLeaseID(leaseID string) (*model.Lease, error)
+	ListActive() ([]*model.Lease, error)
+	ExpireOverdue(now time.Time) (int64, error)
+	LastLeaseIDs(rtIds []int64) (map[int64]int64, error)
+	List(page, pageSize int, rtId int64, bizId string, consumer string, status string) ([]*model.Lease, int64, error)
+}
+
+type leaseRepository struct {
+	db *gorm.DB
+}
+
+// NewLeaseRepository 创建租约仓库实例
+func NewLeaseRepository(db *gorm.DB) LeaseRepository {
+	return &leaseRepository{db: db}
+}
+
+// Create 创建租约
+func (r *leaseRepository) Create(lease *model.Lease) error {
+	return r.db.Create(lease).Error
+}
+
+// Update 更新租约
+func (r *leaseRepository) Update(lease *model.Lease) error {
+	return r.db.Save(lease).Error
+}
+
+// GetByLeaseID 根据租约ID获取租约
+func (r *leaseRepository) GetByLeaseID(leaseID string) (*model.Lease, error) {
+	var lease model.Lease
+	err := r.db.Where("lease_id = ?", leaseID).First(&lease).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &lease, nil
+}
+
+// ListActive 获取全部使用中的租约
+func (r *leaseRepository) ListActive() ([]*model.Lease, error) {
+	var leases []*model.Lease
+	if err := r.db.Where("status = ?", model.LeaseStatusActive).Find(&leases).Error; err != nil {
+		return nil, err
+	}
+	return leases, nil
+}
+
+// ExpireOverdue 将已到期仍未释放的租约标记为 expired，返回回收的数量
+func (r *leaseRepository) ExpireOverdue(now time.Time) (int64, error) {
+	result := r.db.Model(&model.Lease{}).
+		Where("status = ? AND expire_time <= ?", model.LeaseStatusActive, now).
+		Updates(map[string]interface{}{"status": model.LeaseStatusExpired, "update_time": now})
+	return result.RowsAffected, result.Error
+}
+
+// LastLeaseIDs 获取每个RT最近一次租约的主键ID（越大越近），从未被租用的RT不在结果中
+func (r *leaseRepository) LastLeaseIDs(rtIds []int64) (map[int64]int64, error) {
+	lastIds := make(map[int64]int64, len(rtIds))
+	if len(rtIds) == 0 {
+		return lastIds, nil
+	}
+
+	var rows []struct {
+		RtID   int64
+		LastID int64
+	}
+	// 按批查询，避免 IN 参数过多
+	for start := 0; start < len(rtIds); start += 500 {
+		end := start + 500
+		if end > len(rtIds) {
+			end = len(rtIds)
+		}
+		rows = rows[:0]
+		err := r.db.Model(&model.Lease{}).
+			Select("rt_id, MAX(id) AS last_id").
+			Where("rt_id IN ?", rtIds[start:end]).
+			Group("rt_id").
+			Scan(&rows).Error
+		if err != nil {
+			return nil, err
+		}
+		for _, row := range rows {
+			lastIds[row.RtID] = row.LastID
+		}
+	}
+	return lastIds, nil
+}
+
+// List 获取租约列表
+func (r *leaseRepository) List(page, pageSize int, rtId int64, bizId string, consumer string, status string) ([]*model.Lease, int64, error) {
+	var leases []*model.Lease
+	var total int64
+
+	query := r.db.Model(&model.Lease{})
+
+	// 应用筛选条件
+	if rtId > 0 {
+		query = query.Where("rt_id = ?", rtId)
+	}
+	if bizId != "" {
+		query = query.Where("biz_id = ?", bizId)
+	}
+	if consumer != "" {
+		query = query.Where("consumer = ?", consumer)
+	}
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	// 获取总数
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	// 分页查询
+	offset := (page - 1) * pageSize
+	if err := query.Order("id DESC").Offset(offset).Limit(pageSize).Find(&leases).Error; err != nil {
+		return nil, 0, err
+	}
+
+	return leases, total, nil
+}
@@ -0,0 +1,91 @@
+package repository
+
+import (
+	"errors"
+	"time"
+
+	"rt-manage/internal/model"
+
+	"gorm.io/gorm"
+)
+
+// RefreshLogRepository 刷新记录数据仓库接口
+type RefreshLogRepository interface {
+	Create(log *model.RefreshLog) error
+	GetByID(id int64) (*model.RefreshLog, error)
+	List(page, pageSize int, rtId int64, bizId string, trigger string, refreshStatus string, startDate string, endDate string) ([]*model.RefreshLog, int64, error)
+}
+
+type refreshLogRepository struct {
+	db *gorm.DB
+}
+
+// NewRefreshLogRepository 创建刷新记录仓库实例
+func NewRefreshLogRepository(db *gorm.DB) RefreshLogRepository {
+	return &refreshLogRepository{db: db}
+}
+
+// Create 创建刷新记录
+func (r *refreshLogRepository) Create(log *model.RefreshLog) error {
+	return r.db.Create(log).Error
+}
+
+// GetByID 根据 ID 获取刷新记录
+func (r *refreshLogRepository) GetByID(id int64) (*model.RefreshLog, error) {
+	var log model.RefreshLog
+	err := r.db.Where("id = ?", id).First(&log).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &log, nil
+}
+
+// List 获取刷新记录列表
+func (r *refreshLogRepository) List(page, pageSize int, rtId int64, bizId string, trigger string, refreshStatus string, startDate string, endDate string) ([]*model.RefreshLog, int64, error) {
+	var logs []*model.RefreshLog
+	var total int64
+
+	query := r.db.Model(&model.RefreshLog{})
+
+	// 应用筛选条件
+	if rtId > 0 {
+		query = query.Where("rt_id = ?", rtId)
+	}
+	if bizId != "" {
+		query = query.Where("biz_id = ?", bizId)
+	}
+	if trigger != "" {
+		query = query.Where("`trigger` = ?", trigger)
+	}
+	if refreshStatus != "" {
+		query = query.Where("refresh_status = ?", refreshStatus)
+	}
+	if startDate != "" {
+		// 按日期筛选（包含开始日期当天）
+		if startTime, err := time.ParseInLocation("2006-01-02", startDate, time.Local); err == nil {
+			query = query.Where("create_time >= ?", startTime)
+		}
+	}
+	if endDate != "" {
+		// 按日期筛选（包含结束日期当天）
+		if endTime, err := time.ParseInLocation("2006-01-02", endDate, time.Local); err == nil {
+			query = query.Where("create_time < ?", endTime.Add(24*time.Hour))
+		}
+	}
+
+	// 获取总数
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	// 分页查询（列表不返回响应内容）
+	offset := (page - 1) * pageSize
+	if err := query.Omit("response_body").Order("id DESC").Offset(offset).Limit(pageSize).Find(&logs).Error; err != nil {
+		return nil, 0, err
+	}
+
+	return logs, total, nil
+}
@@ -2,13 +2,18 @@ package repository
 
 import (
 	"errors"
+	"fmt"
 	"time"
 
 	"rt-manage/internal/model"
+	"rt-manage/pkg/secret"
 
 	"gorm.io/gorm"
 )
 
+// ErrVersionConflict RT 已被其他操作修改（乐观锁冲突）
+var ErrVersionConflict = errors.New("RT已被其他操作修改，请刷新后重试")
+
 // RTRepository RT 数据仓库接口
 type RTRepository interface {
 	Create(rt *model.RT) error
@@ -16,11 +21,15 @@ type RTRepository interface {
 	GetByID(id int64) (*model.RT, error)
 	GetByBizId(bizId string) (*model.RT, error)
 	GetByEmail(email string) (*model.RT, error)
-	List(page, pageSize int, bizId string, tag string, email string, typeStr string, enabled *bool, createDate string) ([]*model.RT, int64, error)
+	List(page, pageSize int, bizId string, tag string, email string, typeStr string, enabled *bool, createDate string, refreshStatus string) ([]*model.RT, int64, error)
+	ListAfter(afterID int64, limit int, bizId string, tag string, email string, typeStr string, enabled *bool, createDate string, refreshStatus string) ([]*model.RT, error)
 	Delete(id int64) error
 	BatchDelete(ids []int64) (int, int, error)
 	GetByIDs(ids []int64) ([]*model.RT, error)
 	GetByToken(token string) (*model.RT, error)
+	ListDueForRefresh(now time.Time, limit int) ([]*model.RT, error)
+	ListLeasable(tag string, typeStr string, credentialType string, bizIds []string, excludeBizIds []string, allowedTags []string, allowedTypes []string, now time.Time, minExpireTime time.Time) ([]*model.RT, error)
+	ReEncrypt(batchSize int) (int, error)
 }
 
 type rtRepository struct {
@@ -32,14 +41,99 @@ func NewRTRepository(db *gorm.DB) RTRepository {
 	return &rtRepository{db: db}
 }
 
+// encryptRT 返回加密敏感字段后的副本（Rt、At、IdToken、LastRT、RefreshResult），并计算 RtHash
+func encryptRT(rt *model.RT) (*model.RT, error) {
+	row := *rt
+	var err error
+	if row.Rt, err = secret.Encrypt(rt.Rt); err != nil {
+		return nil, err
+	}
+	if row.At, err = secret.Encrypt(rt.At); err != nil {
+		return nil, err
+	}
+	if row.IdToken, err = secret.Encrypt(rt.IdToken); err != nil {
+		return nil, err
+	}
+	if row.LastRT, err = secret.Encrypt(rt.LastRT); err != nil {
+		return nil, err
+	}
+	if row.RefreshResult, err = secret.Encrypt(rt.RefreshResult); err != nil {
+		return nil, err
+	}
+	row.RtHash = secret.Hash(rt.Rt)
+	return &row, nil
+}
+
+// decryptRT 原地解密从数据库读出的敏感字段
+func decryptRT(rt *model.RT) error {
+	var err error
+	if rt.Rt, err = secret.Decrypt(rt.Rt); err != nil {
+		return fmt.Errorf("解密RT失败(id=%d): %w", rt.ID, err)
+	}
+	if rt.At, err = secret.Decrypt(rt.At); err != nil {
+		return fmt.Errorf("解密AT失败(id=%d): %w", rt.ID, err)
+	}
+	if rt.IdToken, err = secret.Decrypt(rt.IdToken); err != nil {
+		return fmt.Errorf("解密id_token失败(id=%d): %w", rt.ID, err)
+	}
+	if rt.LastRT, err = secret.Decrypt(rt.LastRT); err != nil {
+		return fmt.Errorf("解密LastRT失败(id=%d): %w", rt.ID, err)
+	}
+	if rt.RefreshResult, err = secret.Decrypt(rt.RefreshResult); err != nil {
+		return fmt.Errorf("解密刷新结果失败(id=%d): %w", rt.ID, err)
+	}
+	return nil
+}
+
+// decryptRTs 批量解密
+func decryptRTs(rts []*model.RT) error {
+	for _, rt := range rts {
+		if err := decryptRT(rt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // Create 创建新的 RT 记录
 func (r *rtRepository) Create(rt *model.RT) error {
-	return r.db.Create(rt).Error
+	row, err := encryptRT(rt)
+	if err != nil {
+		return err
+	}
+	if err := r.db.Create(row).Error; err != nil {
+		return err
+	}
+	rt.ID = row.ID
+	rt.RtHash = row.RtHash
+	rt.CreateTime = row.CreateTime
+	rt.UpdateTime = row.UpdateTime
+	return nil
 }
 
-// Update 更新 RT 记录
+// Update 更新 RT 记录（乐观锁：仅当数据库中的版本号与 rt.Version 一致时更新，成功后版本号加1）
 func (r *rtRepository) Update(rt *model.RT) error {
-	return r.db.Save(rt).Error
+	row, err := encryptRT(rt)
+	if err != nil {
+		return err
+	}
+	row.Version = rt.Version + 1
+
+	result := r.db.Model(row).
+		Where("version = ?", rt.Version).
+		Select("*").
+		Omit("id", "create_time").
+		Updates(row)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrVersionConflict
+	}
+	rt.Version = row.Version
+	rt.RtHash = row.RtHash
+	rt.UpdateTime = row.UpdateTime
+	return nil
 }
 
 // GetByID 根据 ID 获取 RT
@@ -52,6 +146,9 @@ func (r *rtRepository) GetByID(id int64) (*model.RT, error) {
 		}
 		return nil, err
 	}
+	if err := decryptRT(&rt); err != nil {
+		return nil, err
+	}
 	return &rt, nil
 }
 
@@ -65,6 +162,9 @@ func (r *rtRepository) GetByBizId(bizId string) (*model.RT, error) {
 		}
 		return nil, err
 	}
+	if err := decryptRT(&rt); err != nil {
+		return nil, err
+	}
 	return &rt, nil
 }
 
@@ -78,14 +178,51 @@ func (r *rtRepository) GetByEmail(email string) (*model.RT, error) {
 		}
 		return nil, err
 	}
+	if err := decryptRT(&rt); err != nil {
+		return nil, err
+	}
 	return &rt, nil
 }
 
 // List 获取 RT 列表
-func (r *rtRepository) List(page, pageSize int, bizId string, tag string, email string, typeStr string, enabled *bool, createDate string) ([]*model.RT, int64, error) {
+func (r *rtRepository) List(page, pageSize int, bizId string, tag string, email string, typeStr string, enabled *bool, createDate string, refreshStatus string) ([]*model.RT, int64, error) {
 	var rts []*model.RT
 	var total int64
 
+	query := r.filter(bizId, tag, email, typeStr, enabled, createDate, refreshStatus)
+
+	// 获取总数
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	// 分页查询
+	offset := (page - 1) * pageSize
+	if err := query.Order("id DESC").Offset(offset).Limit(pageSize).Find(&rts).Error; err != nil {
+		return nil, 0, err
+	}
+	if err := decryptRTs(rts); err != nil {
+		return nil, 0, err
+	}
+
+	return rts, total, nil
+}
+
+// ListAfter 按 ID 升序获取 ID 大于 afterID 的一批 RT（游标分页，用于导出），筛选条件同 List
+func (r *rtRepository) ListAfter(afterID int64, limit int, bizId string, tag string, email string, typeStr string, enabled *bool, createDate string, refreshStatus string) ([]*model.RT, error) {
+	var rts []*model.RT
+	query := r.filter(bizId, tag, email, typeStr, enabled, createDate, refreshStatus)
+	if err := query.Where("id > ?", afterID).Order("id ASC").Limit(limit).Find(&rts).Error; err != nil {
+		return nil, err
+	}
+	if err := decryptRTs(rts); err != nil {
+		return nil, err
+	}
+	return rts, nil
+}
+
+// filter 构造带筛选条件的查询
+func (r *rtRepository) filter(bizId string, tag string, email string, typeStr string, enabled *bool, createDate string, refreshStatus string) *gorm.DB {
 	query := r.db.Model(&model.RT{})
 
 	// 应用筛选条件
@@ -104,25 +241,19 @@ func (r *rtRepository) List(page, pageSize int, bizId string, tag string, email
 	if enabled != nil {
 		query = query.Where("enabled = ?", *enabled)
 	}
+	if refreshStatus == model.RefreshStatusStale {
+		// 调度器尚未标记的已过期仅AT账号同样视为 stale
+		query = query.Where("refresh_status = ? OR (credential_type = ? AND at_expire_time <= ?)", refreshStatus, model.CredentialTypeAT, time.Now())
+	} else if refreshStatus != "" {
+		query = query.Where("refresh_status = ?", refreshStatus)
+	}
 	if createDate != "" {
 		// 按日期筛选（忽略时间部分）
 		startTime, _ := time.Parse("2006-01-02", createDate)
 		endTime := startTime.Add(24 * time.Hour)
 		query = query.Where("create_time >= ? AND create_time < ?", startTime, endTime)
 	}
-
-	// 获取总数
-	if err := query.Count(&total).Error; err != nil {
-		return nil, 0, err
-	}
-
-	// 分页查询
-	offset := (page - 1) * pageSize
-	if err := query.Order("id DESC").Offset(offset).Limit(pageSize).Find(&rts).Error; err != nil {
-		return nil, 0, err
-	}
-
-	return rts, total, nil
+	return query
 }
 
 // Delete 删除 RT 记录
@@ -154,18 +285,130 @@ func (r *rtRepository) GetByIDs(ids []int64) ([]*model.RT, error) {
 	if err := r.db.Where("id IN ?", ids).Find(&rts).Error; err != nil {
 		return nil, err
 	}
+	if err := decryptRTs(rts); err != nil {
+		return nil, err
+	}
 	return rts, nil
 }
 
 // GetByToken 根据token获取RT
+// 加密存储时按 rt_hash 查找，同时匹配明文列以兼容启用加密前写入、尚未重新加密的记录
 func (r *rtRepository) GetByToken(token string) (*model.RT, error) {
 	var rt model.RT
-	err := r.db.Where("rt = ?", token).First(&rt).Error
+	query := r.db.Where("rt = ?", token)
+	if hashes := secret.LookupHashes(token); len(hashes) > 0 {
+		query = r.db.Where("rt_hash IN ? OR rt = ?", hashes, token)
+	}
+	err := query.First(&rt).Error
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, nil
 		}
 		return nil, err
 	}
+	if err := decryptRT(&rt); err != nil {
+		return nil, err
+	}
 	return &rt, nil
 }
+
+// ListLeasable 获取可租用的账号：已启用、有AT、最近一次刷新成功、不在冷却期，且AT在 minExpireTime 之后才过期（未知过期时间的视为可用）
+// tag、type 为精确匹配，allowedTags、allowedTypes 为API密钥的授权范围（为空不限制），按 id 升序返回，只读取 id、biz_id（选中后再按 id 读取完整记录）
+func (r *rtRepository) ListLeasable(tag string, typeStr string, credentialType string, bizIds []string, excludeBizIds []string, allowedTags []string, allowedTypes []string, now time.Time, minExpireTime time.Time) ([]*model.RT, error) {
+	query := r.db.Where("enabled = ?", true).
+		Where("at IS NOT NULL AND at <> ''").
+		Where("refresh_status IS NULL OR refresh_status IN ?", []string{"", model.RefreshStatusOK}).
+		Where("cooldown_until IS NULL OR cooldown_until <= ?", now).
+		Where("at_expire_time IS NULL OR at_expire_time > ?", minExpireTime)
+	if tag != "" {
+		query = query.Where("tag = ?", tag)
+	}
+	if typeStr != "" {
+		query = query.Where("type = ?", typeStr)
+	}
+	if credentialType != "" {
+		query = query.Where("credential_type = ?", credentialType)
+	}
+	if len(allowedTags) > 0 {
+		query = query.Where("tag IN ?", allowedTags)
+	}
+	if len(allowedTypes) > 0 {
+		query = query.Where("type IN ?", allowedTypes)
+	}
+	if len(bizIds) > 0 {
+		query = query.Where("biz_id IN ?", bizIds)
+	}
+	if len(excludeBizIds) > 0 {
+		query = query.Where("biz_id NOT IN ?", excludeBizIds)
+	}
+
+	var rts []*model.RT
+	if err := query.Select("id", "biz_id").Order("id ASC").Find(&rts).Error; err != nil {
+		return nil, err
+	}
+	return rts, nil
+}
+
+// ListDueForRefresh 获取已到计划刷新时间的启用RT（从未调度过的RT账号也视为到期，仅AT账号到期即 AT 过期）
+func (r *rtRepository) ListDueForRefresh(now time.Time, limit int) ([]*model.RT, error) {
+	var rts []*model.RT
+	err := r.db.Where("enabled = ?", true).
+		Where("next_refresh_time <= ? OR (next_refresh_time IS NULL AND credential_type = ?)", now, model.CredentialTypeRT).
+		Order("next_refresh_time ASC").
+		Limit(limit).
+		Find(&rts).Error
+	if err != nil {
+		return nil, err
+	}
+	if err := decryptRTs(rts); err != nil {
+		return nil, err
+	}
+	return rts, nil
+}
+
+// ReEncrypt 使用当前密钥重新加密所有记录并重算 rt_hash，返回重新加密的记录数
+// 直接更新列，不修改版本号和更新时间；与其他写入并发时跳过该记录（其他写入已使用当前密钥）
+func (r *rtRepository) ReEncrypt(batchSize int) (int, error) {
+	count := 0
+	var lastID int64
+	for {
+		var rts []*model.RT
+		if err := r.db.Where("id > ?", lastID).Order("id ASC").Limit(batchSize).Find(&rts).Error; err != nil {
+			return count, err
+		}
+		if len(rts) == 0 {
+			return count, nil
+		}
+		lastID = rts[len(rts)-1].ID
+
+		for _, rt := range rts {
+			plain := *rt
+			if err := decryptRT(&plain); err != nil {
+				return count, err
+			}
+			if secret.IsCurrent(rt.Rt) && secret.IsCurrent(rt.At) && secret.IsCurrent(rt.IdToken) && secret.IsCurrent(rt.LastRT) &&
+				secret.IsCurrent(rt.RefreshResult) && rt.RtHash == secret.Hash(plain.Rt) {
+				continue
+			}
+
+			row, err := encryptRT(&plain)
+			if err != nil {
+				return count, err
+			}
+			err = r.db.Model(&model.RT{}).
+				Where("id = ? AND version = ?", rt.ID, rt.Version).
+				UpdateColumns(map[string]interface{}{
+					"rt":             row.Rt,
+					"rt_hash":        row.RtHash,
+					"at":             row.At,
+					"id_token":       row.IdToken,
+					"last_rt":        row.LastRT,
+					"refresh_result": row.RefreshResult,
+				}).Error
+			if err != nil {
+				return count, fmt.Errorf("重新加密RT失败(id=%d): %w", rt.ID, err)
+			}
+			count++
+		}
+	}
+}
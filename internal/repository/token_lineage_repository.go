@@ -0,0 +1,155 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+
+	"rt-manage/internal/model"
+	"rt-manage/pkg/secret"
+
+	"gorm.io/gorm"
+)
+
+// TokenLineageRepository token 谱系数据仓库接口（只追加，不提供更新和删除）
+type TokenLineageRepository interface {
+	Create(lineage *model.TokenLineage) error
+	GetByID(id int64) (*model.TokenLineage, error)
+	ListByRtID(rtId int64) ([]*model.TokenLineage, error)
+	GetLatestByRtID(rtId int64) (*model.TokenLineage, error)
+	ReEncrypt(batchSize int) (int, error)
+}
+
+type tokenLineageRepository struct {
+	db *gorm.DB
+}
+
+// NewTokenLineageRepository 创建 token 谱系仓库实例
+func NewTokenLineageRepository(db *gorm.DB) TokenLineageRepository {
+	return &tokenLineageRepository{db: db}
+}
+
+// encryptLineage 返回加密 token 字段后的副本
+func encryptLineage(lineage *model.TokenLineage) (*model.TokenLineage, error) {
+	row := *lineage
+	var err error
+	if row.Rt, err = secret.Encrypt(lineage.Rt); err != nil {
+		return nil, err
+	}
+	if row.At, err = secret.Encrypt(lineage.At); err != nil {
+		return nil, err
+	}
+	return &row, nil
+}
+
+// decryptLineage 原地解密 token 字段
+func decryptLineage(lineage *model.TokenLineage) error {
+	var err error
+	if lineage.Rt, err = secret.Decrypt(lineage.Rt); err != nil {
+		return fmt.Errorf("解密token谱系失败(id=%d): %w", lineage.ID, err)
+	}
+	if lineage.At, err = secret.Decrypt(lineage.At); err != nil {
+		return fmt.Errorf("解密token谱系失败(id=%d): %w", lineage.ID, err)
+	}
+	return nil
+}
+
+// Create 追加谱系记录
+func (r *tokenLineageRepository) Create(lineage *model.TokenLineage) error {
+	row, err := encryptLineage(lineage)
+	if err != nil {
+		return err
+	}
+	if err := r.db.Create(row).Error; err != nil {
+		return err
+	}
+	lineage.ID = row.ID
+	lineage.CreateTime = row.CreateTime
+	return nil
+}
+
+// GetByID 根据 ID 获取谱系记录
+func (r *tokenLineageRepository) GetByID(id int64) (*model.TokenLineage, error) {
+	var lineage model.TokenLineage
+	err := r.db.Where("id = ?", id).First(&lineage).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if err := decryptLineage(&lineage); err != nil {
+		return nil, err
+	}
+	return &lineage, nil
+}
+
+// ListByRtID 获取RT的全部谱系记录（按时间倒序）
+func (r *tokenLineageRepository) ListByRtID(rtId int64) ([]*model.TokenLineage, error) {
+	var lineages []*model.TokenLineage
+	if err := r.db.Where("rt_id = ?", rtId).Order("id DESC").Find(&lineages).Error; err != nil {
+		return nil, err
+	}
+	for _, lineage := range lineages {
+		if err := decryptLineage(lineage); err != nil {
+			return nil, err
+		}
+	}
+	return lineages, nil
+}
+
+// GetLatestByRtID 获取RT最近一条谱系记录
+func (r *tokenLineageRepository) GetLatestByRtID(rtId int64) (*model.TokenLineage, error) {
+	var lineage model.TokenLineage
+	err := r.db.Where("rt_id = ?", rtId).Order("id DESC").First(&lineage).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if err := decryptLineage(&lineage); err != nil {
+		return nil, err
+	}
+	return &lineage, nil
+}
+
+// ReEncrypt 使用当前密钥重新加密所有谱系记录，返回重新加密的记录数
+func (r *tokenLineageRepository) ReEncrypt(batchSize int) (int, error) {
+	count := 0
+	var lastID int64
+	for {
+		var lineages []*model.TokenLineage
+		if err := r.db.Where("id > ?", lastID).Order("id ASC").Limit(batchSize).Find(&lineages).Error; err != nil {
+			return count, err
+		}
+		if len(lineages) == 0 {
+			return count, nil
+		}
+		lastID = lineages[len(lineages)-1].ID
+
+		for _, lineage := range lineages {
+			if secret.IsCurrent(lineage.Rt) && secret.IsCurrent(lineage.At) {
+				continue
+			}
+
+			plain := *lineage
+			if err := decryptLineage(&plain); err != nil {
+				return count, err
+			}
+			row, err := encryptLineage(&plain)
+			if err != nil {
+				return count, err
+			}
+			err = r.db.Model(&model.TokenLineage{}).
+				Where("id = ?", lineage.ID).
+				UpdateColumns(map[string]interface{}{
+					"rt": row.Rt,
+					"at": row.At,
+				}).Error
+			if err != nil {
+				return count, fmt.Errorf("重新加密token谱系失败(id=%d): %w", lineage.ID, err)
+			}
+			count++
+		}
+	}
+}
@@ -0,0 +1,166 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+
+	"rt-manage/internal/model"
+	"rt-manage/pkg/secret"
+
+	"gorm.io/gorm"
+)
+
+// TokenRotationRepository RT 轮换日志数据仓库接口
+type TokenRotationRepository interface {
+	Create(rotation *model.TokenRotation) error
+	Update(rotation *model.TokenRotation) error
+	Delete(id int64) error
+	ListByStatus(status string) ([]*model.TokenRotation, error)
+	GetLatestByRtID(rtId int64, status string) (*model.TokenRotation, error)
+	ReEncrypt(batchSize int) (int, error)
+}
+
+type tokenRotationRepository struct {
+	db *gorm.DB
+}
+
+// NewTokenRotationRepository 创建 RT 轮换日志仓库实例
+func NewTokenRotationRepository(db *gorm.DB) TokenRotationRepository {
+	return &tokenRotationRepository{db: db}
+}
+
+// encryptRotation 返回加密 token 字段后的副本
+func encryptRotation(rotation *model.TokenRotation) (*model.TokenRotation, error) {
+	row := *rotation
+	var err error
+	if row.OldRt, err = secret.Encrypt(rotation.OldRt); err != nil {
+		return nil, err
+	}
+	if row.NewRt, err = secret.Encrypt(rotation.NewRt); err != nil {
+		return nil, err
+	}
+	if row.NewAt, err = secret.Encrypt(rotation.NewAt); err != nil {
+		return nil, err
+	}
+	return &row, nil
+}
+
+// decryptRotation 原地解密 token 字段
+func decryptRotation(rotation *model.TokenRotation) error {
+	var err error
+	if rotation.OldRt, err = secret.Decrypt(rotation.OldRt); err != nil {
+		return fmt.Errorf("解密轮换日志失败(id=%d): %w", rotation.ID, err)
+	}
+	if rotation.NewRt, err = secret.Decrypt(rotation.NewRt); err != nil {
+		return fmt.Errorf("解密轮换日志失败(id=%d): %w", rotation.ID, err)
+	}
+	if rotation.NewAt, err = secret.Decrypt(rotation.NewAt); err != nil {
+		return fmt.Errorf("解密轮换日志失败(id=%d): %w", rotation.ID, err)
+	}
+	return nil
+}
+
+// Create 创建轮换日志
+func (r *tokenRotationRepository) Create(rotation *model.TokenRotation) error {
+	row, err := encryptRotation(rotation)
+	if err != nil {
+		return err
+	}
+	if err := r.db.Create(row).Error; err != nil {
+		return err
+	}
+	rotation.ID = row.ID
+	rotation.CreateTime = row.CreateTime
+	rotation.UpdateTime = row.UpdateTime
+	return nil
+}
+
+// Update 更新轮换日志
+func (r *tokenRotationRepository) Update(rotation *model.TokenRotation) error {
+	row, err := encryptRotation(rotation)
+	if err != nil {
+		return err
+	}
+	if err := r.db.Save(row).Error; err != nil {
+		return err
+	}
+	rotation.UpdateTime = row.UpdateTime
+	return nil
+}
+
+// Delete 删除轮换日志
+func (r *tokenRotationRepository) Delete(id int64) error {
+	return r.db.Where("id = ?", id).Delete(&model.TokenRotation{}).Error
+}
+
+// ListByStatus 按状态获取轮换日志
+func (r *tokenRotationRepository) ListByStatus(status string) ([]*model.TokenRotation, error) {
+	var rotations []*model.TokenRotation
+	if err := r.db.Where("status = ?", status).Order("id ASC").Find(&rotations).Error; err != nil {
+		return nil, err
+	}
+	for _, rotation := range rotations {
+		if err := decryptRotation(rotation); err != nil {
+			return nil, err
+		}
+	}
+	return rotations, nil
+}
+
+// GetLatestByRtID 获取RT最近一条指定状态的轮换日志
+func (r *tokenRotationRepository) GetLatestByRtID(rtId int64, status string) (*model.TokenRotation, error) {
+	var rotation model.TokenRotation
+	err := r.db.Where("rt_id = ? AND status = ?", rtId, status).Order("id DESC").First(&rotation).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if err := decryptRotation(&rotation); err != nil {
+		return nil, err
+	}
+	return &rotation, nil
+}
+
+// ReEncrypt 使用当前密钥重新加密所有轮换日志，返回重新加密的记录数
+func (r *tokenRotationRepository) ReEncrypt(batchSize int) (int, error) {
+	count := 0
+	var lastID int64
+	for {
+		var rotations []*model.TokenRotation
+		if err := r.db.Where("id > ?", lastID).Order("id ASC").Limit(batchSize).Find(&rotations).Error; err != nil {
+			return count, err
+		}
+		if len(rotations) == 0 {
+			return count, nil
+		}
+		lastID = rotations[len(rotations)-1].ID
+
+		for _, rotation := range rotations {
+			if secret.IsCurrent(rotation.OldRt) && secret.IsCurrent(rotation.NewRt) && secret.IsCurrent(rotation.NewAt) {
+				continue
+			}
+
+			plain := *rotation
+			if err := decryptRotation(&plain); err != nil {
+				return count, err
+			}
+			row, err := encryptRotation(&plain)
+			if err != nil {
+				return count, err
+			}
+			err = r.db.Model(&model.TokenRotation{}).
+				Where("id = ?", rotation.ID).
+				UpdateColumns(map[string]interface{}{
+					"old_rt": row.OldRt,
+					"new_rt": row.NewRt,
+					"new_at": row.NewAt,
+				}).Error
+			if err != nil {
+				return count, fmt.Errorf("重新加密轮换日志失败(id=%d): %w", rotation.ID, err)
+			}
+			count++
+		}
+	}
+}
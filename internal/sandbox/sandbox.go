@@ -0,0 +1,429 @@
+package sandbox
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"rt-manage/internal/config"
+	"rt-manage/pkg/logger"
+)
+
+// Server 沙箱服务，模拟 auth.openai.com 和 chatgpt.com 的相关接口
+//
+// 通过 RT 中包含的关键字触发对应场景（不区分大小写）：
+//   - invalid      : 400 invalid_grant
+//   - reused       : 401 refresh_token_reused
+//   - deactivated  : 401 account_deactivated
+//   - ratelimit    : 429 rate_limit_exceeded（带 Retry-After）
+//   - error5xx     : 502 非 JSON 响应
+//   - timeout      : 长时间不响应，触发客户端超时
+//   - plus/team/pro: 账号类型，默认 free
+//
+// 其余 RT 首次出现时自动注册为新账号，刷新后旧 RT 作废，再次使用返回 refresh_token_reused
+//
+// 授权码登录：GET /authorize 直接签发授权码并重定向到 redirect_uri（不需要登录），
+// login_hint 参数同样支持 plus/team/pro 关键字，授权码使用 authorization_code + PKCE 兑换
+type Server struct {
+	cfg        *config.SandboxConfig
+	httpServer *http.Server
+	listener   net.Listener
+
+	mu            sync.Mutex
+	refreshTokens map[string]*refreshTokenState
+	accessTokens  map[string]*accessTokenState
+	authCodes     map[string]*authCodeState
+}
+
+// authCodeState 授权码状态
+type authCodeState struct {
+	account       *account
+	clientID      string
+	redirectURI   string
+	codeChallenge string
+	expiresAt     time.Time
+}
+
+// account 沙箱账号
+type account struct {
+	AccountID string
+	UserID    string
+	Email     string
+	Name      string
+	PlanType  string
+	CreatedAt time.Time
+}
+
+// refreshTokenState RT 状态
+type refreshTokenState struct {
+	account *account
+	used    bool
+}
+
+// accessTokenState AT 状态
+type accessTokenState struct {
+	account   *account
+	expiresAt time.Time
+}
+
+// Start 启动沙箱服务
+func Start(cfg *config.SandboxConfig) (*Server, error) {
+	s := &Server{
+		cfg:           cfg,
+		refreshTokens: make(map[string]*refreshTokenState),
+		accessTokens:  make(map[string]*accessTokenState),
+		authCodes:     make(map[string]*authCodeState),
+	}
+
+	addr := fmt.Sprintf("%s:%d", cfg.Host, cfg.Port)
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("沙箱服务监听失败: %w", err)
+	}
+	s.listener = listener
+	s.httpServer = &http.Server{Handler: s.routes()}
+
+	go func() {
+		if err := s.httpServer.Serve(listener); err != nil && err != http.ErrServerClosed {
+			logger.Error("沙箱服务异常退出", "error", err)
+		}
+	}()
+
+	logger.Info("沙箱服务已启动", "address", s.URL())
+	return s, nil
+}
+
+// URL 沙箱服务地址
+func (s *Server) URL() string {
+	return "http://" + s.listener.Addr().String()
+}
+
+// Stop 停止沙箱服务
+func (s *Server) Stop() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return s.httpServer.Shutdown(ctx)
+}
+
+// routes 注册沙箱路由
+func (s *Server) routes() http.Handler {
+	r := gin.New()
+	r.Use(gin.Recovery())
+	r.Use(s.latency())
+
+	r.GET("/authorize", s.handleAuthorize)
+	r.POST("/oauth/token", s.handleToken)
+	r.GET("/backend-api/me", s.handleMe)
+	r.GET("/backend-api/accounts/check/:version", s.handleAccountsCheck)
+
+	return r
+}
+
+// latency 模拟接口延迟
+func (s *Server) latency() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if s.cfg.LatencyMs > 0 {
+			time.Sleep(time.Duration(s.cfg.LatencyMs) * time.Millisecond)
+		}
+		c.Next()
+	}
+}
+
+// handleAuthorize 模拟 GET /authorize，直接签发授权码并重定向到 redirect_uri
+func (s *Server) handleAuthorize(c *gin.Context) {
+	clientID := c.Query("client_id")
+	redirectURI := c.Query("redirect_uri")
+	challenge := c.Query("code_challenge")
+	switch {
+	case clientID == "" || redirectURI == "":
+		writeOAuthError(c, http.StatusBadRequest, "invalid_request", "invalid_request_error", "Missing required parameter: 'client_id' or 'redirect_uri'.")
+		return
+	case c.Query("response_type") != "code":
+		writeOAuthError(c, http.StatusBadRequest, "unsupported_response_type", "invalid_request_error", "Unsupported response type.")
+		return
+	case challenge == "" || c.Query("code_challenge_method") != "S256":
+		writeOAuthError(c, http.StatusBadRequest, "invalid_request", "invalid_request_error", "PKCE with S256 is required.")
+		return
+	}
+
+	code := "ac_sandbox_" + randomHex(16)
+	s.mu.Lock()
+	s.authCodes[code] = &authCodeState{
+		account:       newAccount(c.Query("login_hint") + code),
+		clientID:      clientID,
+		redirectURI:   redirectURI,
+		codeChallenge: challenge,
+		expiresAt:     time.Now().Add(10 * time.Minute),
+	}
+	s.mu.Unlock()
+
+	query := url.Values{}
+	query.Set("code", code)
+	query.Set("state", c.Query("state"))
+	separator := "?"
+	if strings.Contains(redirectURI, "?") {
+		separator = "&"
+	}
+	c.Redirect(http.StatusFound, redirectURI+separator+query.Encode())
+}
+
+// handleToken 模拟 POST /oauth/token
+func (s *Server) handleToken(c *gin.Context) {
+	var req struct {
+		ClientID     string `json:"client_id" form:"client_id"`
+		GrantType    string `json:"grant_type" form:"grant_type"`
+		RedirectURI  string `json:"redirect_uri" form:"redirect_uri"`
+		RefreshToken string `json:"refresh_token" form:"refresh_token"`
+		Code         string `json:"code" form:"code"`
+		CodeVerifier string `json:"code_verifier" form:"code_verifier"`
+	}
+	if err := c.ShouldBind(&req); err != nil {
+		writeOAuthError(c, http.StatusBadRequest, "invalid_request", "invalid_request_error", "Could not parse request body.")
+		return
+	}
+
+	if req.ClientID == "" {
+		writeOAuthError(c, http.StatusBadRequest, "invalid_request", "invalid_request_error", "Missing required parameter: 'client_id'.")
+		return
+	}
+	if req.GrantType == "authorization_code" {
+		s.handleAuthorizationCode(c, req.ClientID, req.RedirectURI, req.Code, req.CodeVerifier)
+		return
+	}
+	if req.GrantType != "refresh_token" {
+		writeOAuthError(c, http.StatusBadRequest, "unsupported_grant_type", "invalid_request_error", fmt.Sprintf("Unsupported grant type: '%s'.", req.GrantType))
+		return
+	}
+	if req.RefreshToken == "" {
+		writeOAuthError(c, http.StatusBadRequest, "invalid_request", "invalid_request_error", "Missing required parameter: 'refresh_token'.")
+		return
+	}
+
+	// 关键字场景
+	lower := strings.ToLower(req.RefreshToken)
+	switch {
+	case strings.Contains(lower, "timeout"):
+		select {
+		case <-time.After(60 * time.Second):
+		case <-c.Request.Context().Done():
+		}
+		c.Status(http.StatusGatewayTimeout)
+		return
+	case strings.Contains(lower, "error5xx"):
+		c.Data(http.StatusBadGateway, "text/html; charset=UTF-8", []byte("<html><head><title>502 Bad Gateway</title></head><body><center><h1>502 Bad Gateway</h1></center><hr><center>cloudflare</center></body></html>"))
+		return
+	case strings.Contains(lower, "ratelimit"):
+		c.Header("Retry-After", "30")
+		writeOAuthError(c, http.StatusTooManyRequests, "rate_limit_exceeded", "invalid_request_error", "Too many requests. Please try again later.")
+		return
+	case strings.Contains(lower, "deactivated"):
+		writeOAuthError(c, http.StatusUnauthorized, "account_deactivated", "invalid_request_error", "This user's account has been deactivated.")
+		return
+	case strings.Contains(lower, "reused"):
+		writeOAuthError(c, http.StatusUnauthorized, "refresh_token_reused", "invalid_request_error", "Your refresh token has already been used to generate a new access token. Please try signing in again.")
+		return
+	case strings.Contains(lower, "invalid"):
+		writeOAuthError(c, http.StatusBadRequest, "invalid_grant", "invalid_request_error", "Invalid refresh token.")
+		return
+	}
+
+	s.mu.Lock()
+	state, ok := s.refreshTokens[req.RefreshToken]
+	if !ok {
+		// 未知 RT 自动注册为新账号
+		state = &refreshTokenState{account: newAccount(req.RefreshToken)}
+		s.refreshTokens[req.RefreshToken] = state
+	}
+	if state.used {
+		s.mu.Unlock()
+		writeOAuthError(c, http.StatusUnauthorized, "refresh_token_reused", "invalid_request_error", "Your refresh token has already been used to generate a new access token. Please try signing in again.")
+		return
+	}
+
+	// 轮换 RT
+	state.used = true
+	s.issueTokens(c, state.account, req.ClientID)
+}
+
+// handleAuthorizationCode 授权码 + PKCE 兑换 token，授权码只能使用一次
+func (s *Server) handleAuthorizationCode(c *gin.Context, clientID, redirectURI, code, verifier string) {
+	if code == "" || verifier == "" {
+		writeOAuthError(c, http.StatusBadRequest, "invalid_request", "invalid_request_error", "Missing required parameter: 'code' or 'code_verifier'.")
+		return
+	}
+
+	s.mu.Lock()
+	state, ok := s.authCodes[code]
+	delete(s.authCodes, code)
+	if !ok || time.Now().After(state.expiresAt) {
+		s.mu.Unlock()
+		writeOAuthError(c, http.StatusBadRequest, "invalid_grant", "invalid_request_error", "Invalid authorization code.")
+		return
+	}
+	sum := sha256.Sum256([]byte(verifier))
+	if state.clientID != clientID || state.redirectURI != redirectURI ||
+		base64.RawURLEncoding.EncodeToString(sum[:]) != state.codeChallenge {
+		s.mu.Unlock()
+		writeOAuthError(c, http.StatusBadRequest, "invalid_grant", "invalid_request_error", "Invalid authorization code or code verifier.")
+		return
+	}
+	s.issueTokens(c, state.account, clientID)
+}
+
+// issueTokens 签发新的 RT、AT 和 id_token（调用方须持有 s.mu，返回前释放）
+func (s *Server) issueTokens(c *gin.Context, acc *account, clientID string) {
+	newRefreshToken := "rt_sandbox_" + randomHex(24)
+	s.refreshTokens[newRefreshToken] = &refreshTokenState{account: acc}
+
+	now := time.Now()
+	ttl := time.Duration(s.cfg.AccessTokenTTL) * time.Second
+	accessToken := buildAccessToken(acc, clientID, now, ttl)
+	s.accessTokens[accessToken] = &accessTokenState{account: acc, expiresAt: now.Add(ttl)}
+	s.mu.Unlock()
+
+	c.JSON(http.StatusOK, gin.H{
+		"access_token":  accessToken,
+		"refresh_token": newRefreshToken,
+		"id_token":      buildIDToken(acc, clientID, now),
+		"expires_in":    s.cfg.AccessTokenTTL,
+		"token_type":    "Bearer",
+		"scope":         "openid profile email offline_access",
+	})
+}
+
+// handleMe 模拟 GET /backend-api/me
+func (s *Server) handleMe(c *gin.Context) {
+	acc, ok := s.authenticate(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"object":           "user",
+		"id":               acc.UserID,
+		"email":            acc.Email,
+		"name":             acc.Name,
+		"picture":          "",
+		"created":          acc.CreatedAt.Unix(),
+		"phone_number":     nil,
+		"mfa_flag_enabled": false,
+		"amr":              []string{},
+		"groups":           []string{},
+		"orgs": gin.H{
+			"object": "list",
+			"data":   []interface{}{},
+		},
+	})
+}
+
+// handleAccountsCheck 模拟 GET /backend-api/accounts/check/:version
+func (s *Server) handleAccountsCheck(c *gin.Context) {
+	acc, ok := s.authenticate(c)
+	if !ok {
+		return
+	}
+
+	item := gin.H{
+		"account": gin.H{
+			"account_id":        acc.AccountID,
+			"account_user_role": "account-owner",
+			"plan_type":         acc.PlanType,
+			"structure":         "personal",
+			"is_deactivated":    false,
+		},
+		"features": []string{},
+		"entitlement": gin.H{
+			"subscription_plan":       acc.PlanType,
+			"has_active_subscription": acc.PlanType != "free",
+		},
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"accounts": gin.H{
+			acc.AccountID: item,
+		},
+		"account_ordering": []string{acc.AccountID},
+	})
+}
+
+// authenticate 校验 Bearer AT，失败时直接写入 401 响应
+func (s *Server) authenticate(c *gin.Context) (*account, bool) {
+	token := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+
+	s.mu.Lock()
+	state, ok := s.accessTokens[token]
+	s.mu.Unlock()
+
+	if token == "" || !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"detail": gin.H{
+				"message": "Could not parse your authentication token. Please try signing in again.",
+				"code":    "invalid_jwt",
+			},
+		})
+		return nil, false
+	}
+	if time.Now().After(state.expiresAt) {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"detail": gin.H{
+				"message": "Your authentication token has expired. Please try signing in again.",
+				"code":    "token_expired",
+			},
+		})
+		return nil, false
+	}
+	return state.account, true
+}
+
+// writeOAuthError 输出与 auth.openai.com 一致的错误结构
+func writeOAuthError(c *gin.Context, status int, code, errType, message string) {
+	c.JSON(status, gin.H{
+		"error": gin.H{
+			"message": message,
+			"type":    errType,
+			"param":   nil,
+			"code":    code,
+		},
+	})
+}
+
+// newAccount 根据 RT 生成沙箱账号（同一 RT 生成的账号信息固定）
+func newAccount(refreshToken string) *account {
+	sum := sha256.Sum256([]byte(refreshToken))
+	seed := hex.EncodeToString(sum[:])
+
+	planType := "free"
+	lower := strings.ToLower(refreshToken)
+	for _, plan := range []string{"team", "plus", "pro"} {
+		if strings.Contains(lower, plan) {
+			planType = plan
+			break
+		}
+	}
+
+	return &account{
+		AccountID: fmt.Sprintf("%s-%s-%s-%s-%s", seed[0:8], seed[8:12], seed[12:16], seed[16:20], seed[20:32]),
+		UserID:    "user-" + seed[32:56],
+		Email:     "sandbox-" + seed[:8] + "@example.com",
+		Name:      "Sandbox " + seed[:8],
+		PlanType:  planType,
+		CreatedAt: time.Now(),
+	}
+}
+
+// randomHex 生成随机十六进制字符串
+func randomHex(n int) string {
+	buf := make([]byte, n)
+	rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
@@ -0,0 +1,58 @@
+package sandbox
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"time"
+)
+
+// buildAccessToken 生成结构与线上一致的 AT（签名为随机值，仅沙箱内部有效）
+func buildAccessToken(acc *account, clientID string, now time.Time, ttl time.Duration) string {
+	return encodeJWT(map[string]interface{}{
+		"aud":       []string{"https://api.openai.com/v1"},
+		"client_id": clientID,
+		"exp":       now.Add(ttl).Unix(),
+		"iat":       now.Unix(),
+		"iss":       "https://auth.openai.com",
+		"jti":       randomHex(16),
+		"nbf":       now.Unix(),
+		"scp":       []string{"openid", "profile", "email", "offline_access"},
+		"sub":       acc.UserID,
+		"https://api.openai.com/auth": map[string]interface{}{
+			"chatgpt_account_id": acc.AccountID,
+			"chatgpt_plan_type":  acc.PlanType,
+			"user_id":            acc.UserID,
+		},
+		"https://api.openai.com/profile": map[string]interface{}{
+			"email":          acc.Email,
+			"email_verified": true,
+		},
+	})
+}
+
+// buildIDToken 生成 id_token
+func buildIDToken(acc *account, clientID string, now time.Time) string {
+	return encodeJWT(map[string]interface{}{
+		"aud":            []string{clientID},
+		"email":          acc.Email,
+		"email_verified": true,
+		"exp":            now.Add(time.Hour).Unix(),
+		"iat":            now.Unix(),
+		"iss":            "https://auth.openai.com",
+		"sub":            acc.UserID,
+		"https://api.openai.com/auth": map[string]interface{}{
+			"chatgpt_account_id": acc.AccountID,
+			"chatgpt_plan_type":  acc.PlanType,
+			"chatgpt_user_id":    acc.UserID,
+			"user_id":            acc.UserID,
+		},
+	})
+}
+
+// encodeJWT 编码 JWT（header.payload.signature）
+func encodeJWT(claims map[string]interface{}) string {
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+	enc := base64.RawURLEncoding
+	return enc.EncodeToString(header) + "." + enc.EncodeToString(payload) + "." + randomHex(32)
+}
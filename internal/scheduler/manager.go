@@ -14,7 +14,7 @@ var (
 
 // RTServiceInterface 定义RT服务接口，避免循环导入
 type RTServiceInterface interface {
-	AutoRefreshAll() error
+	RefreshDue() error
 }
 
 // Manager 调度器管理器（单例模式）
@@ -56,7 +56,7 @@ func (m *Manager) Start(intervalDays int) error {
 	}
 
 	// 创建新的调度器
-	m.scheduler = NewScheduler(m.rtService, intervalDays)
+	m.scheduler = NewScheduler(m.rtService)
 	m.intervalDay = intervalDays
 	m.scheduler.Start()
 	m.running = true
@@ -103,7 +103,7 @@ func (m *Manager) Restart(intervalDays int) error {
 	}
 
 	// 启动新调度器
-	m.scheduler = NewScheduler(m.rtService, intervalDays)
+	m.scheduler = NewScheduler(m.rtService)
 	m.intervalDay = intervalDays
 	m.scheduler.Start()
 
@@ -118,7 +118,7 @@ func (m *Manager) IsRunning() bool {
 	return m.running
 }
 
-// GetInterval 获取当前刷新间隔（天）
+// GetInterval 获取当前刷新间隔（天），即 RT 最长使用时间
 func (m *Manager) GetInterval() int {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
@@ -4,10 +4,13 @@ import (
 	"context"
 	"time"
 
+	"rt-manage/internal/config"
 	"rt-manage/pkg/logger"
 )
 
 // Scheduler 定时任务调度器
+// 按固定间隔扫描到达计划刷新时间（next_refresh_time）的RT并刷新，
+// 每个RT的刷新时间由其AT过期时间、RT使用时间和安全余量决定
 type Scheduler struct {
 	rtService RTServiceInterface
 	interval  time.Duration
@@ -16,11 +19,17 @@ type Scheduler struct {
 }
 
 // NewScheduler 创建调度器实例
-func NewScheduler(rtService RTServiceInterface, intervalDays int) *Scheduler {
+func NewScheduler(rtService RTServiceInterface) *Scheduler {
 	ctx, cancel := context.WithCancel(context.Background())
+
+	scanSeconds := config.Get().OpenAI.ScheduleScanSeconds
+	if scanSeconds <= 0 {
+		scanSeconds = 60
+	}
+
 	return &Scheduler{
 		rtService: rtService,
-		interval:  time.Duration(intervalDays) * 24 * time.Hour,
+		interval:  time.Duration(scanSeconds) * time.Second,
 		ctx:       ctx,
 		cancel:    cancel,
 	}
@@ -28,27 +37,20 @@ func NewScheduler(rtService RTServiceInterface, intervalDays int) *Scheduler {
 
 // Start 启动定时任务
 func (s *Scheduler) Start() {
-	logger.Info("启动定时刷新任务", "interval", s.interval)
+	logger.Info("启动定时刷新任务", "scan_interval", s.interval)
 
-	// 立即执行一次
 	go func() {
-		if err := s.rtService.AutoRefreshAll(); err != nil {
-			logger.Error("自动刷新失败", "error", err)
-		}
-	}()
+		// 立即执行一次
+		s.run()
 
-	// 定时执行
-	ticker := time.NewTicker(s.interval)
-	go func() {
+		// 定时扫描，上一轮结束后才会开始下一轮
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
 		for {
 			select {
 			case <-ticker.C:
-				logger.Info("执行定时刷新任务")
-				if err := s.rtService.AutoRefreshAll(); err != nil {
-					logger.Error("自动刷新失败", "error", err)
-				}
+				s.run()
 			case <-s.ctx.Done():
-				ticker.Stop()
 				logger.Info("定时刷新任务已停止")
 				return
 			}
@@ -56,6 +58,16 @@ func (s *Scheduler) Start() {
 	}()
 }
 
+// run 执行一轮到期RT刷新
+func (s *Scheduler) run() {
+	if s.ctx.Err() != nil {
+		return
+	}
+	if err := s.rtService.RefreshDue(); err != nil {
+		logger.Error("自动刷新失败", "error", err)
+	}
+}
+
 // Stop 停止定时任务
 func (s *Scheduler) Stop() {
 	s.cancel()
@@ -0,0 +1,329 @@
+package service
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"rt-manage/internal/config"
+	"rt-manage/internal/model"
+	"rt-manage/internal/repository"
+	jwtutil "rt-manage/pkg/jwt"
+	"rt-manage/pkg/logger"
+
+	"github.com/google/uuid"
+)
+
+// 登录会话错误
+var (
+	ErrSessionInvalid      = errors.New("登录会话无效或已注销")
+	ErrRefreshTokenInvalid = errors.New("刷新令牌无效或已过期")
+	ErrRefreshTokenReused  = errors.New("刷新令牌已被使用，会话已注销，请重新登录")
+)
+
+// refreshTokenPrefix 刷新令牌的前缀，便于识别和扫描泄露
+const refreshTokenPrefix = "rts_"
+
+// sessionCacheTTL 会话校验结果的缓存时间，本实例注销会话时立即清空缓存
+const sessionCacheTTL = 10 * time.Second
+
+// sessionPurgeInterval、sessionPurgeAfter 过期会话的清理间隔和保留时长
+const (
+	sessionPurgeInterval = time.Hour
+	sessionPurgeAfter    = 7 * 24 * time.Hour
+)
+
+// 有效会话的缓存（包级共享）：会话ID -> 缓存过期时间
+var (
+	sessionCacheMu   sync.Mutex
+	sessionCache     = make(map[string]time.Time)
+	sessionLastPurge time.Time
+)
+
+// SessionTokens 登录或刷新后签发的令牌
+type SessionTokens struct {
+	AccessToken      string
+	RefreshToken     string
+	ExpiresIn        int // 访问令牌有效期（秒）
+	RefreshExpiresIn int // 刷新令牌有效期（秒）
+	Session          *model.AdminSession
+	User             *model.AdminUser
+}
+
+// AdminSessionService 管理后台登录会话服务接口
+type AdminSessionService interface {
+	Create(user *model.AdminUser, ip, userAgent string) (*SessionTokens, error)
+	Refresh(refreshToken, ip string) (*SessionTokens, error)
+	Validate(sessionID string) error
+	GetBySessionID(sessionID string) (*model.AdminSession, error)
+	Revoke(sessionID, reason string) error
+	RevokeUser(userID int64, exceptSessionID, reason string) (int64, error)
+	List(page, pageSize int, userID int64, username string, activeOnly bool) ([]*model.AdminSession, int64, error)
+}
+
+type adminSessionService struct {
+	repo     repository.AdminSessionRepository
+	userRepo repository.AdminUserRepository
+}
+
+// NewAdminSessionService 创建管理后台会话服务实例
+func NewAdminSessionService(repo repository.AdminSessionRepository, userRepo repository.AdminUserRepository) AdminSessionService {
+	return &adminSessionService{repo: repo, userRepo: userRepo}
+}
+
+// hashRefreshToken 计算刷新令牌的 SHA-256（令牌本身是高熵随机串，不需要加盐）
+func hashRefreshToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func newRefreshToken() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("生成刷新令牌失败: %v", err)
+	}
+	return refreshTokenPrefix + hex.EncodeToString(buf), nil
+}
+
+// sessionTTL 登录会话（刷新令牌）有效期
+func sessionTTL() time.Duration {
+	hours := config.Get().Auth.JWTExpireHours
+	if hours <= 0 {
+		hours = 24
+	}
+	return time.Duration(hours) * time.Hour
+}
+
+// accessTokenTTL 访问令牌有效期
+func accessTokenTTL() time.Duration {
+	minutes := config.Get().Auth.AccessTokenMinutes
+	if minutes <= 0 {
+		minutes = 15
+	}
+	return time.Duration(minutes) * time.Minute
+}
+
+// Create 登录成功后创建会话，签发访问令牌和刷新令牌
+func (s *adminSessionService) Create(user *model.AdminUser, ip, userAgent string) (*SessionTokens, error) {
+	now := time.Now()
+	s.purgeExpired(now)
+
+	refreshToken, err := newRefreshToken()
+	if err != nil {
+		return nil, err
+	}
+	if len(userAgent) > 255 {
+		userAgent = userAgent[:255]
+	}
+
+	session := &model.AdminSession{
+		SessionID:        uuid.New().String(),
+		UserID:           user.ID,
+		Username:         user.Username,
+		RefreshTokenHash: hashRefreshToken(refreshToken),
+		ClientIP:         ip,
+		UserAgent:        userAgent,
+		ExpireTime:       now.Add(sessionTTL()),
+	}
+	if err := s.repo.Create(session); err != nil {
+		return nil, fmt.Errorf("创建登录会话失败: %v", err)
+	}
+
+	return s.issue(session, user, refreshToken, now)
+}
+
+// Refresh 用刷新令牌换取新的访问令牌，同时轮换刷新令牌
+// 已轮换的旧令牌再次使用说明令牌可能已泄露，整个会话会被注销
+func (s *adminSessionService) Refresh(refreshToken, ip string) (*SessionTokens, error) {
+	now := time.Now()
+	tokenHash := hashRefreshToken(refreshToken)
+
+	session, err := s.repo.GetByRefreshHash(tokenHash)
+	if err != nil {
+		return nil, err
+	}
+	if session == nil {
+		previous, err := s.repo.GetByPreviousHash(tokenHash)
+		if err != nil {
+			return nil, err
+		}
+		if previous != nil && previous.RevokedTime == nil {
+			s.revokeReused(previous, ip, now)
+			return nil, ErrRefreshTokenReused
+		}
+		return nil, ErrRefreshTokenInvalid
+	}
+	if !session.Active(now) {
+		return nil, ErrRefreshTokenInvalid
+	}
+
+	// 账号被删除或禁用后不再续期
+	user, err := s.userRepo.GetByID(session.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil || !user.Enabled {
+		reason := model.SessionRevokeUserDisabled
+		if user == nil {
+			reason = model.SessionRevokeUserDeleted
+		}
+		if err := s.Revoke(session.SessionID, reason); err != nil {
+			logger.Warn("注销登录会话失败", "session_id", session.SessionID, "error", err)
+		}
+		return nil, ErrRefreshTokenInvalid
+	}
+
+	newToken, err := newRefreshToken()
+	if err != nil {
+		return nil, err
+	}
+	expireTime := now.Add(sessionTTL())
+	rotated, err := s.repo.Rotate(session.SessionID, tokenHash, hashRefreshToken(newToken), expireTime, now)
+	if err != nil {
+		return nil, fmt.Errorf("轮换刷新令牌失败: %v", err)
+	}
+	if !rotated {
+		// 同一个刷新令牌被并发使用，另一个请求已完成轮换
+		s.revokeReused(session, ip, now)
+		return nil, ErrRefreshTokenReused
+	}
+	session.ExpireTime = expireTime
+	session.LastRefreshTime = &now
+
+	return s.issue(session, user, newToken, now)
+}
+
+// revokeReused 刷新令牌被重放时注销会话
+func (s *adminSessionService) revokeReused(session *model.AdminSession, ip string, now time.Time) {
+	logger.Warn("审计：刷新令牌被重复使用，已注销会话", "session_id", session.SessionID, "username", session.Username, "client_ip", ip, "login_ip", session.ClientIP)
+	if _, err := s.repo.Revoke(session.SessionID, model.SessionRevokeRefreshReused, now); err != nil {
+		logger.Error("注销登录会话失败", "session_id", session.SessionID, "error", err)
+	}
+	invalidateSessionCache()
+}
+
+// issue 签发访问令牌（角色取自账号的当前角色）
+func (s *adminSessionService) issue(session *model.AdminSession, user *model.AdminUser, refreshToken string, now time.Time) (*SessionTokens, error) {
+	ttl := accessTokenTTL()
+	accessToken, err := jwtutil.GenerateToken(user.Username, user.Role, session.SessionID, config.Get().Auth.JWTSecret, ttl)
+	if err != nil {
+		return nil, fmt.Errorf("生成令牌失败: %v", err)
+	}
+	return &SessionTokens{
+		AccessToken:      accessToken,
+		RefreshToken:     refreshToken,
+		ExpiresIn:        int(ttl.Seconds()),
+		RefreshExpiresIn: int(session.ExpireTime.Sub(now).Seconds()),
+		Session:          session,
+		User:             user,
+	}, nil
+}
+
+// Validate 校验访问令牌所属的会话未注销且未过期，结果缓存 sessionCacheTTL
+func (s *adminSessionService) Validate(sessionID string) error {
+	now := time.Now()
+
+	sessionCacheMu.Lock()
+	cachedUntil, ok := sessionCache[sessionID]
+	sessionCacheMu.Unlock()
+	if ok && cachedUntil.After(now) {
+		return nil
+	}
+
+	session, err := s.repo.GetBySessionID(sessionID)
+	if err != nil {
+		return err
+	}
+	if session == nil || !session.Active(now) {
+		return ErrSessionInvalid
+	}
+
+	cachedUntil = now.Add(sessionCacheTTL)
+	if session.ExpireTime.Before(cachedUntil) {
+		cachedUntil = session.ExpireTime
+	}
+	sessionCacheMu.Lock()
+	for id, until := range sessionCache {
+		if len(sessionCache) < 10000 {
+			break
+		}
+		if !until.After(now) {
+			delete(sessionCache, id)
+		}
+	}
+	sessionCache[sessionID] = cachedUntil
+	sessionCacheMu.Unlock()
+	return nil
+}
+
+// GetBySessionID 根据会话ID获取会话
+func (s *adminSessionService) GetBySessionID(sessionID string) (*model.AdminSession, error) {
+	return s.repo.GetBySessionID(sessionID)
+}
+
+// Revoke 注销单个会话
+func (s *adminSessionService) Revoke(sessionID, reason string) error {
+	count, err := s.repo.Revoke(sessionID, reason, time.Now())
+	if err != nil {
+		return fmt.Errorf("注销登录会话失败: %v", err)
+	}
+	invalidateSessionCache()
+	logger.Info("注销登录会话", "session_id", sessionID, "reason", reason, "count", count)
+	return nil
+}
+
+// RevokeUser 注销账号的所有会话，exceptSessionID 不为空时保留该会话
+func (s *adminSessionService) RevokeUser(userID int64, exceptSessionID, reason string) (int64, error) {
+	return revokeUserSessions(s.repo, userID, exceptSessionID, reason)
+}
+
+// List 获取会话列表
+func (s *adminSessionService) List(page, pageSize int, userID int64, username string, activeOnly bool) ([]*model.AdminSession, int64, error) {
+	return s.repo.List(page, pageSize, userID, username, activeOnly, time.Now())
+}
+
+// purgeExpired 定期删除过期超过 sessionPurgeAfter 的会话
+func (s *adminSessionService) purgeExpired(now time.Time) {
+	sessionCacheMu.Lock()
+	due := now.Sub(sessionLastPurge) >= sessionPurgeInterval
+	if due {
+		sessionLastPurge = now
+	}
+	sessionCacheMu.Unlock()
+	if !due {
+		return
+	}
+
+	count, err := s.repo.DeleteExpiredBefore(now.Add(-sessionPurgeAfter))
+	if err != nil {
+		logger.Error("清理过期登录会话失败", "error", err)
+		return
+	}
+	if count > 0 {
+		logger.Info("已清理过期登录会话", "count", count)
+	}
+}
+
+// revokeUserSessions 注销账号的会话并清空会话缓存（账号被禁用、删除、修改角色或密码时调用）
+func revokeUserSessions(repo repository.AdminSessionRepository, userID int64, exceptSessionID, reason string) (int64, error) {
+	count, err := repo.RevokeByUser(userID, exceptSessionID, reason, time.Now())
+	if err != nil {
+		return 0, fmt.Errorf("注销登录会话失败: %v", err)
+	}
+	invalidateSessionCache()
+	if count > 0 {
+		logger.Info("注销账号的登录会话", "user_id", userID, "reason", reason, "count", count)
+	}
+	return count, nil
+}
+
+// invalidateSessionCache 清空会话缓存，使注销立即生效
+func invalidateSessionCache() {
+	sessionCacheMu.Lock()
+	sessionCache = make(map[string]time.Time)
+	sessionCacheMu.Unlock()
+}
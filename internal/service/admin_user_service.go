@@ -0,0 +1,322 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"rt-manage/internal/model"
+	"rt-manage/internal/repository"
+	"rt-manage/pkg/logger"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// 管理后台账号错误
+var (
+	ErrLoginFailed       = errors.New("用户名或密码错误")
+	ErrAdminUserDisabled = errors.New("账号已禁用")
+	ErrLastAdmin         = errors.New("至少需要保留一个已启用的管理员")
+)
+
+// AdminRoles 可分配的角色
+var AdminRoles = []string{model.RoleAdmin, model.RoleOperator, model.RoleViewer}
+
+// minPasswordLength 密码最小长度
+const minPasswordLength = 8
+
+// dummyPasswordHash 用户不存在时参与比较的哈希，使登录耗时与用户是否存在无关
+var dummyPasswordHash, _ = bcrypt.GenerateFromPassword([]byte("rt-manage-dummy-password"), bcrypt.DefaultCost)
+
+// AdminUserService 管理后台账号服务接口
+type AdminUserService interface {
+	Authenticate(username, password, ip string) (*model.AdminUser, error)
+	Create(user *model.AdminUser, password string) error
+	Update(id int64, updates map[string]interface{}, operator string) (*model.AdminUser, error)
+	Delete(id int64, operator string) error
+	List(page, pageSize int, username, role string, enabled *bool) ([]*model.AdminUser, int64, error)
+	GetByID(id int64) (*model.AdminUser, error)
+	GetByUsername(username string) (*model.AdminUser, error)
+	ChangePassword(username, oldPassword, newPassword, currentSessionID string) error
+	EnsureDefaultAdmin(username, password string) error
+}
+
+type adminUserService struct {
+	repo        repository.AdminUserRepository
+	sessionRepo repository.AdminSessionRepository
+}
+
+// NewAdminUserService 创建管理后台账号服务实例
+func NewAdminUserService(repo repository.AdminUserRepository, sessionRepo repository.AdminSessionRepository) AdminUserService {
+	return &adminUserService{repo: repo, sessionRepo: sessionRepo}
+}
+
+// hashPassword 校验密码长度并计算 bcrypt 哈希
+func hashPassword(password string) (string, error) {
+	if len(password) < minPasswordLength {
+		return "", fmt.Errorf("密码长度不能少于 %d 位", minPasswordLength)
+	}
+	// bcrypt 只使用前 72 字节，超出部分会被忽略
+	if len(password) > 72 {
+		return "", fmt.Errorf("密码长度不能超过 72 字节")
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", fmt.Errorf("计算密码哈希失败: %v", err)
+	}
+	return string(hash), nil
+}
+
+func checkRole(role string) error {
+	if !containsString(AdminRoles, role) {
+		return fmt.Errorf("不支持的角色: %s，可选值: %s", role, strings.Join(AdminRoles, ","))
+	}
+	return nil
+}
+
+// Authenticate 校验用户名和密码，通过后记录最后登录时间和IP
+func (s *adminUserService) Authenticate(username, password, ip string) (*model.AdminUser, error) {
+	user, err := s.repo.GetByUsername(username)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		_ = bcrypt.CompareHashAndPassword(dummyPasswordHash, []byte(password))
+		return nil, ErrLoginFailed
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)); err != nil {
+		return nil, ErrLoginFailed
+	}
+	if !user.Enabled {
+		return user, ErrAdminUserDisabled
+	}
+
+	now := time.Now()
+	if err := s.repo.UpdateLastLogin(user.ID, now, ip); err != nil {
+		logger.Warn("更新最后登录时间失败", "username", user.Username, "error", err)
+	} else {
+		user.LastLoginTime = &now
+		user.LastLoginIP = ip
+	}
+	return user, nil
+}
+
+// Create 创建账号
+func (s *adminUserService) Create(user *model.AdminUser, password string) error {
+	user.Username = strings.TrimSpace(user.Username)
+	if user.Username == "" {
+		return fmt.Errorf("用户名不能为空")
+	}
+	if err := checkRole(user.Role); err != nil {
+		return err
+	}
+	existing, err := s.repo.GetByUsername(user.Username)
+	if err != nil {
+		return err
+	}
+	if existing != nil {
+		return fmt.Errorf("用户名已存在: %s", user.Username)
+	}
+
+	hash, err := hashPassword(password)
+	if err != nil {
+		return err
+	}
+	user.PasswordHash = hash
+
+	if err := s.repo.Create(user); err != nil {
+		return fmt.Errorf("创建账号失败: %v", err)
+	}
+	logger.Info("创建管理后台账号", "id", user.ID, "username", user.Username, "role", user.Role, "enabled", user.Enabled)
+	return nil
+}
+
+// Update 更新账号的角色、启用状态、备注，或重置密码（用户名不可修改）
+// operator 为当前登录用户，不能禁用自己或修改自己的角色
+func (s *adminUserService) Update(id int64, updates map[string]interface{}, operator string) (*model.AdminUser, error) {
+	user, err := s.repo.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, fmt.Errorf("账号不存在")
+	}
+	wasActiveAdmin := user.Role == model.RoleAdmin && user.Enabled
+	revokeReason := ""
+
+	if role, ok := updates["role"].(string); ok && role != user.Role {
+		if err := checkRole(role); err != nil {
+			return nil, err
+		}
+		if user.Username == operator {
+			return nil, fmt.Errorf("不能修改自己的角色")
+		}
+		user.Role = role
+		revokeReason = model.SessionRevokeRoleChanged
+	}
+	if enabled, ok := updates["enabled"].(bool); ok && enabled != user.Enabled {
+		if user.Username == operator {
+			return nil, fmt.Errorf("不能禁用自己的账号")
+		}
+		user.Enabled = enabled
+		if !enabled {
+			revokeReason = model.SessionRevokeUserDisabled
+		}
+	}
+	if memo, ok := updates["memo"].(string); ok {
+		user.Memo = memo
+	}
+	passwordReset := false
+	if password, ok := updates["password"].(string); ok && password != "" {
+		hash, err := hashPassword(password)
+		if err != nil {
+			return nil, err
+		}
+		user.PasswordHash = hash
+		passwordReset = true
+		if revokeReason == "" {
+			revokeReason = model.SessionRevokePasswordChanged
+		}
+	}
+
+	if wasActiveAdmin && !(user.Role == model.RoleAdmin && user.Enabled) {
+		if err := s.ensureOtherAdmin(); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := s.repo.Update(user); err != nil {
+		return nil, fmt.Errorf("更新账号失败: %v", err)
+	}
+	logger.Info("更新管理后台账号", "id", user.ID, "username", user.Username, "role", user.Role, "enabled", user.Enabled, "password_reset", passwordReset, "operator", operator)
+
+	// 角色变更、禁用或重置密码后，已登录的会话全部失效，需要重新登录
+	if revokeReason != "" {
+		if _, err := revokeUserSessions(s.sessionRepo, user.ID, "", revokeReason); err != nil {
+			logger.Error("注销账号的登录会话失败", "id", user.ID, "error", err)
+		}
+	}
+	return user, nil
+}
+
+// Delete 删除账号，不能删除自己和最后一个已启用的管理员
+func (s *adminUserService) Delete(id int64, operator string) error {
+	user, err := s.repo.GetByID(id)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return fmt.Errorf("账号不存在")
+	}
+	if user.Username == operator {
+		return fmt.Errorf("不能删除自己的账号")
+	}
+	if user.Role == model.RoleAdmin && user.Enabled {
+		if err := s.ensureOtherAdmin(); err != nil {
+			return err
+		}
+	}
+
+	if err := s.repo.Delete(id); err != nil {
+		return fmt.Errorf("删除账号失败: %v", err)
+	}
+	logger.Info("删除管理后台账号", "id", id, "username", user.Username, "operator", operator)
+
+	if _, err := revokeUserSessions(s.sessionRepo, user.ID, "", model.SessionRevokeUserDeleted); err != nil {
+		logger.Error("注销账号的登录会话失败", "id", user.ID, "error", err)
+	}
+	return nil
+}
+
+// ensureOtherAdmin 当前管理员被降级、禁用或删除前，确认还有其他已启用的管理员
+func (s *adminUserService) ensureOtherAdmin() error {
+	count, err := s.repo.CountEnabledAdmins()
+	if err != nil {
+		return err
+	}
+	if count <= 1 {
+		return ErrLastAdmin
+	}
+	return nil
+}
+
+// List 获取账号列表
+func (s *adminUserService) List(page, pageSize int, username, role string, enabled *bool) ([]*model.AdminUser, int64, error) {
+	return s.repo.List(page, pageSize, username, role, enabled)
+}
+
+// GetByID 根据 ID 获取账号
+func (s *adminUserService) GetByID(id int64) (*model.AdminUser, error) {
+	return s.repo.GetByID(id)
+}
+
+// GetByUsername 根据用户名获取账号
+func (s *adminUserService) GetByUsername(username string) (*model.AdminUser, error) {
+	return s.repo.GetByUsername(username)
+}
+
+// ChangePassword 修改自己的密码，需校验原密码
+// 修改成功后注销该账号的其他会话，currentSessionID 对应的当前会话保持登录
+func (s *adminUserService) ChangePassword(username, oldPassword, newPassword, currentSessionID string) error {
+	user, err := s.repo.GetByUsername(username)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return fmt.Errorf("账号不存在")
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(oldPassword)); err != nil {
+		return fmt.Errorf("原密码错误")
+	}
+	if oldPassword == newPassword {
+		return fmt.Errorf("新密码不能与原密码相同")
+	}
+
+	hash, err := hashPassword(newPassword)
+	if err != nil {
+		return err
+	}
+	user.PasswordHash = hash
+	if err := s.repo.Update(user); err != nil {
+		return fmt.Errorf("修改密码失败: %v", err)
+	}
+	logger.Info("修改管理后台账号密码", "id", user.ID, "username", user.Username)
+
+	if _, err := revokeUserSessions(s.sessionRepo, user.ID, currentSessionID, model.SessionRevokePasswordChanged); err != nil {
+		logger.Error("注销账号的登录会话失败", "id", user.ID, "error", err)
+	}
+	return nil
+}
+
+// EnsureDefaultAdmin 账号表为空时，用配置文件中的 auth.username、auth.password 创建初始管理员
+// 之后账号和密码只在管理后台维护，配置文件中的密码不再生效
+// 无法创建时返回错误，调用方应终止启动，否则没有账号可以登录管理后台
+func (s *adminUserService) EnsureDefaultAdmin(username, password string) error {
+	count, err := s.repo.Count()
+	if err != nil {
+		return fmt.Errorf("查询管理后台账号失败: %v", err)
+	}
+	if count > 0 {
+		return nil
+	}
+	if username == "" || password == "" {
+		return fmt.Errorf("管理后台账号为空，且未配置 auth.username、auth.password，无法创建初始管理员")
+	}
+	// 旧版本不限制密码长度，升级时配置的密码可能不满足要求
+	if len(password) < minPasswordLength {
+		return fmt.Errorf("配置文件中的 auth.password 少于 %d 位，无法创建初始管理员，请修改为至少 %d 位的密码后重新启动（创建后可在管理后台修改密码）", minPasswordLength, minPasswordLength)
+	}
+
+	user := &model.AdminUser{
+		Username: username,
+		Role:     model.RoleAdmin,
+		Enabled:  true,
+		Memo:     "由配置文件创建的初始管理员",
+	}
+	if err := s.Create(user, password); err != nil {
+		return fmt.Errorf("创建初始管理员失败: %v", err)
+	}
+	logger.Warn("已根据配置文件创建初始管理员，请登录后修改密码，之后配置文件中的 auth.password 不再生效", "username", username)
+	return nil
+}
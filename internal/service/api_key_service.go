@@ -0,0 +1,267 @@
+package service
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"rt-manage/internal/model"
+	"rt-manage/internal/repository"
+	"rt-manage/pkg/logger"
+)
+
+// API 密钥错误
+var (
+	ErrAPIKeyInvalid  = errors.New("API密钥无效")
+	ErrAPIKeyDisabled = errors.New("API密钥已禁用")
+	ErrAPIKeyExpired  = errors.New("API密钥已过期")
+)
+
+// APIKeyEndpoints 可授权给 API 密钥的对外接口（/health 不需要授权）
+var APIKeyEndpoints = []string{"refresh", "get-at", "codex-auth", "lease", "release", "report"}
+
+// apiKeyPrefix 明文密钥的前缀，便于识别和扫描泄露
+const apiKeyPrefix = "rtk_"
+
+// signingSecretPrefix 签名密钥的前缀
+const signingSecretPrefix = "rtsig_"
+
+// lastUsedInterval 最后使用时间的最小更新间隔，避免每次请求都写库
+const lastUsedInterval = time.Minute
+
+// APIKeyService API 密钥服务接口
+type APIKeyService interface {
+	Create(key *model.APIKey) (string, string, error)
+	ResetSigningSecret(id int64) (*model.APIKey, string, error)
+	Update(id int64, updates map[string]interface{}) (*model.APIKey, error)
+	Delete(id int64) error
+	GetByID(id int64) (*model.APIKey, error)
+	List(page, pageSize int, name string, enabled *bool) ([]*model.APIKey, int64, error)
+	Authenticate(plaintext string, ip string) (*model.APIKey, error)
+	AuthenticateSigned(req *SignedRequest, ip string) (*model.APIKey, error)
+}
+
+type apiKeyService struct {
+	repo repository.APIKeyRepository
+}
+
+// NewAPIKeyService 创建 API 密钥服务实例
+func NewAPIKeyService(repo repository.APIKeyRepository) APIKeyService {
+	return &apiKeyService{repo: repo}
+}
+
+// hashAPIKey 计算密钥的 SHA-256（密钥本身是高熵随机串，不需要加盐）
+func hashAPIKey(plaintext string) string {
+	sum := sha256.Sum256([]byte(plaintext))
+	return hex.EncodeToString(sum[:])
+}
+
+// newSigningSecret 生成签名请求使用的 HMAC 密钥
+// 与 API 密钥相互独立，数据库中的 key_hash 泄露时无法据此伪造签名
+func newSigningSecret() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("生成签名密钥失败: %v", err)
+	}
+	return signingSecretPrefix + hex.EncodeToString(buf), nil
+}
+
+// Create 生成新密钥并保存哈希，返回明文密钥和签名密钥（只在此时可见）
+func (s *apiKeyService) Create(key *model.APIKey) (string, string, error) {
+	key.Name = strings.TrimSpace(key.Name)
+	if key.Name == "" {
+		return "", "", fmt.Errorf("名称不能为空")
+	}
+	if err := normalizeAPIKeyScopes(key); err != nil {
+		return "", "", err
+	}
+
+	// 前缀在签名请求中作为密钥ID，须唯一
+	var plaintext string
+	for i := 0; ; i++ {
+		buf := make([]byte, 32)
+		if _, err := rand.Read(buf); err != nil {
+			return "", "", fmt.Errorf("生成密钥失败: %v", err)
+		}
+		plaintext = apiKeyPrefix + hex.EncodeToString(buf)
+		existing, err := s.repo.GetByPrefix(plaintext[:len(apiKeyPrefix)+8])
+		if err != nil {
+			return "", "", err
+		}
+		if existing == nil {
+			break
+		}
+		if i >= 3 {
+			return "", "", fmt.Errorf("生成密钥失败: 密钥前缀重复")
+		}
+	}
+	signingSecret, err := newSigningSecret()
+	if err != nil {
+		return "", "", err
+	}
+	key.KeyHash = hashAPIKey(plaintext)
+	key.KeyPrefix = plaintext[:len(apiKeyPrefix)+8]
+	key.SigningSecret = signingSecret
+
+	if err := s.repo.Create(key); err != nil {
+		return "", "", fmt.Errorf("创建API密钥失败: %v", err)
+	}
+	logger.Info("创建API密钥", "id", key.ID, "name", key.Name, "key_prefix", key.KeyPrefix, "allowed_endpoints", key.AllowedEndpoints, "allowed_tags", key.AllowedTags, "allowed_types", key.AllowedTypes)
+	return plaintext, signingSecret, nil
+}
+
+// ResetSigningSecret 重新生成签名密钥，返回新的签名密钥（只在此时可见），旧签名密钥立即失效
+// 升级前创建的密钥没有签名密钥，需要先重新生成才能发送签名请求
+func (s *apiKeyService) ResetSigningSecret(id int64) (*model.APIKey, string, error) {
+	key, err := s.repo.GetByID(id)
+	if err != nil {
+		return nil, "", err
+	}
+	if key == nil {
+		return nil, "", fmt.Errorf("API密钥不存在")
+	}
+
+	signingSecret, err := newSigningSecret()
+	if err != nil {
+		return nil, "", err
+	}
+	key.SigningSecret = signingSecret
+	if err := s.repo.Update(key); err != nil {
+		return nil, "", fmt.Errorf("重新生成签名密钥失败: %v", err)
+	}
+	logger.Info("重新生成API密钥的签名密钥", "id", key.ID, "name", key.Name, "key_prefix", key.KeyPrefix)
+	return key, signingSecret, nil
+}
+
+// Update 更新密钥的名称、授权范围、启用状态、过期时间和备注（密钥本身不可修改）
+func (s *apiKeyService) Update(id int64, updates map[string]interface{}) (*model.APIKey, error) {
+	key, err := s.repo.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if key == nil {
+		return nil, fmt.Errorf("API密钥不存在")
+	}
+
+	if name, ok := updates["name"].(string); ok {
+		if name = strings.TrimSpace(name); name == "" {
+			return nil, fmt.Errorf("名称不能为空")
+		}
+		key.Name = name
+	}
+	if endpoints, ok := updates["allowed_endpoints"].(string); ok {
+		key.AllowedEndpoints = endpoints
+	}
+	if tags, ok := updates["allowed_tags"].(string); ok {
+		key.AllowedTags = tags
+	}
+	if types, ok := updates["allowed_types"].(string); ok {
+		key.AllowedTypes = types
+	}
+	if enabled, ok := updates["enabled"].(bool); ok {
+		key.Enabled = enabled
+	}
+	if requireSignature, ok := updates["require_signature"].(bool); ok {
+		key.RequireSignature = requireSignature
+	}
+	if memo, ok := updates["memo"].(string); ok {
+		key.Memo = memo
+	}
+	// 过期时间为 RFC3339 格式，传空字符串表示永不过期
+	if expireTime, ok := updates["expire_time"].(string); ok {
+		if expireTime == "" {
+			key.ExpireTime = nil
+		} else {
+			t, err := time.Parse(time.RFC3339, expireTime)
+			if err != nil {
+				return nil, fmt.Errorf("过期时间格式错误: %v", err)
+			}
+			key.ExpireTime = &t
+		}
+	}
+	if err := normalizeAPIKeyScopes(key); err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.Update(key); err != nil {
+		return nil, fmt.Errorf("更新API密钥失败: %v", err)
+	}
+	logger.Info("更新API密钥", "id", key.ID, "name", key.Name, "enabled", key.Enabled, "require_signature", key.RequireSignature, "allowed_endpoints", key.AllowedEndpoints, "allowed_tags", key.AllowedTags, "allowed_types", key.AllowedTypes, "expire_time", key.ExpireTime)
+	return key, nil
+}
+
+// normalizeAPIKeyScopes 规范化逗号分隔的授权范围，并校验接口名
+func normalizeAPIKeyScopes(key *model.APIKey) error {
+	endpoints := model.SplitList(key.AllowedEndpoints)
+	for _, endpoint := range endpoints {
+		if !containsString(APIKeyEndpoints, endpoint) {
+			return fmt.Errorf("不支持的接口: %s，可选值: %s", endpoint, strings.Join(APIKeyEndpoints, ","))
+		}
+	}
+	key.AllowedEndpoints = strings.Join(endpoints, ",")
+	key.AllowedTags = strings.Join(model.SplitList(key.AllowedTags), ",")
+	key.AllowedTypes = strings.Join(model.SplitList(key.AllowedTypes), ",")
+	return nil
+}
+
+// Delete 删除密钥
+func (s *apiKeyService) Delete(id int64) error {
+	if err := s.repo.Delete(id); err != nil {
+		return fmt.Errorf("删除API密钥失败: %v", err)
+	}
+	logger.Info("删除API密钥", "id", id)
+	return nil
+}
+
+// GetByID 根据 ID 获取密钥
+func (s *apiKeyService) GetByID(id int64) (*model.APIKey, error) {
+	return s.repo.GetByID(id)
+}
+
+// List 获取密钥列表
+func (s *apiKeyService) List(page, pageSize int, name string, enabled *bool) ([]*model.APIKey, int64, error) {
+	return s.repo.List(page, pageSize, name, enabled)
+}
+
+// Authenticate 校验明文密钥，通过后记录最后使用时间和IP
+func (s *apiKeyService) Authenticate(plaintext string, ip string) (*model.APIKey, error) {
+	if !strings.HasPrefix(plaintext, apiKeyPrefix) {
+		return nil, ErrAPIKeyInvalid
+	}
+	key, err := s.repo.GetByHash(hashAPIKey(plaintext))
+	if err != nil {
+		return nil, err
+	}
+	if key == nil {
+		return nil, ErrAPIKeyInvalid
+	}
+	if key.RequireSignature {
+		return key, ErrSignatureRequired
+	}
+	return s.checkUsable(key, ip)
+}
+
+// checkUsable 检查密钥的启用状态和过期时间，可用时记录最后使用时间和IP
+func (s *apiKeyService) checkUsable(key *model.APIKey, ip string) (*model.APIKey, error) {
+	now := time.Now()
+	if !key.Enabled {
+		return key, ErrAPIKeyDisabled
+	}
+	if key.Expired(now) {
+		return key, ErrAPIKeyExpired
+	}
+
+	if key.LastUsedTime == nil || now.Sub(*key.LastUsedTime) >= lastUsedInterval || key.LastUsedIP != ip {
+		if err := s.repo.UpdateLastUsed(key.ID, now, ip); err != nil {
+			logger.Warn("更新API密钥最后使用时间失败", "id", key.ID, "error", err)
+		} else {
+			key.LastUsedTime = &now
+			key.LastUsedIP = ip
+		}
+	}
+	return key, nil
+}
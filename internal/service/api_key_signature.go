@@ -0,0 +1,129 @@
+package service
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"rt-manage/internal/config"
+	"rt-manage/internal/model"
+)
+
+// 签名请求错误
+var (
+	ErrSignatureRequired = errors.New("该API密钥只接受签名请求")
+	ErrSignatureInvalid  = errors.New("请求签名无效")
+	ErrSignatureExpired  = errors.New("请求时间戳超出允许的时钟偏差")
+	ErrNonceReused       = errors.New("nonce 已被使用")
+)
+
+// SignedRequest 签名请求的各项内容
+// 签名 = hex(HMAC-SHA256(签名密钥, METHOD\nPATH\nTIMESTAMP\nNONCE\nhex(SHA-256(BODY))))
+// 签名密钥在创建 API 密钥时单独生成，与 API 密钥无关
+// PATH 含查询字符串，TIMESTAMP 为 Unix 秒
+type SignedRequest struct {
+	KeyID     string // 密钥前缀（key_prefix）
+	Method    string
+	Path      string
+	Timestamp string
+	Nonce     string
+	Body      []byte
+	Signature string
+}
+
+// nonceCache 最近使用过的 nonce（包级共享）：密钥ID|nonce -> 过期时间
+var (
+	nonceMu          sync.Mutex
+	nonceCache       = make(map[string]time.Time)
+	nonceLastCleanup time.Time
+)
+
+// StringToSign 待签名的字符串
+func (req *SignedRequest) StringToSign() string {
+	bodyHash := sha256.Sum256(req.Body)
+	return strings.Join([]string{
+		strings.ToUpper(req.Method),
+		req.Path,
+		req.Timestamp,
+		req.Nonce,
+		hex.EncodeToString(bodyHash[:]),
+	}, "\n")
+}
+
+// sign 以签名密钥（字符串本身）作为 HMAC 密钥计算签名
+func sign(signingSecret string, stringToSign string) string {
+	mac := hmac.New(sha256.New, []byte(signingSecret))
+	mac.Write([]byte(stringToSign))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// AuthenticateSigned 校验签名请求：时间戳在允许的偏差内、签名正确、nonce 在窗口内未被使用过
+// 签名正确后才记录 nonce，避免伪造请求占用合法客户端的 nonce
+func (s *apiKeyService) AuthenticateSigned(req *SignedRequest, ip string) (*model.APIKey, error) {
+	if req.KeyID == "" || req.Timestamp == "" || req.Signature == "" {
+		return nil, fmt.Errorf("%w: 缺少 X-Key-Id、X-Timestamp 或 X-Signature", ErrSignatureInvalid)
+	}
+	if len(req.Nonce) < 8 || len(req.Nonce) > 64 {
+		return nil, fmt.Errorf("%w: nonce 长度须为 8-64", ErrSignatureInvalid)
+	}
+
+	timestamp, err := strconv.ParseInt(req.Timestamp, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("%w: 时间戳格式错误", ErrSignatureInvalid)
+	}
+	maxSkew := time.Duration(config.Get().Auth.SignatureMaxSkew) * time.Second
+	now := time.Now()
+	if skew := now.Sub(time.Unix(timestamp, 0)); skew > maxSkew || skew < -maxSkew {
+		return nil, ErrSignatureExpired
+	}
+
+	key, err := s.repo.GetByPrefix(req.KeyID)
+	if err != nil {
+		return nil, err
+	}
+	if key == nil {
+		return nil, ErrAPIKeyInvalid
+	}
+
+	if key.SigningSecret == "" {
+		return nil, fmt.Errorf("%w: 该密钥没有签名密钥，请在管理后台重新生成", ErrSignatureInvalid)
+	}
+
+	expected := sign(key.SigningSecret, req.StringToSign())
+	if !hmac.Equal([]byte(expected), []byte(strings.ToLower(req.Signature))) {
+		return nil, ErrSignatureInvalid
+	}
+
+	// 时间戳最多偏差 maxSkew，nonce 保留 2 倍偏差即可覆盖所有可能被接受的重放
+	if !rememberNonce(key.KeyPrefix+"|"+req.Nonce, now, 2*maxSkew) {
+		return nil, ErrNonceReused
+	}
+	return s.checkUsable(key, ip)
+}
+
+// rememberNonce 记录 nonce，窗口内已存在时返回 false
+func rememberNonce(key string, now time.Time, window time.Duration) bool {
+	nonceMu.Lock()
+	defer nonceMu.Unlock()
+
+	if now.Sub(nonceLastCleanup) >= time.Minute {
+		nonceLastCleanup = now
+		for k, expireTime := range nonceCache {
+			if !expireTime.After(now) {
+				delete(nonceCache, k)
+			}
+		}
+	}
+
+	if expireTime, ok := nonceCache[key]; ok && expireTime.After(now) {
+		return false
+	}
+	nonceCache[key] = now.Add(window)
+	return true
+}
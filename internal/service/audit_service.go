@@ -0,0 +1,206 @@
+package service
+
+import (
+	"encoding/json"
+	"reflect"
+	"sync"
+	"time"
+
+	"rt-manage/internal/config"
+	"rt-manage/internal/model"
+	"rt-manage/internal/repository"
+	"rt-manage/pkg/logger"
+	"rt-manage/pkg/secret"
+)
+
+// auditPurgeInterval 过期审计事件的清理间隔
+const auditPurgeInterval = time.Hour
+
+// auditIgnoredFields 不记录到修改内容中的字段
+var auditIgnoredFields = map[string]bool{
+	"create_time": true,
+	"update_time": true,
+}
+
+// 上次清理过期审计事件的时间（包级共享）
+var (
+	auditPurgeMu   sync.Mutex
+	auditLastPurge time.Time
+)
+
+// AuditChange 单个字段的修改前后内容
+type AuditChange struct {
+	Before interface{} `json:"before"`
+	After  interface{} `json:"after"`
+}
+
+// AuditService 审计事件服务接口
+type AuditService interface {
+	Record(event *model.AuditEvent)
+	List(page, pageSize int, actor, actorType, action, targetType, targetID, clientIP string, success *bool, startDate, endDate string) ([]*model.AuditEvent, int64, error)
+	Purge() (int64, error)
+}
+
+type auditService struct {
+	repo repository.AuditEventRepository
+}
+
+// NewAuditService 创建审计事件服务实例
+func NewAuditService(repo repository.AuditEventRepository) AuditService {
+	return &auditService{repo: repo}
+}
+
+// Record 保存审计事件，失败只记录日志，不影响请求本身
+// 同时按 audit.retention_days 定期清理过期事件
+func (s *auditService) Record(event *model.AuditEvent) {
+	cfg := config.Get().Audit
+	if !cfg.Enabled {
+		return
+	}
+
+	if err := s.repo.Create(event); err != nil {
+		logger.Error("保存审计事件失败", "action", event.Action, "actor", event.Actor, "target_ids", event.TargetIDs, "error", err)
+	}
+
+	if cfg.RetentionDays > 0 && s.purgeDue(time.Now()) {
+		go func() {
+			if _, err := s.Purge(); err != nil {
+				logger.Error("清理过期审计事件失败", "error", err)
+			}
+		}()
+	}
+}
+
+// purgeDue 距上次清理超过清理间隔时返回 true 并记录本次清理时间
+func (s *auditService) purgeDue(now time.Time) bool {
+	auditPurgeMu.Lock()
+	defer auditPurgeMu.Unlock()
+
+	if now.Sub(auditLastPurge) < auditPurgeInterval {
+		return false
+	}
+	auditLastPurge = now
+	return true
+}
+
+// Purge 删除超过保留天数的审计事件
+func (s *auditService) Purge() (int64, error) {
+	retentionDays := config.Get().Audit.RetentionDays
+	if retentionDays <= 0 {
+		return 0, nil
+	}
+
+	before := time.Now().AddDate(0, 0, -retentionDays)
+	count, err := s.repo.DeleteBefore(before)
+	if err != nil {
+		return 0, err
+	}
+	if count > 0 {
+		logger.Info("已清理过期审计事件", "count", count, "retention_days", retentionDays)
+	}
+	return count, nil
+}
+
+// List 获取审计事件列表
+func (s *auditService) List(page, pageSize int, actor, actorType, action, targetType, targetID, clientIP string, success *bool, startDate, endDate string) ([]*model.AuditEvent, int64, error) {
+	return s.repo.List(page, pageSize, actor, actorType, action, targetType, targetID, clientIP, success, startDate, endDate)
+}
+
+// AuditChanges 比较修改前后的对象（按 JSON 字段），返回有变化的字段，token 等敏感字段已脱敏
+// before 为 nil 表示新建，after 为 nil 表示删除；没有变化时返回空字符串
+func AuditChanges(before, after interface{}) string {
+	beforeRaw, beforeRedacted := auditFields(before)
+	afterRaw, afterRedacted := auditFields(after)
+
+	changes := make(map[string]AuditChange)
+	for key := range beforeRaw {
+		if _, ok := afterRaw[key]; !ok && !auditIgnoredFields[key] && !isEmptyAuditValue(beforeRaw[key]) {
+			changes[key] = AuditChange{Before: beforeRedacted[key]}
+		}
+	}
+	for key, value := range afterRaw {
+		if auditIgnoredFields[key] {
+			continue
+		}
+		old, ok := beforeRaw[key]
+		if ok && reflect.DeepEqual(old, value) {
+			continue
+		}
+		// 新建时不记录空字段
+		if !ok && isEmptyAuditValue(value) {
+			continue
+		}
+		changes[key] = AuditChange{Before: beforeRedacted[key], After: afterRedacted[key]}
+	}
+	if len(changes) == 0 {
+		return ""
+	}
+
+	data, err := json.Marshal(changes)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
+// AuditBatchItem 批量操作中的单个对象，Before 为 nil 表示新建，After 为 nil 表示删除
+type AuditBatchItem struct {
+	ID     int64
+	BizId  string
+	Before interface{}
+	After  interface{}
+}
+
+// auditBatchEntry 批量操作审计内容中的单个对象
+type auditBatchEntry struct {
+	ID      int64           `json:"id"`
+	BizId   string          `json:"biz_id,omitempty"`
+	Changes json.RawMessage `json:"changes,omitempty"`
+}
+
+// AuditBatchChanges 批量操作按对象分别记录修改前后内容（changes 格式同 AuditChanges），没有对象时返回空字符串
+// 没有变化的对象（如刷新失败）也会记录ID和业务ID
+func AuditBatchChanges(items []AuditBatchItem) string {
+	if len(items) == 0 {
+		return ""
+	}
+	entries := make([]auditBatchEntry, 0, len(items))
+	for _, item := range items {
+		entry := auditBatchEntry{ID: item.ID, BizId: item.BizId}
+		if diff := AuditChanges(item.Before, item.After); diff != "" {
+			entry.Changes = json.RawMessage(diff)
+		}
+		entries = append(entries, entry)
+	}
+
+	data, err := json.Marshal(entries)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
+// isEmptyAuditValue 判断 JSON 值是否为空（null 或空字符串）
+func isEmptyAuditValue(value interface{}) bool {
+	return value == nil || value == ""
+}
+
+// auditFields 将对象转为 JSON 字段，分别返回原值（用于比较）和脱敏后的值（用于保存）
+func auditFields(value interface{}) (map[string]interface{}, map[string]interface{}) {
+	if value == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, nil
+	}
+
+	var raw, redacted map[string]interface{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, nil
+	}
+	if err := json.Unmarshal([]byte(secret.RedactJSON(string(data))), &redacted); err != nil {
+		return nil, nil
+	}
+	return raw, redacted
+}
@@ -0,0 +1,61 @@
+package service
+
+import (
+	"fmt"
+	"time"
+
+	"rt-manage/internal/model"
+)
+
+// CodexAuth Codex CLI 的 auth.json（~/.codex/auth.json）
+type CodexAuth struct {
+	OpenAIAPIKey *string     `json:"OPENAI_API_KEY"`
+	Tokens       CodexTokens `json:"tokens"`
+	LastRefresh  *time.Time  `json:"last_refresh"`
+}
+
+// CodexTokens auth.json 中的 token
+type CodexTokens struct {
+	IDToken      string `json:"id_token"`
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	AccountID    string `json:"account_id"`
+}
+
+// applyIDToken 保存刷新返回的 id_token，并从中读取账号ID（未返回 id_token 时从 AT 中读取）
+func applyIDToken(rt *model.RT, idToken string) {
+	if idToken != "" {
+		rt.IdToken = idToken
+	}
+	accountID := jwtAccountID(idToken)
+	if accountID == "" {
+		accountID = jwtAccountID(rt.At)
+	}
+	if accountID != "" {
+		rt.AccountID = accountID
+	}
+}
+
+// BuildCodexAuth 根据RT生成 Codex 可直接使用的 auth.json
+func BuildCodexAuth(rt *model.RT) (*CodexAuth, error) {
+	if rt.At == "" {
+		return nil, fmt.Errorf("RT尚未获取AT，请先刷新")
+	}
+	if rt.IdToken == "" {
+		return nil, fmt.Errorf("RT尚未获取id_token，请先刷新")
+	}
+
+	accountID := rt.AccountID
+	if accountID == "" {
+		accountID = jwtAccountID(rt.IdToken)
+	}
+	return &CodexAuth{
+		Tokens: CodexTokens{
+			IDToken:      rt.IdToken,
+			AccessToken:  rt.At,
+			RefreshToken: rt.Rt,
+			AccountID:    accountID,
+		},
+		LastRefresh: rt.LastRefreshTime,
+	}, nil
+}
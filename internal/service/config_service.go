@@ -2,10 +2,12 @@ package service
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"math/rand"
 	"os"
 
+	"rt-manage/internal/model"
 	"rt-manage/internal/repository"
 	"rt-manage/internal/scheduler"
 	"rt-manage/pkg/logger"
@@ -249,7 +251,7 @@ func (s *configService) updateAllRTConfigs(proxyListStr, clientIdListStr string)
 	}
 
 	// 获取所有RT（不限制启用状态）
-	rts, total, err := s.rtRepo.List(1, 100000, "", "", "", "", nil, "")
+	rts, total, err := s.rtRepo.List(1, 100000, "", "", "", "", nil, "", "")
 	if err != nil {
 		return fmt.Errorf("获取RT列表失败: %v", err)
 	}
@@ -301,7 +303,18 @@ func (s *configService) updateAllRTConfigs(proxyListStr, clientIdListStr string)
 
 		// 如果需要更新，保存到数据库
 		if needUpdate {
-			if err := s.rtRepo.Update(rt); err != nil {
+			newProxy, newClientId := rt.Proxy, rt.ClientID
+			err := s.rtRepo.Update(rt)
+			if errors.Is(err, repository.ErrVersionConflict) {
+				// 记录已被并发修改（如刷新写入新token），基于最新记录重新分配一次
+				var latest *model.RT
+				if latest, err = s.rtRepo.GetByID(rt.ID); err == nil && latest != nil {
+					latest.Proxy, latest.ClientID = newProxy, newClientId
+					err = s.rtRepo.Update(latest)
+					rt = latest
+				}
+			}
+			if err != nil {
 				logger.Error("更新RT配置失败", "rt_id", rt.ID, "name", rt.BizId, "error", err)
 			} else {
 				updatedCount++
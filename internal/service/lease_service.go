@@ -0,0 +1,307 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"rt-manage/internal/config"
+	"rt-manage/internal/model"
+	"rt-manage/internal/repository"
+	"rt-manage/pkg/logger"
+)
+
+// 租约错误
+var (
+	ErrInvalidLeaseRequest = errors.New("租用参数无效")
+	ErrNoLeasableAccount   = errors.New("没有符合条件的可用账号")
+	ErrLeaseNotFound       = errors.New("租约不存在")
+	ErrLeaseExpired        = errors.New("租约已过期，账号已被回收")
+	ErrLeaseForbidden      = errors.New("租约不属于当前API密钥")
+)
+
+// LeaseRequest 租用条件，为空的条件不限制
+type LeaseRequest struct {
+	Tag            string   // 标签（精确匹配）
+	Type           string   // 账号类型（精确匹配，如 plus、team）
+	CredentialType string   // 凭证类型（rt、at）
+	BizIds         []string // 只从这些业务ID中选择
+	ExcludeBizIds  []string // 排除的业务ID
+	Strategy       string   // round_robin（默认）、lru
+	Exclusive      bool     // 独占：租约期间账号不会再租给其他调用方
+	TTL            int      // 租约时长（秒），0 使用 lease.default_ttl
+	Consumer       string   // 调用方标识
+	APIKeyID       *int64   // 租用时使用的API密钥，为空表示旧版密钥
+	AllowedTags    []string // API密钥允许的标签，为空不限制
+	AllowedTypes   []string // API密钥允许的账号类型，为空不限制
+}
+
+// LeaseService AT 租约服务接口
+type LeaseService interface {
+	Lease(req LeaseRequest) (*model.Lease, *model.RT, error)
+	Release(leaseID string, apiKeyID *int64) (*model.Lease, error)
+	GetByLeaseID(leaseID string) (*model.Lease, error)
+	List(page, pageSize int, rtId int64, bizId string, consumer string, status string) ([]*model.Lease, int64, error)
+	ReclaimExpired() (int64, error)
+}
+
+type leaseService struct {
+	repo   repository.LeaseRepository
+	rtRepo repository.RTRepository
+}
+
+// NewLeaseService 创建租约服务实例
+func NewLeaseService(repo repository.LeaseRepository, rtRepo repository.RTRepository) LeaseService {
+	return &leaseService{repo: repo, rtRepo: rtRepo}
+}
+
+// 选择账号和创建租约须串行执行，避免同一账号被同时独占
+var leaseMu sync.Mutex
+
+// leaseCursors 轮询游标：筛选条件 -> 上一次租出的RT ID
+var leaseCursors = make(map[string]int64)
+
+// normalize 校验租用条件并填充默认值
+func (req *LeaseRequest) normalize() error {
+	cfg := config.Get().Lease
+	if req.Strategy == "" {
+		req.Strategy = model.LeaseStrategyRoundRobin
+	}
+	if req.Strategy != model.LeaseStrategyRoundRobin && req.Strategy != model.LeaseStrategyLRU {
+		return fmt.Errorf("%w: 不支持的选择策略 %s", ErrInvalidLeaseRequest, req.Strategy)
+	}
+	if req.CredentialType != "" && req.CredentialType != model.CredentialTypeRT && req.CredentialType != model.CredentialTypeAT {
+		return fmt.Errorf("%w: 不支持的凭证类型 %s", ErrInvalidLeaseRequest, req.CredentialType)
+	}
+	if req.TTL < 0 {
+		return fmt.Errorf("%w: ttl 不能为负数", ErrInvalidLeaseRequest)
+	}
+	if req.TTL == 0 {
+		req.TTL = cfg.DefaultTTL
+	}
+	if cfg.MaxTTL > 0 && req.TTL > cfg.MaxTTL {
+		return fmt.Errorf("%w: ttl 不能超过 %d 秒", ErrInvalidLeaseRequest, cfg.MaxTTL)
+	}
+	return nil
+}
+
+// poolKey 轮询游标的 key，相同筛选条件共用一个游标
+func (req *LeaseRequest) poolKey() string {
+	return strings.Join([]string{
+		req.Tag, req.Type, req.CredentialType,
+		strings.Join(req.BizIds, ","), strings.Join(req.ExcludeBizIds, ","),
+		strings.Join(req.AllowedTags, ","), strings.Join(req.AllowedTypes, ","),
+	}, "|")
+}
+
+// Lease 按条件从账号池中选择一个可用账号并创建租约
+// 已被独占的账号不会被选中；独占租约只会选择当前没有任何租约的账号
+func (s *leaseService) Lease(req LeaseRequest) (*model.Lease, *model.RT, error) {
+	if err := req.normalize(); err != nil {
+		return nil, nil, err
+	}
+
+	leaseMu.Lock()
+	defer leaseMu.Unlock()
+
+	now := time.Now()
+	if _, err := s.reclaimExpired(now); err != nil {
+		return nil, nil, err
+	}
+
+	// AT 须在租约结束前一直有效
+	ttl := time.Duration(req.TTL) * time.Second
+	candidates, err := s.rtRepo.ListLeasable(req.Tag, req.Type, req.CredentialType, req.BizIds, req.ExcludeBizIds, req.AllowedTags, req.AllowedTypes, now, now.Add(ttl))
+	if err != nil {
+		return nil, nil, err
+	}
+	available, err := s.filterAvailable(candidates, req.Exclusive)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for len(available) > 0 {
+		chosen, err := s.choose(available, &req)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		rt, err := s.rtRepo.GetByID(chosen.ID)
+		if err != nil {
+			return nil, nil, err
+		}
+		// 选择后到读取前账号可能已被删除、禁用或被反馈进入冷却，换一个
+		if rt == nil || !rt.Enabled || rt.At == "" || rt.InCooldown(now) {
+			available = removeRT(available, chosen.ID)
+			continue
+		}
+
+		lease := &model.Lease{
+			LeaseID:    "ls_" + generateRandomID(),
+			RtID:       rt.ID,
+			BizId:      rt.BizId,
+			Consumer:   req.Consumer,
+			APIKeyID:   req.APIKeyID,
+			Exclusive:  req.Exclusive,
+			Strategy:   req.Strategy,
+			Status:     model.LeaseStatusActive,
+			ExpireTime: now.Add(ttl),
+		}
+		if err := s.repo.Create(lease); err != nil {
+			return nil, nil, fmt.Errorf("创建租约失败: %v", err)
+		}
+		logger.Info("租出账号", "lease_id", lease.LeaseID, "rt_id", rt.ID, "biz_id", rt.BizId, "consumer", req.Consumer, "exclusive", req.Exclusive, "strategy", req.Strategy, "ttl", req.TTL)
+		return lease, rt, nil
+	}
+	return nil, nil, ErrNoLeasableAccount
+}
+
+// filterAvailable 根据使用中的租约过滤候选账号
+func (s *leaseService) filterAvailable(candidates []*model.RT, exclusive bool) ([]*model.RT, error) {
+	if len(candidates) == 0 {
+		return nil, nil
+	}
+	active, err := s.repo.ListActive()
+	if err != nil {
+		return nil, err
+	}
+
+	exclusiveHeld := make(map[int64]bool)
+	sharedCount := make(map[int64]int)
+	for _, lease := range active {
+		if lease.Exclusive {
+			exclusiveHeld[lease.RtID] = true
+		} else {
+			sharedCount[lease.RtID]++
+		}
+	}
+
+	maxShared := config.Get().Lease.MaxShared
+	available := make([]*model.RT, 0, len(candidates))
+	for _, rt := range candidates {
+		switch {
+		case exclusiveHeld[rt.ID]:
+		case exclusive && sharedCount[rt.ID] > 0:
+		case !exclusive && maxShared > 0 && sharedCount[rt.ID] >= maxShared:
+		default:
+			available = append(available, rt)
+		}
+	}
+	return available, nil
+}
+
+// choose 按策略选择账号（available 按 id 升序）
+func (s *leaseService) choose(available []*model.RT, req *LeaseRequest) (*model.RT, error) {
+	if req.Strategy == model.LeaseStrategyLRU {
+		ids := make([]int64, len(available))
+		for i, rt := range available {
+			ids[i] = rt.ID
+		}
+		lastIds, err := s.repo.LastLeaseIDs(ids)
+		if err != nil {
+			return nil, err
+		}
+		// 从未租出的优先（租约ID为0），其次最久未租出的
+		sorted := append([]*model.RT(nil), available...)
+		sort.SliceStable(sorted, func(i, j int) bool {
+			return lastIds[sorted[i].ID] < lastIds[sorted[j].ID]
+		})
+		return sorted[0], nil
+	}
+
+	key := req.poolKey()
+	chosen := available[0]
+	for _, rt := range available {
+		if rt.ID > leaseCursors[key] {
+			chosen = rt
+			break
+		}
+	}
+	leaseCursors[key] = chosen.ID
+	return chosen, nil
+}
+
+func removeRT(rts []*model.RT, id int64) []*model.RT {
+	result := make([]*model.RT, 0, len(rts))
+	for _, rt := range rts {
+		if rt.ID != id {
+			result = append(result, rt)
+		}
+	}
+	return result
+}
+
+// Release 释放租约，重复释放直接返回
+// apiKeyID 不为空时只能释放该密钥租用的租约；为空（管理后台、旧版密钥）时不限制
+// 旧版密钥和升级前创建的租约 api_key_id 为空，不属于任何新版密钥，只能由旧版密钥或管理后台释放
+func (s *leaseService) Release(leaseID string, apiKeyID *int64) (*model.Lease, error) {
+	leaseMu.Lock()
+	defer leaseMu.Unlock()
+
+	lease, err := s.repo.GetByLeaseID(leaseID)
+	if err != nil {
+		return nil, err
+	}
+	if lease == nil {
+		return nil, ErrLeaseNotFound
+	}
+	if apiKeyID != nil && (lease.APIKeyID == nil || *lease.APIKeyID != *apiKeyID) {
+		return nil, ErrLeaseForbidden
+	}
+
+	now := time.Now()
+	switch {
+	case lease.Status == model.LeaseStatusReleased:
+		return lease, nil
+	case lease.Status == model.LeaseStatusExpired:
+		return lease, ErrLeaseExpired
+	case !lease.ExpireTime.After(now):
+		lease.Status = model.LeaseStatusExpired
+		if err := s.repo.Update(lease); err != nil {
+			return nil, err
+		}
+		return lease, ErrLeaseExpired
+	}
+
+	lease.Status = model.LeaseStatusReleased
+	lease.ReleaseTime = &now
+	if err := s.repo.Update(lease); err != nil {
+		return nil, fmt.Errorf("释放租约失败: %v", err)
+	}
+	logger.Info("释放租约", "lease_id", lease.LeaseID, "rt_id", lease.RtID, "biz_id", lease.BizId, "consumer", lease.Consumer)
+	return lease, nil
+}
+
+// GetByLeaseID 根据租约ID获取租约
+func (s *leaseService) GetByLeaseID(leaseID string) (*model.Lease, error) {
+	return s.repo.GetByLeaseID(leaseID)
+}
+
+// List 获取租约列表（先回收已过期的租约）
+func (s *leaseService) List(page, pageSize int, rtId int64, bizId string, consumer string, status string) ([]*model.Lease, int64, error) {
+	if _, err := s.ReclaimExpired(); err != nil {
+		return nil, 0, err
+	}
+	return s.repo.List(page, pageSize, rtId, bizId, consumer, status)
+}
+
+// ReclaimExpired 回收已到期仍未释放的租约
+func (s *leaseService) ReclaimExpired() (int64, error) {
+	leaseMu.Lock()
+	defer leaseMu.Unlock()
+	return s.reclaimExpired(time.Now())
+}
+
+func (s *leaseService) reclaimExpired(now time.Time) (int64, error) {
+	count, err := s.repo.ExpireOverdue(now)
+	if err != nil {
+		return 0, fmt.Errorf("回收过期租约失败: %v", err)
+	}
+	if count > 0 {
+		logger.Info("已回收过期租约", "count", count)
+	}
+	return count, nil
+}
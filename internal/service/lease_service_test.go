@@ -0,0 +1,86 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"rt-manage/internal/model"
+	"rt-manage/internal/repository"
+)
+
+// memoryLeaseRepo 按 lease_id 保存租约，只实现 Release 用到的方法
+type memoryLeaseRepo struct {
+	repository.LeaseRepository
+	leases map[string]*model.Lease
+}
+
+func (r *memoryLeaseRepo) GetByLeaseID(leaseID string) (*model.Lease, error) {
+	lease, ok := r.leases[leaseID]
+	if !ok {
+		return nil, nil
+	}
+	copied := *lease
+	return &copied, nil
+}
+
+func (r *memoryLeaseRepo) Update(lease *model.Lease) error {
+	copied := *lease
+	r.leases[lease.LeaseID] = &copied
+	return nil
+}
+
+func TestReleaseLeaseOwnership(t *testing.T) {
+	ownerID := int64(5)
+	legacyKey := &model.APIKey{Name: "legacy", Enabled: true}
+	ownerKey := &model.APIKey{ID: ownerID, Name: "owner", Enabled: true}
+	otherKey := &model.APIKey{ID: 6, Name: "other", Enabled: true}
+
+	tests := []struct {
+		name    string
+		owner   *int64 // 租约的 api_key_id，nil 表示旧版密钥或升级前创建的租约
+		key     *model.APIKey
+		wantErr error
+	}{
+		{name: "legacy key releases ownerless lease", owner: nil, key: legacyKey},
+		{name: "legacy key releases owned lease", owner: &ownerID, key: legacyKey},
+		{name: "api key cannot release ownerless lease", owner: nil, key: ownerKey, wantErr: ErrLeaseForbidden},
+		{name: "api key releases its own lease", owner: &ownerID, key: ownerKey},
+		{name: "api key cannot release another key's lease", owner: &ownerID, key: otherKey, wantErr: ErrLeaseForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &memoryLeaseRepo{leases: map[string]*model.Lease{
+				"lease-1": {
+					LeaseID:    "lease-1",
+					RtID:       1,
+					Status:     model.LeaseStatusActive,
+					ExpireTime: time.Now().Add(time.Hour),
+					APIKeyID:   tt.owner,
+				},
+			}}
+			svc := NewLeaseService(repo, nil)
+
+			lease, err := svc.Release("lease-1", tt.key.LeaseOwnerID())
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Release() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr == nil && lease.Status != model.LeaseStatusReleased {
+				t.Fatalf("lease status = %q, want %q", lease.Status, model.LeaseStatusReleased)
+			}
+			if tt.wantErr != nil && repo.leases["lease-1"].Status != model.LeaseStatusActive {
+				t.Fatalf("rejected release changed lease status to %q", repo.leases["lease-1"].Status)
+			}
+		})
+	}
+}
+
+func TestLegacyKeyLeaseOwnerIsNil(t *testing.T) {
+	if id := (&model.APIKey{Name: "legacy"}).LeaseOwnerID(); id != nil {
+		t.Fatalf("legacy key LeaseOwnerID() = %d, want nil", *id)
+	}
+	if id := (&model.APIKey{ID: 5}).LeaseOwnerID(); id == nil || *id != 5 {
+		t.Fatalf("LeaseOwnerID() = %v, want 5", id)
+	}
+}
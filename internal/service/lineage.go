@@ -0,0 +1,122 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"rt-manage/internal/config"
+	"rt-manage/internal/model"
+	"rt-manage/pkg/logger"
+)
+
+// ErrRotationInProgress RT 有未完成的轮换（上游请求进行中或新RT尚未写入RT表）
+var ErrRotationInProgress = errors.New("该RT有未完成的轮换，请稍后再试")
+
+// appendLineage 追加一条 token 谱系记录，parent 为追加前RT的最新谱系记录；写入失败不影响主流程
+func (s *rtService) appendLineage(rt *model.RT, source string, refreshLogID int64, promotedFrom int64, operator string) {
+	lineage := &model.TokenLineage{
+		RtID:         rt.ID,
+		Rt:           rt.Rt,
+		At:           rt.At,
+		AtExpireTime: rt.AtExpireTime,
+		Source:       source,
+		RefreshLogID: refreshLogID,
+		PromotedFrom: promotedFrom,
+		Operator:     operator,
+	}
+
+	parent, err := s.lineageRepo.GetLatestByRtID(rt.ID)
+	if err != nil {
+		logger.Error("查询token谱系失败", "rt_id", rt.ID, "error", err)
+	} else if parent != nil {
+		lineage.ParentID = parent.ID
+	}
+
+	if err := s.lineageRepo.Create(lineage); err != nil {
+		logger.Error("写入token谱系失败", "rt_id", rt.ID, "source", source, "error", err)
+	}
+}
+
+// ListLineage 获取RT的 token 谱系（按时间倒序）
+func (s *rtService) ListLineage(rtID int64) ([]*model.TokenLineage, error) {
+	return s.lineageRepo.ListByRtID(rtID)
+}
+
+// PromoteLineage 将谱系中较早的 token 恢复为RT的当前 token（最近一次轮换得到的 token 有问题时使用）
+// 当前RT保存到 LastRT，并追加一条 promote 谱系记录；恢复后不自动刷新，刷新状态重置为未验证
+// 有未完成的轮换时返回 ErrRotationInProgress，与刷新等写入并发时返回 ErrVersionConflict
+func (s *rtService) PromoteLineage(rtID int64, lineageID int64, operator string) (*model.RT, error) {
+	rt, err := s.repo.GetByID(rtID)
+	if err != nil {
+		return nil, err
+	}
+	if rt == nil {
+		return nil, fmt.Errorf("RT不存在")
+	}
+
+	lineage, err := s.lineageRepo.GetByID(lineageID)
+	if err != nil {
+		return nil, err
+	}
+	if lineage == nil || lineage.RtID != rtID {
+		return nil, fmt.Errorf("谱系记录不存在")
+	}
+	if lineage.Rt == "" {
+		return nil, fmt.Errorf("该谱系记录没有RT，无法恢复")
+	}
+	if lineage.Rt == rt.Rt && lineage.At == rt.At {
+		return nil, fmt.Errorf("该token已是当前token")
+	}
+
+	// 轮换进行中或新RT尚未写入RT表时恢复，会覆盖上游刚签发的新RT
+	for _, status := range []string{model.RotationStatusPending, model.RotationStatusReceived} {
+		rotation, err := s.rotationRepo.GetLatestByRtID(rtID, status)
+		if err != nil {
+			return nil, err
+		}
+		if rotation != nil {
+			return nil, fmt.Errorf("%w（%s）", ErrRotationInProgress, status)
+		}
+	}
+
+	if lineage.Rt != rt.Rt {
+		rt.LastRT = rt.Rt
+		rt.Rt = lineage.Rt
+	}
+	rt.At = lineage.At
+	rt.AtExpireTime = lineage.AtExpireTime
+	// 恢复的RT可能已被上游使用过，自动刷新会触发 refresh_token_reused 并导致整组token被撤销
+	// 因此不立即调度刷新，需要验证时由调用方显式刷新
+	rt.RefreshStatus = ""
+	rt.RefreshResult = ""
+	rt.NextRefreshTime = promotedRefreshTime(rt.AtExpireTime, s.rtMaxAge())
+
+	if err := s.repo.Update(rt); err != nil {
+		return nil, err
+	}
+	s.appendLineage(rt, model.LineageSourcePromote, 0, lineage.ID, operator)
+
+	logger.Warn("已将历史token恢复为当前token",
+		"rt_id", rt.ID,
+		"biz_id", rt.BizId,
+		"lineage_id", lineage.ID,
+		"rt", tokenPreview(rt.Rt),
+		"operator", operator,
+	)
+	return rt, nil
+}
+
+// promotedRefreshTime 恢复历史token后的下次刷新时间：恢复的AT过期前按正常余量刷新，
+// AT没有过期时间或已过期时按RT最长使用时间推迟，避免调度器立即刷新
+func promotedRefreshTime(atExpireTime *time.Time, maxAge time.Duration) *time.Time {
+	now := time.Now()
+	next := now.Add(maxAge)
+	if atExpireTime != nil {
+		margin := time.Duration(config.Get().OpenAI.RefreshMarginMinutes) * time.Minute
+		if byExpire := atExpireTime.Add(-margin); byExpire.After(now) && byExpire.Before(next) {
+			next = byExpire
+		}
+	}
+	return &next
+}
@@ -0,0 +1,290 @@
+package service
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"rt-manage/internal/config"
+	"rt-manage/internal/model"
+	"rt-manage/pkg/logger"
+)
+
+// onboardingTTL 授权会话有效期，超时后需要重新发起
+const onboardingTTL = 15 * time.Minute
+
+// OnboardingOptions 通过授权码登录新增RT时的参数
+type OnboardingOptions struct {
+	BizId    string
+	ClientID string
+	Proxy    string
+	Tag      string
+	Memo     string
+	Enabled  bool
+}
+
+// OnboardingStart 发起授权返回的信息，用户在浏览器中打开 AuthURL 完成登录
+type OnboardingStart struct {
+	State       string    `json:"state"`
+	AuthURL     string    `json:"auth_url"`
+	ClientID    string    `json:"client_id"`
+	RedirectURI string    `json:"redirect_uri"`
+	ExpiresAt   time.Time `json:"expires_at"`
+}
+
+// onboardingSession 进行中的授权会话（PKCE verifier 只保存在服务端）
+type onboardingSession struct {
+	options      OnboardingOptions
+	redirectURI  string
+	codeVerifier string
+	expiresAt    time.Time
+}
+
+// onboardingSessions 进行中的授权会话（包级共享，对所有 rtService 实例生效；重启后失效）
+var onboardingSessions = struct {
+	sync.Mutex
+	m map[string]*onboardingSession
+}{m: make(map[string]*onboardingSession)}
+
+// StartOnboarding 生成 PKCE verifier、challenge 和授权地址
+// client_id 须为配置文件或系统配置中的 Client ID，未指定时使用配置文件中的默认值；未指定代理时从代理列表中随机选择
+func (s *rtService) StartOnboarding(opts OnboardingOptions, proxyList []string, clientIdList []string) (*OnboardingStart, error) {
+	cfg := config.Get()
+	if opts.ClientID == "" {
+		opts.ClientID = cfg.OpenAI.ClientID
+	}
+	if opts.ClientID != cfg.OpenAI.ClientID && !containsString(clientIdList, opts.ClientID) {
+		return nil, fmt.Errorf("Client ID '%s' 未在配置中", opts.ClientID)
+	}
+	opts.Proxy = pickFromList(opts.Proxy, proxyList)
+	if opts.BizId != "" {
+		existing, err := s.repo.GetByBizId(opts.BizId)
+		if err != nil {
+			return nil, err
+		}
+		if existing != nil {
+			return nil, fmt.Errorf("RT名称 '%s' 已存在", opts.BizId)
+		}
+	}
+
+	state, err := randomURLString(24)
+	if err != nil {
+		return nil, err
+	}
+	verifier, err := randomURLString(48)
+	if err != nil {
+		return nil, err
+	}
+	challenge := sha256.Sum256([]byte(verifier))
+
+	query := url.Values{}
+	query.Set("client_id", opts.ClientID)
+	query.Set("redirect_uri", cfg.OpenAI.RedirectURI)
+	query.Set("response_type", "code")
+	query.Set("scope", cfg.OpenAI.Scope)
+	query.Set("state", state)
+	query.Set("code_challenge", base64.RawURLEncoding.EncodeToString(challenge[:]))
+	query.Set("code_challenge_method", "S256")
+	query.Set("prompt", "login")
+
+	session := &onboardingSession{
+		options:      opts,
+		redirectURI:  cfg.OpenAI.RedirectURI,
+		codeVerifier: verifier,
+		expiresAt:    time.Now().Add(onboardingTTL),
+	}
+
+	onboardingSessions.Lock()
+	now := time.Now()
+	for key, item := range onboardingSessions.m {
+		if now.After(item.expiresAt) {
+			delete(onboardingSessions.m, key)
+		}
+	}
+	onboardingSessions.m[state] = session
+	onboardingSessions.Unlock()
+
+	return &OnboardingStart{
+		State:       state,
+		AuthURL:     authURL("/authorize") + "?" + query.Encode(),
+		ClientID:    opts.ClientID,
+		RedirectURI: session.redirectURI,
+		ExpiresAt:   session.expiresAt,
+	}, nil
+}
+
+// CompleteOnboarding 用回调中的授权码换取 token，并自动创建RT（同时获取用户信息和账号信息）
+// callback 可以是授权码本身，也可以是粘贴的完整回调地址；state 为空时取回调地址中的 state
+func (s *rtService) CompleteOnboarding(state string, callback string) (*model.RT, error) {
+	code, callbackState, err := parseAuthorizationCallback(callback)
+	if err != nil {
+		return nil, err
+	}
+	if state == "" {
+		state = callbackState
+	}
+	if callbackState != "" && callbackState != state {
+		return nil, fmt.Errorf("回调地址中的 state 与授权会话不一致")
+	}
+
+	// 授权码只能使用一次，取出后即删除会话
+	onboardingSessions.Lock()
+	session := onboardingSessions.m[state]
+	delete(onboardingSessions.m, state)
+	onboardingSessions.Unlock()
+	if session == nil || time.Now().After(session.expiresAt) {
+		return nil, fmt.Errorf("授权会话不存在或已过期，请重新发起")
+	}
+	opts := session.options
+
+	tokenResp, err := s.exchangeAuthorizationCode(code, session)
+	if err != nil {
+		return nil, err
+	}
+	if tokenResp.RefreshToken == "" {
+		return nil, fmt.Errorf("上游未返回 refresh_token，请确认 scope 包含 offline_access")
+	}
+
+	now := time.Now()
+	rt := &model.RT{
+		BizId:           opts.BizId,
+		Rt:              tokenResp.RefreshToken,
+		At:              tokenResp.AccessToken,
+		Proxy:           opts.Proxy,
+		ClientID:        opts.ClientID,
+		Tag:             opts.Tag,
+		Memo:            opts.Memo,
+		Enabled:         opts.Enabled,
+		LastRefreshTime: &now,
+		RefreshStatus:   model.RefreshStatusOK,
+	}
+	applyIDToken(rt, tokenResp.IDToken)
+	s.scheduleNext(rt, tokenResp.ExpiresIn)
+
+	if err := s.fetchUserInfo(rt); err != nil {
+		logger.Warn("授权登录 - 获取用户信息失败", "error", err)
+	}
+	if err := s.fetchAccountInfo(rt); err != nil {
+		logger.Warn("授权登录 - 获取账号信息失败", "error", err)
+	}
+
+	// 发起授权后业务ID被占用时改用随机ID，避免新签发的RT丢失
+	if rt.BizId != "" {
+		if existing, _ := s.repo.GetByBizId(rt.BizId); existing != nil {
+			logger.Warn("授权登录 - 业务ID已被占用，改用随机ID", "biz_id", rt.BizId)
+			rt.BizId = ""
+		}
+	}
+	if err := s.Create(rt); err != nil {
+		logger.Error("授权登录 - 创建RT失败", "email", rt.Email, "rt", tokenPreview(rt.Rt), "error", err)
+		return nil, fmt.Errorf("创建RT失败: %v", err)
+	}
+
+	logger.Info("授权登录新增RT成功", "id", rt.ID, "biz_id", rt.BizId, "email", rt.Email, "type", rt.Type, "client_id", rt.ClientID)
+	return rt, nil
+}
+
+// exchangeAuthorizationCode 在 token 接口用授权码和 PKCE verifier 换取 token
+func (s *rtService) exchangeAuthorizationCode(code string, session *onboardingSession) (*OpenAITokenResponse, error) {
+	jsonData, err := json.Marshal(map[string]string{
+		"client_id":     session.options.ClientID,
+		"grant_type":    "authorization_code",
+		"code":          code,
+		"code_verifier": session.codeVerifier,
+		"redirect_uri":  session.redirectURI,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("构造请求体失败: %v", err)
+	}
+
+	client, err := createHTTPClient(session.options.Proxy, 30*time.Second)
+	if err != nil {
+		logger.Warn("创建HTTP客户端失败", "proxy", session.options.Proxy, "error", err)
+		client = &http.Client{Timeout: 30 * time.Second}
+	}
+
+	req, err := http.NewRequest("POST", authURL("/oauth/token"), bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("创建请求失败: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("请求失败: %v", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("读取响应失败: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		refreshErr := classifyRefreshResponse(resp.StatusCode, resp.Header, body)
+		logger.Error("授权码兑换失败", "status", resp.StatusCode, "error_code", refreshErr.Code, "body", redactResponseBody(body))
+		return nil, fmt.Errorf("授权码兑换失败: %s", refreshErr.Message)
+	}
+
+	var tokenResp OpenAITokenResponse
+	if err := json.Unmarshal(body, &tokenResp); err != nil {
+		return nil, fmt.Errorf("解析响应失败: %v", err)
+	}
+	return &tokenResp, nil
+}
+
+// parseAuthorizationCallback 从授权码或回调地址中解析 code 和 state
+func parseAuthorizationCallback(callback string) (string, string, error) {
+	callback = strings.TrimSpace(callback)
+	if callback == "" {
+		return "", "", fmt.Errorf("缺少授权码或回调地址")
+	}
+	if !strings.Contains(callback, "code=") && !strings.Contains(callback, "error=") {
+		return callback, "", nil
+	}
+
+	// 回调地址可能为自定义 scheme（如 com.openai.chat://...），只取 ? 或 # 之后的参数
+	rawQuery := callback
+	if i := strings.IndexAny(callback, "?#"); i >= 0 {
+		rawQuery = callback[i+1:]
+	}
+	rawQuery = strings.ReplaceAll(rawQuery, "#", "&")
+	query, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return "", "", fmt.Errorf("回调地址格式错误: %v", err)
+	}
+	if errCode := query.Get("error"); errCode != "" {
+		return "", "", fmt.Errorf("授权失败: %s %s", errCode, query.Get("error_description"))
+	}
+	code := query.Get("code")
+	if code == "" {
+		return "", "", fmt.Errorf("回调地址中缺少 code")
+	}
+	return code, query.Get("state"), nil
+}
+
+func containsString(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
+
+// randomURLString 生成 URL 安全的随机字符串
+func randomURLString(n int) (string, error) {
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("生成随机数失败: %v", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
@@ -0,0 +1,122 @@
+package service
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"rt-manage/internal/model"
+	"rt-manage/pkg/secret"
+)
+
+// RefreshError 刷新失败错误，携带失败分类
+type RefreshError struct {
+	Status     string        // 刷新状态，见 model.RefreshStatus*
+	HTTPStatus int           // 上游 HTTP 状态码，请求未完成时为 0
+	Code       string        // 上游错误码
+	Message    string        // 错误信息
+	RetryAfter time.Duration // 上游返回的 Retry-After
+}
+
+// Error 实现 error 接口
+func (e *RefreshError) Error() string {
+	return "刷新失败: " + e.Message
+}
+
+// Permanent 是否为不可恢复的失败（RT 已失效）
+func (e *RefreshError) Permanent() bool {
+	switch e.Status {
+	case model.RefreshStatusInvalidGrant, model.RefreshStatusRefreshTokenReused, model.RefreshStatusAccountDeactivated:
+		return true
+	}
+	return false
+}
+
+// OAuth 标准错误响应结构（error 为字符串）
+type oauthErrorResponse struct {
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+// newNetworkError 请求未完成（网络、代理、超时）
+func newNetworkError(err error) *RefreshError {
+	return &RefreshError{
+		Status:  model.RefreshStatusNetworkError,
+		Message: fmt.Sprintf("请求失败: %v", err),
+	}
+}
+
+// requestNotSent 请求错误是否确定发生在请求发出之前（建立连接、代理握手、TLS 握手失败）
+// 此时上游不可能已轮换RT；读写超时、连接中断等错误无法确定请求是否已送达，返回 false
+func requestNotSent(err error) bool {
+	var opErr *net.OpError
+	if errors.As(err, &opErr) {
+		switch opErr.Op {
+		case "dial", "proxyconnect", "connect", "socks connect":
+			return true
+		}
+	}
+	var dnsErr *net.DNSError
+	var headerErr tls.RecordHeaderError
+	var verifyErr *tls.CertificateVerificationError
+	var authorityErr x509.UnknownAuthorityError
+	var hostnameErr x509.HostnameError
+	var invalidErr x509.CertificateInvalidError
+	return errors.As(err, &dnsErr) || errors.As(err, &headerErr) || errors.As(err, &verifyErr) ||
+		errors.As(err, &authorityErr) || errors.As(err, &hostnameErr) || errors.As(err, &invalidErr)
+}
+
+// classifyRefreshResponse 根据上游响应对刷新失败分类
+func classifyRefreshResponse(statusCode int, header http.Header, body []byte) *RefreshError {
+	refreshErr := &RefreshError{HTTPStatus: statusCode}
+
+	// 解析错误码，兼容 {"error":{"code":...}} 和 {"error":"...","error_description":...} 两种格式
+	var errorResp OpenAIErrorResponse
+	var oauthResp oauthErrorResponse
+	if err := json.Unmarshal(body, &errorResp); err == nil && (errorResp.Error.Code != "" || errorResp.Error.Message != "") {
+		refreshErr.Code = errorResp.Error.Code
+		refreshErr.Message = fmt.Sprintf("%s: %s", errorResp.Error.Code, errorResp.Error.Message)
+	} else if err := json.Unmarshal(body, &oauthResp); err == nil && oauthResp.Error != "" {
+		refreshErr.Code = oauthResp.Error
+		refreshErr.Message = fmt.Sprintf("%s: %s", oauthResp.Error, oauthResp.ErrorDescription)
+	} else {
+		refreshErr.Message = fmt.Sprintf("HTTP %d: %s", statusCode, redactResponseBody(body))
+	}
+
+	if seconds, err := strconv.Atoi(strings.TrimSpace(header.Get("Retry-After"))); err == nil && seconds > 0 {
+		refreshErr.RetryAfter = time.Duration(seconds) * time.Second
+	}
+
+	code := strings.ToLower(refreshErr.Code)
+	switch {
+	case code == "refresh_token_reused":
+		refreshErr.Status = model.RefreshStatusRefreshTokenReused
+	case code == "account_deactivated" || strings.Contains(strings.ToLower(refreshErr.Message), "deactivated"):
+		refreshErr.Status = model.RefreshStatusAccountDeactivated
+	case code == "invalid_grant" || code == "refresh_token_expired" || code == "refresh_token_invalidated" || code == "invalid_refresh_token":
+		refreshErr.Status = model.RefreshStatusInvalidGrant
+	case statusCode == http.StatusTooManyRequests || code == "rate_limit_exceeded":
+		refreshErr.Status = model.RefreshStatusRateLimited
+	case statusCode >= 500:
+		refreshErr.Status = model.RefreshStatusUpstreamError
+	default:
+		refreshErr.Status = model.RefreshStatusUnknownError
+	}
+
+	return refreshErr
+}
+
+// redactResponseBody 脱敏上游响应（隐藏 token），非 JSON 响应截断保存
+func redactResponseBody(body []byte) string {
+	if !json.Valid(body) && len(body) > 4096 {
+		return secret.MaskJWTs(string(body[:4096])) + "..."
+	}
+	return secret.RedactJSON(string(body))
+}
@@ -0,0 +1,31 @@
+package service
+
+import (
+	"rt-manage/internal/model"
+	"rt-manage/internal/repository"
+)
+
+// RefreshLogService 刷新记录服务接口
+type RefreshLogService interface {
+	GetByID(id int64) (*model.RefreshLog, error)
+	List(page, pageSize int, rtId int64, bizId string, trigger string, refreshStatus string, startDate string, endDate string) ([]*model.RefreshLog, int64, error)
+}
+
+type refreshLogService struct {
+	repo repository.RefreshLogRepository
+}
+
+// NewRefreshLogService 创建刷新记录服务实例
+func NewRefreshLogService(repo repository.RefreshLogRepository) RefreshLogService {
+	return &refreshLogService{repo: repo}
+}
+
+// GetByID 获取刷新记录详情
+func (s *refreshLogService) GetByID(id int64) (*model.RefreshLog, error) {
+	return s.repo.GetByID(id)
+}
+
+// List 获取刷新记录列表
+func (s *refreshLogService) List(page, pageSize int, rtId int64, bizId string, trigger string, refreshStatus string, startDate string, endDate string) ([]*model.RefreshLog, int64, error) {
+	return s.repo.List(page, pageSize, rtId, bizId, trigger, refreshStatus, startDate, endDate)
+}
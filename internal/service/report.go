@@ -0,0 +1,182 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"rt-manage/internal/config"
+	"rt-manage/internal/model"
+	"rt-manage/pkg/logger"
+)
+
+// ErrInvalidReport 反馈参数无效
+var ErrInvalidReport = errors.New("反馈参数无效")
+
+// 反馈处理结果
+const (
+	ReportActionIgnored       = "ignored"        // 反馈的AT已不是当前AT，无需处理
+	ReportActionCooldown      = "cooldown"       // 已进入冷却
+	ReportActionRefreshed     = "refreshed"      // 已刷新出新的AT，冷却解除
+	ReportActionRefreshFailed = "refresh_failed" // 刷新失败，保持冷却
+	ReportActionValid         = "valid"          // 仅AT账号校验通过，冷却解除
+	ReportActionStale         = "stale"          // 仅AT账号校验确认AT已失效，需重新导入会话
+	ReportActionProbeFailed   = "probe_failed"   // 仅AT账号校验请求失败，保持冷却
+)
+
+// ReportRequest 调用方对AT的反馈
+type ReportRequest struct {
+	Status      int    // 调用方请求下游时观察到的 HTTP 状态码：401、403、429
+	RetryAfter  int    // 429 响应的 Retry-After（秒），0 使用 report.rate_limit_cooldown
+	AccessToken string // 调用方使用的AT，401/403 必填；与当前AT不一致时说明AT已更新，反馈直接忽略
+	Consumer    string // 调用方标识
+}
+
+// ReportResult 反馈处理结果
+type ReportResult struct {
+	Action string
+	RT     *model.RT
+}
+
+// Report 处理调用方反馈：429 按 Retry-After 冷却；401/403 先冷却，再刷新RT（仅AT账号则校验AT），成功后解除冷却
+// 冷却期间账号不参与 /lease 选择
+func (s *rtService) Report(id int64, req ReportRequest) (*ReportResult, error) {
+	if req.Status != http.StatusUnauthorized && req.Status != http.StatusForbidden && req.Status != http.StatusTooManyRequests {
+		return nil, fmt.Errorf("%w: 不支持的状态码 %d，仅支持 401、403、429", ErrInvalidReport, req.Status)
+	}
+	if req.RetryAfter < 0 {
+		return nil, fmt.Errorf("%w: retry_after 不能为负数", ErrInvalidReport)
+	}
+	// 401/403 会触发刷新，必须提供AT，否则重复反馈可以让RT被无限次轮换
+	if req.Status != http.StatusTooManyRequests && req.AccessToken == "" {
+		return nil, fmt.Errorf("%w: 401、403 反馈必须提供 access_token", ErrInvalidReport)
+	}
+
+	rt, err := s.repo.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if rt == nil {
+		return nil, fmt.Errorf("RT不存在")
+	}
+
+	logger.Info("收到调用方反馈", "id", rt.ID, "biz_id", rt.BizId, "status", req.Status, "retry_after", req.RetryAfter, "consumer", req.Consumer)
+
+	if req.AccessToken != "" && req.AccessToken != rt.At {
+		logger.Info("反馈的AT已不是当前AT，忽略", "id", rt.ID, "biz_id", rt.BizId, "consumer", req.Consumer)
+		return &ReportResult{Action: ReportActionIgnored, RT: rt}, nil
+	}
+
+	cfg := config.Get().Report
+	now := time.Now()
+
+	if req.Status == http.StatusTooManyRequests {
+		seconds := req.RetryAfter
+		if seconds == 0 {
+			seconds = cfg.RateLimitCooldown
+		}
+		if err := s.setCooldown(rt, now, seconds, model.CooldownReasonRateLimited); err != nil {
+			return nil, err
+		}
+		return &ReportResult{Action: ReportActionCooldown, RT: rt}, nil
+	}
+
+	if err := s.setCooldown(rt, now, cfg.UnauthorizedCooldown, model.CooldownReasonUnauthorized); err != nil {
+		return nil, err
+	}
+
+	if rt.IsATOnly() {
+		return s.probeReportedAT(rt)
+	}
+
+	refreshed, err := s.Refresh(rt.ID, false, false, model.RefreshTriggerReport)
+	if err != nil {
+		logger.Warn("反馈后刷新失败，保持冷却", "id", rt.ID, "biz_id", rt.BizId, "error", err)
+		if refreshed != nil {
+			rt = refreshed
+		}
+		return &ReportResult{Action: ReportActionRefreshFailed, RT: rt}, nil
+	}
+	if err := s.clearCooldown(refreshed); err != nil {
+		return nil, err
+	}
+	return &ReportResult{Action: ReportActionRefreshed, RT: refreshed}, nil
+}
+
+// probeReportedAT 仅AT账号无法刷新，请求 /backend-api/me 校验AT：有效则解除冷却，401/403 则标记为 stale
+func (s *rtService) probeReportedAT(rt *model.RT) (*ReportResult, error) {
+	status, body, err := requestMe(rt)
+	if err != nil {
+		logger.Warn("仅AT账号校验请求失败，保持冷却", "id", rt.ID, "biz_id", rt.BizId, "error", err)
+		return &ReportResult{Action: ReportActionProbeFailed, RT: rt}, nil
+	}
+
+	switch status {
+	case http.StatusOK:
+		if err := s.clearCooldown(rt); err != nil {
+			return nil, err
+		}
+		logger.Info("仅AT账号校验通过，解除冷却", "id", rt.ID, "biz_id", rt.BizId)
+		return &ReportResult{Action: ReportActionValid, RT: rt}, nil
+	case http.StatusUnauthorized, http.StatusForbidden:
+		logger.Warn("仅AT账号校验确认AT已失效，标记为stale", "id", rt.ID, "biz_id", rt.BizId, "status", status)
+		result := fmt.Sprintf("调用方反馈AT失效，校验返回 HTTP %d", status)
+		apply := func(latest *model.RT) error {
+			latest.RefreshStatus = model.RefreshStatusStale
+			latest.RefreshResult = result
+			latest.NextRefreshTime = nil
+			return nil
+		}
+		apply(rt)
+		if err := s.saveWithRetry(rt, apply); err != nil {
+			return nil, fmt.Errorf("更新RT失败: %v", err)
+		}
+		return &ReportResult{Action: ReportActionStale, RT: rt}, nil
+	default:
+		logger.Warn("仅AT账号校验返回异常状态，保持冷却", "id", rt.ID, "biz_id", rt.BizId, "status", status, "body", redactResponseBody(body))
+		return &ReportResult{Action: ReportActionProbeFailed, RT: rt}, nil
+	}
+}
+
+// setCooldown 设置冷却（不超过 report.max_cooldown），已有更晚的冷却截止时间时保留较晚的
+func (s *rtService) setCooldown(rt *model.RT, now time.Time, seconds int, reason string) error {
+	if maxCooldown := config.Get().Report.MaxCooldown; maxCooldown > 0 && seconds > maxCooldown {
+		seconds = maxCooldown
+	}
+	until := now.Add(time.Duration(seconds) * time.Second)
+
+	apply := func(latest *model.RT) error {
+		if latest.InCooldown(now) && latest.CooldownUntil.After(until) {
+			return nil
+		}
+		latest.CooldownUntil = &until
+		latest.CooldownReason = reason
+		return nil
+	}
+	apply(rt)
+	if err := s.saveWithRetry(rt, apply); err != nil {
+		return fmt.Errorf("设置冷却失败: %v", err)
+	}
+	logger.Info("账号进入冷却", "id", rt.ID, "biz_id", rt.BizId, "reason", rt.CooldownReason, "cooldown_until", rt.CooldownUntil)
+	return nil
+}
+
+// clearCooldown 解除 401 触发的冷却（429 冷却须等到 Retry-After 结束）
+func (s *rtService) clearCooldown(rt *model.RT) error {
+	apply := func(latest *model.RT) error {
+		if latest.CooldownReason == model.CooldownReasonUnauthorized {
+			latest.CooldownUntil = nil
+			latest.CooldownReason = ""
+		}
+		return nil
+	}
+	if rt.CooldownReason != model.CooldownReasonUnauthorized {
+		return nil
+	}
+	apply(rt)
+	if err := s.saveWithRetry(rt, apply); err != nil {
+		return fmt.Errorf("解除冷却失败: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,157 @@
+package service
+
+import (
+	"fmt"
+	"time"
+
+	"rt-manage/internal/model"
+	"rt-manage/pkg/logger"
+)
+
+// beginRotation 发起上游刷新前写入轮换日志，写入失败时不允许刷新
+func (s *rtService) beginRotation(rt *model.RT) (*model.TokenRotation, error) {
+	rotation := &model.TokenRotation{
+		RtID:   rt.ID,
+		OldRt:  rt.Rt,
+		Status: model.RotationStatusPending,
+	}
+	if err := s.rotationRepo.Create(rotation); err != nil {
+		return nil, err
+	}
+	return rotation, nil
+}
+
+// rotationWriteRetries、rotationWriteRetryDelay 写入新RT到轮换日志的重试次数和间隔（按次数递增）
+const (
+	rotationWriteRetries    = 3
+	rotationWriteRetryDelay = 500 * time.Millisecond
+)
+
+// markRotationReceived 收到新RT后先写入轮换日志，再更新RT表
+// 旧RT此时已在上游作废，写入失败时重试，仍失败则返回错误
+func (s *rtService) markRotationReceived(rotation *model.TokenRotation, tokenResp *OpenAITokenResponse) error {
+	rotation.NewRt = tokenResp.RefreshToken
+	rotation.NewAt = tokenResp.AccessToken
+	rotation.ExpiresIn = tokenResp.ExpiresIn
+	rotation.Status = model.RotationStatusReceived
+	return s.writeRotation(rotation)
+}
+
+// writeRotation 保存轮换日志，失败时按递增间隔重试
+func (s *rtService) writeRotation(rotation *model.TokenRotation) error {
+	var err error
+	for i := 0; i < rotationWriteRetries; i++ {
+		if i > 0 {
+			time.Sleep(time.Duration(i) * rotationWriteRetryDelay)
+		}
+		if err = s.rotationRepo.Update(rotation); err == nil {
+			return nil
+		}
+		logger.Error("写入RT轮换日志失败", "rt_id", rotation.RtID, "rotation_id", rotation.ID, "retry", i, "error", err)
+	}
+	return err
+}
+
+// markRotationUnknown 上游结果无法确认（超时、响应无法解析等），保留日志便于排查
+func (s *rtService) markRotationUnknown(rotation *model.TokenRotation) {
+	rotation.Status = model.RotationStatusUnknown
+	if err := s.rotationRepo.Update(rotation); err != nil {
+		logger.Error("更新RT轮换日志失败", "rt_id", rotation.RtID, "rotation_id", rotation.ID, "error", err)
+	}
+}
+
+// finishRotation 轮换结果已写入RT表（或上游明确拒绝），删除轮换日志
+func (s *rtService) finishRotation(rotation *model.TokenRotation) {
+	if err := s.rotationRepo.Delete(rotation.ID); err != nil {
+		logger.Error("删除RT轮换日志失败", "rt_id", rotation.RtID, "rotation_id", rotation.ID, "error", err)
+	}
+}
+
+// applyRotation 将上游返回的新RT/AT写入RT
+func (s *rtService) applyRotation(rt *model.RT, newRt, newAt string, expiresIn int, refreshTime time.Time) {
+	// 上游未返回新RT时（RT未轮换）保留原RT
+	if newRt != "" && newRt != rt.Rt {
+		// 保存旧的RT Token到LastRT
+		rt.LastRT = rt.Rt
+		// 更新为新的RT Token
+		rt.Rt = newRt
+	}
+	// 保存Access Token
+	rt.At = newAt
+	// 更新刷新时间
+	rt.LastRefreshTime = &refreshTime
+	rt.RefreshStatus = model.RefreshStatusOK
+	// 根据AT过期时间和RT使用时间计算下次刷新时间
+	s.scheduleNext(rt, expiresIn)
+}
+
+// recoverPendingRotation 将已收到但未写入RT表的新RT恢复到RT上
+func (s *rtService) recoverPendingRotation(rt *model.RT) (bool, error) {
+	rotation, err := s.rotationRepo.GetLatestByRtID(rt.ID, model.RotationStatusReceived)
+	if err != nil {
+		return false, err
+	}
+	if rotation == nil {
+		return false, nil
+	}
+
+	// RT已是新值（写库成功但删除日志失败），或已被人工更换，无需恢复
+	if rt.Rt != rotation.OldRt {
+		logger.Info("RT轮换已完成或RT已变更，清理轮换日志", "rt_id", rt.ID, "rotation_id", rotation.ID)
+		s.finishRotation(rotation)
+		return false, nil
+	}
+
+	s.applyRotation(rt, rotation.NewRt, rotation.NewAt, rotation.ExpiresIn, rotation.UpdateTime)
+	if err := s.saveWithRetry(rt, func(latest *model.RT) error {
+		if latest.Rt != rotation.OldRt {
+			return fmt.Errorf("RT已被人工更换，放弃恢复")
+		}
+		s.applyRotation(latest, rotation.NewRt, rotation.NewAt, rotation.ExpiresIn, rotation.UpdateTime)
+		return nil
+	}); err != nil {
+		return false, fmt.Errorf("写入恢复的RT失败: %v", err)
+	}
+	s.finishRotation(rotation)
+	s.appendLineage(rt, model.LineageSourceRecover, 0, 0, "")
+
+	logger.Warn("已恢复未写入的RT轮换结果", "rt_id", rt.ID, "biz_id", rt.BizId, "rotation_id", rotation.ID, "new_rt", tokenPreview(rt.Rt))
+	return true, nil
+}
+
+// RecoverRotations 启动时处理未完成的RT轮换
+// received：恢复新RT到RT表；pending：进程在上游请求期间退出，结果未知，标记为 unknown
+func (s *rtService) RecoverRotations() error {
+	received, err := s.rotationRepo.ListByStatus(model.RotationStatusReceived)
+	if err != nil {
+		return err
+	}
+	for _, rotation := range received {
+		rt, err := s.repo.GetByID(rotation.RtID)
+		if err != nil {
+			return err
+		}
+		if rt == nil {
+			logger.Warn("RT已删除，丢弃轮换日志", "rt_id", rotation.RtID, "rotation_id", rotation.ID)
+			s.finishRotation(rotation)
+			continue
+		}
+		if _, err := s.recoverPendingRotation(rt); err != nil {
+			logger.Error("恢复RT轮换失败", "rt_id", rt.ID, "rotation_id", rotation.ID, "error", err)
+		}
+	}
+
+	pending, err := s.rotationRepo.ListByStatus(model.RotationStatusPending)
+	if err != nil {
+		return err
+	}
+	for _, rotation := range pending {
+		logger.Warn("发现中断的RT刷新，上游结果未知", "rt_id", rotation.RtID, "rotation_id", rotation.ID, "create_time", rotation.CreateTime)
+		s.markRotationUnknown(rotation)
+	}
+
+	if len(received) > 0 || len(pending) > 0 {
+		logger.Info("RT轮换日志处理完成", "received", len(received), "pending", len(pending))
+	}
+	return nil
+}
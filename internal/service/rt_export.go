@@ -0,0 +1,330 @@
+package service
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"rt-manage/internal/model"
+	"rt-manage/pkg/secret"
+)
+
+// 导出格式
+const (
+	ExportFormatCSV   = "csv"
+	ExportFormatJSON  = "json"
+	ExportFormatJSONL = "jsonl"
+)
+
+// 导出时 token 字段的处理方式
+const (
+	ExportTokensInclude = "include" // 导出明文
+	ExportTokensMask    = "mask"    // 导出脱敏值
+	ExportTokensExclude = "exclude" // 不导出 token 列
+)
+
+// exportBatchSize 导出时每批读取的记录数
+const exportBatchSize = 500
+
+// exportColumn 可导出的列
+type exportColumn struct {
+	name   string
+	secret bool // 是否为 token 等敏感字段
+	value  func(rt *model.RT) interface{}
+}
+
+// exportColumns 全部可导出的列（按导出顺序）
+var exportColumns = []exportColumn{
+	{name: "id", value: func(rt *model.RT) interface{} { return rt.ID }},
+	{name: "biz_id", value: func(rt *model.RT) interface{} { return rt.BizId }},
+	{name: "credential_type", value: func(rt *model.RT) interface{} { return rt.CredentialType }},
+	{name: "rt", secret: true, value: func(rt *model.RT) interface{} { return rt.Rt }},
+	{name: "at", secret: true, value: func(rt *model.RT) interface{} { return rt.At }},
+	{name: "id_token", secret: true, value: func(rt *model.RT) interface{} { return rt.IdToken }},
+	{name: "account_id", value: func(rt *model.RT) interface{} { return rt.AccountID }},
+	{name: "last_rt", secret: true, value: func(rt *model.RT) interface{} { return rt.LastRT }},
+	{name: "proxy", value: func(rt *model.RT) interface{} { return rt.Proxy }},
+	{name: "client_id", value: func(rt *model.RT) interface{} { return rt.ClientID }},
+	{name: "tag", value: func(rt *model.RT) interface{} { return rt.Tag }},
+	{name: "memo", value: func(rt *model.RT) interface{} { return rt.Memo }},
+	{name: "enabled", value: func(rt *model.RT) interface{} { return rt.Enabled }},
+	{name: "email", value: func(rt *model.RT) interface{} { return rt.Email }},
+	{name: "user_name", value: func(rt *model.RT) interface{} { return rt.UserName }},
+	{name: "type", value: func(rt *model.RT) interface{} { return rt.Type }},
+	{name: "refresh_status", value: func(rt *model.RT) interface{} { return rt.RefreshStatus }},
+	{name: "refresh_result", secret: true, value: func(rt *model.RT) interface{} { return rt.RefreshResult }},
+	{name: "user_info", value: func(rt *model.RT) interface{} { return rt.UserInfo }},
+	{name: "account_info", value: func(rt *model.RT) interface{} { return rt.AccountInfo }},
+	{name: "at_expire_time", value: func(rt *model.RT) interface{} { return rt.AtExpireTime }},
+	{name: "last_refresh_time", value: func(rt *model.RT) interface{} { return rt.LastRefreshTime }},
+	{name: "next_refresh_time", value: func(rt *model.RT) interface{} { return rt.NextRefreshTime }},
+	{name: "create_time", value: func(rt *model.RT) interface{} { return rt.CreateTime }},
+	{name: "update_time", value: func(rt *model.RT) interface{} { return rt.UpdateTime }},
+}
+
+// DefaultExportColumns 未指定列时导出的列，包含导入所需的全部字段
+var DefaultExportColumns = []string{
+	"id", "biz_id", "credential_type", "rt", "at", "id_token", "account_id", "proxy", "client_id", "tag", "memo", "enabled",
+	"email", "user_name", "type", "refresh_status", "at_expire_time", "create_time",
+}
+
+// ExportOptions 导出参数，筛选条件同 List
+type ExportOptions struct {
+	Format  string   // csv、json、jsonl，默认 csv
+	Columns []string // 导出的列，为空时使用 DefaultExportColumns
+	Tokens  string   // include、mask、exclude，默认 mask
+
+	BizId         string
+	Tag           string
+	Email         string
+	Type          string
+	Enabled       *bool
+	CreateDate    string
+	RefreshStatus string
+}
+
+// Normalize 填充默认值并校验参数，须在开始写出数据前调用
+func (o *ExportOptions) Normalize() error {
+	o.Format = strings.ToLower(strings.TrimSpace(o.Format))
+	if o.Format == "" {
+		o.Format = ExportFormatCSV
+	}
+	if o.Format != ExportFormatCSV && o.Format != ExportFormatJSON && o.Format != ExportFormatJSONL {
+		return fmt.Errorf("不支持的导出格式: %s", o.Format)
+	}
+
+	o.Tokens = strings.ToLower(strings.TrimSpace(o.Tokens))
+	if o.Tokens == "" {
+		o.Tokens = ExportTokensMask
+	}
+	if o.Tokens != ExportTokensInclude && o.Tokens != ExportTokensMask && o.Tokens != ExportTokensExclude {
+		return fmt.Errorf("不支持的 token 导出方式: %s", o.Tokens)
+	}
+
+	if len(o.Columns) == 0 {
+		o.Columns = DefaultExportColumns
+	}
+	seen := make(map[string]bool, len(o.Columns))
+	columns := make([]string, 0, len(o.Columns))
+	for _, name := range o.Columns {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" || seen[name] {
+			continue
+		}
+		column := findExportColumn(name)
+		if column == nil {
+			return fmt.Errorf("不支持的导出列: %s", name)
+		}
+		seen[name] = true
+		if column.secret && o.Tokens == ExportTokensExclude {
+			continue
+		}
+		columns = append(columns, name)
+	}
+	if len(columns) == 0 {
+		return fmt.Errorf("没有可导出的列")
+	}
+	o.Columns = columns
+	return nil
+}
+
+// IncludesTokens 是否导出明文 token
+func (o *ExportOptions) IncludesTokens() bool {
+	if o.Tokens != ExportTokensInclude {
+		return false
+	}
+	for _, name := range o.Columns {
+		if column := findExportColumn(name); column != nil && column.secret {
+			return true
+		}
+	}
+	return false
+}
+
+func findExportColumn(name string) *exportColumn {
+	for i := range exportColumns {
+		if exportColumns[i].name == name {
+			return &exportColumns[i]
+		}
+	}
+	return nil
+}
+
+// ExportColumnNames 全部可导出的列名
+func ExportColumnNames() []string {
+	names := make([]string, len(exportColumns))
+	for i, column := range exportColumns {
+		names[i] = column.name
+	}
+	return names
+}
+
+// Export 按筛选条件分批读取RT并流式写出，返回导出的记录数
+// opts 须已调用 Normalize；写出过程中出错时已写出的数据不完整
+func (s *rtService) Export(w io.Writer, opts *ExportOptions) (int, error) {
+	columns := make([]*exportColumn, len(opts.Columns))
+	for i, name := range opts.Columns {
+		columns[i] = findExportColumn(name)
+	}
+
+	buffered := bufio.NewWriter(w)
+	writer := newExportWriter(buffered, opts.Format, opts.Columns)
+	if err := writer.begin(); err != nil {
+		return 0, err
+	}
+
+	count := 0
+	var lastID int64
+	for {
+		rts, err := s.repo.ListAfter(lastID, exportBatchSize, opts.BizId, opts.Tag, opts.Email, opts.Type, opts.Enabled, opts.CreateDate, opts.RefreshStatus)
+		if err != nil {
+			return count, err
+		}
+		if len(rts) == 0 {
+			break
+		}
+		lastID = rts[len(rts)-1].ID
+		markStale(rts...)
+
+		for _, rt := range rts {
+			values := make([]interface{}, len(columns))
+			for i, column := range columns {
+				values[i] = column.value(rt)
+				if column.secret && opts.Tokens == ExportTokensMask {
+					values[i] = maskExportValue(column.name, values[i].(string))
+				}
+			}
+			if err := writer.write(values); err != nil {
+				return count, err
+			}
+			count++
+		}
+		if err := buffered.Flush(); err != nil {
+			return count, err
+		}
+	}
+
+	if err := writer.end(); err != nil {
+		return count, err
+	}
+	return count, buffered.Flush()
+}
+
+// maskExportValue 脱敏导出的敏感字段
+func maskExportValue(name, value string) string {
+	if name == "refresh_result" {
+		return secret.RedactJSON(value)
+	}
+	return secret.Mask(value)
+}
+
+// exportWriter 按格式写出导出数据
+type exportWriter struct {
+	w       io.Writer
+	format  string
+	columns []string
+	csv     *csv.Writer
+	count   int
+}
+
+func newExportWriter(w io.Writer, format string, columns []string) *exportWriter {
+	writer := &exportWriter{w: w, format: format, columns: columns}
+	if format == ExportFormatCSV {
+		writer.csv = csv.NewWriter(w)
+	}
+	return writer
+}
+
+func (e *exportWriter) begin() error {
+	switch e.format {
+	case ExportFormatCSV:
+		return e.csv.Write(e.columns)
+	case ExportFormatJSON:
+		_, err := io.WriteString(e.w, "[")
+		return err
+	}
+	return nil
+}
+
+func (e *exportWriter) write(values []interface{}) error {
+	defer func() { e.count++ }()
+
+	if e.format == ExportFormatCSV {
+		record := make([]string, len(values))
+		for i, value := range values {
+			record[i] = csvValue(value)
+		}
+		if err := e.csv.Write(record); err != nil {
+			return err
+		}
+		e.csv.Flush()
+		return e.csv.Error()
+	}
+
+	// 按列顺序输出 JSON 对象
+	var b strings.Builder
+	if e.format == ExportFormatJSON && e.count > 0 {
+		b.WriteString(",")
+	}
+	if e.format == ExportFormatJSON {
+		b.WriteString("\n")
+	}
+	b.WriteString("{")
+	for i, value := range values {
+		if i > 0 {
+			b.WriteString(",")
+		}
+		key, _ := json.Marshal(e.columns[i])
+		data, err := json.Marshal(value)
+		if err != nil {
+			return err
+		}
+		b.Write(key)
+		b.WriteString(":")
+		b.Write(data)
+	}
+	b.WriteString("}")
+	if e.format == ExportFormatJSONL {
+		b.WriteString("\n")
+	}
+	_, err := io.WriteString(e.w, b.String())
+	return err
+}
+
+func (e *exportWriter) end() error {
+	switch e.format {
+	case ExportFormatCSV:
+		e.csv.Flush()
+		return e.csv.Error()
+	case ExportFormatJSON:
+		_, err := io.WriteString(e.w, "\n]\n")
+		return err
+	}
+	return nil
+}
+
+// csvValue 将字段值转换为 CSV 单元格
+func csvValue(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case bool:
+		return strconv.FormatBool(v)
+	case time.Time:
+		return v.Format(time.RFC3339)
+	case *time.Time:
+		if v == nil {
+			return ""
+		}
+		return v.Format(time.RFC3339)
+	default:
+		return fmt.Sprint(v)
+	}
+}
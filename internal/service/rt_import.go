@@ -0,0 +1,591 @@
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"rt-manage/internal/config"
+	"rt-manage/internal/model"
+	"rt-manage/pkg/logger"
+	"rt-manage/pkg/secret"
+)
+
+// 导入文件格式
+const (
+	ImportFormatCSV   = "csv"
+	ImportFormatJSON  = "json"
+	ImportFormatJSONL = "jsonl"
+	ImportFormatText  = "text"
+)
+
+// 导入结果状态
+const (
+	ImportStatusCreated   = "created"   // 已创建
+	ImportStatusDuplicate = "duplicate" // RT 或业务ID已存在
+	ImportStatusInvalid   = "invalid"   // 数据无效
+)
+
+// MaxImportRows 单次导入的最大行数
+const MaxImportRows = 10000
+
+// ImportRow 导入文件中的一行，未填写的 proxy、client_id、tag 使用导入请求中的默认值
+// 没有 RT 只有 AT 的行（或 credential_type 为 at）按仅AT账号导入
+type ImportRow struct {
+	Line           int    `json:"line"`
+	BizId          string `json:"biz_id"`
+	CredentialType string `json:"credential_type"`
+	Rt             string `json:"rt"`
+	At             string `json:"at"`
+	IdToken        string `json:"id_token"`
+	Proxy          string `json:"proxy"`
+	ClientID       string `json:"client_id"`
+	Tag            string `json:"tag"`
+	Memo           string `json:"memo"`
+	Enabled        *bool  `json:"enabled"`
+	AccountID      string `json:"account_id"`
+	Email          string `json:"email"`
+	UserName       string `json:"user_name"`
+	Type           string `json:"type"`
+
+	session *ChatGPTSession // 行为 ChatGPT 会话 JSON 时的会话信息
+	err     string          // 解析错误，不为空时该行直接判为 invalid
+}
+
+// ImportDefaults 导入请求中的默认值
+type ImportDefaults struct {
+	Tag      string
+	Proxy    string
+	ClientID string
+	Enabled  bool
+}
+
+// ImportResult 单行导入结果
+type ImportResult struct {
+	Line   int    `json:"line"`
+	ID     int64  `json:"id,omitempty"`
+	BizId  string `json:"biz_id"`
+	Rt     string `json:"rt"` // 已脱敏
+	Status string `json:"status"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// DetectImportFormat 根据文件扩展名判断导入格式，无法判断时按纯文本处理
+func DetectImportFormat(filename string) string {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".csv":
+		return ImportFormatCSV
+	case ".json":
+		return ImportFormatJSON
+	case ".jsonl", ".ndjson":
+		return ImportFormatJSONL
+	default:
+		return ImportFormatText
+	}
+}
+
+// ParseImportFile 解析导入文件
+// csv：首行为表头，必须包含 rt 或 at 列；json：对象数组或单个对象；jsonl：每行一个 JSON 对象；text：每行一个 RT，# 开头的行为注释
+// json、jsonl 中的对象也可以是 ChatGPT 会话 JSON（含 accessToken），按仅AT账号导入
+// 导出（csv、json、jsonl）的文件可直接导入
+func ParseImportFile(format string, r io.Reader) ([]*ImportRow, error) {
+	var rows []*ImportRow
+	var err error
+	switch format {
+	case ImportFormatCSV:
+		rows, err = parseImportCSV(r)
+	case ImportFormatJSON:
+		rows, err = parseImportJSON(r)
+	case ImportFormatJSONL:
+		rows, err = parseImportJSONL(r)
+	case ImportFormatText:
+		rows, err = parseImportText(r)
+	default:
+		return nil, fmt.Errorf("不支持的导入格式: %s", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("文件中没有可导入的数据")
+	}
+	if len(rows) > MaxImportRows {
+		return nil, fmt.Errorf("单次最多导入 %d 行，当前 %d 行", MaxImportRows, len(rows))
+	}
+	return rows, nil
+}
+
+// importColumns 表头别名 -> 字段
+var importColumns = map[string]string{
+	"rt":              "rt",
+	"rt_token":        "rt",
+	"refresh_token":   "rt",
+	"at":              "at",
+	"access_token":    "at",
+	"accesstoken":     "at",
+	"credential_type": "credential_type",
+	"id_token":        "id_token",
+	"biz_id":          "biz_id",
+	"name":            "biz_id",
+	"proxy":           "proxy",
+	"client_id":       "client_id",
+	"tag":             "tag",
+	"memo":            "memo",
+	"enabled":         "enabled",
+	"account_id":      "account_id",
+	"email":           "email",
+	"user_name":       "user_name",
+	"type":            "type",
+	"plan_type":       "type",
+}
+
+func parseImportCSV(r io.Reader) ([]*ImportRow, error) {
+	reader := csv.NewReader(bufio.NewReader(r))
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("读取CSV表头失败: %v", err)
+	}
+
+	columns := make([]string, len(header))
+	hasToken := false
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		columns[i] = importColumns[name]
+		hasToken = hasToken || columns[i] == "rt" || columns[i] == "at"
+	}
+	if !hasToken {
+		return nil, fmt.Errorf("CSV表头缺少 rt 或 at 列")
+	}
+
+	var rows []*ImportRow
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return rows, nil
+		}
+		if err != nil {
+			// 解析失败时没有字段位置（FieldPos 会 panic），行号取自 ParseError
+			var parseErr *csv.ParseError
+			if errors.As(err, &parseErr) {
+				rows = append(rows, &ImportRow{Line: parseErr.StartLine, err: fmt.Sprintf("CSV格式错误: %v", err)})
+				continue
+			}
+			return nil, fmt.Errorf("读取CSV失败: %v", err)
+		}
+		if isBlankRecord(record) {
+			continue
+		}
+
+		line, _ := reader.FieldPos(0)
+		row := &ImportRow{Line: line}
+		for i, value := range record {
+			if i < len(columns) {
+				row.set(columns[i], value)
+			}
+		}
+		rows = append(rows, row)
+	}
+}
+
+func isBlankRecord(record []string) bool {
+	for _, value := range record {
+		if strings.TrimSpace(value) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// set 按字段名设置值，enabled 无法解析时记录错误
+func (row *ImportRow) set(field, value string) {
+	value = strings.TrimSpace(value)
+	switch field {
+	case "credential_type":
+		row.CredentialType = strings.ToLower(value)
+	case "rt":
+		row.Rt = value
+	case "at":
+		row.At = value
+	case "id_token":
+		row.IdToken = value
+	case "biz_id":
+		row.BizId = value
+	case "proxy":
+		row.Proxy = value
+	case "client_id":
+		row.ClientID = value
+	case "tag":
+		row.Tag = value
+	case "memo":
+		row.Memo = value
+	case "account_id":
+		row.AccountID = value
+	case "email":
+		row.Email = value
+	case "user_name":
+		row.UserName = value
+	case "type":
+		row.Type = strings.ToLower(value)
+	case "enabled":
+		if value == "" {
+			return
+		}
+		enabled, err := strconv.ParseBool(value)
+		if err != nil {
+			row.err = fmt.Sprintf("enabled 值无效: %s", value)
+			return
+		}
+		row.Enabled = &enabled
+	}
+}
+
+func parseImportJSON(r io.Reader) ([]*ImportRow, error) {
+	decoder := json.NewDecoder(r)
+	decoder.UseNumber()
+	var raw json.RawMessage
+	if err := decoder.Decode(&raw); err != nil {
+		return nil, fmt.Errorf("JSON格式错误: %v", err)
+	}
+
+	// 单个对象（如直接保存的 ChatGPT 会话 JSON）按一行处理
+	var items []map[string]interface{}
+	if trimmed := bytes.TrimSpace(raw); len(trimmed) > 0 && trimmed[0] == '{' {
+		items = make([]map[string]interface{}, 1)
+		err := decodeJSONNumber(trimmed, &items[0])
+		if err != nil {
+			return nil, fmt.Errorf("JSON格式错误: %v", err)
+		}
+	} else if err := decodeJSONNumber(raw, &items); err != nil {
+		return nil, fmt.Errorf("JSON格式错误，须为对象数组或对象: %v", err)
+	}
+
+	// json 格式的行号为数组下标（从1开始）
+	rows := make([]*ImportRow, 0, len(items))
+	for i, fields := range items {
+		rows = append(rows, importRowFromFields(i+1, fields))
+	}
+	return rows, nil
+}
+
+func parseImportJSONL(r io.Reader) ([]*ImportRow, error) {
+	var rows []*ImportRow
+	err := scanLines(r, func(line int, text string) {
+		var fields map[string]interface{}
+		decoder := json.NewDecoder(strings.NewReader(text))
+		decoder.UseNumber()
+		if err := decoder.Decode(&fields); err != nil {
+			rows = append(rows, &ImportRow{Line: line, err: fmt.Sprintf("JSON格式错误: %v", err)})
+			return
+		}
+		rows = append(rows, importRowFromFields(line, fields))
+	})
+	return rows, err
+}
+
+// decodeJSONNumber 解析 JSON，数字保留为 json.Number
+func decodeJSONNumber(data []byte, v interface{}) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	return decoder.Decode(v)
+}
+
+// importRowFromFields 从 JSON 对象构造导入行，未知字段忽略
+func importRowFromFields(line int, fields map[string]interface{}) *ImportRow {
+	row := &ImportRow{Line: line}
+
+	// ChatGPT 会话 JSON：读取 AT 以及 user、account 中的账号信息
+	if _, ok := fields["accessToken"]; ok {
+		data, _ := json.Marshal(fields)
+		session, err := ParseChatGPTSession(data)
+		if err != nil {
+			row.err = err.Error()
+			return row
+		}
+		row.session = session
+		row.CredentialType = model.CredentialTypeAT
+	}
+
+	for name, value := range fields {
+		field, ok := importColumns[strings.ToLower(name)]
+		if !ok || value == nil {
+			continue
+		}
+		switch v := value.(type) {
+		case string:
+			row.set(field, v)
+		case bool:
+			row.set(field, strconv.FormatBool(v))
+		case json.Number:
+			row.set(field, v.String())
+		default:
+			row.err = fmt.Sprintf("%s 类型无效", name)
+		}
+	}
+	return row
+}
+
+func parseImportText(r io.Reader) ([]*ImportRow, error) {
+	var rows []*ImportRow
+	err := scanLines(r, func(line int, text string) {
+		if strings.HasPrefix(text, "#") {
+			return
+		}
+		rows = append(rows, &ImportRow{Line: line, Rt: text})
+	})
+	return rows, err
+}
+
+// scanLines 逐行读取，跳过空行，fn 收到的行号从1开始
+func scanLines(r io.Reader, fn func(line int, text string)) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(string(bytes.TrimPrefix(scanner.Bytes(), []byte("\ufeff"))))
+		if text != "" {
+			fn(line, text)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("读取文件失败: %v", err)
+	}
+	return nil
+}
+
+// Import 按行导入RT，返回每一行的导入结果
+func (s *rtService) Import(rows []*ImportRow, defaults ImportDefaults, proxyList []string, clientIdList []string) []*ImportResult {
+	results := make([]*ImportResult, 0, len(rows))
+	seenTokens := make(map[string]int)
+	seenBizIds := make(map[string]int)
+
+	for _, row := range rows {
+		result := &ImportResult{Line: row.Line, BizId: row.BizId, Rt: secret.Mask(row.Rt)}
+		results = append(results, result)
+
+		if rejection := s.checkImportRow(row, seenTokens, seenBizIds); rejection != nil {
+			result.Status, result.Reason = rejection.status, rejection.message
+			continue
+		}
+		seenTokens[row.token()] = row.Line
+		if row.BizId != "" {
+			seenBizIds[row.BizId] = row.Line
+		}
+
+		rt := &model.RT{
+			BizId:          row.BizId,
+			CredentialType: row.CredentialType,
+			Rt:             row.Rt,
+			Proxy:          row.Proxy,
+			ClientID:       row.ClientID,
+			Tag:            row.Tag,
+			Memo:           row.Memo,
+			Enabled:        defaults.Enabled,
+			AccountID:      row.AccountID,
+			Email:          row.Email,
+			UserName:       row.UserName,
+			Type:           row.Type,
+		}
+		if rt.BizId == "" {
+			rt.BizId = s.newBizId()
+		}
+		if rt.Proxy == "" {
+			rt.Proxy = pickFromList(defaults.Proxy, proxyList)
+		}
+		if rt.ClientID == "" {
+			rt.ClientID = pickFromList(defaults.ClientID, clientIdList)
+			if rt.ClientID == "" {
+				rt.ClientID = config.Get().OpenAI.ClientID
+			}
+		}
+		if rt.Tag == "" {
+			rt.Tag = defaults.Tag
+		}
+		if row.Enabled != nil {
+			rt.Enabled = *row.Enabled
+		}
+
+		if rt.IsATOnly() {
+			// 仅AT账号：会话中的账号信息优先，其余从 AT 中读取
+			if row.session != nil {
+				row.session.Apply(rt)
+			}
+			if err := s.applyAccessToken(rt, row.At); err != nil {
+				result.Status, result.Reason = ImportStatusInvalid, err.Error()
+				continue
+			}
+		} else if row.At != "" {
+			// 导入已有的 AT，未过期时按 AT 过期时间安排刷新
+			if expireTime := jwtExpireTime(row.At); expireTime != nil && !expireTime.After(time.Now()) {
+				result.Reason = "AT已过期，未导入AT"
+			} else {
+				rt.At = row.At
+				s.scheduleNext(rt, 0)
+			}
+		}
+		applyIDToken(rt, row.IdToken)
+
+		if err := s.repo.Create(rt); err != nil {
+			logger.Error("导入RT失败", "line", row.Line, "biz_id", rt.BizId, "error", err)
+			result.Status = ImportStatusInvalid
+			result.Reason = fmt.Sprintf("创建失败: %v", err)
+			continue
+		}
+		s.appendLineage(rt, model.LineageSourceCreate, 0, 0, "")
+
+		result.ID = rt.ID
+		result.BizId = rt.BizId
+		result.Status = ImportStatusCreated
+	}
+
+	return results
+}
+
+// importRejection 行被拒绝的原因
+type importRejection struct {
+	status  string
+	message string
+}
+
+// token 行的去重依据：RT 账号为 RT，仅AT账号为 AT
+func (row *ImportRow) token() string {
+	if row.CredentialType == model.CredentialTypeAT {
+		return row.At
+	}
+	return row.Rt
+}
+
+// checkImportRow 检查行数据是否有效、是否重复，通过时返回 nil
+func (s *rtService) checkImportRow(row *ImportRow, seenTokens, seenBizIds map[string]int) *importRejection {
+	if row.err != "" {
+		return &importRejection{ImportStatusInvalid, row.err}
+	}
+	if row.CredentialType == "" {
+		row.CredentialType = model.CredentialTypeRT
+		if row.Rt == "" && row.At != "" {
+			row.CredentialType = model.CredentialTypeAT
+		}
+	}
+	if len(row.BizId) > 255 {
+		return &importRejection{ImportStatusInvalid, "业务ID过长"}
+	}
+	// 默认导出的 token 为脱敏值，不能作为真实 token 导入
+	if secret.IsMasked(row.Rt) || secret.IsMasked(row.At) || secret.IsMasked(row.IdToken) {
+		return &importRejection{ImportStatusInvalid, "token 为脱敏值，请使用 tokens=include 导出的文件导入"}
+	}
+
+	switch row.CredentialType {
+	case model.CredentialTypeRT:
+		if rejection := s.checkImportRT(row, seenTokens); rejection != nil {
+			return rejection
+		}
+	case model.CredentialTypeAT:
+		if rejection := s.checkImportAT(row, seenTokens); rejection != nil {
+			return rejection
+		}
+	default:
+		return &importRejection{ImportStatusInvalid, fmt.Sprintf("不支持的凭证类型: %s", row.CredentialType)}
+	}
+
+	if row.BizId != "" {
+		if line, ok := seenBizIds[row.BizId]; ok {
+			return &importRejection{ImportStatusDuplicate, fmt.Sprintf("与第 %d 行的业务ID重复", line)}
+		}
+		existing, err := s.repo.GetByBizId(row.BizId)
+		if err != nil {
+			return &importRejection{ImportStatusInvalid, fmt.Sprintf("检查业务ID是否存在失败: %v", err)}
+		}
+		if existing != nil {
+			return &importRejection{ImportStatusDuplicate, fmt.Sprintf("业务ID '%s' 已存在", row.BizId)}
+		}
+	}
+	return nil
+}
+
+// checkImportRT 检查RT账号行
+func (s *rtService) checkImportRT(row *ImportRow, seenTokens map[string]int) *importRejection {
+	if row.Rt == "" {
+		return &importRejection{ImportStatusInvalid, "缺少RT"}
+	}
+	if strings.ContainsAny(row.Rt, " \t") {
+		return &importRejection{ImportStatusInvalid, "RT中包含空白字符"}
+	}
+
+	if line, ok := seenTokens[row.Rt]; ok {
+		return &importRejection{ImportStatusDuplicate, fmt.Sprintf("与第 %d 行的RT重复", line)}
+	}
+	existing, err := s.repo.GetByToken(row.Rt)
+	if err != nil {
+		return &importRejection{ImportStatusInvalid, fmt.Sprintf("检查RT是否存在失败: %v", err)}
+	}
+	if existing != nil {
+		return &importRejection{ImportStatusDuplicate, fmt.Sprintf("RT已存在（%s）", existing.BizId)}
+	}
+	return nil
+}
+
+// checkImportAT 检查仅AT账号行，按 AT 和邮箱去重
+func (s *rtService) checkImportAT(row *ImportRow, seenTokens map[string]int) *importRejection {
+	if row.Rt != "" {
+		return &importRejection{ImportStatusInvalid, "仅AT账号不能填写RT"}
+	}
+	if row.At == "" {
+		return &importRejection{ImportStatusInvalid, "缺少AT"}
+	}
+	if strings.ContainsAny(row.At, " \t") {
+		return &importRejection{ImportStatusInvalid, "AT中包含空白字符"}
+	}
+
+	if line, ok := seenTokens[row.At]; ok {
+		return &importRejection{ImportStatusDuplicate, fmt.Sprintf("与第 %d 行的AT重复", line)}
+	}
+	email, _ := jwtProfile(row.At)
+	if email == "" {
+		email = row.Email
+	}
+	if row.session != nil && row.session.User.Email != "" {
+		email = row.session.User.Email
+	}
+	existing, err := s.findSameAccount(&model.RT{Email: email})
+	if err != nil {
+		return &importRejection{ImportStatusInvalid, fmt.Sprintf("检查账号是否存在失败: %v", err)}
+	}
+	if existing != nil {
+		return &importRejection{ImportStatusDuplicate, fmt.Sprintf("账号 '%s' 已存在（%s）", email, existing.BizId)}
+	}
+	return nil
+}
+
+// newBizId 生成未被使用的32位业务ID
+func (s *rtService) newBizId() string {
+	name := generateRandomID()
+	existing, _ := s.repo.GetByBizId(name)
+	for existing != nil {
+		name = generateRandomID()
+		existing, _ = s.repo.GetByBizId(name)
+	}
+	return name
+}
+
+// pickFromList 优先使用指定值，否则从列表中随机选择
+func pickFromList(value string, list []string) string {
+	if value != "" || len(list) == 0 {
+		return value
+	}
+	return list[rand.Intn(len(list))]
+}
@@ -0,0 +1,98 @@
+package service
+
+import (
+	"strings"
+	"testing"
+
+	"rt-manage/pkg/secret"
+)
+
+func TestParseImportCSVMalformedQuotes(t *testing.T) {
+	tests := []struct {
+		name      string
+		input     string
+		wantRows  int
+		wantLines []int
+		wantErrs  []bool
+	}{
+		{
+			name:      "unterminated quote",
+			input:     "rt\n\"abc\n",
+			wantRows:  1,
+			wantLines: []int{2},
+			wantErrs:  []bool{true},
+		},
+		{
+			name:      "bare quote",
+			input:     "rt\na\"b\nc\n",
+			wantRows:  2,
+			wantLines: []int{2, 3},
+			wantErrs:  []bool{true, false},
+		},
+		{
+			name:      "valid rows around malformed row",
+			input:     "rt,tag\nrt_one,a\nrt_\"two,b\nrt_three,c\n",
+			wantRows:  3,
+			wantLines: []int{2, 3, 4},
+			wantErrs:  []bool{false, true, false},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rows, err := parseImportCSV(strings.NewReader(tt.input))
+			if err != nil {
+				t.Fatalf("parseImportCSV() error = %v", err)
+			}
+			if len(rows) != tt.wantRows {
+				t.Fatalf("parseImportCSV() rows = %d, want %d", len(rows), tt.wantRows)
+			}
+			for i, row := range rows {
+				if row.Line != tt.wantLines[i] {
+					t.Errorf("row %d line = %d, want %d", i, row.Line, tt.wantLines[i])
+				}
+				if (row.err != "") != tt.wantErrs[i] {
+					t.Errorf("row %d err = %q, want error %v", i, row.err, tt.wantErrs[i])
+				}
+			}
+		})
+	}
+}
+
+func TestParseImportCSVExportedColumns(t *testing.T) {
+	input := "id,biz_id,credential_type,rt,account_id,email,user_name,type,refresh_status\n" +
+		"7,acc-1,rt,rt_plain_token_value,acct-123,a@example.com,Alice,plus,ok\n"
+
+	rows, err := parseImportCSV(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("parseImportCSV() error = %v", err)
+	}
+	if len(rows) != 1 {
+		t.Fatalf("parseImportCSV() rows = %d, want 1", len(rows))
+	}
+	row := rows[0]
+	if row.AccountID != "acct-123" || row.Email != "a@example.com" || row.UserName != "Alice" || row.Type != "plus" {
+		t.Errorf("exported columns not mapped: %+v", row)
+	}
+}
+
+func TestCheckImportRowRejectsMaskedTokens(t *testing.T) {
+	s := &rtService{}
+	tests := []struct {
+		name string
+		row  *ImportRow
+	}{
+		{name: "masked rt", row: &ImportRow{Rt: secret.Mask("rt_plain_token_value_1234")}},
+		{name: "masked at", row: &ImportRow{At: secret.Mask("eyJhbGciOiJSUzI1NiJ9.payload.signature")}},
+		{name: "short masked rt", row: &ImportRow{Rt: secret.Mask("short")}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rejection := s.checkImportRow(tt.row, map[string]int{}, map[string]int{})
+			if rejection == nil || rejection.status != ImportStatusInvalid {
+				t.Fatalf("checkImportRow() = %+v, want invalid", rejection)
+			}
+		})
+	}
+}
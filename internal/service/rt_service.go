@@ -4,13 +4,16 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"math/rand"
 	"net"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"rt-manage/internal/config"
@@ -23,36 +26,52 @@ import (
 	"github.com/bogdanfinn/tls-client/profiles"
 	"github.com/google/uuid"
 	"golang.org/x/net/proxy"
+	"golang.org/x/sync/singleflight"
 )
 
 // RTService RT 服务接口
 type RTService interface {
 	Create(rt *model.RT) error
-	Update(id int64, updates map[string]interface{}) (*model.RT, error)
+	Update(id int64, updates map[string]interface{}, expectedVersion *int64) (*model.RT, error)
 	GetByID(id int64) (*model.RT, error)
+	GetByIDs(ids []int64) ([]*model.RT, error)
 	GetByBizId(bizId string) (*model.RT, error)
 	GetByEmail(email string) (*model.RT, error)
-	List(page, pageSize int, name string, tag string, email string, typeStr string, enabled *bool, createDate string) ([]*model.RT, int64, error)
+	List(page, pageSize int, name string, tag string, email string, typeStr string, enabled *bool, createDate string, refreshStatus string) ([]*model.RT, int64, error)
 	Delete(id int64) error
 	BatchDelete(ids []int64) (int, int, error)
-	Refresh(id int64, refreshUserInfo, refreshAccountInfo bool) (*model.RT, error)
+	Refresh(id int64, refreshUserInfo, refreshAccountInfo bool, trigger string) (*model.RT, error)
 	RefreshUserInfo(id int64) (*model.RT, error)
 	RefreshAccountInfo(id int64) (*model.RT, error)
-	BatchRefresh(ids []int64) (int, int, []map[string]interface{}, error)
-	BatchImport(batchName string, tag string, proxy string, clientID string, tokens []string, proxyList []string, clientIdList []string) (int, int, error)
-	AutoRefreshAll() error
+	BatchRefresh(ids []int64, trigger string) (int, int, []map[string]interface{}, error)
+	BatchImport(batchName string, tag string, proxy string, clientID string, tokens []string, proxyList []string, clientIdList []string) (int, int, []*model.RT, error)
+	Import(rows []*ImportRow, defaults ImportDefaults, proxyList []string, clientIdList []string) []*ImportResult
+	Export(w io.Writer, opts *ExportOptions) (int, error)
+	StartOnboarding(opts OnboardingOptions, proxyList []string, clientIdList []string) (*OnboardingStart, error)
+	CompleteOnboarding(state string, callback string) (*model.RT, error)
+	Report(id int64, req ReportRequest) (*ReportResult, error)
+	RefreshDue() error
+	RecoverRotations() error
+	ListLineage(rtID int64) ([]*model.TokenLineage, error)
+	PromoteLineage(rtID int64, lineageID int64, operator string) (*model.RT, error)
 }
 
 type rtService struct {
-	repo       repository.RTRepository
-	configRepo repository.ConfigRepository
+	repo         repository.RTRepository
+	configRepo   repository.ConfigRepository
+	logRepo      repository.RefreshLogRepository
+	rotationRepo repository.TokenRotationRepository
+	lineageRepo  repository.TokenLineageRepository
 }
 
 // NewRTService 创建 RT 服务实例
-func NewRTService(repo repository.RTRepository, configRepo repository.ConfigRepository) RTService {
+func NewRTService(repo repository.RTRepository, configRepo repository.ConfigRepository, logRepo repository.RefreshLogRepository, rotationRepo repository.TokenRotationRepository, lineageRepo repository.TokenLineageRepository) RTService {
 	return &rtService{
-		repo:       repo,
-		configRepo: configRepo,
+		repo:         repo,
+		configRepo:   configRepo,
+		logRepo:      logRepo,
+		rotationRepo: rotationRepo,
+		lineageRepo:  lineageRepo,
 	}
 }
 
@@ -115,6 +134,16 @@ func createHTTPClient(proxyURL string, timeout time.Duration) (*http.Client, err
 	return client, nil
 }
 
+// authURL 拼接 Token 接口地址（openai.auth_base_url）
+func authURL(path string) string {
+	return strings.TrimRight(config.Get().OpenAI.AuthBaseURL, "/") + path
+}
+
+// chatGPTURL 拼接 ChatGPT 后端接口地址（openai.chatgpt_base_url）
+func chatGPTURL(path string) string {
+	return strings.TrimRight(config.Get().OpenAI.ChatGPTBaseURL, "/") + path
+}
+
 // Create 创建 RT
 func (s *rtService) Create(rt *model.RT) error {
 	// 如果name为空，生成32位UUID
@@ -131,13 +160,27 @@ func (s *rtService) Create(rt *model.RT) error {
 		return fmt.Errorf("RT名称 '%s' 已存在", rt.BizId)
 	}
 
-	// 检查token是否已存在
-	existingToken, err := s.repo.GetByToken(rt.Rt)
-	if err != nil {
+	if err := s.prepareCredential(rt); err != nil {
 		return err
 	}
-	if existingToken != nil {
-		return fmt.Errorf("此RT Token已存在")
+
+	// 检查token是否已存在，仅AT账号按邮箱检查
+	if rt.IsATOnly() {
+		existingAccount, err := s.findSameAccount(rt)
+		if err != nil {
+			return err
+		}
+		if existingAccount != nil {
+			return fmt.Errorf("账号 '%s' 已存在（%s）", rt.Email, existingAccount.BizId)
+		}
+	} else {
+		existingToken, err := s.repo.GetByToken(rt.Rt)
+		if err != nil {
+			return err
+		}
+		if existingToken != nil {
+			return fmt.Errorf("此RT Token已存在")
+		}
 	}
 
 	// 填充默认配置值（如果字段为空）
@@ -147,11 +190,23 @@ func (s *rtService) Create(rt *model.RT) error {
 		logger.Info("创建RT时填充默认 client_id", "client_id", rt.ClientID)
 	}
 
-	return s.repo.Create(rt)
+	if err := s.repo.Create(rt); err != nil {
+		return err
+	}
+	s.appendLineage(rt, model.LineageSourceCreate, 0, 0, "")
+	return nil
 }
 
+// ErrVersionConflict RT 已被其他操作修改（乐观锁冲突）
+var ErrVersionConflict = repository.ErrVersionConflict
+
+// maxUpdateRetries 乐观锁冲突时的最大重试次数
+const maxUpdateRetries = 3
+
 // Update 更新 RT
-func (s *rtService) Update(id int64, updates map[string]interface{}) (*model.RT, error) {
+// expectedVersion 不为空时（If-Match），版本不一致直接返回 ErrVersionConflict；
+// 为空时遇到并发修改会基于最新记录重新应用更新
+func (s *rtService) Update(id int64, updates map[string]interface{}, expectedVersion *int64) (*model.RT, error) {
 	rt, err := s.repo.GetByID(id)
 	if err != nil {
 		return nil, err
@@ -159,16 +214,41 @@ func (s *rtService) Update(id int64, updates map[string]interface{}) (*model.RT,
 	if rt == nil {
 		return nil, fmt.Errorf("RT不存在")
 	}
+	if expectedVersion != nil && *expectedVersion != rt.Version {
+		return nil, ErrVersionConflict
+	}
 
 	// 应用更新
-	logger.Info("更新RT - 接收到的updates", "id", id, "updates", updates)
-	
+	logger.Info("更新RT - 接收到的updates", "id", id, "updates", updates, "expected_version", expectedVersion)
+
+	if err := s.applyUpdates(rt, updates); err != nil {
+		return nil, err
+	}
+
+	if expectedVersion != nil {
+		if err := s.repo.Update(rt); err != nil {
+			return nil, err
+		}
+		return rt, nil
+	}
+
+	if err := s.saveWithRetry(rt, func(latest *model.RT) error {
+		return s.applyUpdates(latest, updates)
+	}); err != nil {
+		return nil, err
+	}
+
+	return rt, nil
+}
+
+// applyUpdates 将管理端的更新字段应用到RT
+func (s *rtService) applyUpdates(rt *model.RT, updates map[string]interface{}) error {
 	if bizId, ok := updates["biz_id"].(string); ok && bizId != "" {
 		// 检查新名称是否与其他RT冲突
 		if bizId != rt.BizId {
 			existing, _ := s.repo.GetByBizId(bizId)
 			if existing != nil {
-				return nil, fmt.Errorf("RT名称 '%s' 已被使用", bizId)
+				return fmt.Errorf("RT名称 '%s' 已被使用", bizId)
 			}
 		}
 		rt.BizId = bizId
@@ -190,32 +270,121 @@ func (s *rtService) Update(id int64, updates map[string]interface{}) (*model.RT,
 	if memo, ok := updates["memo"].(string); ok {
 		rt.Memo = memo
 	}
+	// 仅AT账号可直接更换AT（如重新导出的会话）
+	if at, ok := updates["at"].(string); ok {
+		if !rt.IsATOnly() {
+			return fmt.Errorf("只有仅AT账号可以直接更新AT")
+		}
+		if err := s.applyAccessToken(rt, strings.TrimSpace(at)); err != nil {
+			return err
+		}
+		// 换了新的AT，之前反馈 401 触发的冷却不再适用
+		if rt.CooldownReason == model.CooldownReasonUnauthorized {
+			rt.CooldownUntil = nil
+			rt.CooldownReason = ""
+		}
+	}
+	// 手动解除调用方反馈触发的冷却
+	if clear, ok := updates["clear_cooldown"].(bool); ok && clear {
+		rt.CooldownUntil = nil
+		rt.CooldownReason = ""
+	}
+	return nil
+}
 
-	if err := s.repo.Update(rt); err != nil {
-		return nil, err
+// saveWithRetry 保存RT，遇到乐观锁冲突时读取最新记录，通过 apply 重新应用本次变更后重试
+func (s *rtService) saveWithRetry(rt *model.RT, apply func(latest *model.RT) error) error {
+	for i := 0; ; i++ {
+		err := s.repo.Update(rt)
+		if !errors.Is(err, ErrVersionConflict) || i >= maxUpdateRetries {
+			return err
+		}
+
+		logger.Warn("RT更新冲突，基于最新记录重试", "id", rt.ID, "retry", i+1)
+		latest, err := s.repo.GetByID(rt.ID)
+		if err != nil {
+			return err
+		}
+		if latest == nil {
+			return fmt.Errorf("RT不存在")
+		}
+		if err := apply(latest); err != nil {
+			return err
+		}
+		*rt = *latest
 	}
+}
 
-	return rt, nil
+// saveRefreshResult 保存刷新结果，与管理端编辑冲突时以最新记录为基础重新写入
+// base 为刷新前读取的记录，用于判断哪些账号信息是本次刷新得到的
+func (s *rtService) saveRefreshResult(rt *model.RT, base *model.RT) error {
+	refreshed := *rt
+	return s.saveWithRetry(rt, func(latest *model.RT) error {
+		copyRefreshResult(latest, &refreshed, base)
+		return nil
+	})
+}
+
+// copyRefreshResult 将刷新得到的token、调度信息复制到最新记录上
+// 账号信息只复制本次刷新实际获取到的非空字段，其余保留最新记录的值，避免覆盖管理端的并发修改
+func copyRefreshResult(dst, src, base *model.RT) {
+	dst.Rt = src.Rt
+	dst.LastRT = src.LastRT
+	dst.At = src.At
+	dst.IdToken = src.IdToken
+	dst.RefreshResult = src.RefreshResult
+	dst.RefreshStatus = src.RefreshStatus
+	dst.LastRefreshTime = src.LastRefreshTime
+	dst.AtExpireTime = src.AtExpireTime
+	dst.NextRefreshTime = src.NextRefreshTime
+	copyIfProduced(&dst.AccountID, src.AccountID, base.AccountID)
+	copyIfProduced(&dst.UserInfo, src.UserInfo, base.UserInfo)
+	copyIfProduced(&dst.AccountInfo, src.AccountInfo, base.AccountInfo)
+	copyIfProduced(&dst.Email, src.Email, base.Email)
+	copyIfProduced(&dst.UserName, src.UserName, base.UserName)
+	copyIfProduced(&dst.Type, src.Type, base.Type)
+}
+
+// copyIfProduced 刷新得到的值非空且与刷新前不同时才写入
+func copyIfProduced(dst *string, value, before string) {
+	if value != "" && value != before {
+		*dst = value
+	}
 }
 
 // GetByID 获取RT
 func (s *rtService) GetByID(id int64) (*model.RT, error) {
-	return s.repo.GetByID(id)
+	rt, err := s.repo.GetByID(id)
+	markStale(rt)
+	return rt, err
+}
+
+// GetByIDs 根据ID列表获取RT
+func (s *rtService) GetByIDs(ids []int64) ([]*model.RT, error) {
+	rts, err := s.repo.GetByIDs(ids)
+	markStale(rts...)
+	return rts, err
 }
 
 // GetByBizId 根据业务ID获取RT
 func (s *rtService) GetByBizId(bizId string) (*model.RT, error) {
-	return s.repo.GetByBizId(bizId)
+	rt, err := s.repo.GetByBizId(bizId)
+	markStale(rt)
+	return rt, err
 }
 
 // GetByEmail 根据邮箱获取RT
 func (s *rtService) GetByEmail(email string) (*model.RT, error) {
-	return s.repo.GetByEmail(email)
+	rt, err := s.repo.GetByEmail(email)
+	markStale(rt)
+	return rt, err
 }
 
 // List 获取列表
-func (s *rtService) List(page, pageSize int, name string, tag string, email string, typeStr string, enabled *bool, createDate string) ([]*model.RT, int64, error) {
-	return s.repo.List(page, pageSize, name, tag, email, typeStr, enabled, createDate)
+func (s *rtService) List(page, pageSize int, name string, tag string, email string, typeStr string, enabled *bool, createDate string, refreshStatus string) ([]*model.RT, int64, error) {
+	rts, total, err := s.repo.List(page, pageSize, name, tag, email, typeStr, enabled, createDate, refreshStatus)
+	markStale(rts...)
+	return rts, total, err
 }
 
 // Delete 删除RT
@@ -232,6 +401,7 @@ func (s *rtService) BatchDelete(ids []int64) (int, int, error) {
 type OpenAITokenResponse struct {
 	AccessToken  string `json:"access_token"`
 	RefreshToken string `json:"refresh_token"`
+	IDToken      string `json:"id_token"`
 	ExpiresIn    int    `json:"expires_in"`
 	TokenType    string `json:"token_type"`
 	Scope        string `json:"scope"`
@@ -263,8 +433,76 @@ type UserInfoResponse struct {
 	Name  string `json:"name"`
 }
 
+// refreshGroup 合并同一RT的并发刷新（包级共享，对所有 rtService 实例生效）
+// 同一个RT在上游只允许一个刷新请求在途，避免重复提交同一个RT触发 refresh_token_reused
+var refreshGroup singleflight.Group
+
+// errRefreshSkipped 调度器发现RT尚未到期，跳过了本次刷新
+var errRefreshSkipped = errors.New("RT未到计划刷新时间，已跳过")
+
+// refreshCall 合并刷新的执行结果，以及实际执行刷新的调用方参数
+type refreshCall struct {
+	rt                 *model.RT
+	refreshUserInfo    bool
+	refreshAccountInfo bool
+	trigger            string
+	skipped            bool // 调度器跳过了未到期的RT，没有请求上游
+}
+
 // Refresh 刷新单个RT
-func (s *rtService) Refresh(id int64, refreshUserInfo, refreshAccountInfo bool) (*model.RT, error) {
+// 同一RT的并发调用会合并为一次上游请求（以RT ID合并，避免重复提交同一个RT），所有调用方获得相同的刷新结果
+// 实际执行刷新的调用方没有获取用户信息或账号信息时，要求获取的调用方在刷新完成后自行获取
+func (s *rtService) Refresh(id int64, refreshUserInfo, refreshAccountInfo bool, trigger string) (*model.RT, error) {
+	v, err, shared := refreshGroup.Do(strconv.FormatInt(id, 10), func() (interface{}, error) {
+		rt, err := s.doRefresh(id, refreshUserInfo, refreshAccountInfo, trigger)
+		skipped := errors.Is(err, errRefreshSkipped)
+		if skipped {
+			err = nil
+		}
+		return &refreshCall{rt: rt, refreshUserInfo: refreshUserInfo, refreshAccountInfo: refreshAccountInfo, trigger: trigger, skipped: skipped}, err
+	})
+
+	// 每个调用方拿到独立的副本
+	call, _ := v.(*refreshCall)
+	var rt *model.RT
+	if call != nil && call.rt != nil {
+		copied := *call.rt
+		rt = &copied
+	}
+	if !shared || call == nil {
+		return rt, err
+	}
+
+	// 合并到了被调度器跳过的调用，手动或对外API的刷新须重新执行（此时分组已结束，会发起新的刷新）
+	if call.skipped && trigger != model.RefreshTriggerScheduler {
+		logger.Info("合并的刷新已被调度器跳过，重新刷新", "id", id, "trigger", trigger)
+		return s.Refresh(id, refreshUserInfo, refreshAccountInfo, trigger)
+	}
+
+	// 刷新记录中的触发方式为实际执行刷新的调用方
+	logger.Info("合并并发刷新请求", "id", id, "trigger", trigger, "refresh_trigger", call.trigger)
+	if err != nil || rt == nil || rt.At == "" {
+		return rt, err
+	}
+	if refreshUserInfo && !call.refreshUserInfo {
+		if updated, err := s.RefreshUserInfo(id); err != nil {
+			logger.Warn("获取用户信息失败", "id", id, "name", rt.BizId, "error", err)
+		} else {
+			rt = updated
+		}
+	}
+	if refreshAccountInfo && !call.refreshAccountInfo {
+		if updated, err := s.RefreshAccountInfo(id); err != nil {
+			logger.Warn("获取账号信息失败", "id", id, "name", rt.BizId, "error", err)
+		} else {
+			rt = updated
+		}
+	}
+	return rt, nil
+}
+
+// doRefresh 执行单个RT的刷新
+func (s *rtService) doRefresh(id int64, refreshUserInfo, refreshAccountInfo bool, trigger string) (*model.RT, error) {
 	rt, err := s.repo.GetByID(id)
 	if err != nil {
 		return nil, err
@@ -273,7 +511,25 @@ func (s *rtService) Refresh(id int64, refreshUserInfo, refreshAccountInfo bool)
 		return nil, fmt.Errorf("RT不存在")
 	}
 
-	logger.Info("开始刷新RT", "id", id, "name", rt.BizId, "has_proxy", rt.Proxy != "")
+	// 仅AT账号没有RT，只检查AT是否过期
+	if rt.IsATOnly() {
+		return s.checkAccessTokenOnly(rt)
+	}
+
+	// 存在已收到但未写入的轮换结果时先恢复，避免向上游提交已失效的旧RT
+	if _, err := s.recoverPendingRotation(rt); err != nil {
+		logger.Error("恢复RT轮换失败", "id", id, "error", err)
+		return rt, fmt.Errorf("恢复未完成的RT轮换失败: %v", err)
+	}
+
+	// 调度器任务列出后，RT可能已被其他请求刷新，未到期则跳过
+	if trigger == model.RefreshTriggerScheduler && rt.NextRefreshTime != nil && rt.NextRefreshTime.After(time.Now()) {
+		logger.Info("RT已被其他请求刷新，跳过本次调度", "id", id, "biz_id", rt.BizId, "next_refresh_time", rt.NextRefreshTime)
+		return rt, errRefreshSkipped
+	}
+
+	logger.Info("开始刷新RT", "id", id, "name", rt.BizId, "has_proxy", rt.Proxy != "", "trigger", trigger)
+	base := *rt
 
 	// 获取 client_id，优先使用 RT 记录中的，如果为空则使用配置文件中的默认值
 	clientID := rt.ClientID
@@ -287,7 +543,7 @@ func (s *rtService) Refresh(id int64, refreshUserInfo, refreshAccountInfo bool)
 	requestBody := map[string]string{
 		"client_id":     clientID,
 		"grant_type":    "refresh_token",
-		"redirect_uri":  "com.openai.chat://auth0.openai.com/ios/com.openai.chat/callback",
+		"redirect_uri":  config.Get().OpenAI.RedirectURI,
 		"refresh_token": rt.Rt,
 	}
 
@@ -297,18 +553,28 @@ func (s *rtService) Refresh(id int64, refreshUserInfo, refreshAccountInfo bool)
 		return nil, fmt.Errorf("构造请求体失败: %v", err)
 	}
 
+	// 本次刷新记录
+	attempt := &model.RefreshLog{
+		RtID:     rt.ID,
+		BizId:    rt.BizId,
+		Trigger:  trigger,
+		Proxy:    rt.Proxy,
+		ClientID: clientID,
+	}
+
 	// 创建支持 SOCKS5 的 HTTP 客户端
 	client, err := createHTTPClient(rt.Proxy, 10*time.Second)
 	if err != nil {
 		logger.Warn("创建HTTP客户端失败", "proxy", rt.Proxy, "error", err)
 		// 使用无代理的客户端
 		client = &http.Client{Timeout: 10 * time.Second}
+		attempt.Proxy = ""
 	} else if rt.Proxy != "" {
 		logger.Info("使用代理", "proxy", rt.Proxy)
 	}
 
 	// 创建请求
-	req, err := http.NewRequest("POST", "https://auth.openai.com/oauth/token", bytes.NewBuffer(jsonData))
+	req, err := http.NewRequest("POST", authURL("/oauth/token"), bytes.NewBuffer(jsonData))
 	if err != nil {
 		logger.Error("创建请求失败", "error", err)
 		return nil, fmt.Errorf("创建请求失败: %v", err)
@@ -316,54 +582,74 @@ func (s *rtService) Refresh(id int64, refreshUserInfo, refreshAccountInfo bool)
 
 	req.Header.Set("Content-Type", "application/json")
 
+	// 先写入轮换日志，保证上游返回的新RT可以恢复
+	rotation, err := s.beginRotation(rt)
+	if err != nil {
+		logger.Error("写入RT轮换日志失败，放弃刷新", "id", id, "error", err)
+		return rt, fmt.Errorf("写入RT轮换日志失败: %v", err)
+	}
+
 	// 发送请求
+	start := time.Now()
 	resp, err := client.Do(req)
 	if err != nil {
 		logger.Error("请求失败", "error", err)
+		if requestNotSent(err) {
+			s.finishRotation(rotation)
+		} else {
+			// 请求可能已送达，上游可能已轮换RT，保留轮换日志
+			logger.Warn("刷新请求中断，上游结果未知", "id", id, "biz_id", rt.BizId, "rotation_id", rotation.ID)
+			s.markRotationUnknown(rotation)
+		}
+		attempt.LatencyMs = time.Since(start).Milliseconds()
 		// 保存失败结果
 		rt.RefreshResult = fmt.Sprintf("请求失败: %v", err)
-		s.repo.Update(rt)
-		return rt, fmt.Errorf("请求失败: %v", err)
+		return rt, s.handleRefreshFailure(rt, attempt, newNetworkError(err))
 	}
 	defer resp.Body.Close()
 
 	// 读取响应
 	body, err := io.ReadAll(resp.Body)
+	attempt.LatencyMs = time.Since(start).Milliseconds()
+	attempt.HTTPStatus = resp.StatusCode
 	if err != nil {
 		logger.Error("读取响应失败", "error", err)
+		s.markRotationUnknown(rotation)
 		rt.RefreshResult = fmt.Sprintf("读取响应失败: %v", err)
-		s.repo.Update(rt)
-		return rt, fmt.Errorf("读取响应失败: %v", err)
+		return rt, s.handleRefreshFailure(rt, attempt, newNetworkError(err))
 	}
 
 	// 保存完整的响应body到refresh_result
 	rt.RefreshResult = string(body)
+	attempt.ResponseBody = redactResponseBody(body)
 
 	// 解析响应
+	var journalErr error
 	if resp.StatusCode == 200 {
 		// 成功响应
 		var tokenResp OpenAITokenResponse
 		if err := json.Unmarshal(body, &tokenResp); err != nil {
-			logger.Error("解析成功响应失败", "error", err, "body", string(body))
-			s.repo.Update(rt)
-			return rt, fmt.Errorf("解析响应失败: %v", err)
+			logger.Error("解析成功响应失败", "error", err, "body", redactResponseBody(body))
+			s.markRotationUnknown(rotation)
+			return rt, s.handleRefreshFailure(rt, attempt, &RefreshError{
+				Status:     model.RefreshStatusUnknownError,
+				HTTPStatus: resp.StatusCode,
+				Message:    fmt.Sprintf("解析响应失败: %v", err),
+			})
 		}
 
-		// 保存旧的RT Token到LastRT
-		rt.LastRT = rt.Rt
-		// 更新为新的RT Token
-		rt.Rt = tokenResp.RefreshToken
-		// 保存Access Token
-		rt.At = tokenResp.AccessToken
-		// 更新刷新时间
-		now := time.Now()
-		rt.LastRefreshTime = &now
+		// 新RT先写入轮换日志，再更新RT；日志写入失败时仍尝试写入RT表
+		journalErr = s.markRotationReceived(rotation, &tokenResp)
+		s.applyRotation(rt, tokenResp.RefreshToken, tokenResp.AccessToken, tokenResp.ExpiresIn, time.Now())
+		applyIDToken(rt, tokenResp.IDToken)
 
 		logger.Info("刷新RT成功",
 			"id", id,
 			"name", rt.BizId,
-			"old_rt", rt.LastRT[:20]+"...",
-			"new_rt", rt.Rt[:20]+"...",
+			"old_rt", tokenPreview(rt.LastRT),
+			"new_rt", tokenPreview(rt.Rt),
+			"at_expire_time", rt.AtExpireTime,
+			"next_refresh_time", rt.NextRefreshTime,
 		)
 
 		// 根据参数决定是否获取用户信息
@@ -384,41 +670,144 @@ func (s *rtService) Refresh(id int64, refreshUserInfo, refreshAccountInfo bool)
 			}
 		}
 	} else {
-		// 失败响应
-		var errorResp OpenAIErrorResponse
-		var errorMsg string
-
-		if err := json.Unmarshal(body, &errorResp); err != nil {
-			// 解析失败，使用原始响应
-			logger.Error("解析错误响应失败", "error", err, "status", resp.StatusCode, "body", string(body))
-			errorMsg = fmt.Sprintf("HTTP %d: %s", resp.StatusCode, string(body))
-		} else {
-			logger.Error("刷新RT失败",
-				"id", id,
-				"name", rt.BizId,
-				"error_code", errorResp.Error.Code,
-				"error_message", errorResp.Error.Message,
-			)
-			errorMsg = fmt.Sprintf("%s: %s", errorResp.Error.Code, errorResp.Error.Message)
+		// 失败响应，按错误类型分类处理
+		refreshErr := classifyRefreshResponse(resp.StatusCode, resp.Header, body)
+		logger.Error("刷新RT失败",
+			"id", id,
+			"name", rt.BizId,
+			"status", resp.StatusCode,
+			"refresh_status", refreshErr.Status,
+			"error_code", refreshErr.Code,
+			"error_message", refreshErr.Message,
+		)
+
+		// 上游明确拒绝，RT、LastRT保持不变
+		s.finishRotation(rotation)
+		return rt, s.handleRefreshFailure(rt, attempt, refreshErr)
+	}
+
+	// 成功时才更新数据库，与管理端编辑并发时以最新记录为基础重新写入新token
+	attempt.RefreshStatus = model.RefreshStatusOK
+	if err := s.saveRefreshResult(rt, &base); err != nil {
+		// 轮换日志写入失败时再试一次，数据库可能已恢复
+		if journalErr != nil {
+			journalErr = s.writeRotation(rotation)
+		}
+		if journalErr != nil {
+			// 新RT未能写入任何位置，旧RT已在上游作废
+			logger.Error("更新RT失败，且新RT未能写入轮换日志，新RT已丢失", "id", id, "rotation_id", rotation.ID, "error", err, "journal_error", journalErr)
+			attempt.ErrorMessage = fmt.Sprintf("更新RT失败，新RT未能保存: %v", err)
+			s.recordRefreshLog(attempt)
+			return nil, fmt.Errorf("更新RT失败，新RT未能保存: %v", err)
 		}
+		// 新RT保留在轮换日志中，下次刷新或重启时恢复
+		logger.Error("更新RT失败，新RT已保存在轮换日志中", "id", id, "rotation_id", rotation.ID, "error", err)
+		attempt.ErrorMessage = fmt.Sprintf("更新RT失败: %v", err)
+		s.recordRefreshLog(attempt)
+		return nil, fmt.Errorf("更新RT失败: %v", err)
+	}
+	s.finishRotation(rotation)
+	s.recordRefreshLog(attempt)
+	s.appendLineage(rt, model.LineageSourceRefresh, attempt.ID, 0, "")
 
-		// RT、LastRT和Enabled保持不变，只更新RefreshResult
-		// 更新数据库
-		if err := s.repo.Update(rt); err != nil {
-			logger.Error("更新RT失败", "error", err)
+	return rt, nil
+}
+
+// scheduleNext 刷新成功后计算下次刷新时间
+// 取 AT 过期时间减去安全余量 与 RT 最长使用时间 两者中较早的一个
+func (s *rtService) scheduleNext(rt *model.RT, expiresIn int) {
+	cfg := config.Get()
+	now := time.Now()
+
+	// AT 过期时间优先取 JWT 的 exp，其次取 expires_in
+	rt.AtExpireTime = jwtExpireTime(rt.At)
+	if rt.AtExpireTime == nil && expiresIn > 0 {
+		expireTime := now.Add(time.Duration(expiresIn) * time.Second)
+		rt.AtExpireTime = &expireTime
+	}
+
+	next := now.Add(s.rtMaxAge())
+	if rt.AtExpireTime != nil {
+		margin := time.Duration(cfg.OpenAI.RefreshMarginMinutes) * time.Minute
+		if byExpire := rt.AtExpireTime.Add(-margin); byExpire.Before(next) {
+			next = byExpire
 		}
+	}
 
-		// 返回错误给调用方
-		return rt, fmt.Errorf("刷新失败: %s", errorMsg)
+	// 避免AT有效期短于安全余量时反复刷新
+	retry := time.Duration(cfg.OpenAI.RefreshRetryMinutes) * time.Minute
+	if earliest := now.Add(retry); next.Before(earliest) {
+		next = earliest
 	}
+	rt.NextRefreshTime = &next
+}
 
-	// 成功时才更新数据库
-	if err := s.repo.Update(rt); err != nil {
+// handleRefreshFailure 记录刷新失败状态并按失败策略处理（自动禁用或安排重试）
+func (s *rtService) handleRefreshFailure(rt *model.RT, attempt *model.RefreshLog, refreshErr *RefreshError) error {
+	cfg := config.Get()
+	policy := cfg.OpenAI.FailurePolicies[refreshErr.Status]
+
+	rt.RefreshStatus = refreshErr.Status
+
+	if policy.Disable {
+		if rt.Enabled {
+			logger.Warn("RT已失效，自动禁用", "id", rt.ID, "biz_id", rt.BizId, "refresh_status", refreshErr.Status)
+		}
+		rt.Enabled = false
+		rt.NextRefreshTime = nil
+	} else {
+		retry := time.Duration(policy.RetryMinutes) * time.Minute
+		if retry <= 0 {
+			retry = time.Duration(cfg.OpenAI.RefreshRetryMinutes) * time.Minute
+		}
+		// 上游要求的等待时间更长时以上游为准
+		if refreshErr.RetryAfter > retry {
+			retry = refreshErr.RetryAfter
+		}
+		next := time.Now().Add(retry)
+		rt.NextRefreshTime = &next
+	}
+
+	failed := *rt
+	if err := s.saveWithRetry(rt, func(latest *model.RT) error {
+		latest.RefreshResult = failed.RefreshResult
+		latest.RefreshStatus = failed.RefreshStatus
+		latest.NextRefreshTime = failed.NextRefreshTime
+		if policy.Disable {
+			latest.Enabled = false
+		}
+		return nil
+	}); err != nil {
 		logger.Error("更新RT失败", "error", err)
-		return nil, fmt.Errorf("更新RT失败: %v", err)
 	}
 
-	return rt, nil
+	attempt.RefreshStatus = refreshErr.Status
+	attempt.ErrorCode = refreshErr.Code
+	attempt.ErrorMessage = refreshErr.Message
+	s.recordRefreshLog(attempt)
+
+	return refreshErr
+}
+
+// recordRefreshLog 保存刷新记录，失败不影响刷新流程
+func (s *rtService) recordRefreshLog(attempt *model.RefreshLog) {
+	if err := s.logRepo.Create(attempt); err != nil {
+		logger.Error("保存刷新记录失败", "rt_id", attempt.RtID, "error", err)
+	}
+}
+
+// rtMaxAge RT 最长使用时间，取系统配置 auto_refresh_interval（天），未配置时使用配置文件
+func (s *rtService) rtMaxAge() time.Duration {
+	days := config.Get().OpenAI.RefreshInterval
+	if item, err := s.configRepo.GetByKey("auto_refresh_interval"); err == nil && item != nil {
+		if v, err := strconv.Atoi(item.ConfigValue); err == nil && v > 0 {
+			days = v
+		}
+	}
+	if days <= 0 {
+		days = 2
+	}
+	return time.Duration(days) * 24 * time.Hour
 }
 
 // createTLSClient 创建带有 Firefox TLS 指纹的客户端
@@ -438,6 +827,44 @@ func createTLSClient(proxyURL string, timeout time.Duration) (tls_client.HttpCli
 
 // fetchUserInfo 获取用户信息
 func (s *rtService) fetchUserInfo(rt *model.RT) error {
+	statusCode, body, err := requestMe(rt)
+	if err != nil {
+		return err
+	}
+
+	// 解析响应
+	if statusCode == 200 {
+		// 保存完整的用户信息到 UserInfo
+		rt.UserInfo = string(body)
+
+		var userResp UserInfoResponse
+		if err := json.Unmarshal(body, &userResp); err != nil {
+			return fmt.Errorf("解析响应失败: %v", err)
+		}
+
+		// 保存Email和UserName
+		if userResp.Email != "" {
+			rt.Email = userResp.Email
+		}
+		if userResp.Name != "" {
+			rt.UserName = userResp.Name
+		}
+
+		if rt.Email != "" || rt.UserName != "" {
+			logger.Info("获取用户信息成功", "id", rt.ID, "biz_id", rt.BizId, "user_name", rt.UserName, "email", rt.Email)
+		} else {
+			logger.Warn("用户邮箱和名称均为空", "id", rt.ID, "biz_id", rt.BizId)
+		}
+	} else {
+		logger.Warn("获取用户信息失败", "id", rt.ID, "status", statusCode, "body", redactResponseBody(body))
+		return fmt.Errorf("HTTP %d", statusCode)
+	}
+
+	return nil
+}
+
+// requestMe 使用RT当前的AT请求 /backend-api/me，返回状态码和响应内容
+func requestMe(rt *model.RT) (int, []byte, error) {
 	// 创建 TLS 客户端（使用 Firefox 指纹）
 	client, err := createTLSClient(rt.Proxy, 30*time.Second)
 	if err != nil {
@@ -445,14 +872,14 @@ func (s *rtService) fetchUserInfo(rt *model.RT) error {
 		// 尝试不使用代理
 		client, err = createTLSClient("", 30*time.Second)
 		if err != nil {
-			return fmt.Errorf("创建TLS客户端失败: %v", err)
+			return 0, nil, fmt.Errorf("创建TLS客户端失败: %v", err)
 		}
 	}
 
 	// 创建请求（使用 fhttp）
-	req, err := http2.NewRequest("GET", "https://chatgpt.com/backend-api/me", nil)
+	req, err := http2.NewRequest("GET", chatGPTURL("/backend-api/me"), nil)
 	if err != nil {
-		return fmt.Errorf("创建请求失败: %v", err)
+		return 0, nil, fmt.Errorf("创建请求失败: %v", err)
 	}
 
 	// 设置请求头（模拟 Firefox 浏览器行为）
@@ -472,47 +899,16 @@ func (s *rtService) fetchUserInfo(rt *model.RT) error {
 	resp, err := client.Do(req)
 	if err != nil {
 		logger.Error("【刷新用户信息】请求失败", "id", rt.ID, "error", err)
-		return fmt.Errorf("请求失败: %v", err)
+		return 0, nil, fmt.Errorf("请求失败: %v", err)
 	}
 	defer resp.Body.Close()
 
 	// 读取响应
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return fmt.Errorf("读取响应失败: %v", err)
-	}
-
-	logger.Info(string(body))
-
-	// 解析响应
-	if resp.StatusCode == 200 {
-		// 保存完整的用户信息到 UserInfo
-		rt.UserInfo = string(body)
-
-		var userResp UserInfoResponse
-		if err := json.Unmarshal(body, &userResp); err != nil {
-			return fmt.Errorf("解析响应失败: %v", err)
-		}
-
-		// 保存Email和UserName
-		if userResp.Email != "" {
-			rt.Email = userResp.Email
-		}
-		if userResp.Name != "" {
-			rt.UserName = userResp.Name
-		}
-
-		if rt.Email != "" || rt.UserName != "" {
-			logger.Info("获取用户信息成功", "id", rt.ID, "biz_id", rt.BizId, "user_name", rt.UserName, "email", rt.Email)
-		} else {
-			logger.Warn("用户邮箱和名称均为空", "id", rt.ID, "biz_id", rt.BizId)
-		}
-	} else {
-		logger.Warn("获取用户信息失败", "id", rt.ID, "status", resp.StatusCode, "body", string(body))
-		return fmt.Errorf("HTTP %d", resp.StatusCode)
+		return 0, nil, fmt.Errorf("读取响应失败: %v", err)
 	}
-
-	return nil
+	return resp.StatusCode, body, nil
 }
 
 // fetchAccountInfo 获取账号信息
@@ -529,7 +925,7 @@ func (s *rtService) fetchAccountInfo(rt *model.RT) error {
 	}
 
 	// 创建请求（使用 fhttp）
-	req, err := http2.NewRequest("GET", "https://chatgpt.com/backend-api/accounts/check/v4-2023-04-27?timezone_offset_min=-480", nil)
+	req, err := http2.NewRequest("GET", chatGPTURL("/backend-api/accounts/check/v4-2023-04-27?timezone_offset_min=-480"), nil)
 	if err != nil {
 		return fmt.Errorf("创建请求失败: %v", err)
 	}
@@ -602,7 +998,7 @@ func (s *rtService) fetchAccountInfo(rt *model.RT) error {
 			logger.Warn("未找到任何plan_type", "id", rt.ID, "name", rt.BizId)
 		}
 	} else {
-		logger.Warn("获取账号信息失败", "id", rt.ID, "status", resp.StatusCode, "body", string(body))
+		logger.Warn("获取账号信息失败", "id", rt.ID, "status", resp.StatusCode, "body", redactResponseBody(body))
 		return fmt.Errorf("HTTP %d", resp.StatusCode)
 	}
 
@@ -633,7 +1029,13 @@ func (s *rtService) RefreshUserInfo(id int64) (*model.RT, error) {
 	}
 
 	// 更新数据库
-	if err := s.repo.Update(rt); err != nil {
+	fetched := *rt
+	if err := s.saveWithRetry(rt, func(latest *model.RT) error {
+		latest.UserInfo = fetched.UserInfo
+		latest.Email = fetched.Email
+		latest.UserName = fetched.UserName
+		return nil
+	}); err != nil {
 		logger.Error("保存用户信息失败", "id", id, "error", err)
 		return nil, fmt.Errorf("保存用户信息失败: %v", err)
 	}
@@ -666,7 +1068,12 @@ func (s *rtService) RefreshAccountInfo(id int64) (*model.RT, error) {
 	}
 
 	// 更新数据库
-	if err := s.repo.Update(rt); err != nil {
+	fetched := *rt
+	if err := s.saveWithRetry(rt, func(latest *model.RT) error {
+		latest.AccountInfo = fetched.AccountInfo
+		latest.Type = fetched.Type
+		return nil
+	}); err != nil {
 		logger.Error("保存账号信息失败", "id", id, "error", err)
 		return nil, fmt.Errorf("保存账号信息失败: %v", err)
 	}
@@ -676,50 +1083,159 @@ func (s *rtService) RefreshAccountInfo(id int64) (*model.RT, error) {
 }
 
 // BatchRefresh 批量刷新
-func (s *rtService) BatchRefresh(ids []int64) (int, int, []map[string]interface{}, error) {
-	rts, err := s.repo.GetByIDs(ids)
+// 按代理分组并发刷新：全局并发数由 refresh_concurrency 限制，
+// 同一代理的并发数由 refresh_per_proxy_concurrency 限制，避免单个慢代理拖住整批任务，
+// 同一代理相邻两次请求之间按 refresh_proxy_interval_ms 随机间隔；不存在的ID作为失败项返回
+func (s *rtService) BatchRefresh(ids []int64, trigger string) (int, int, []map[string]interface{}, error) {
+	found, err := s.repo.GetByIDs(ids)
 	if err != nil {
 		return 0, 0, nil, err
 	}
+	byID := make(map[int64]*model.RT, len(found))
+	for _, rt := range found {
+		byID[rt.ID] = rt
+	}
 
-	successCount := 0
-	failCount := 0
-	results := make([]map[string]interface{}, 0, len(rts))
+	// 按输入顺序去重，不存在的ID直接记为失败
+	var rts []*model.RT
+	var missing []map[string]interface{}
+	seen := make(map[int64]bool, len(ids))
+	for _, id := range ids {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		if rt, ok := byID[id]; ok {
+			rts = append(rts, rt)
+		} else {
+			missing = append(missing, map[string]interface{}{
+				"rt_id":   id,
+				"rt_name": "",
+				"success": false,
+				"message": "RT不存在",
+			})
+		}
+	}
+
+	cfg := config.Get()
+	concurrency := cfg.OpenAI.RefreshConcurrency
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+	perProxy := cfg.OpenAI.RefreshPerProxyConcurrency
+	if perProxy <= 0 || perProxy > concurrency {
+		perProxy = concurrency
+	}
 
+	// 按代理分组，保留原始下标以便按输入顺序返回结果
+	groups := make(map[string][]int)
 	for i, rt := range rts {
-		result := map[string]interface{}{
-			"rt_name": rt.BizId,
+		groups[rt.Proxy] = append(groups[rt.Proxy], i)
+	}
+
+	interval := time.Duration(cfg.OpenAI.RefreshProxyIntervalMs) * time.Millisecond
+
+	logger.Info("批量刷新开始", "count", len(rts), "missing", len(missing), "proxy_groups", len(groups), "concurrency", concurrency, "per_proxy", perProxy, "proxy_interval", interval)
+
+	results := make([]map[string]interface{}, len(rts))
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+
+	for _, indexes := range groups {
+		// 每个代理分组内的任务队列
+		queue := make(chan int, len(indexes))
+		for _, idx := range indexes {
+			queue <- idx
 		}
+		close(queue)
 
-		// 刷新，批量刷新时默认获取用户信息和账号信息
-		_, err := s.Refresh(rt.ID, true, true)
-		if err != nil {
-			failCount++
-			result["success"] = false
-			result["message"] = err.Error()
-		} else {
-			successCount++
-			result["success"] = true
-			result["message"] = "刷新成功"
+		workers := perProxy
+		if workers > len(indexes) {
+			workers = len(indexes)
+		}
+		pacer := &proxyPacer{interval: interval}
+		for w := 0; w < workers; w++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for idx := range queue {
+					pacer.wait()
+					sem <- struct{}{}
+					results[idx] = s.refreshForBatch(rts[idx], trigger)
+					<-sem
+				}
+			}()
 		}
+	}
 
-		results = append(results, result)
+	wg.Wait()
+	results = append(results, missing...)
 
-		// 相邻刷新之间随机延迟 1-3 秒（最后一个不需要延迟）
-		if i < len(rts)-1 {
-			delay := time.Duration(1+rand.Intn(3)) * time.Second
-			logger.Info("批量刷新延迟", "delay_seconds", delay.Seconds())
-			time.Sleep(delay)
+	successCount := 0
+	failCount := 0
+	for _, result := range results {
+		if result["success"] == true {
+			successCount++
+		} else {
+			failCount++
 		}
 	}
 
 	return successCount, failCount, results, nil
 }
 
-// BatchImport 批量导入（batchName参数已弃用，每个RT都会生成唯一的32位UUID）
-func (s *rtService) BatchImport(batchName string, tag string, proxy string, clientID string, tokens []string, proxyList []string, clientIdList []string) (int, int, error) {
+// proxyPacer 控制同一代理相邻两次请求的间隔，实际间隔在 interval 的 50%-150% 之间随机
+type proxyPacer struct {
+	mu       sync.Mutex
+	interval time.Duration
+	next     time.Time
+}
+
+// wait 等待到本次请求可以发出的时间，第一个请求不等待
+func (p *proxyPacer) wait() {
+	if p.interval <= 0 {
+		return
+	}
+	p.mu.Lock()
+	now := time.Now()
+	start := p.next
+	if start.Before(now) {
+		start = now
+	}
+	jitter := time.Duration(rand.Int63n(int64(p.interval))) - p.interval/2
+	p.next = start.Add(p.interval + jitter)
+	p.mu.Unlock()
+
+	if delay := start.Sub(now); delay > 0 {
+		time.Sleep(delay)
+	}
+}
+
+// refreshForBatch 批量刷新中的单个任务，返回结果项
+func (s *rtService) refreshForBatch(rt *model.RT, trigger string) map[string]interface{} {
+	result := map[string]interface{}{
+		"rt_id":   rt.ID,
+		"rt_name": rt.BizId,
+	}
+
+	// 刷新，批量刷新时默认获取用户信息和账号信息
+	_, err := s.Refresh(rt.ID, true, true, trigger)
+	if err != nil {
+		result["success"] = false
+		result["message"] = err.Error()
+	} else {
+		result["success"] = true
+		result["message"] = "刷新成功"
+	}
+
+	return result
+}
+
+// BatchImport 批量导入（batchName参数已弃用，每个RT都会生成唯一的32位UUID），返回导入成功的RT
+func (s *rtService) BatchImport(batchName string, tag string, proxy string, clientID string, tokens []string, proxyList []string, clientIdList []string) (int, int, []*model.RT, error) {
 	successCount := 0
 	failCount := 0
+	var created []*model.RT
 
 	// 去重
 	uniqueTokens := make(map[string]bool)
@@ -735,44 +1251,20 @@ func (s *rtService) BatchImport(batchName string, tag string, proxy string, clie
 		existing, _ := s.repo.GetByToken(token)
 		if existing != nil {
 			failCount++
-			tokenPreview := token
-			if len(token) > 20 {
-				tokenPreview = token[:20] + "..."
-			}
-			logger.Warn("Token已存在，跳过", "token", tokenPreview)
+			logger.Warn("Token已存在，跳过", "token", tokenPreview(token))
 			continue
 		}
 
 		// 生成唯一的32位UUID
-		name := generateRandomID()
-		existingName, _ := s.repo.GetByBizId(name)
-		for existingName != nil {
-			name = generateRandomID()
-			existingName, _ = s.repo.GetByBizId(name)
-		}
-
-		// 确定使用的代理
-		var selectedProxy string
-		if proxy != "" {
-			// 如果用户指定了 proxy，优先使用
-			selectedProxy = proxy
-		} else if len(proxyList) > 0 {
-			// 否则从列表中随机选择
-			selectedProxy = proxyList[rand.Intn(len(proxyList))]
-		}
-
-		// 确定使用的 Client ID
-		var selectedClientID string
-		if clientID != "" {
-			// 如果用户指定了 client_id，优先使用
-			selectedClientID = clientID
-		} else if len(clientIdList) > 0 {
-			// 否则从列表中随机选择
-			selectedClientID = clientIdList[rand.Intn(len(clientIdList))]
-		} else {
-			// 都没有则使用配置中的默认值
-			cfg := config.Get()
-			selectedClientID = cfg.OpenAI.ClientID
+		name := s.newBizId()
+
+		// 确定使用的代理：优先使用用户指定的 proxy，否则从列表中随机选择
+		selectedProxy := pickFromList(proxy, proxyList)
+
+		// 确定使用的 Client ID：优先使用用户指定的，否则从列表中随机选择，都没有则使用配置中的默认值
+		selectedClientID := pickFromList(clientID, clientIdList)
+		if selectedClientID == "" {
+			selectedClientID = config.Get().OpenAI.ClientID
 		}
 
 		// 创建RT
@@ -790,11 +1282,13 @@ func (s *rtService) BatchImport(batchName string, tag string, proxy string, clie
 			logger.Error("创建RT失败", "name", name, "error", err)
 		} else {
 			successCount++
+			created = append(created, rt)
+			s.appendLineage(rt, model.LineageSourceCreate, 0, 0, "")
 			logger.Info("导入RT成功", "name", name)
 		}
 	}
 
-	return successCount, failCount, nil
+	return successCount, failCount, created, nil
 }
 
 // getProxyListFromConfig 从配置中获取代理列表
@@ -812,33 +1306,37 @@ func (s *rtService) getProxyListFromConfig() []string {
 	return proxyList
 }
 
-// AutoRefreshAll 自动刷新所有启用的RT
-func (s *rtService) AutoRefreshAll() error {
-	// 获取所有启用的RT
-	enabled := true
-	rts, count, err := s.List(1, 10000, "", "", "", "", &enabled, "")
+// tokenPreview 截取token前20位用于日志
+func tokenPreview(token string) string {
+	if len(token) > 20 {
+		return token[:20] + "..."
+	}
+	return token
+}
+
+// RefreshDue 刷新已到计划刷新时间的RT（由调度器周期调用）
+func (s *rtService) RefreshDue() error {
+	rts, err := s.repo.ListDueForRefresh(time.Now(), 10000)
 	if err != nil {
 		return err
 	}
+	if len(rts) == 0 {
+		return nil
+	}
 
-	logger.Info("开始自动刷新", "count", count)
+	logger.Info("开始刷新到期RT", "count", len(rts))
 
-	// 提取RT的ID
 	ids := make([]int64, 0, len(rts))
 	for _, rt := range rts {
 		ids = append(ids, rt.ID)
 	}
 
-	// 批量刷新
-	if len(ids) > 0 {
-		successCount, failCount, _, err := s.BatchRefresh(ids)
-		if err != nil {
-			logger.Error("批量刷新失败", "error", err)
-			return err
-		}
-		logger.Info("自动刷新完成", "success", successCount, "fail", failCount)
+	successCount, failCount, _, err := s.BatchRefresh(ids, model.RefreshTriggerScheduler)
+	if err != nil {
+		logger.Error("刷新到期RT失败", "error", err)
+		return err
 	}
-
+	logger.Info("到期RT刷新完成", "success", successCount, "fail", failCount)
 	return nil
 }
 
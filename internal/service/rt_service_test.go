@@ -0,0 +1,82 @@
+package service
+
+import (
+	"testing"
+
+	"rt-manage/internal/model"
+	"rt-manage/internal/repository"
+)
+
+// conflictRTRepo 第一次 Update 返回版本冲突，模拟刷新期间管理端修改了记录
+type conflictRTRepo struct {
+	repository.RTRepository
+	latest    model.RT
+	conflicts int
+	saved     *model.RT
+}
+
+func (r *conflictRTRepo) Update(rt *model.RT) error {
+	if r.conflicts > 0 {
+		r.conflicts--
+		return ErrVersionConflict
+	}
+	saved := *rt
+	r.saved = &saved
+	return nil
+}
+
+func (r *conflictRTRepo) GetByID(id int64) (*model.RT, error) {
+	latest := r.latest
+	return &latest, nil
+}
+
+func TestSaveRefreshResultKeepsConcurrentEmailEdit(t *testing.T) {
+	tests := []struct {
+		name         string
+		refreshEmail string // 刷新得到的邮箱，为空表示本次刷新没有获取用户信息
+		wantEmail    string
+	}{
+		{name: "refresh did not fetch user info", refreshEmail: "", wantEmail: "admin@example.com"},
+		{name: "refresh fetched the same email", refreshEmail: "old@example.com", wantEmail: "admin@example.com"},
+		{name: "refresh fetched a new email", refreshEmail: "new@example.com", wantEmail: "new@example.com"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			base := model.RT{ID: 1, BizId: "b1", Rt: "rt_old", Email: "old@example.com", Type: "plus", Version: 1}
+
+			// 管理端在刷新期间修改了邮箱
+			latest := base
+			latest.Email = "admin@example.com"
+			latest.Version = 2
+			repo := &conflictRTRepo{latest: latest, conflicts: 1}
+			s := &rtService{repo: repo}
+
+			rt := base
+			rt.LastRT, rt.Rt, rt.At = rt.Rt, "rt_new", "at_new"
+			rt.RefreshStatus = model.RefreshStatusOK
+			if tt.refreshEmail != "" {
+				rt.Email = tt.refreshEmail
+			}
+
+			if err := s.saveRefreshResult(&rt, &base); err != nil {
+				t.Fatalf("saveRefreshResult() error = %v", err)
+			}
+			if repo.saved == nil {
+				t.Fatal("record was not saved after the conflict")
+			}
+			if repo.saved.Rt != "rt_new" || repo.saved.LastRT != "rt_old" || repo.saved.At != "at_new" {
+				t.Errorf("tokens = (%q, %q, %q), want (rt_new, rt_old, at_new)", repo.saved.Rt, repo.saved.LastRT, repo.saved.At)
+			}
+			if repo.saved.Email != tt.wantEmail {
+				t.Errorf("Email = %q, want %q", repo.saved.Email, tt.wantEmail)
+			}
+			if repo.saved.Type != "plus" {
+				t.Errorf("Type = %q, want plus", repo.saved.Type)
+			}
+			if repo.saved.Version != 2 {
+				t.Errorf("Version = %d, want 2 (based on the latest record)", repo.saved.Version)
+			}
+		})
+	}
+}
@@ -0,0 +1,161 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"rt-manage/internal/model"
+	"rt-manage/pkg/logger"
+)
+
+// ChatGPTSession ChatGPT 网页端会话 JSON（chatgpt.com/api/auth/session 的返回），只有 AT 没有 RT
+type ChatGPTSession struct {
+	AccessToken string `json:"accessToken"`
+	Expires     string `json:"expires"`
+	User        struct {
+		ID    string `json:"id"`
+		Name  string `json:"name"`
+		Email string `json:"email"`
+	} `json:"user"`
+	Account struct {
+		ID       string `json:"id"`
+		PlanType string `json:"planType"`
+	} `json:"account"`
+}
+
+// ParseChatGPTSession 解析会话 JSON
+func ParseChatGPTSession(data []byte) (*ChatGPTSession, error) {
+	var session ChatGPTSession
+	if err := json.Unmarshal(data, &session); err != nil {
+		return nil, fmt.Errorf("会话JSON格式错误: %v", err)
+	}
+	session.AccessToken = strings.TrimSpace(session.AccessToken)
+	if session.AccessToken == "" {
+		return nil, fmt.Errorf("会话JSON中缺少 accessToken")
+	}
+	return &session, nil
+}
+
+// Apply 将会话中的 AT 和账号信息写入RT，并标记为仅AT账号
+func (session *ChatGPTSession) Apply(rt *model.RT) {
+	rt.CredentialType = model.CredentialTypeAT
+	rt.At = session.AccessToken
+	if session.User.Email != "" {
+		rt.Email = session.User.Email
+	}
+	if session.User.Name != "" {
+		rt.UserName = session.User.Name
+	}
+	if session.Account.ID != "" {
+		rt.AccountID = session.Account.ID
+	}
+	if session.Account.PlanType != "" {
+		rt.Type = session.Account.PlanType
+	}
+	// AT 不是 JWT 时以会话过期时间作为 AT 过期时间
+	if jwtExpireTime(rt.At) == nil {
+		if expires, err := time.Parse(time.RFC3339, session.Expires); err == nil {
+			rt.AtExpireTime = &expires
+		}
+	}
+}
+
+// prepareCredential 创建前确定凭证类型并校验，未指定类型时有RT按 rt 处理，只有AT按 at 处理
+func (s *rtService) prepareCredential(rt *model.RT) error {
+	if rt.CredentialType == "" {
+		rt.CredentialType = model.CredentialTypeRT
+		if rt.Rt == "" && rt.At != "" {
+			rt.CredentialType = model.CredentialTypeAT
+		}
+	}
+
+	switch rt.CredentialType {
+	case model.CredentialTypeRT:
+		if rt.Rt == "" {
+			return fmt.Errorf("缺少RT")
+		}
+		return nil
+	case model.CredentialTypeAT:
+		if rt.Rt != "" {
+			return fmt.Errorf("仅AT账号不能填写RT")
+		}
+		if rt.At == "" {
+			return fmt.Errorf("缺少AT")
+		}
+		return s.applyAccessToken(rt, rt.At)
+	default:
+		return fmt.Errorf("不支持的凭证类型: %s", rt.CredentialType)
+	}
+}
+
+// applyAccessToken 设置仅AT账号的AT，并从 AT 中读取过期时间、邮箱等信息
+// 仅AT账号无法刷新，下次刷新时间即 AT 过期时间，到期时由调度器标记为 stale
+func (s *rtService) applyAccessToken(rt *model.RT, at string) error {
+	rt.At = at
+	if expireTime := jwtExpireTime(at); expireTime != nil {
+		rt.AtExpireTime = expireTime
+	}
+	if rt.ATExpired(time.Now()) {
+		return fmt.Errorf("AT已过期")
+	}
+
+	email, planType := jwtProfile(at)
+	if rt.Email == "" {
+		rt.Email = email
+	}
+	if rt.Type == "" {
+		rt.Type = planType
+	}
+	if rt.AccountID == "" {
+		applyIDToken(rt, "")
+	}
+
+	rt.RefreshStatus = model.RefreshStatusOK
+	rt.RefreshResult = ""
+	rt.NextRefreshTime = rt.AtExpireTime
+	return nil
+}
+
+// findSameAccount 按邮箱查找已存在的账号，用于仅AT账号去重（仅AT账号没有RT可比较）
+func (s *rtService) findSameAccount(rt *model.RT) (*model.RT, error) {
+	if rt.Email == "" {
+		return nil, nil
+	}
+	return s.repo.GetByEmail(rt.Email)
+}
+
+// checkAccessTokenOnly 仅AT账号的“刷新”：不请求上游，AT 过期时标记为 stale 并停止调度
+func (s *rtService) checkAccessTokenOnly(rt *model.RT) (*model.RT, error) {
+	if !rt.ATExpired(time.Now()) {
+		return rt, nil
+	}
+
+	if rt.RefreshStatus != model.RefreshStatusStale || rt.NextRefreshTime != nil {
+		logger.Warn("仅AT账号的AT已过期，标记为stale", "id", rt.ID, "biz_id", rt.BizId, "at_expire_time", rt.AtExpireTime)
+		rt.RefreshStatus = model.RefreshStatusStale
+		rt.NextRefreshTime = nil
+		if err := s.saveWithRetry(rt, func(latest *model.RT) error {
+			if !latest.ATExpired(time.Now()) {
+				return fmt.Errorf("AT已被更新")
+			}
+			latest.RefreshStatus = model.RefreshStatusStale
+			latest.NextRefreshTime = nil
+			return nil
+		}); err != nil {
+			logger.Error("更新RT失败", "id", rt.ID, "error", err)
+		}
+	}
+	return rt, fmt.Errorf("仅AT账号的AT已过期，请重新导入会话")
+}
+
+// markStale 展示时将已过期的仅AT账号标记为 stale（调度器未运行时数据库中的状态可能尚未更新）
+func markStale(rts ...*model.RT) {
+	now := time.Now()
+	for _, rt := range rts {
+		if rt != nil && rt.IsATOnly() && rt.ATExpired(now) {
+			rt.RefreshStatus = model.RefreshStatusStale
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package service
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// parseJWTClaims 解析 JWT 的 payload（不校验签名，仅用于读取 exp 等信息）
+func parseJWTClaims(token string) (map[string]interface{}, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("不是有效的JWT")
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return nil, fmt.Errorf("解码JWT payload失败: %v", err)
+	}
+
+	var claims map[string]interface{}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return nil, fmt.Errorf("解析JWT payload失败: %v", err)
+	}
+	return claims, nil
+}
+
+// jwtExpireTime 读取 JWT 的 exp 声明
+func jwtExpireTime(token string) *time.Time {
+	claims, err := parseJWTClaims(token)
+	if err != nil {
+		return nil
+	}
+	exp, ok := claims["exp"].(float64)
+	if !ok || exp <= 0 {
+		return nil
+	}
+	t := time.Unix(int64(exp), 0)
+	return &t
+}
+
+// jwtAccountID 读取 id_token / access_token 中的 ChatGPT 账号ID
+func jwtAccountID(token string) string {
+	claims, err := parseJWTClaims(token)
+	if err != nil {
+		return ""
+	}
+	auth, ok := claims["https://api.openai.com/auth"].(map[string]interface{})
+	if !ok {
+		return ""
+	}
+	accountID, _ := auth["chatgpt_account_id"].(string)
+	return accountID
+}
+
+// jwtProfile 读取 access_token 中的邮箱和套餐类型
+func jwtProfile(token string) (email string, planType string) {
+	claims, err := parseJWTClaims(token)
+	if err != nil {
+		return "", ""
+	}
+	if profile, ok := claims["https://api.openai.com/profile"].(map[string]interface{}); ok {
+		email, _ = profile["email"].(string)
+	}
+	if auth, ok := claims["https://api.openai.com/auth"].(map[string]interface{}); ok {
+		planType, _ = auth["chatgpt_plan_type"].(string)
+	}
+	return email, planType
+}
@@ -15,17 +15,21 @@ var (
 
 // Claims JWT自定义声明
 type Claims struct {
-	Username string `json:"username"`
+	Username  string `json:"username"`
+	Role      string `json:"role"`
+	SessionID string `json:"sid"` // 登录会话ID，用于服务端注销
 	jwt.RegisteredClaims
 }
 
 // GenerateToken 生成JWT token
-func GenerateToken(username, secret string, expireHours int) (string, error) {
+func GenerateToken(username, role, sessionID, secret string, ttl time.Duration) (string, error) {
 	nowTime := time.Now()
-	expireTime := nowTime.Add(time.Duration(expireHours) * time.Hour)
+	expireTime := nowTime.Add(ttl)
 
 	claims := Claims{
-		Username: username,
+		Username:  username,
+		Role:      role,
+		SessionID: sessionID,
 		RegisteredClaims: jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(expireTime),
 			IssuedAt:  jwt.NewNumericDate(nowTime),
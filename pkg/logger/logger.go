@@ -4,9 +4,11 @@ import (
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 	"rt-manage/internal/config"
+	"rt-manage/pkg/secret"
 )
 
-var log *zap.Logger
+// log 未调用 Init 时（如单元测试）不输出日志
+var log = zap.NewNop()
 
 // Init 初始化日志
 func Init() error {
@@ -52,29 +54,77 @@ func getLogLevel(level string) zapcore.Level {
 	}
 }
 
+// redact 日志字段脱敏：敏感字段名（rt、at、*_token、*_secret 等）的值全部隐藏，其他字符串中形如 JWT 的片段隐藏
+func redact(fields []interface{}) []interface{} {
+	redacted := make([]interface{}, len(fields))
+	copy(redacted, fields)
+
+	for i := 0; i < len(redacted); i++ {
+		secretValue := false
+		if i%2 == 1 {
+			if key, ok := redacted[i-1].(string); ok {
+				secretValue = secret.IsSecretKey(key)
+			}
+		}
+
+		switch v := redacted[i].(type) {
+		case string:
+			if secretValue {
+				redacted[i] = secret.Mask(v)
+			} else {
+				redacted[i] = secret.RedactText(v)
+			}
+		case error:
+			// 错误信息中可能附带上游响应，其中的 token 不一定是 JWT 格式
+			if masked := secret.RedactText(v.Error()); masked != v.Error() {
+				redacted[i] = masked
+			}
+		case map[string]interface{}:
+			redacted[i] = redactMap(v)
+		}
+	}
+	return redacted
+}
+
+// redactMap 对 map 类型的日志字段（如更新参数）按字段名脱敏
+func redactMap(m map[string]interface{}) map[string]interface{} {
+	masked := make(map[string]interface{}, len(m))
+	for key, value := range m {
+		if s, ok := value.(string); ok {
+			if secret.IsSecretKey(key) {
+				value = secret.Mask(s)
+			} else {
+				value = secret.MaskJWTs(s)
+			}
+		}
+		masked[key] = value
+	}
+	return masked
+}
+
 // Debug 记录debug日志
 func Debug(msg string, fields ...interface{}) {
-	log.Sugar().Debugw(msg, fields...)
+	log.Sugar().Debugw(secret.MaskJWTs(msg), redact(fields)...)
 }
 
 // Info 记录info日志
 func Info(msg string, fields ...interface{}) {
-	log.Sugar().Infow(msg, fields...)
+	log.Sugar().Infow(secret.MaskJWTs(msg), redact(fields)...)
 }
 
 // Warn 记录warn日志
 func Warn(msg string, fields ...interface{}) {
-	log.Sugar().Warnw(msg, fields...)
+	log.Sugar().Warnw(secret.MaskJWTs(msg), redact(fields)...)
 }
 
 // Error 记录error日志
 func Error(msg string, fields ...interface{}) {
-	log.Sugar().Errorw(msg, fields...)
+	log.Sugar().Errorw(secret.MaskJWTs(msg), redact(fields)...)
 }
 
 // Fatal 记录fatal日志
 func Fatal(msg string, fields ...interface{}) {
-	log.Sugar().Fatalw(msg, fields...)
+	log.Sugar().Fatalw(secret.MaskJWTs(msg), redact(fields)...)
 }
 
 // Sync 刷新日志缓冲
@@ -0,0 +1,148 @@
+package ratelimit
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Rule 令牌桶规则：每分钟补充 PerMinute 个令牌，最多积累 Burst 个
+// PerMinute 为 0 表示不限制；Burst 为 0 时取 PerMinute
+type Rule struct {
+	PerMinute int
+	Burst     int
+}
+
+// Enabled 规则是否生效
+func (r Rule) Enabled() bool {
+	return r.PerMinute > 0
+}
+
+func (r Rule) capacity() float64 {
+	if r.Burst > 0 {
+		return float64(r.Burst)
+	}
+	return float64(r.PerMinute)
+}
+
+// Stat 单个令牌桶的计数
+type Stat struct {
+	Key      string    `json:"key"`
+	Tokens   float64   `json:"tokens"`  // 当前剩余令牌
+	Allowed  int64     `json:"allowed"` // 放行次数
+	Limited  int64     `json:"limited"` // 被限流次数
+	LastSeen time.Time `json:"last_seen"`
+}
+
+type bucket struct {
+	tokens   float64
+	last     time.Time
+	allowed  int64
+	limited  int64
+	lastSeen time.Time
+}
+
+// Limiter 按 key 维护令牌桶，并发安全
+type Limiter struct {
+	mu          sync.Mutex
+	buckets     map[string]*bucket
+	idleTTL     time.Duration
+	lastCleanup time.Time
+}
+
+// New 创建限流器，空闲超过 idleTTL 且令牌已补满的桶会被清理
+func New(idleTTL time.Duration) *Limiter {
+	return &Limiter{
+		buckets: make(map[string]*bucket),
+		idleTTL: idleTTL,
+	}
+}
+
+// Allow 从 key 对应的桶中取一个令牌，不足时返回 false 和需要等待的时间
+func (l *Limiter) Allow(key string, rule Rule, now time.Time) (bool, time.Duration) {
+	if !rule.Enabled() {
+		return true, 0
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.cleanup(now)
+
+	capacity := rule.capacity()
+	rate := float64(rule.PerMinute) / 60 // 每秒补充的令牌
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: capacity, last: now}
+		l.buckets[key] = b
+	}
+
+	// 按经过的时间补充令牌（规则调小时不超过新的容量）
+	if elapsed := now.Sub(b.last).Seconds(); elapsed > 0 {
+		b.tokens += elapsed * rate
+		b.last = now
+	}
+	if b.tokens > capacity {
+		b.tokens = capacity
+	}
+	b.lastSeen = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		b.allowed++
+		return true, 0
+	}
+
+	b.limited++
+	wait := time.Duration(math.Ceil((1-b.tokens)/rate*1000)) * time.Millisecond
+	return false, wait
+}
+
+// cleanup 每分钟最多执行一次，清理长时间未使用的桶（调用方持有锁）
+func (l *Limiter) cleanup(now time.Time) {
+	if l.idleTTL <= 0 || now.Sub(l.lastCleanup) < time.Minute {
+		return
+	}
+	l.lastCleanup = now
+	for key, b := range l.buckets {
+		if now.Sub(b.lastSeen) > l.idleTTL {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// Stats 返回 key 以 prefix 开头的桶的计数，按被限流次数降序
+func (l *Limiter) Stats(prefix string) []Stat {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	stats := make([]Stat, 0, len(l.buckets))
+	for key, b := range l.buckets {
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+		stats = append(stats, Stat{
+			Key:      key,
+			Tokens:   math.Floor(b.tokens*100) / 100,
+			Allowed:  b.allowed,
+			Limited:  b.limited,
+			LastSeen: b.lastSeen,
+		})
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].Limited != stats[j].Limited {
+			return stats[i].Limited > stats[j].Limited
+		}
+		return stats[i].Key < stats[j].Key
+	})
+	return stats
+}
+
+// Reset 清空所有桶和计数
+func (l *Limiter) Reset() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.buckets = make(map[string]*bucket)
+}
@@ -0,0 +1,115 @@
+package secret
+
+import (
+	"encoding/json"
+	"regexp"
+	"strings"
+)
+
+// 形如 JWT 的字符串（header.payload.signature，header 以 eyJ 开头）
+var jwtPattern = regexp.MustCompile(`eyJ[A-Za-z0-9_-]{8,}\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]*`)
+
+// 敏感字段名（精确匹配，小写）
+var secretKeys = map[string]bool{
+	"rt":             true,
+	"at":             true,
+	"last_rt":        true,
+	"old_rt":         true,
+	"new_rt":         true,
+	"new_at":         true,
+	"refresh_result": true,
+	"authorization":  true,
+	"cookie":         true,
+	"session":        true,
+}
+
+// 敏感字段名后缀（如 access_token、api_secret、password）
+var secretKeySuffixes = []string{"token", "secret", "password"}
+
+// IsSecretKey 判断字段名是否为敏感字段
+func IsSecretKey(key string) bool {
+	key = strings.ToLower(key)
+	if secretKeys[key] {
+		return true
+	}
+	for _, suffix := range secretKeySuffixes {
+		if strings.HasSuffix(key, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// maskPlaceholder Mask 替换隐藏部分使用的占位符
+const maskPlaceholder = "******"
+
+// Mask 脱敏：保留前6位和后4位，短值全部隐藏
+func Mask(value string) string {
+	if value == "" {
+		return ""
+	}
+	if len(value) <= 16 {
+		return maskPlaceholder
+	}
+	return value[:6] + maskPlaceholder + value[len(value)-4:]
+}
+
+// IsMasked 判断值是否为 Mask 的脱敏结果（token 中不会出现 *）
+func IsMasked(value string) bool {
+	return strings.Contains(value, maskPlaceholder)
+}
+
+// MaskJWTs 将文本中形如 JWT 的片段脱敏
+func MaskJWTs(text string) string {
+	if !strings.Contains(text, "eyJ") {
+		return text
+	}
+	return jwtPattern.ReplaceAllStringFunc(text, Mask)
+}
+
+// RedactText 对文本中的 JWT 以及内嵌 JSON 对象中的敏感字段脱敏（如错误信息中附带的上游响应）
+func RedactText(text string) string {
+	start := strings.Index(text, "{")
+	end := strings.LastIndex(text, "}")
+	if start >= 0 && end > start && json.Valid([]byte(text[start:end+1])) {
+		return MaskJWTs(text[:start]) + RedactJSON(text[start:end+1]) + MaskJWTs(text[end+1:])
+	}
+	return MaskJWTs(text)
+}
+
+// RedactJSON 对 JSON 中的敏感字段和 JWT 脱敏，非 JSON 文本只处理 JWT
+func RedactJSON(text string) string {
+	var data interface{}
+	if err := json.Unmarshal([]byte(text), &data); err != nil {
+		return MaskJWTs(text)
+	}
+
+	redacted, err := json.Marshal(redactValue("", data))
+	if err != nil {
+		return ""
+	}
+	return string(redacted)
+}
+
+// redactValue 递归脱敏 JSON 值
+func redactValue(key string, value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for k, item := range v {
+			v[k] = redactValue(k, item)
+		}
+		return v
+	case []interface{}:
+		for i, item := range v {
+			v[i] = redactValue(key, item)
+		}
+		return v
+	case string:
+		if key != "" && IsSecretKey(key) {
+			return Mask(v)
+		}
+		return MaskJWTs(v)
+	default:
+		return v
+	}
+}
@@ -0,0 +1,208 @@
+package secret
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"rt-manage/internal/config"
+)
+
+// 密文前缀，格式为 enc:v1:<密钥ID>:<base64(nonce+密文)>，不带前缀的值视为明文（加密启用前写入的数据）
+const prefix = "enc:v1:"
+
+var (
+	ErrKeyNotFound = errors.New("找不到解密所需的密钥，请检查 encryption.key / previous_keys 配置")
+	ErrNoKey       = errors.New("数据已加密，但未配置加密密钥")
+)
+
+// key 一个 AES-256 密钥及其派生的哈希密钥
+type key struct {
+	id      string
+	aead    cipher.AEAD
+	hashKey []byte
+}
+
+var (
+	current  *key            // 当前密钥，为空表示未启用加密
+	keys     map[string]*key // 所有可用于解密的密钥（当前 + 旧密钥）
+	previous []*key
+)
+
+// Init 根据配置初始化加密密钥，未配置密钥时不启用加密
+func Init(cfg *config.EncryptionConfig) error {
+	current, keys, previous = nil, map[string]*key{}, nil
+
+	encoded := strings.TrimSpace(cfg.Key)
+	if encoded == "" && cfg.KeyFile != "" {
+		data, err := os.ReadFile(cfg.KeyFile)
+		if err != nil {
+			return fmt.Errorf("读取密钥文件失败: %v", err)
+		}
+		encoded = strings.TrimSpace(string(data))
+	}
+
+	if encoded != "" {
+		k, err := newKey(encoded)
+		if err != nil {
+			return fmt.Errorf("加密密钥无效: %v", err)
+		}
+		current = k
+		keys[k.id] = k
+	}
+
+	for i, encoded := range cfg.PreviousKeys {
+		k, err := newKey(strings.TrimSpace(encoded))
+		if err != nil {
+			return fmt.Errorf("旧密钥 #%d 无效: %v", i+1, err)
+		}
+		if _, ok := keys[k.id]; ok {
+			continue
+		}
+		keys[k.id] = k
+		previous = append(previous, k)
+	}
+
+	return nil
+}
+
+// newKey 解析 base64 编码的 32 字节密钥
+func newKey(encoded string) (*key, error) {
+	raw, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("不是有效的 base64: %v", err)
+	}
+	if len(raw) != 32 {
+		return nil, fmt.Errorf("密钥长度必须为 32 字节，当前为 %d 字节", len(raw))
+	}
+
+	block, err := aes.NewCipher(raw)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	sum := sha256.Sum256(raw)
+	mac := hmac.New(sha256.New, raw)
+	mac.Write([]byte("rt-manage token hash"))
+
+	return &key{
+		id:      hex.EncodeToString(sum[:4]),
+		aead:    aead,
+		hashKey: mac.Sum(nil),
+	}, nil
+}
+
+// GenerateKey 生成一个新的 base64 编码密钥
+func GenerateKey() (string, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(raw), nil
+}
+
+// Enabled 是否启用了加密
+func Enabled() bool {
+	return current != nil
+}
+
+// IsEncrypted 判断值是否为密文
+func IsEncrypted(value string) bool {
+	return strings.HasPrefix(value, prefix)
+}
+
+// IsCurrent 判断值是否已使用当前密钥加密（未启用加密时判断是否为明文）
+func IsCurrent(value string) bool {
+	if value == "" {
+		return true
+	}
+	if current == nil {
+		return !IsEncrypted(value)
+	}
+	return strings.HasPrefix(value, prefix+current.id+":")
+}
+
+// Encrypt 使用当前密钥加密，未启用加密或值为空时原样返回
+func Encrypt(plaintext string) (string, error) {
+	if current == nil || plaintext == "" {
+		return plaintext, nil
+	}
+
+	nonce := make([]byte, current.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("生成随机数失败: %v", err)
+	}
+	sealed := current.aead.Seal(nonce, nonce, []byte(plaintext), nil)
+	return prefix + current.id + ":" + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt 解密，明文（无密文前缀）原样返回
+func Decrypt(value string) (string, error) {
+	if !IsEncrypted(value) {
+		return value, nil
+	}
+	if len(keys) == 0 {
+		return "", ErrNoKey
+	}
+
+	id, payload, ok := strings.Cut(strings.TrimPrefix(value, prefix), ":")
+	if !ok {
+		return "", errors.New("密文格式错误")
+	}
+	k, ok := keys[id]
+	if !ok {
+		return "", ErrKeyNotFound
+	}
+
+	sealed, err := base64.StdEncoding.DecodeString(payload)
+	if err != nil {
+		return "", fmt.Errorf("密文格式错误: %v", err)
+	}
+	nonceSize := k.aead.NonceSize()
+	if len(sealed) < nonceSize {
+		return "", errors.New("密文格式错误")
+	}
+	plaintext, err := k.aead.Open(nil, sealed[:nonceSize], sealed[nonceSize:], nil)
+	if err != nil {
+		return "", fmt.Errorf("解密失败: %v", err)
+	}
+	return string(plaintext), nil
+}
+
+// Hash 使用当前密钥计算 token 的 HMAC-SHA256，用于在密文列上按 token 精确查找，未启用加密时返回空
+func Hash(token string) string {
+	if current == nil || token == "" {
+		return ""
+	}
+	return hashWith(current, token)
+}
+
+// LookupHashes 返回 token 在当前密钥和所有旧密钥下的哈希，密钥轮换期间未重新加密的记录也能查到
+func LookupHashes(token string) []string {
+	if current == nil || token == "" {
+		return nil
+	}
+	hashes := []string{hashWith(current, token)}
+	for _, k := range previous {
+		hashes = append(hashes, hashWith(k, token))
+	}
+	return hashes
+}
+
+func hashWith(k *key, token string) string {
+	mac := hmac.New(sha256.New, k.hashKey)
+	mac.Write([]byte(token))
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -7,20 +7,33 @@ CREATE TABLE `rt_rts` (
   `user_name` varchar(255) DEFAULT NULL COMMENT '用户名',
   `email` varchar(255) DEFAULT NULL COMMENT '邮箱',
   `type` varchar(50) DEFAULT NULL COMMENT '账号类型（如：free, team）',
+  `credential_type` varchar(20) NOT NULL DEFAULT 'rt' COMMENT '凭证类型（rt: 有 Refresh Token, at: 仅 Access Token）',
   `rt` text NOT NULL COMMENT 'Refresh Token',
+  `rt_hash` varchar(64) DEFAULT NULL COMMENT 'Refresh Token 的 HMAC（加密存储时用于查找）',
   `at` text COMMENT 'Access Token',
+  `id_token` text COMMENT 'id_token',
+  `account_id` varchar(255) DEFAULT NULL COMMENT 'ChatGPT 账号ID（取自 id_token）',
   `proxy` varchar(255) DEFAULT NULL COMMENT '代理地址',
   `client_id` varchar(255) DEFAULT NULL COMMENT 'OpenAI Client ID',
   `tag` varchar(255) DEFAULT NULL COMMENT '标签',
   `enabled` tinyint(1) NOT NULL DEFAULT '1' COMMENT '是否启用（1:启用, 0:禁用）',
   `last_rt` text COMMENT '上一次的 Refresh Token',
   `refresh_result` text COMMENT '刷新结果',
+  `refresh_status` varchar(50) DEFAULT NULL COMMENT '刷新状态（ok, invalid_grant, refresh_token_reused, account_deactivated, network_error, rate_limited, upstream_error, unknown_error, stale）',
   `user_info` text COMMENT '用户信息（JSON）',
   `account_info` text COMMENT '账号信息（JSON）',
   `last_refresh_time` datetime DEFAULT NULL COMMENT '最后刷新时间',
+  `at_expire_time` datetime DEFAULT NULL COMMENT 'Access Token 过期时间',
+  `next_refresh_time` datetime DEFAULT NULL COMMENT '下次计划刷新时间',
+  `cooldown_until` datetime DEFAULT NULL COMMENT '冷却截止时间（调用方反馈 401/429）',
+  `cooldown_reason` varchar(50) DEFAULT NULL COMMENT '冷却原因（rate_limited, unauthorized）',
   `memo` text COMMENT '备注',
+  `version` bigint NOT NULL DEFAULT '0' COMMENT '乐观锁版本号',
   PRIMARY KEY (`id`),
-  UNIQUE KEY `uni_rt_rts_biz_id` (`biz_id`)
+  UNIQUE KEY `uni_rt_rts_biz_id` (`biz_id`),
+  KEY `idx_rt_rts_next_refresh_time` (`next_refresh_time`),
+  KEY `idx_rt_rts_refresh_status` (`refresh_status`),
+  KEY `idx_rt_rts_rt_hash` (`rt_hash`)
 ) ENGINE=InnoDB AUTO_INCREMENT=1 DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci COMMENT='RT Token 管理表';
 
 -- 系统配置表
@@ -34,6 +47,176 @@ CREATE TABLE `rt_system_configs` (
   UNIQUE KEY `uni_rt_system_configs_config_key` (`config_key`)
 ) ENGINE=InnoDB AUTO_INCREMENT=1 DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci COMMENT='系统配置表';
 
+-- 刷新记录表
+CREATE TABLE `rt_refresh_logs` (
+  `id` bigint NOT NULL AUTO_INCREMENT COMMENT '主键ID',
+  `create_time` datetime(3) DEFAULT NULL COMMENT '创建时间',
+  `rt_id` bigint NOT NULL COMMENT 'RT ID',
+  `biz_id` varchar(255) DEFAULT NULL COMMENT '业务ID',
+  `trigger` varchar(20) DEFAULT NULL COMMENT '触发来源（manual, batch, scheduler, public_api）',
+  `proxy` varchar(255) DEFAULT NULL COMMENT '使用的代理',
+  `client_id` varchar(255) DEFAULT NULL COMMENT '使用的 Client ID',
+  `http_status` bigint DEFAULT NULL COMMENT '上游 HTTP 状态码',
+  `refresh_status` varchar(50) DEFAULT NULL COMMENT '刷新状态',
+  `error_code` varchar(100) DEFAULT NULL COMMENT '上游错误码',
+  `error_message` text COMMENT '错误信息',
+  `latency_ms` bigint DEFAULT NULL COMMENT '耗时（毫秒）',
+  `response_body` text COMMENT '响应内容（已脱敏）',
+  PRIMARY KEY (`id`),
+  KEY `idx_refresh_logs_rt_id` (`rt_id`),
+  KEY `idx_refresh_logs_biz_id` (`biz_id`),
+  KEY `idx_refresh_logs_trigger` (`trigger`),
+  KEY `idx_refresh_logs_refresh_status` (`refresh_status`),
+  KEY `idx_refresh_logs_create_time` (`create_time`)
+) ENGINE=InnoDB AUTO_INCREMENT=1 DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci COMMENT='刷新记录表';
+
+-- RT 轮换日志表（新RT先落库于此，再写入 rt_rts）
+CREATE TABLE `rt_token_rotations` (
+  `id` bigint NOT NULL AUTO_INCREMENT COMMENT '主键ID',
+  `create_time` datetime(3) DEFAULT NULL COMMENT '创建时间',
+  `update_time` datetime(3) DEFAULT NULL COMMENT '更新时间',
+  `rt_id` bigint NOT NULL COMMENT 'RT ID',
+  `old_rt` text COMMENT '轮换前的 Refresh Token',
+  `new_rt` text COMMENT '上游返回的新 Refresh Token',
+  `new_at` text COMMENT '上游返回的新 Access Token',
+  `expires_in` bigint DEFAULT NULL COMMENT 'AT 有效期（秒）',
+  `status` varchar(20) DEFAULT NULL COMMENT '状态（pending, received, unknown）',
+  PRIMARY KEY (`id`),
+  KEY `idx_token_rotations_rt_id` (`rt_id`),
+  KEY `idx_token_rotations_status` (`status`)
+) ENGINE=InnoDB AUTO_INCREMENT=1 DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci COMMENT='RT 轮换日志表';
+
+-- token 谱系表（只追加，记录每个 RT 签发过的所有 RT/AT）
+CREATE TABLE `rt_token_lineages` (
+  `id` bigint NOT NULL AUTO_INCREMENT COMMENT '主键ID',
+  `create_time` datetime(3) DEFAULT NULL COMMENT '创建时间',
+  `rt_id` bigint NOT NULL COMMENT 'RT ID',
+  `parent_id` bigint DEFAULT NULL COMMENT '产生本条记录时的当前谱系记录',
+  `rt` text COMMENT 'Refresh Token',
+  `at` text COMMENT 'Access Token',
+  `at_expire_time` datetime DEFAULT NULL COMMENT 'Access Token 过期时间',
+  `source` varchar(20) DEFAULT NULL COMMENT '来源（create, refresh, recover, promote）',
+  `refresh_log_id` bigint DEFAULT NULL COMMENT '产生该 token 的刷新记录ID',
+  `promoted_from` bigint DEFAULT NULL COMMENT 'promote 时被恢复的谱系记录ID',
+  `operator` varchar(255) DEFAULT NULL COMMENT '操作人',
+  PRIMARY KEY (`id`),
+  KEY `idx_token_lineages_rt_id` (`rt_id`)
+) ENGINE=InnoDB AUTO_INCREMENT=1 DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci COMMENT='token 谱系表';
+
+-- AT 租约表
+CREATE TABLE `rt_leases` (
+  `id` bigint NOT NULL AUTO_INCREMENT COMMENT '主键ID',
+  `create_time` datetime(3) DEFAULT NULL COMMENT '创建时间',
+  `update_time` datetime(3) DEFAULT NULL COMMENT '更新时间',
+  `lease_id` varchar(64) NOT NULL COMMENT '租约ID',
+  `rt_id` bigint NOT NULL COMMENT 'RT ID',
+  `biz_id` varchar(255) DEFAULT NULL COMMENT '业务ID',
+  `consumer` varchar(255) DEFAULT NULL COMMENT '调用方标识',
+  `api_key_id` bigint DEFAULT NULL COMMENT '租用时使用的API密钥ID',
+  `exclusive` tinyint(1) NOT NULL DEFAULT '0' COMMENT '是否独占',
+  `strategy` varchar(20) DEFAULT NULL COMMENT '选择策略（round_robin, lru）',
+  `status` varchar(20) DEFAULT NULL COMMENT '状态（active, released, expired）',
+  `expire_time` datetime DEFAULT NULL COMMENT '租约到期时间',
+  `release_time` datetime DEFAULT NULL COMMENT '释放时间',
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `idx_leases_lease_id` (`lease_id`),
+  KEY `idx_leases_rt_id` (`rt_id`),
+  KEY `idx_leases_status` (`status`),
+  KEY `idx_leases_api_key_id` (`api_key_id`)
+) ENGINE=InnoDB AUTO_INCREMENT=1 DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci COMMENT='AT 租约表';
+
+-- 对外 API 密钥表
+CREATE TABLE `rt_api_keys` (
+  `id` bigint NOT NULL AUTO_INCREMENT COMMENT '主键ID',
+  `create_time` datetime(3) DEFAULT NULL COMMENT '创建时间',
+  `update_time` datetime(3) DEFAULT NULL COMMENT '更新时间',
+  `name` varchar(255) NOT NULL COMMENT '名称',
+  `key_prefix` varchar(20) DEFAULT NULL COMMENT '密钥前缀（用于识别）',
+  `key_hash` varchar(64) NOT NULL COMMENT '密钥的 SHA-256',
+  `signing_secret` text COMMENT '签名请求的 HMAC 密钥（启用加密时加密保存）',
+  `allowed_endpoints` text COMMENT '允许的接口（逗号分隔，为空不限制）',
+  `allowed_tags` text COMMENT '允许的标签（逗号分隔，为空不限制）',
+  `allowed_types` text COMMENT '允许的账号类型（逗号分隔，为空不限制）',
+  `enabled` tinyint(1) NOT NULL DEFAULT '1' COMMENT '是否启用',
+  `require_signature` tinyint(1) NOT NULL DEFAULT '0' COMMENT '只接受签名请求',
+  `expire_time` datetime DEFAULT NULL COMMENT '过期时间',
+  `last_used_time` datetime DEFAULT NULL COMMENT '最后使用时间',
+  `last_used_ip` varchar(64) DEFAULT NULL COMMENT '最后使用IP',
+  `memo` text COMMENT '备注',
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `idx_api_keys_key_hash` (`key_hash`),
+  KEY `idx_api_keys_key_prefix` (`key_prefix`)
+) ENGINE=InnoDB AUTO_INCREMENT=1 DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci COMMENT='对外 API 密钥表';
+
+-- 管理后台账号表
+CREATE TABLE `rt_admin_users` (
+  `id` bigint NOT NULL AUTO_INCREMENT COMMENT '主键ID',
+  `create_time` datetime(3) DEFAULT NULL COMMENT '创建时间',
+  `update_time` datetime(3) DEFAULT NULL COMMENT '更新时间',
+  `username` varchar(100) NOT NULL COMMENT '用户名',
+  `password_hash` varchar(255) NOT NULL COMMENT '密码的 bcrypt 哈希',
+  `role` varchar(20) NOT NULL COMMENT '角色（admin, operator, viewer）',
+  `enabled` tinyint(1) NOT NULL DEFAULT '1' COMMENT '是否启用',
+  `last_login_time` datetime DEFAULT NULL COMMENT '最后登录时间',
+  `last_login_ip` varchar(64) DEFAULT NULL COMMENT '最后登录IP',
+  `memo` text COMMENT '备注',
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `idx_admin_users_username` (`username`)
+) ENGINE=InnoDB AUTO_INCREMENT=1 DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci COMMENT='管理后台账号表';
+
+-- 审计事件表
+CREATE TABLE `rt_audit_events` (
+  `id` bigint NOT NULL AUTO_INCREMENT COMMENT '主键ID',
+  `create_time` datetime(3) DEFAULT NULL COMMENT '创建时间',
+  `actor_type` varchar(20) NOT NULL COMMENT '操作者类型（jwt, api_key, anonymous）',
+  `actor` varchar(150) DEFAULT NULL COMMENT '操作者（用户名或 api_key:名称）',
+  `api_key_id` bigint DEFAULT NULL COMMENT 'API 密钥ID',
+  `client_ip` varchar(64) DEFAULT NULL COMMENT '客户端IP',
+  `action` varchar(100) NOT NULL COMMENT '操作',
+  `target_type` varchar(50) DEFAULT NULL COMMENT '操作对象类型',
+  `target_ids` mediumtext COMMENT '操作对象ID（逗号分隔）',
+  `changes` mediumtext COMMENT '修改前后内容（JSON，token 已脱敏）',
+  `status_code` int DEFAULT NULL COMMENT 'HTTP 状态码',
+  `success` tinyint(1) NOT NULL COMMENT '是否成功',
+  PRIMARY KEY (`id`),
+  KEY `idx_audit_events_actor` (`actor`),
+  KEY `idx_audit_events_action` (`action`),
+  KEY `idx_audit_events_create_time` (`create_time`)
+) ENGINE=InnoDB AUTO_INCREMENT=1 DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci COMMENT='审计事件表';
+
+-- 管理后台登录会话表
+CREATE TABLE `rt_admin_sessions` (
+  `id` bigint NOT NULL AUTO_INCREMENT COMMENT '主键ID',
+  `create_time` datetime(3) DEFAULT NULL COMMENT '创建时间',
+  `update_time` datetime(3) DEFAULT NULL COMMENT '更新时间',
+  `session_id` varchar(64) NOT NULL COMMENT '会话ID（JWT 中的 sid）',
+  `user_id` bigint NOT NULL COMMENT '管理后台账号ID',
+  `username` varchar(100) DEFAULT NULL COMMENT '用户名',
+  `refresh_token_hash` varchar(64) NOT NULL COMMENT '当前刷新令牌的 SHA-256',
+  `previous_token_hash` varchar(64) DEFAULT NULL COMMENT '上一个刷新令牌的 SHA-256，用于发现令牌重放',
+  `client_ip` varchar(64) DEFAULT NULL COMMENT '登录IP',
+  `user_agent` varchar(255) DEFAULT NULL COMMENT '登录 User-Agent',
+  `expire_time` datetime NOT NULL COMMENT '刷新令牌过期时间',
+  `last_refresh_time` datetime DEFAULT NULL COMMENT '最后刷新时间',
+  `revoked_time` datetime DEFAULT NULL COMMENT '注销时间',
+  `revoke_reason` varchar(50) DEFAULT NULL COMMENT '注销原因',
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `idx_admin_sessions_session_id` (`session_id`),
+  KEY `idx_admin_sessions_user_id` (`user_id`),
+  UNIQUE KEY `idx_admin_sessions_refresh_token_hash` (`refresh_token_hash`),
+  KEY `idx_admin_sessions_previous_token_hash` (`previous_token_hash`)
+) ENGINE=InnoDB AUTO_INCREMENT=1 DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci COMMENT='管理后台登录会话表';
+
+-- 数据库迁移记录表
+CREATE TABLE `rt_schema_migrations` (
+  `version` bigint NOT NULL COMMENT '迁移版本号',
+  `name` varchar(255) NOT NULL COMMENT '迁移名称',
+  `applied_at` datetime(3) NOT NULL COMMENT '执行时间',
+  PRIMARY KEY (`version`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci COMMENT='数据库迁移记录表';
+
 -- 如果表已存在但缺少唯一索引，执行以下语句：
 -- ALTER TABLE rt_rts ADD UNIQUE INDEX `uni_rt_rts_biz_id` (`biz_id`);
 
+-- 升级：程序启动时会自动执行 internal/database/migrations.go 中未执行的迁移（database.auto_migrate），
+-- 也可以手动执行 ./server migrate status 查看状态、./server migrate up 执行迁移，无需再手写 ALTER 语句